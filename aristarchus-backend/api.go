@@ -0,0 +1,884 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The JSON API exposes books, people, publishers and series as resources:
+//
+//	GET    /books            list all books, or those matching ?query=...
+//	POST   /books            add a book
+//	GET    /books/{id}       get a book
+//	PATCH  /books/{id}       update the fields given in the request body
+//	DELETE /books/{id}       delete a book
+//	GET    /books/{id}/citation?style=...&form=...&format=...
+//	                         cite a book, by default in Chicago bibliography
+//	                         style as plain text
+//
+// and likewise for /people, /publishers and /series, whose records are a name
+// and the ids of their books, with a location for publishers. GET
+// /search?q=... runs a full-text search.
+//
+// Errors are returned as {"error": "..."} with a status code reflecting the
+// error type, e.g. 404 for unknown ids and 409 for duplicates or records still
+// in use.
+
+// BookJSON is the representation of a Book used by the JSON API. Isbn is
+// hyphenated, e.g. "978-1-4335-5307-3", and IsbnDigits not; either form is
+// accepted for Isbn, and IsbnDigits is ignored, when a book is added.
+type BookJSON struct {
+	Id                 int      `json:"id"`
+	Author             string   `json:"author,omitempty"`
+	Editor             string   `json:"editor,omitempty"`
+	Translator         string   `json:"translator,omitempty"`
+	Illustrator        string   `json:"illustrator,omitempty"`
+	Foreword           string   `json:"foreword,omitempty"`
+	Compiler           string   `json:"compiler,omitempty"`
+	Title              string   `json:"title"`
+	Subtitle           string   `json:"subtitle,omitempty"`
+	Year               int      `json:"year,omitempty"`
+	Edition            int      `json:"edition,omitempty"`
+	EditionDescription string   `json:"edition_description,omitempty"`
+	Publisher          string   `json:"publisher"`
+	Isbn               string   `json:"isbn,omitempty"`
+	IsbnDigits         string   `json:"isbn_digits,omitempty"`
+	Series             string   `json:"series,omitempty"`
+	Status             []string `json:"status"`
+	Purchased          string   `json:"purchased,omitempty"`
+	Rating             *float64 `json:"rating,omitempty"`
+}
+
+// BookPatch holds the fields of a PATCH request to a book. Fields missing from
+// the request are nil and left unchanged.
+type BookPatch struct {
+	Author             *string   `json:"author"`
+	Editor             *string   `json:"editor"`
+	Translator         *string   `json:"translator"`
+	Illustrator        *string   `json:"illustrator"`
+	Foreword           *string   `json:"foreword"`
+	Compiler           *string   `json:"compiler"`
+	Title              *string   `json:"title"`
+	Subtitle           *string   `json:"subtitle"`
+	Year               *int      `json:"year"`
+	Edition            *int      `json:"edition"`
+	EditionDescription *string   `json:"edition_description"`
+	Publisher          *string   `json:"publisher"`
+	Isbn               *string   `json:"isbn"`
+	Series             *string   `json:"series"`
+	Status             *[]string `json:"status"`
+	Purchased          *string   `json:"purchased"`
+	Rating             *float64  `json:"rating"`
+	ClearRating        bool      `json:"clear_rating"`
+}
+
+// contributors gives a pointer to the field of the patch for a role, as
+// Book.contributors does for books.
+func (p *BookPatch) contributors(role ContributorRole) **string {
+	switch role {
+	case RoleAuthor:
+		return &p.Author
+	case RoleEditor:
+		return &p.Editor
+	case RoleTranslator:
+		return &p.Translator
+	case RoleIllustrator:
+		return &p.Illustrator
+	case RoleForeword:
+		return &p.Foreword
+	case RoleCompiler:
+		return &p.Compiler
+	default:
+		return nil
+	}
+}
+
+// NamedJSON is the representation used by the JSON API for people, publishers
+// and series.
+type NamedJSON struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Location string `json:"location,omitempty"`
+	Books    []int  `json:"books"`
+}
+
+// NamedPatch holds the fields of a PATCH request to a person, publisher or
+// series. Only publishers have a location.
+type NamedPatch struct {
+	Name     *string `json:"name"`
+	Location *string `json:"location"`
+}
+
+// CitationJSON is a citation of a book, in the style, form and format given.
+type CitationJSON struct {
+	Id       int    `json:"id"`
+	Style    string `json:"style"`
+	Form     string `json:"form"`
+	Format   string `json:"format"`
+	Citation string `json:"citation"`
+}
+
+// SearchResultJSON is the representation of a SearchResult used by the JSON
+// API.
+type SearchResultJSON struct {
+	Book    BookJSON `json:"book"`
+	Snippet string   `json:"snippet"`
+}
+
+// ApiErrorJSON is the body of an error response. Position is given for query
+// syntax errors.
+type ApiErrorJSON struct {
+	Error    string `json:"error"`
+	Position int    `json:"position,omitempty"`
+}
+
+type BadRequestError struct {
+	CallFunc string
+	Reason   string
+}
+
+func (e *BadRequestError) Error() string {
+	return fmt.Sprintf("%v: %v", e.CallFunc, e.Reason)
+}
+
+type NotFoundError struct {
+	Path string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("No resource at %v", e.Path)
+}
+
+func bookToJSON(b Book) BookJSON {
+	bj := BookJSON{
+		Id:                 b.id,
+		Author:             b.author,
+		Editor:             b.editor,
+		Translator:         b.translator,
+		Illustrator:        b.illustrator,
+		Foreword:           b.foreword,
+		Compiler:           b.compiler,
+		Title:              b.title,
+		Subtitle:           b.subtitle,
+		Year:               b.year,
+		Edition:            b.edition.number,
+		EditionDescription: b.edition.description,
+		Publisher:          b.publisher,
+		Isbn:               b.isbn.String(),
+		IsbnDigits:         string(b.isbn),
+		Series:             b.series,
+		Status:             b.status,
+		Purchased:          b.purchased.String(),
+	}
+	if bj.Status == nil {
+		bj.Status = []string{}
+	}
+	if b.rating.rated {
+		stars := b.rating.stars()
+		bj.Rating = &stars
+	}
+	return bj
+}
+
+func bookFromJSON(bj BookJSON) (Book, error) {
+	isbn, err := parseIsbn(bj.Isbn)
+	if err != nil {
+		return Book{}, fmt.Errorf("bookFromJSON: %w", err)
+	}
+
+	b := Book{
+		author:      bj.Author,
+		editor:      bj.Editor,
+		translator:  bj.Translator,
+		illustrator: bj.Illustrator,
+		foreword:    bj.Foreword,
+		compiler:    bj.Compiler,
+		title:       bj.Title,
+		subtitle:    bj.Subtitle,
+		year:        bj.Year,
+		edition:     Edition{bj.Edition, bj.EditionDescription},
+		publisher:   bj.Publisher,
+		isbn:        isbn,
+		series:      bj.Series,
+		status:      bj.Status,
+	}
+	if len(bj.Purchased) > 0 {
+		if err := b.purchased.setDate(bj.Purchased); err != nil {
+			return Book{}, fmt.Errorf("bookFromJSON: %w", err)
+		}
+	}
+	if bj.Rating != nil {
+		b.rating, err = newRating(*bj.Rating)
+		if err != nil {
+			return Book{}, fmt.Errorf("bookFromJSON: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// apiErrorStatus gives the HTTP status code for an error returned by the
+// library functions.
+func apiErrorStatus(err error) int {
+	var (
+		invBookIdErr      *InvalidBookIdError
+		invPersonIdErr    *InvalidPersonIdError
+		invPublisherIdErr *InvalidPublisherIdError
+		invSeriesIdErr    *InvalidSeriesIdError
+		notFoundErr       *NotFoundError
+		duplicateErr      *AddingDuplicateBookError
+		personInUseErr    *PersonInUseError
+		publisherInUseErr *PublisherInUseError
+		seriesInUseErr    *SeriesInUseError
+		transitionErr     *StatusTransitionError
+		dupNameErr        *DuplicateNameError
+		badRequestErr     *BadRequestError
+		syntaxErr         *QuerySyntaxError
+		invIsbnErr        *InvalidIsbnError
+		emptyTitleErr     *EmptyTitleError
+		emptyStatusErr    *EmptyStatusError
+		invRatingErr      *InvalidRatingError
+		dateErr           *DateParsingError
+		dupRowErr         *DuplicateRowError
+		columnErr         *CsvColumnError
+		missingFieldErr   *MissingFieldError
+		risSyntaxErr      *RisSyntaxError
+		typeErr           *UnsupportedTypeError
+		templateErr       *TemplateError
+	)
+	switch {
+	case errors.As(err, &invBookIdErr), errors.As(err, &invPersonIdErr),
+		errors.As(err, &invPublisherIdErr), errors.As(err, &invSeriesIdErr),
+		errors.As(err, &notFoundErr):
+		return http.StatusNotFound
+	case errors.As(err, &duplicateErr), errors.As(err, &personInUseErr),
+		errors.As(err, &publisherInUseErr), errors.As(err, &seriesInUseErr),
+		errors.As(err, &transitionErr), errors.As(err, &dupRowErr),
+		errors.As(err, &dupNameErr):
+		return http.StatusConflict
+	case errors.As(err, &badRequestErr), errors.As(err, &syntaxErr),
+		errors.As(err, &invIsbnErr),
+		errors.As(err, &emptyTitleErr), errors.As(err, &emptyStatusErr),
+		errors.As(err, &invRatingErr),
+		errors.As(err, &dateErr), errors.As(err, &columnErr),
+		errors.As(err, &missingFieldErr), errors.As(err, &risSyntaxErr),
+		errors.As(err, &typeErr), errors.As(err, &templateErr):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writeJSON, Couldn't write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := apiErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Printf("API error: %v", err)
+	}
+	apiErr := ApiErrorJSON{Error: err.Error()}
+	var syntaxErr *QuerySyntaxError
+	if errors.As(err, &syntaxErr) {
+		apiErr.Position = syntaxErr.Position
+	}
+	writeJSON(w, status, apiErr)
+}
+
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &BadRequestError{"readJSON", fmt.Sprintf("Invalid request body: %v", err)}
+	}
+	return nil
+}
+
+// resourceId gets the id from a request path such as /books/3. It returns 0
+// for the collection path, e.g. /books or /books/.
+func resourceId(path string, prefix string) (int, error) {
+	rest := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if len(rest) == 0 {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(rest)
+	if err != nil || id <= 0 {
+		return 0, &NotFoundError{path}
+	}
+	return id, nil
+}
+
+type ApiServer struct {
+	db *sql.DB
+}
+
+func newApiServer(db *sql.DB) http.Handler {
+	s := &ApiServer{db}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/books", s.handleBooks)
+	mux.HandleFunc("/books/", s.handleBooks)
+	mux.HandleFunc("/search", s.handleSearch)
+	for _, r := range []namedResource{peopleResource, publisherResource, seriesResource} {
+		handler := s.namedHandler(r)
+		mux.HandleFunc(r.path, handler)
+		mux.HandleFunc(r.path+"/", handler)
+	}
+	return mux
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeJSON(w, http.StatusMethodNotAllowed,
+		ApiErrorJSON{Error: fmt.Sprintf("Method not allowed, use %v", allowed)})
+}
+
+func (s *ApiServer) handleBooks(w http.ResponseWriter, r *http.Request) {
+	if path, ok := strings.CutSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/citation"); ok {
+		s.citeBook(w, r, path)
+		return
+	}
+	id, err := resourceId(r.URL.Path, "/books")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if id == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listBooks(w, r)
+		case http.MethodPost:
+			s.createBook(w, r)
+		default:
+			methodNotAllowed(w, "GET, POST")
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		b, err := getBookById(s.db, id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, bookToJSON(b))
+	case http.MethodPatch:
+		s.patchBook(w, r, id)
+	case http.MethodDelete:
+		if err := deleteBook(s.db, id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "GET, PATCH, DELETE")
+	}
+}
+
+// citeBook handles GET /books/{id}/citation, given the path of the book.
+func (s *ApiServer) citeBook(w http.ResponseWriter, r *http.Request, path string) {
+	id, err := resourceId(path, "/books")
+	if err != nil || id == 0 {
+		writeError(w, &NotFoundError{r.URL.Path})
+		return
+	}
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	var opts CitationOptions
+	query := r.URL.Query()
+	if style := query.Get("style"); len(style) > 0 {
+		if opts.Style, err = parseCitationStyle(style); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if form := query.Get("form"); len(form) > 0 {
+		if opts.Form, err = parseCitationForm(form); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if format := query.Get("format"); len(format) > 0 {
+		if opts.Format, err = parseTextFormat(format); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	citation, err := citeBook(s.db, id, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, CitationJSON{id, opts.Style.String(), opts.Form.String(),
+		opts.Format.String(), citation})
+}
+
+func (s *ApiServer) listBooks(w http.ResponseWriter, r *http.Request) {
+	ids, err := queryBookIds(s.db, r.URL.Query().Get("query"))
+	if err != nil {
+		writeError(w, fmt.Errorf("listBooks: %w", err))
+		return
+	}
+	loaded, err := loadBooks(s.db, ids)
+	if err != nil {
+		writeError(w, fmt.Errorf("listBooks: %v", err))
+		return
+	}
+	books := []BookJSON{}
+	for _, b := range loaded {
+		books = append(books, bookToJSON(b))
+	}
+	writeJSON(w, http.StatusOK, books)
+}
+
+func (s *ApiServer) createBook(w http.ResponseWriter, r *http.Request) {
+	var bj BookJSON
+	if err := readJSON(r, &bj); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(bj.Title) == 0 {
+		writeError(w, &BadRequestError{"createBook", "Book must have a title"})
+		return
+	}
+	if len(bj.Publisher) == 0 {
+		writeError(w, &BadRequestError{"createBook", "Book must have a publisher"})
+		return
+	}
+	b, err := bookFromJSON(bj)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id, err := addBook(s.db, &b)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	added, err := getBookById(s.db, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/books/%d", id))
+	writeJSON(w, http.StatusCreated, bookToJSON(added))
+}
+
+// patchBook applies each field given in the request using the matching
+// updateBookX function. The updates are made in a single transaction, so if
+// one fails none of the fields are changed.
+func (s *ApiServer) patchBook(w http.ResponseWriter, r *http.Request, id int) {
+	var p BookPatch
+	if err := readJSON(r, &p); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	orig, err := getBookById(s.db, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = withTx(s.db, func(tx DBInterface) error {
+		return applyBookPatch(tx, id, orig, p)
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b, err := getBookById(s.db, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, bookToJSON(b))
+}
+
+// applyBookPatch makes the changes in a BookPatch to a book, using the matching
+// updateBookX function for each field given.
+func applyBookPatch(db DBInterface, id int, orig Book, p BookPatch) error {
+	var err error
+	if p.Author != nil {
+		if _, err = updateBookAuthor(db, id, *p.Author); err != nil {
+			return err
+		}
+	}
+	if p.Editor != nil {
+		if _, err = updateBookEditor(db, id, *p.Editor); err != nil {
+			return err
+		}
+	}
+	contributors := []struct {
+		role  ContributorRole
+		names *string
+	}{
+		{RoleTranslator, p.Translator},
+		{RoleIllustrator, p.Illustrator},
+		{RoleForeword, p.Foreword},
+		{RoleCompiler, p.Compiler},
+	}
+	for _, c := range contributors {
+		if c.names == nil {
+			continue
+		}
+		if _, err = updateBookContributors(db, id, c.role, *c.names); err != nil {
+			return err
+		}
+	}
+	if p.Title != nil {
+		if _, err = updateBookTitle(db, id, *p.Title); err != nil {
+			return err
+		}
+	}
+	if p.Subtitle != nil {
+		if _, err = updateBookSubtitle(db, id, *p.Subtitle); err != nil {
+			return err
+		}
+	}
+	if p.Year != nil {
+		if _, err = updateBookYear(db, id, *p.Year); err != nil {
+			return err
+		}
+	}
+	if p.Edition != nil || p.EditionDescription != nil {
+		edition := orig.edition
+		if p.Edition != nil {
+			edition.number = *p.Edition
+		}
+		if p.EditionDescription != nil {
+			edition.description = *p.EditionDescription
+		}
+		if edition.number < 0 {
+			return &BadRequestError{"applyBookPatch", "Edition cannot be negative"}
+		}
+		if _, err = updateBookEdition(db, id, edition); err != nil {
+			return err
+		}
+	}
+	if p.Publisher != nil {
+		if len(*p.Publisher) == 0 {
+			return &BadRequestError{"applyBookPatch", "Publisher cannot be empty"}
+		}
+		if _, err = updateBookPublisherByName(db, id, *p.Publisher); err != nil {
+			return err
+		}
+		if err = deleteUnusedPublisher(db, orig.publisher); err != nil {
+			return err
+		}
+	}
+	if p.Isbn != nil {
+		if _, err = updateBookIsbn(db, id, *p.Isbn); err != nil {
+			return err
+		}
+	}
+	if p.Series != nil {
+		if _, err = updateBookSeriesByName(db, id, *p.Series); err != nil {
+			return err
+		}
+		if len(orig.series) > 0 {
+			if err = deleteUnusedSeries(db, orig.series); err != nil {
+				return err
+			}
+		}
+	}
+	if p.Status != nil {
+		if _, err = updateBookStatus(db, id, *p.Status); err != nil {
+			return err
+		}
+	}
+	if p.Purchased != nil {
+		var pd PurchasedDate
+		if err = pd.setDate(*p.Purchased); err != nil {
+			return err
+		}
+		if _, err = updateBookPurchaseDate(db, id, pd); err != nil {
+			return err
+		}
+	}
+	if p.Rating != nil || p.ClearRating {
+		var rating Rating
+		if p.Rating != nil {
+			if rating, err = newRating(*p.Rating); err != nil {
+				return err
+			}
+		}
+		if _, err = updateBookRating(db, id, rating); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUnusedPublisher removes a publisher no longer used by any book, in the
+// same way deleteBook does.
+func deleteUnusedPublisher(db DBInterface, name string) error {
+	pubId, err := publisherId(db, name)
+	if err != nil {
+		return err
+	}
+	err = deletePublisher(db, pubId)
+	var pubInUseErr *PublisherInUseError
+	if err != nil && !errors.As(err, &pubInUseErr) {
+		return err
+	}
+	return nil
+}
+
+// deleteUnusedSeries removes a series no longer used by any book, in the same
+// way deleteBook does.
+func deleteUnusedSeries(db DBInterface, name string) error {
+	serId, err := seriesId(db, name)
+	if err != nil {
+		return err
+	}
+	err = deleteSeries(db, serId)
+	var serInUseErr *SeriesInUseError
+	if err != nil && !errors.As(err, &serInUseErr) {
+		return err
+	}
+	return nil
+}
+
+func (s *ApiServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	results, err := searchBooks(s.db, r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resultsJSON := []SearchResultJSON{}
+	for _, sr := range results {
+		resultsJSON = append(resultsJSON, SearchResultJSON{bookToJSON(sr.Book), sr.Snippet})
+	}
+	writeJSON(w, http.StatusOK, resultsJSON)
+}
+
+// namedResource describes one of the resources which is just a name with a
+// list of books, i.e. people, publishers and series, in terms of the library
+// functions which handle it.
+type namedResource struct {
+	path       string
+	kind       string
+	idByName   stmtKey
+	list       func(db DBInterface) ([]int, error)
+	name       func(db DBInterface, id int) (string, error)
+	books      func(db DBInterface, id int) ([]int, error)
+	create     func(db DBInterface, name string) (int, error)
+	updateName func(db DBInterface, id int, name string) (string, error)
+	delete     func(db DBInterface, id int) error
+
+	// only for publishers
+	location       func(db DBInterface, id int) (string, error)
+	updateLocation func(db DBInterface, id int, location string) error
+}
+
+var peopleResource = namedResource{
+	path:       "/people",
+	kind:       "Person",
+	idByName:   stmtPersonIdByName,
+	list:       getListOfPersonIDs,
+	name:       personName,
+	books:      booksByPersonId,
+	create:     personId,
+	updateName: updatePersonName,
+	delete:     deletePerson,
+}
+
+var publisherResource = namedResource{
+	path:       "/publishers",
+	kind:       "Publisher",
+	idByName:   stmtPublisherIdByName,
+	list:       getListOfPublisherIDs,
+	name:       publisherName,
+	books:      publisherBooks,
+	create:     publisherId,
+	updateName: updatePublisherName,
+	delete:     deletePublisher,
+
+	location:       publisherLocation,
+	updateLocation: updatePublisherLocation,
+}
+
+var seriesResource = namedResource{
+	path:       "/series",
+	kind:       "Series",
+	idByName:   stmtSeriesIdByName,
+	list:       getListOfSeriesIDs,
+	name:       seriesName,
+	books:      seriesBooks,
+	create:     seriesId,
+	updateName: updateSeriesName,
+	delete:     deleteSeries,
+}
+
+func getNamed(db DBInterface, res namedResource, id int) (NamedJSON, error) {
+	name, err := res.name(db, id)
+	if err != nil {
+		return NamedJSON{}, err
+	}
+	books, err := res.books(db, id)
+	if err != nil {
+		return NamedJSON{}, err
+	}
+	if books == nil {
+		books = []int{}
+	}
+	record := NamedJSON{Id: id, Name: name, Books: books}
+	if res.location != nil {
+		if record.Location, err = res.location(db, id); err != nil {
+			return NamedJSON{}, err
+		}
+	}
+	return record, nil
+}
+
+func (s *ApiServer) namedHandler(res namedResource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := resourceId(r.URL.Path, res.path)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if id == 0 {
+			switch r.Method {
+			case http.MethodGet:
+				ids, err := res.list(s.db)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				records := []NamedJSON{}
+				for _, id := range ids {
+					record, err := getNamed(s.db, res, id)
+					if err != nil {
+						writeError(w, err)
+						return
+					}
+					records = append(records, record)
+				}
+				writeJSON(w, http.StatusOK, records)
+			case http.MethodPost:
+				var nj NamedJSON
+				if err := readJSON(r, &nj); err != nil {
+					writeError(w, err)
+					return
+				}
+				if len(nj.Name) == 0 {
+					writeError(w, &BadRequestError{"namedHandler", "Name cannot be empty"})
+					return
+				}
+				// the get-or-create functions would return the existing
+				// record, so check for it first
+				existing, err := otherNameId(s.db, res.idByName, nj.Name, 0)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				if existing != 0 {
+					writeError(w, &DuplicateNameError{"namedHandler", res.kind,
+						nj.Name, existing})
+					return
+				}
+				id, err := res.create(s.db, nj.Name)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				record, err := getNamed(s.db, res, id)
+				if err != nil {
+					writeError(w, err)
+					return
+				}
+				w.Header().Set("Location", fmt.Sprintf("%v/%d", res.path, id))
+				writeJSON(w, http.StatusCreated, record)
+			default:
+				methodNotAllowed(w, "GET, POST")
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			record, err := getNamed(s.db, res, id)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, record)
+		case http.MethodPatch:
+			var patch NamedPatch
+			if err := readJSON(r, &patch); err != nil {
+				writeError(w, err)
+				return
+			}
+			if (patch.Name == nil && patch.Location == nil) ||
+				(patch.Name != nil && len(*patch.Name) == 0) {
+				writeError(w, &BadRequestError{"namedHandler", "Name cannot be empty"})
+				return
+			}
+			if patch.Location != nil && res.updateLocation == nil {
+				writeError(w, &BadRequestError{"namedHandler",
+					"Only publishers have a location"})
+				return
+			}
+			if _, err := res.name(s.db, id); err != nil {
+				writeError(w, err)
+				return
+			}
+			if patch.Name != nil {
+				if _, err := res.updateName(s.db, id, *patch.Name); err != nil {
+					writeError(w, err)
+					return
+				}
+			}
+			if patch.Location != nil {
+				if err := res.updateLocation(s.db, id, *patch.Location); err != nil {
+					writeError(w, err)
+					return
+				}
+			}
+			record, err := getNamed(s.db, res, id)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, record)
+		case http.MethodDelete:
+			if err := res.delete(s.db, id); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			methodNotAllowed(w, "GET, PATCH, DELETE")
+		}
+	}
+}
+
+func serveApi(db *sql.DB, addr string) error {
+	log.Printf("Serving JSON API on %v", addr)
+	return http.ListenAndServe(addr, newApiServer(db))
+}
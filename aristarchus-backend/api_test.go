@@ -0,0 +1,531 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// apiRequest makes a request to the API handler and returns the response,
+// decoding any JSON body into v if it is not nil.
+func apiRequest(t *testing.T, handler http.Handler, method string, path string,
+	body string, v any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if v != nil && rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Errorf("Couldn't decode response to %v %v: %v\n%v", method, path,
+				err, rec.Body.String())
+		}
+	}
+	return rec
+}
+
+func TestApiGetBook(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	rating := 4.5
+	expected := BookJSON{
+		Id:         5,
+		Author:     "Peter J. Gentry and Stephen J. Wellum",
+		Title:      "Kingdom through Covenant",
+		Subtitle:   "A Biblical-Theological Understanding of the Covenants",
+		Year:       2018,
+		Edition:    2,
+		Publisher:  "Crossway",
+		Isbn:       "978-1-4335-5307-3",
+		IsbnDigits: "9781433553073",
+		Status:     []string{"Owned", "Read"},
+		Purchased:  "January 2022",
+		Rating:     &rating,
+	}
+
+	var bj BookJSON
+	rec := apiRequest(t, newApiServer(db), http.MethodGet, "/books/5", "", &bj)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /books/5 returned status %v, expected %v", rec.Code,
+			http.StatusOK)
+	}
+	if !reflect.DeepEqual(bj, expected) {
+		t.Errorf("GET /books/5 returned unexpected book. Expected %+v, got %+v",
+			expected, bj)
+	}
+}
+
+func TestApiGetBookInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, path := range []string{"/books/1000", "/books/abc", "/people/1000",
+		"/publishers/1000", "/series/1000"} {
+		var apiErr ApiErrorJSON
+		rec := apiRequest(t, newApiServer(db), http.MethodGet, path, "", &apiErr)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %v returned status %v, expected %v", path, rec.Code,
+				http.StatusNotFound)
+		}
+		if len(apiErr.Error) == 0 {
+			t.Errorf("GET %v did not return error message", path)
+		}
+	}
+}
+
+func TestApiListBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var books []BookJSON
+	rec := apiRequest(t, newApiServer(db), http.MethodGet, "/books", "", &books)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /books returned status %v, expected %v", rec.Code,
+			http.StatusOK)
+	}
+	if len(books) != 6 {
+		t.Errorf("GET /books returned %v books, expected 6", len(books))
+	}
+}
+
+func TestApiAddAndDeleteBook(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	server := newApiServer(db)
+
+	body := `{"author": "Karen H. Jobes and Moisés Silva",
+		"title": "Invitation to the Septuagint", "year": 2015, "edition": 2,
+		"publisher": "Baker Academic", "isbn": "978-0-8010-3649-1",
+		"status": ["Owned"], "purchased": "March 2016", "rating": 4}`
+
+	var added BookJSON
+	rec := apiRequest(t, server, http.MethodPost, "/books", body, &added)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /books returned status %v, expected %v: %v", rec.Code,
+			http.StatusCreated, rec.Body.String())
+	}
+	if added.Title != "Invitation to the Septuagint" || added.Isbn != "978-0-8010-3649-1" ||
+		added.IsbnDigits != "9780801036491" ||
+		added.Rating == nil || *added.Rating != 4 {
+		t.Errorf("POST /books returned unexpected book: %+v", added)
+	}
+	path := rec.Header().Get("Location")
+	if path != fmt.Sprintf("/books/%d", added.Id) {
+		t.Errorf("POST /books returned unexpected location %v", path)
+	}
+
+	// adding again should be a conflict
+	var apiErr ApiErrorJSON
+	rec = apiRequest(t, server, http.MethodPost, "/books", body, &apiErr)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Duplicate POST /books returned status %v, expected %v",
+			rec.Code, http.StatusConflict)
+	}
+
+	rec = apiRequest(t, server, http.MethodDelete, path, "", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE %v returned status %v, expected %v", path, rec.Code,
+			http.StatusNoContent)
+	}
+
+	rec = apiRequest(t, server, http.MethodGet, path, "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET deleted book returned status %v, expected %v", rec.Code,
+			http.StatusNotFound)
+	}
+}
+
+func TestApiAddBookInvalid(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	bodies := []string{
+		`{"title": "No publisher"}`,
+		`{"title": "Bad ISBN", "publisher": "IVP", "isbn": "978-0-8010-3649-2"}`,
+		`{"title": "Bad rating", "publisher": "IVP", "rating": 7}`,
+		`{"title": "Unknown field", "publisher": "IVP", "colour": "red"}`,
+		`not json`,
+	}
+	for _, body := range bodies {
+		rec := apiRequest(t, newApiServer(db), http.MethodPost, "/books", body, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /books with %v returned status %v, expected %v", body,
+				rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestApiPatchBook(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	server := newApiServer(db)
+
+	var bj BookJSON
+	rec := apiRequest(t, server, http.MethodPatch, "/books/1",
+		`{"title": "An Introduction to the Old Testament", "year": 1970}`, &bj)
+	if rec.Code != http.StatusOK {
+		t.Errorf("PATCH /books/1 returned status %v, expected %v: %v", rec.Code,
+			http.StatusOK, rec.Body.String())
+	}
+	if bj.Title != "An Introduction to the Old Testament" || bj.Year != 1970 {
+		t.Errorf("PATCH /books/1 did not update book: %+v", bj)
+	}
+	if bj.Author != "R. K. Harrison" {
+		t.Errorf("PATCH /books/1 changed fields not in request: %+v", bj)
+	}
+
+	// Revert database back to original state
+	rec = apiRequest(t, server, http.MethodPatch, "/books/1",
+		`{"title": "Introduction to the Old Testament", "year": 1969}`, &bj)
+	if rec.Code != http.StatusOK || bj.Title != "Introduction to the Old Testament" ||
+		bj.Year != 1969 {
+		t.Errorf("Problem reverting book: %v %+v", rec.Code, bj)
+	}
+}
+
+func TestApiPatchBookErrors(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	requests := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{"/books/1000", `{"title": "Missing"}`, http.StatusNotFound},
+		{"/books/1", `{"title": ""}`, http.StatusBadRequest},
+		{"/books/6", `{"status": ["Lent"]}`, http.StatusConflict},
+		{"/books/1", `{"status": []}`, http.StatusBadRequest},
+	}
+	for _, r := range requests {
+		var apiErr ApiErrorJSON
+		rec := apiRequest(t, newApiServer(db), http.MethodPatch, r.path, r.body,
+			&apiErr)
+		if rec.Code != r.status {
+			t.Errorf("PATCH %v with %v returned status %v, expected %v", r.path,
+				r.body, rec.Code, r.status)
+		}
+		if len(apiErr.Error) == 0 {
+			t.Errorf("PATCH %v with %v did not return error message", r.path, r.body)
+		}
+	}
+}
+
+// A PATCH which fails part way through should leave the book as it was.
+func TestApiPatchBookRollback(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	server := newApiServer(db)
+
+	var orig BookJSON
+	apiRequest(t, server, http.MethodGet, "/books/1", "", &orig)
+
+	body := `{"author": "Tremper Longman III", "title": "Changed title",
+		"isbn": "978-0-8010-3649-2"}`
+	rec := apiRequest(t, server, http.MethodPatch, "/books/1", body, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH /books/1 with invalid isbn returned status %v, expected %v",
+			rec.Code, http.StatusBadRequest)
+	}
+
+	var bj BookJSON
+	apiRequest(t, server, http.MethodGet, "/books/1", "", &bj)
+	if !reflect.DeepEqual(bj, orig) {
+		t.Errorf("Failed PATCH /books/1 changed book. Expected %+v, got %+v", orig, bj)
+	}
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM people WHERE name = ?",
+		"Tremper Longman III").Scan(&count)
+	if err != nil {
+		t.Errorf("Problem counting people: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Failed PATCH /books/1 left new author in database")
+	}
+}
+
+func TestApiGetPerson(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := NamedJSON{Id: 3, Name: "Peter J. Gentry", Books: []int{4, 5}}
+
+	var nj NamedJSON
+	rec := apiRequest(t, newApiServer(db), http.MethodGet, "/people/3", "", &nj)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /people/3 returned status %v, expected %v", rec.Code,
+			http.StatusOK)
+	}
+	if !reflect.DeepEqual(nj, expected) {
+		t.Errorf("GET /people/3 returned unexpected record. Expected %v, got %v",
+			expected, nj)
+	}
+}
+
+func TestApiDeleteInUse(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, path := range []string{"/people/3", "/publishers/1", "/series/1"} {
+		var apiErr ApiErrorJSON
+		rec := apiRequest(t, newApiServer(db), http.MethodDelete, path, "", &apiErr)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("DELETE %v returned status %v, expected %v", path, rec.Code,
+				http.StatusConflict)
+		}
+		if len(apiErr.Error) == 0 {
+			t.Errorf("DELETE %v did not return error message", path)
+		}
+	}
+}
+
+func TestApiNamedResourceLifecycle(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	server := newApiServer(db)
+
+	var created NamedJSON
+	rec := apiRequest(t, server, http.MethodPost, "/publishers",
+		`{"name": "Zondervan"}`, &created)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /publishers returned status %v, expected %v", rec.Code,
+			http.StatusCreated)
+	}
+	if created.Name != "Zondervan" || len(created.Books) != 0 {
+		t.Errorf("POST /publishers returned unexpected record: %v", created)
+	}
+	path := rec.Header().Get("Location")
+
+	var renamed NamedJSON
+	rec = apiRequest(t, server, http.MethodPatch, path,
+		`{"name": "Zondervan Academic"}`, &renamed)
+	if rec.Code != http.StatusOK || renamed.Name != "Zondervan Academic" {
+		t.Errorf("PATCH %v returned %v, %v", path, rec.Code, renamed)
+	}
+
+	rec = apiRequest(t, server, http.MethodDelete, path, "", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE %v returned status %v, expected %v", path, rec.Code,
+			http.StatusNoContent)
+	}
+}
+
+func TestApiNamedResourceDuplicate(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPost, "/people", `{"name": "Anselm"}`},
+		{http.MethodPost, "/publishers", `{"name": "Crossway"}`},
+		{http.MethodPost, "/series", `{"name": "Spectrum Multiview Books"}`},
+		{http.MethodPatch, "/people/1", `{"name": "Anselm"}`},
+		{http.MethodPatch, "/publishers/1", `{"name": "Hackett"}`},
+	}
+	for _, r := range requests {
+		var apiErr ApiErrorJSON
+		rec := apiRequest(t, newApiServer(db), r.method, r.path, r.body, &apiErr)
+		if rec.Code != http.StatusConflict {
+			t.Errorf("%v %v with %v returned status %v, expected %v", r.method, r.path,
+				r.body, rec.Code, http.StatusConflict)
+		}
+		if len(apiErr.Error) == 0 {
+			t.Errorf("%v %v with %v did not return error message", r.method, r.path,
+				r.body)
+		}
+	}
+
+	var person NamedJSON
+	apiRequest(t, newApiServer(db), http.MethodGet, "/people/1", "", &person)
+	if person.Name != "R. K. Harrison" {
+		t.Errorf("Duplicate name wrongly given to person #1: %v", person.Name)
+	}
+}
+
+func TestApiMethodNotAllowed(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	rec := apiRequest(t, newApiServer(db), http.MethodPut, "/books/1", "{}", nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /books/1 returned status %v, expected %v", rec.Code,
+			http.StatusMethodNotAllowed)
+	}
+}
+
+func TestApiSearch(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var results []SearchResultJSON
+	rec := apiRequest(t, newApiServer(db), http.MethodGet,
+		"/search?q=gentry+covenant", "", &results)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /search returned status %v, expected %v", rec.Code,
+			http.StatusOK)
+	}
+	if len(results) != 1 || results[0].Book.Id != 5 || len(results[0].Snippet) == 0 {
+		t.Errorf("GET /search returned unexpected results: %+v", results)
+	}
+}
+
+func TestApiQueryBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var books []BookJSON
+	rec := apiRequest(t, newApiServer(db), http.MethodGet,
+		"/books?query=author%3AGentry+year%3A2018", "", &books)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /books?query= returned status %v, expected %v", rec.Code,
+			http.StatusOK)
+	}
+	if len(books) != 1 || books[0].Id != 5 {
+		t.Errorf("GET /books?query= returned unexpected books: %+v", books)
+	}
+
+	var apiErr ApiErrorJSON
+	rec = apiRequest(t, newApiServer(db), http.MethodGet,
+		"/books?query=colour%3Ared", "", &apiErr)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /books with invalid query returned status %v, expected %v",
+			rec.Code, http.StatusBadRequest)
+	}
+	if apiErr.Position != 1 {
+		t.Errorf("GET /books with invalid query gave position %v, expected 1",
+			apiErr.Position)
+	}
+}
+
+func TestApiCiteBook(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	server := newApiServer(db)
+
+	var cit CitationJSON
+	rec := apiRequest(t, server, http.MethodGet,
+		"/books/3/citation?style=apa&format=html", "", &cit)
+	expected := CitationJSON{3, "apa", "bibliography", "html",
+		"Anselm. (2007). <i>Basic Writings</i> (T. Williams, Trans.). Hackett."}
+	if rec.Code != http.StatusOK || cit != expected {
+		t.Errorf("GET /books/3/citation returned %v, %+v", rec.Code, cit)
+	}
+
+	rec = apiRequest(t, server, http.MethodGet, "/books/4/citation/?form=note", "", &cit)
+	expected = CitationJSON{4, "chicago", "note", "text", "Peter J. Gentry, How to Read " +
+		"and Understand the Biblical Prophets (Wheaton, IL: Crossway, 2017)."}
+	if rec.Code != http.StatusOK || cit != expected {
+		t.Errorf("GET /books/4/citation/ returned %v, %+v", rec.Code, cit)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/books/3/citation?style=mla", http.StatusBadRequest},
+		{http.MethodGet, "/books/3/citation?format=pdf", http.StatusBadRequest},
+		{http.MethodGet, "/books/999/citation", http.StatusNotFound},
+		{http.MethodGet, "/books/citation", http.StatusNotFound},
+		{http.MethodPost, "/books/3/citation", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		rec := apiRequest(t, server, test.method, test.path, "", nil)
+		if rec.Code != test.code {
+			t.Errorf("%v %v returned status %v, expected %v", test.method, test.path,
+				rec.Code, test.code)
+		}
+	}
+}
+
+func TestApiPublisherLocation(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	server := newApiServer(db)
+
+	var publisher NamedJSON
+	rec := apiRequest(t, server, http.MethodGet, "/publishers/3", "", &publisher)
+	if rec.Code != http.StatusOK || publisher.Location != "Wheaton, IL" {
+		t.Errorf("GET /publishers/3 returned %v, %+v", rec.Code, publisher)
+	}
+
+	defer updatePublisherLocation(db, 2, "Indianapolis")
+	rec = apiRequest(t, server, http.MethodPatch, "/publishers/2",
+		`{"location": "Cambridge, MA"}`, &publisher)
+	if rec.Code != http.StatusOK || publisher.Name != "Hackett" ||
+		publisher.Location != "Cambridge, MA" {
+		t.Errorf("PATCH /publishers/2 location returned %v, %+v", rec.Code, publisher)
+	}
+
+	var person NamedJSON
+	rec = apiRequest(t, server, http.MethodPatch, "/people/3",
+		`{"location": "Louisville, KY"}`, &person)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH /people/3 location returned status %v, expected %v", rec.Code,
+			http.StatusBadRequest)
+	}
+	rec = apiRequest(t, server, http.MethodPatch, "/publishers/2", `{}`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH /publishers/2 with no fields returned status %v, expected %v",
+			rec.Code, http.StatusBadRequest)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"os"
 	"slices"
 	"strings"
 	"time"
@@ -103,31 +103,58 @@ func (pd *PurchasedDate) setDate(s string) error {
 	return nil
 }
 
-type Book struct {
-	id        int
-	author    string
-	editor    string
-	title     string
-	subtitle  string
-	year      int
-	edition   int
-	publisher string
-	isbn      string
-	series    string
-	status    string
-	purchased PurchasedDate
-}
+// timestampFormat is the layout used for timestamps stored in the database,
+// in UTC. It sorts lexically in time order, and is understood by SQLite date
+// functions.
+const timestampFormat = "2006-01-02 15:04:05"
 
+// clock gives the time recorded for status changes and notes. It can be
+// replaced in tests to give predictable timestamps.
+var clock = time.Now
+
+type Book struct {
+	id          int
+	author      string
+	editor      string
+	translator  string
+	illustrator string
+	foreword    string
+	compiler    string
+	title       string
+	subtitle    string
+	year        int
+	edition     Edition
+	publisher   string
+	isbn        ISBN
+	series      string
+	status      []string
+	purchased   PurchasedDate
+	rating      Rating
+}
+
+// String gives the book on one line through the "line" display template. As
+// templates are checked when loaded, the built-in template is only used in its
+// place if the book can't be rendered.
 func (b Book) String() string {
-	return fmt.Sprintf("%v, %v (%v) [%v]", b.authorEditor(), b.fullTitle(),
-		b.year, b.status)
+	s, err := renderBookString("line", newBookView(b))
+	if err != nil {
+		var sb strings.Builder
+		builtinTemplates.ExecuteTemplate(&sb, "line", newBookView(b))
+		return sb.String()
+	}
+	return s
 }
 
 func (b Book) authorEditor() string {
 	if len(b.author) > 0 {
 		return fmt.Sprintf("%v", b.author)
 	} else if len(b.editor) > 0 {
+		if len(nameListFromString(b.editor)) > 1 {
+			return fmt.Sprintf("%v (eds.)", b.editor)
+		}
 		return fmt.Sprintf("%v (ed.)", b.editor)
+	} else if len(b.compiler) > 0 {
+		return fmt.Sprintf("%v (comp.)", b.compiler)
 	} else {
 		return fmt.Sprintf("[No author]")
 	}
@@ -149,7 +176,7 @@ type DBInterface interface {
 
 func countAllBooks(db DBInterface) (int, error) {
 	var bookCount int
-	err := db.QueryRow("SELECT COUNT(book_id) FROM books").Scan(&bookCount)
+	err := stmtQueryRow(db, stmtCountBooks).Scan(&bookCount)
 	if err != nil {
 		return 0, err
 	}
@@ -158,8 +185,7 @@ func countAllBooks(db DBInterface) (int, error) {
 
 func countBooksByStatus(db DBInterface, status string) (int, error) {
 	var bookCount int
-	err := db.QueryRow("SELECT COUNT(book_id) FROM books WHERE status = ?",
-		status).Scan(&bookCount)
+	err := stmtQueryRow(db, stmtCountBooksByStatus, status).Scan(&bookCount)
 	if err != nil {
 		return 0, err
 	}
@@ -186,6 +212,66 @@ func getListOfBookIDs(db DBInterface) ([]int, error) {
 	return idList, nil
 }
 
+func getListOfPersonIDs(db DBInterface) ([]int, error) {
+	var idList []int
+	rows, err := db.Query("SELECT person_id FROM people ORDER BY person_id")
+	if err != nil {
+		return idList, fmt.Errorf("getListOfPersonIDs, %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("getListOfPersonIDs, %v", err)
+		}
+		idList = append(idList, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getListOfPersonIDs, rows.Next() error: %v", err)
+	}
+	return idList, nil
+}
+
+func getListOfPublisherIDs(db DBInterface) ([]int, error) {
+	var idList []int
+	rows, err := db.Query("SELECT publisher_id FROM publishers ORDER BY publisher_id")
+	if err != nil {
+		return idList, fmt.Errorf("getListOfPublisherIDs, %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("getListOfPublisherIDs, %v", err)
+		}
+		idList = append(idList, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getListOfPublisherIDs, rows.Next() error: %v", err)
+	}
+	return idList, nil
+}
+
+func getListOfSeriesIDs(db DBInterface) ([]int, error) {
+	var idList []int
+	rows, err := db.Query("SELECT series_id FROM series ORDER BY series_id")
+	if err != nil {
+		return idList, fmt.Errorf("getListOfSeriesIDs, %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("getListOfSeriesIDs, %v", err)
+		}
+		idList = append(idList, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getListOfSeriesIDs, rows.Next() error: %v", err)
+	}
+	return idList, nil
+}
+
 func formatNameList(names []string) string {
 	switch len(names) {
 	case 0:
@@ -230,86 +316,43 @@ func nameListFromString(nameString string) []string {
 }
 
 func getAuthorsListById(db DBInterface, id int) ([]string, error) {
-	bookValid, err := BookIDValid(db, id)
-	if err != nil {
-		return []string{}, fmt.Errorf(
-			"getAuthorsListById, could not validate book id #%v: %v",
-			id, err,
-		)
-	}
-	if !bookValid {
-		return []string{}, &InvalidBookIdError{"getAuthorsListById", id}
-	}
-
-	var authors []string
-	sqlStmt := `
-          SELECT people.name
-          FROM people
-          INNER JOIN book_author
-            ON book_author.author_id = people.person_id
-          WHERE book_author.book_id = ?`
-	authorRows, err := db.Query(sqlStmt, id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return authors, fmt.Errorf(
-				"getAuthorsListById %d: Query returned no results",
-				id,
-			)
-		}
-		return authors, fmt.Errorf("getAuthorsListById %d: %v", id, err)
-	}
-	defer authorRows.Close()
-	for authorRows.Next() {
-		var authorName string
-		err = authorRows.Scan(&authorName)
-		if err != nil {
-			return authors, fmt.Errorf("getAuthorsListById %d, %v", id, err)
-		}
-		authors = append(authors, authorName)
-	}
-	return authors, nil
+	return getContributorsListById(db, id, RoleAuthor)
 }
 
 func getEditorsListById(db DBInterface, id int) ([]string, error) {
+	return getContributorsListById(db, id, RoleEditor)
+}
+
+func getStatusListById(db DBInterface, id int) ([]string, error) {
 	bookValid, err := BookIDValid(db, id)
 	if err != nil {
 		return []string{}, fmt.Errorf(
-			"getEditorsListById, could not validate book id #%v: %v",
+			"getStatusListById, could not validate book id #%v: %v",
 			id, err,
 		)
 	}
 	if !bookValid {
-		return []string{}, &InvalidBookIdError{"getEditorsListById", id}
+		return []string{}, &InvalidBookIdError{"getStatusListById", id}
 	}
 
-	var editors []string
-	sqlStmt := `
-          SELECT people.name
-          FROM people
-          INNER JOIN book_editor
-            ON book_editor.editor_id = people.person_id
-          WHERE book_editor.book_id = ?`
-	editorRows, err := db.Query(sqlStmt, id)
+	var statuses []string
+	statusRows, err := stmtQuery(db, stmtStatusListById, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return editors, fmt.Errorf(
-				"getEditorsListById %d: Query returned no results",
-				id,
-			)
-		}
-		return editors, fmt.Errorf("getEditorsListById %d: %v", id, err)
+		return statuses, fmt.Errorf("getStatusListById %d: %v", id, err)
 	}
-	defer editorRows.Close()
-	for editorRows.Next() {
-		var editorName string
-		err = editorRows.Scan(&editorName)
-		// I think we need to handle no rows case as meaning no editors!!
+	defer statusRows.Close()
+	for statusRows.Next() {
+		var statusName string
+		err = statusRows.Scan(&statusName)
 		if err != nil {
-			return editors, fmt.Errorf("getEditorsListById %d, %v", id, err)
+			return statuses, fmt.Errorf("getStatusListById %d, %v", id, err)
 		}
-		editors = append(editors, editorName)
+		statuses = append(statuses, statusName)
+	}
+	if err := statusRows.Err(); err != nil {
+		return statuses, fmt.Errorf("getStatusListById %d, %v", id, err)
 	}
-	return editors, nil
+	return statuses, nil
 }
 
 type InvalidBookIdError struct {
@@ -322,13 +365,8 @@ func (e *InvalidBookIdError) Error() string {
 }
 
 func BookIDValid(db DBInterface, id int) (bool, error) {
-	sqlStmt := `
-        SELECT COUNT(*)
-        FROM books
-        WHERE book_id = ?`
-
 	var count int
-	if err := db.QueryRow(sqlStmt, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtBookIdValid, id).Scan(&count); err != nil {
 		return false, fmt.Errorf("BookIDValid, problem reading from DB: %v", err)
 	}
 	if count == 1 {
@@ -338,90 +376,44 @@ func BookIDValid(db DBInterface, id int) (bool, error) {
 	}
 }
 
+// getBookById loads a book with its contributors and statuses, in one query
+// for each.
 func getBookById(db DBInterface, id int) (Book, error) {
-	bookValid, err := BookIDValid(db, id)
+	b, err := scanBook(stmtQueryRow(db, stmtBookById, id))
 	if err != nil {
-		return Book{}, fmt.Errorf("getBookById, could not validate id #%v: %w", id, err)
-	}
-	if !bookValid {
-		return Book{}, &InvalidBookIdError{"getBookById", id}
-	}
-
-	var b Book
-	b.id = id
-
-	var subtitle sql.NullString
-	var seriesName sql.NullString
-	var edition sql.NullInt64
-	var purDate sql.NullString
-
-	sqlStmt := `
-            SELECT title, subtitle, year, edition, publishers.name, isbn,
-            series.series_name, status, purchased_date
-            FROM books
-            INNER JOIN publishers
-              ON books.publisher_id = publishers.publisher_id
-            LEFT JOIN series
-              ON books.series_id = series.series_id
-            WHERE book_id = ?`
-	row := db.QueryRow(sqlStmt, id)
-	if err := row.Scan(&b.title, &subtitle, &b.year, &edition,
-		&b.publisher, &b.isbn, &seriesName, &b.status, &purDate); err != nil {
 		if err == sql.ErrNoRows {
-			return b, &InvalidBookIdError{"getBookById", id}
+			return Book{}, &InvalidBookIdError{"getBookById", id}
 		}
-		return b, fmt.Errorf("getBookById %d: %v", id, err)
+		return Book{}, fmt.Errorf("getBookById %d: %w", id, err)
 	}
 
-	if subtitle.Valid {
-		b.subtitle = subtitle.String
-	}
-	if seriesName.Valid {
-		b.series = seriesName.String
-	}
-	if edition.Valid {
-		b.edition = int(edition.Int64)
-	}
-	if purDate.Valid {
-		b.purchased.setDate(purDate.String)
-	}
-
-	var authorList []string
-	authorList, err = getAuthorsListById(db, id)
+	err = loadBookDetails(db, map[int]*Book{id: &b}, "WHERE book_id = ?", []any{id})
 	if err != nil {
-		log.Fatal(err)
+		return Book{}, fmt.Errorf("getBookById %d: %w", id, err)
 	}
-	b.author = formatNameList(authorList)
-
-	var editorList []string
-	editorList, err = getEditorsListById(db, id)
-	if err != nil {
-		log.Fatal(err)
-	}
-	b.editor = formatNameList(editorList)
 
 	return b, nil
 }
 
-func printBookList(db DBInterface) ([]Book, error) {
-	idList, err := getListOfBookIDs(db)
+// printBookList prints every book in the library through the named display
+// template.
+func printBookList(db DBInterface, template string) ([]Book, error) {
+	if err := checkDisplayTemplate(template); err != nil {
+		return nil, fmt.Errorf("printBookList: %w", err)
+	}
+	bookList, err := loadAllBooks(db)
 	if err != nil {
 		return nil, err
 	}
 
-	var bookList []Book
-
 	fmt.Println("Books in library are:")
 
-	for _, id := range idList {
-		var book Book
-		book, err = getBookById(db, id)
+	for _, book := range bookList {
+		line, err := renderBookString(template, newBookView(book))
 		if err != nil {
-			return nil, err
+			return bookList, fmt.Errorf("printBookList: %w", err)
 		}
-
-		fmt.Println(book)
-		bookList = append(bookList, book)
+		fmt.Println(line)
 	}
 
 	return bookList, nil
@@ -438,11 +430,8 @@ func (e *InvalidPersonIdError) Error() string {
 
 func personName(db DBInterface, id int) (string, error) {
 	// check valid person id
-	checkPersonIdSql := `SELECT COUNT(*)
-        FROM people
-        WHERE person_id = ?`
 	var count int
-	if err := db.QueryRow(checkPersonIdSql, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtPersonIdValid, id).Scan(&count); err != nil {
 		return "", fmt.Errorf(
 			"personName, Could not look up person ID #%v: %v",
 			id,
@@ -455,11 +444,7 @@ func personName(db DBInterface, id int) (string, error) {
 
 	// person id valid, so retrieve name
 	var name string
-	nameSql := `SELECT name
-        FROM people
-        WHERE person_id = ?`
-
-	if err := db.QueryRow(nameSql, id).Scan(&name); err != nil {
+	if err := stmtQueryRow(db, stmtPersonName, id).Scan(&name); err != nil {
 		return "", fmt.Errorf(
 			"personId: Issue retrieving id #%v from database, %v ",
 			id,
@@ -475,18 +460,11 @@ func personId(db DBInterface, person string) (int, error) {
 	}
 
 	var id int
-	if err := db.QueryRow("SELECT person_id FROM people WHERE name = ?",
-		person).Scan(&id); err != nil {
+	if err := stmtQueryRow(db, stmtPersonIdByName, person).Scan(&id); err != nil {
 		if err == sql.ErrNoRows {
-			result, err := db.Exec("INSERT INTO people (name) VALUES (?)", person)
-			if err != nil {
-				return 0, fmt.Errorf("personId, %v", err)
-			}
-			liid, err := result.LastInsertId()
-			if err != nil {
+			if err := stmtQueryRow(db, stmtInsertPerson, person).Scan(&id); err != nil {
 				return 0, fmt.Errorf("personId, %v", err)
 			}
-			id = int(liid)
 		} else {
 			return 0, fmt.Errorf("personId, %v", err)
 		}
@@ -498,11 +476,8 @@ func booksByPersonId(db DBInterface, id int) ([]int, error) {
 	var bookList []int
 
 	// check valid person id
-	checkPersonSql := `SELECT COUNT(*)
-        FROM people
-        WHERE person_id = ?`
 	var count int
-	if err := db.QueryRow(checkPersonSql, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtPersonIdValid, id).Scan(&count); err != nil {
 		return bookList, fmt.Errorf(
 			"booksByPersonId: Could not look up person ID #%v in database: %v",
 			id,
@@ -513,16 +488,8 @@ func booksByPersonId(db DBInterface, id int) ([]int, error) {
 		return bookList, &InvalidPersonIdError{"booksByPersonId", id}
 	}
 
-	bookAuthorSql := `
-        SELECT book_id
-        FROM book_author
-        WHERE author_id = ?
-        UNION
-        SELECT book_id
-        FROM book_editor
-        WHERE editor_id = ?`
 	var bookId int
-	rows, err := db.Query(bookAuthorSql, id, id)
+	rows, err := stmtQuery(db, stmtBooksByPersonId, id)
 	if err != nil {
 		return bookList, fmt.Errorf(
 			"booksByPersonId: Couldn't retrieve books authored by person ID #%v, %v",
@@ -555,19 +522,11 @@ func publisherId(db DBInterface, publisher string) (int, error) {
 	}
 
 	var id int
-	if err := db.QueryRow("SELECT publisher_id FROM publishers WHERE name = ?",
-		publisher).Scan(&id); err != nil {
+	if err := stmtQueryRow(db, stmtPublisherIdByName, publisher).Scan(&id); err != nil {
 		if err == sql.ErrNoRows {
-			result, err := db.Exec("INSERT INTO publishers (name) VALUES (?)",
-				publisher)
-			if err != nil {
+			if err := stmtQueryRow(db, stmtInsertPublisher, publisher).Scan(&id); err != nil {
 				return 0, fmt.Errorf("publisherId, %v", err)
 			}
-			liid, err := result.LastInsertId()
-			if err != nil {
-				return 0, fmt.Errorf("publisherId, %v", err)
-			}
-			id = int(liid)
 		} else {
 			return 0, fmt.Errorf("publisherId, %v", err)
 		}
@@ -577,11 +536,8 @@ func publisherId(db DBInterface, publisher string) (int, error) {
 
 func publisherName(db DBInterface, id int) (string, error) {
 	// check valid publisher id
-	checkPublisherSql := `SELECT COUNT(*)
-        FROM publishers
-        WHERE publisher_id = ?`
 	var count int
-	if err := db.QueryRow(checkPublisherSql, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtPublisherIdValid, id).Scan(&count); err != nil {
 		return "", fmt.Errorf(
 			"publisherName: Could not look up publisher #%v in database: %v",
 			id,
@@ -593,11 +549,8 @@ func publisherName(db DBInterface, id int) (string, error) {
 	}
 
 	// get publisher name
-	publisherNameSql := `SELECT name
-        FROM publishers
-        WHERE publisher_id = ?`
 	var name string
-	if err := db.QueryRow(publisherNameSql, id).Scan(&name); err != nil {
+	if err := stmtQueryRow(db, stmtPublisherName, id).Scan(&name); err != nil {
 		return "", fmt.Errorf(
 			"publisherName, Could not retrieve publisher #%v name: %v",
 			id,
@@ -611,11 +564,8 @@ func publisherBooks(db DBInterface, id int) ([]int, error) {
 	var bookList []int
 
 	// check valid publisher id
-	checkPublisherSql := `SELECT COUNT(*)
-        FROM publishers
-        WHERE publisher_id = ?`
 	var count int
-	if err := db.QueryRow(checkPublisherSql, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtPublisherIdValid, id).Scan(&count); err != nil {
 		return bookList, fmt.Errorf(
 			"publisherBooks: Could not look up publisher #%v in database: %v",
 			id,
@@ -664,19 +614,11 @@ func seriesId(db DBInterface, series string) (int, error) {
 
 	var id int
 
-	if err := db.QueryRow("SELECT series_id FROM series WHERE series_name = ?",
-		series).Scan(&id); err != nil {
+	if err := stmtQueryRow(db, stmtSeriesIdByName, series).Scan(&id); err != nil {
 		if err == sql.ErrNoRows {
-			result, err := db.Exec("INSERT INTO series (series_name) VALUES (?)",
-				series)
-			if err != nil {
-				return 0, fmt.Errorf("seriesId, %v", err)
-			}
-			liid, err := result.LastInsertId()
-			if err != nil {
+			if err := stmtQueryRow(db, stmtInsertSeries, series).Scan(&id); err != nil {
 				return 0, fmt.Errorf("seriesId, %v", err)
 			}
-			id = int(liid)
 		} else {
 			return 0, fmt.Errorf("seriesId, %v", err)
 		}
@@ -688,11 +630,8 @@ func seriesBooks(db DBInterface, id int) ([]int, error) {
 	var bookList []int
 
 	// check valid series id
-	checkSeriesSql := `SELECT COUNT(*)
-        FROM series
-        WHERE series_id = ?`
 	var count int
-	if err := db.QueryRow(checkSeriesSql, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtSeriesIdValid, id).Scan(&count); err != nil {
 		return bookList, fmt.Errorf(
 			"seriesBooks, Could not look up series ID #%v: %v",
 			id,
@@ -736,11 +675,8 @@ func seriesBooks(db DBInterface, id int) ([]int, error) {
 
 func seriesName(db DBInterface, id int) (string, error) {
 	// check valid series id
-	checkSeriesSql := `SELECT COUNT(*)
-        FROM series
-        WHERE series_id = ?`
 	var count int
-	if err := db.QueryRow(checkSeriesSql, id).Scan(&count); err != nil {
+	if err := stmtQueryRow(db, stmtSeriesIdValid, id).Scan(&count); err != nil {
 		return "", fmt.Errorf(
 			"seriesName, Could not look up series ID #%v: %v",
 			id,
@@ -752,11 +688,8 @@ func seriesName(db DBInterface, id int) (string, error) {
 	}
 
 	// get series name
-	seriesNameSql := `SELECT series_name
-        FROM series
-        WHERE series_id = ?`
 	var name string
-	if err := db.QueryRow(seriesNameSql, id).Scan(&name); err != nil {
+	if err := stmtQueryRow(db, stmtSeriesName, id).Scan(&name); err != nil {
 		return "", fmt.Errorf(
 			"seriesName, Could not retrieve series #%v name: %v",
 			id,
@@ -766,6 +699,44 @@ func seriesName(db DBInterface, id int) (string, error) {
 	return name, nil
 }
 
+func statusId(db DBInterface, status string) (int, error) {
+	if len(status) == 0 {
+		return 0, fmt.Errorf("statusId: Status cannot be empty")
+	}
+
+	var id int
+	if err := stmtQueryRow(db, stmtStatusIdByName, status).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			if err := stmtQueryRow(db, stmtInsertStatus, status).Scan(&id); err != nil {
+				return 0, fmt.Errorf("statusId, %v", err)
+			}
+		} else {
+			return 0, fmt.Errorf("statusId, %v", err)
+		}
+	}
+	return id, nil
+}
+
+func listStatuses(db DBInterface) ([]string, error) {
+	var statuses []string
+	rows, err := db.Query("SELECT status_name FROM status ORDER BY status_name")
+	if err != nil {
+		return statuses, fmt.Errorf("listStatuses, %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("listStatuses, %v", err)
+		}
+		statuses = append(statuses, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listStatuses, rows.Next() error: %v", err)
+	}
+	return statuses, nil
+}
+
 type AddingDuplicateBookError struct {
 	book *Book
 	id   int
@@ -795,20 +766,22 @@ func checkBookInDb(db DBInterface, b *Book) (int, error) {
 	sqlStmt := `
         SELECT books.book_id
         FROM books
-        INNER JOIN book_author
-          ON books.book_id = book_author.book_id
+        INNER JOIN book_contributor
+          ON books.book_id = book_contributor.book_id
         INNER JOIN people
-          ON book_author.author_id = people.person_id
-        WHERE people.name = ?
+          ON book_contributor.person_id = people.person_id
+        WHERE book_contributor.role = 'author'
+          AND people.name = ?
           AND books.title = ?
         UNION
         SELECT books.book_id
         FROM books
-        INNER JOIN book_editor
-          ON books.book_id = book_editor.book_id
+        INNER JOIN book_contributor
+          ON books.book_id = book_contributor.book_id
         INNER JOIN people
-          ON book_editor.editor_id = people.person_id
-        WHERE people.name = ?
+          ON book_contributor.person_id = people.person_id
+        WHERE book_contributor.role = 'editor'
+          AND people.name = ?
           AND books.title = ?
 `
 
@@ -837,26 +810,44 @@ func addBook(db *sql.DB, b *Book) (int, error) {
 		return id, &AddingDuplicateBookError{b, id}
 	}
 
-	// handle people
-	var authorList, editorList []string
-	authorList = nameListFromString(b.author)
-	editorList = nameListFromString(b.editor)
+	isbn, err := parseIsbn(string(b.isbn))
+	if err != nil {
+		return 0, fmt.Errorf("addBook: %w", err)
+	}
 
-	// Create lists of author ids from the author lists
-	var authorIdList, editorIdList []int
-	for _, authorName := range authorList {
-		authorId, err := personId(db, authorName)
-		if err != nil {
-			return 0, fmt.Errorf("addBook, %v", err)
+	// a status given twice, e.g. "Owned, Owned", is only added once
+	var statuses []string
+	for _, status := range b.status {
+		if !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
 		}
-		authorIdList = append(authorIdList, authorId)
 	}
-	for _, editorName := range editorList {
-		editorId, err := personId(db, editorName)
+	b.status = statuses
+
+	lifecycle, err := lifecycleStatuses(db)
+	if err != nil {
+		return 0, fmt.Errorf("addBook: %v", err)
+	}
+	var bookLifecycle []string
+	for _, status := range b.status {
+		if slices.Contains(lifecycle, status) {
+			bookLifecycle = append(bookLifecycle, status)
+		}
+	}
+	if len(bookLifecycle) > 1 {
+		return 0, &StatusTransitionError{"addBook", 0, bookLifecycle[0], bookLifecycle[1]}
+	}
+
+	var statusIdList []int
+	for _, status := range b.status {
+		if len(status) == 0 {
+			return 0, &EmptyStatusError{"addBook", 0}
+		}
+		stId, err := statusId(db, status)
 		if err != nil {
 			return 0, fmt.Errorf("addBook, %v", err)
 		}
-		editorIdList = append(editorIdList, editorId)
+		statusIdList = append(statusIdList, stId)
 	}
 
 	pubId, err := publisherId(db, b.publisher)
@@ -884,12 +875,32 @@ func addBook(db *sql.DB, b *Book) (int, error) {
 		subtitle.String = b.subtitle
 	}
 
+	if b.edition.number < 0 {
+		return 0, &BadRequestError{"addBook",
+			fmt.Sprintf("Edition number cannot be negative, got %v", b.edition.number)}
+	}
 	var edition sql.NullInt64
-	if b.edition == 0 {
+	if b.edition.number == 0 {
 		edition.Valid = false
 	} else {
 		edition.Valid = true
-		edition.Int64 = int64(b.edition)
+		edition.Int64 = int64(b.edition.number)
+	}
+
+	var editionDesc sql.NullString
+	if len(b.edition.description) == 0 {
+		editionDesc.Valid = false
+	} else {
+		editionDesc.Valid = true
+		editionDesc.String = b.edition.description
+	}
+
+	var bookIsbn sql.NullString
+	if len(isbn) == 0 {
+		bookIsbn.Valid = false
+	} else {
+		bookIsbn.Valid = true
+		bookIsbn.String = string(isbn)
 	}
 
 	var purDate sql.NullString
@@ -900,42 +911,47 @@ func addBook(db *sql.DB, b *Book) (int, error) {
 		purDate.String = b.purchased.String()
 	}
 
+	var bookRating sql.NullFloat64
+	if b.rating.rated {
+		bookRating.Valid = true
+		bookRating.Float64 = b.rating.stars()
+	}
+
 	// insert book -- at this point, use a transaction to ensure author/editor
 	// info is included for every book in the database.
-	tx, err := db.Begin()
+	tx, err := beginTx(db)
 	if err != nil {
 		return 0, fmt.Errorf("addBook, Couldn't start sql transaction: %v", err)
 	}
 	defer tx.Rollback()
 
 	var bookId int
-	result, err := tx.Exec(`INSERT INTO books (title, subtitle, year, edition,
-                            publisher_id, isbn, series_id, status,
-                            purchased_date) VALUES (?, ?, ?, ?, ?, ?, ?,
-                            ?, ?)`,
-		b.title, subtitle, b.year, edition, pubId, b.isbn, serId, b.status,
-		purDate)
-	if err != nil {
-		return 0, fmt.Errorf("addBook: %v", err)
-	}
-	liid, err := result.LastInsertId()
+	err = tx.QueryRow(`INSERT INTO books (title, subtitle, year, edition,
+                       edition_description, publisher_id, isbn,
+                       series_id, purchased_date, rating) VALUES (?, ?,
+                       ?, ?, ?, ?, ?, ?, ?, ?)
+                       RETURNING book_id`,
+		b.title, subtitle, b.year, edition, editionDesc, pubId, bookIsbn, serId,
+		purDate, bookRating).Scan(&bookId)
 	if err != nil {
 		return 0, fmt.Errorf("addBook: %v", err)
 	}
-	bookId = int(liid)
 
-	// handle book_author
-	for _, authId := range authorIdList {
-		_, err = tx.Exec("INSERT INTO book_author VALUES (?, ?)", bookId,
-			authId)
-		if err != nil {
+	// handle book_contributor
+	for _, role := range contributorRoles {
+		nameList := nameListFromString(*b.contributors(role))
+		if err := addBookContributors(tx, bookId, role, nameList); err != nil {
 			return 0, fmt.Errorf("addBook: %v", err)
 		}
 	}
 
-	// handle book_editor
-	for _, edId := range editorIdList {
-		_, err = tx.Exec("INSERT INTO book_editor VALUES (?, ?)", bookId, edId)
+	// handle book_status
+	for i, stId := range statusIdList {
+		_, err = tx.Exec("INSERT INTO book_status VALUES (?, ?)", bookId, stId)
+		if err != nil {
+			return 0, fmt.Errorf("addBook: %v", err)
+		}
+		err = recordStatusChange(tx, bookId, "", b.status[i])
 		if err != nil {
 			return 0, fmt.Errorf("addBook: %v", err)
 		}
@@ -949,150 +965,64 @@ func addBook(db *sql.DB, b *Book) (int, error) {
 	return bookId, nil
 }
 
-func updateBookAuthor(db *sql.DB, id int, authorString string) (string, error) {
-	newAuthorsList := nameListFromString(authorString)
-	oldAuthorsList, err := getAuthorsListById(db, id)
-	if err != nil {
-		return "", err
-	}
-
-	var authorsToAdd, authorsToDelete []string
-
-	for _, author := range newAuthorsList {
-		if !slices.Contains(oldAuthorsList, author) {
-			authorsToAdd = append(authorsToAdd, author)
-		}
-	}
-
-	for _, author := range oldAuthorsList {
-		if !slices.Contains(newAuthorsList, author) {
-			authorsToDelete = append(authorsToDelete, author)
-		}
-	}
-
-	// start a transaction to make the edit of authors atomic
-	tx, err := db.Begin()
-	if err != nil {
-		return "", fmt.Errorf("updateBookAuthor, Couldn't start sql transaction: %v", err)
-	}
-	defer tx.Rollback()
-
-	for _, author := range authorsToAdd {
-		personId, personIdErr := personId(tx, author)
-		if personIdErr != nil {
-			return "", fmt.Errorf("updateBookAuthor: %v", personIdErr)
-		}
-
-		_, err := tx.Exec("INSERT INTO book_author (book_id, author_id) VALUES (?, ?)",
-			id, personId)
-		if err != nil {
-			return "", fmt.Errorf("updateBookAuthor: %v", err)
-		}
-	}
-
-	for _, author := range authorsToDelete {
-		personId, personIdErr := personId(tx, author)
-		if personIdErr != nil {
-			return "", fmt.Errorf("updateBookAuthor:, %v", personIdErr)
-		}
-
-		_, err := tx.Exec("DELETE FROM book_author WHERE book_id = ? AND author_id = ?",
-			id, personId)
-		if err != nil {
-			return "", fmt.Errorf("updateBookAuthor: %v", err)
-		}
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return "", fmt.Errorf("updateBookAuthor, issue updating authors: %v", err)
-	}
-
-	updatedAuthorList, err := getAuthorsListById(db, id)
-	if err != nil {
-		return "", fmt.Errorf("updateBookAuthor, Couldn't fetch updated authors: %v", err)
-	}
-	updatedAuthor := formatNameList(updatedAuthorList)
-
-	return updatedAuthor, nil
+func updateBookAuthor(db DBInterface, id int, authorString string) (string, error) {
+	return updateBookContributors(db, id, RoleAuthor, authorString)
 }
 
-func updateBookEditor(db *sql.DB, id int, editorString string) (string, error) {
-	newEditorsList := nameListFromString(editorString)
-	oldEditorsList, err := getEditorsListById(db, id)
-	if err != nil {
-		return "", err
-	}
-
-	var editorsToAdd, editorsToDelete []string
+func updateBookEditor(db DBInterface, id int, editorString string) (string, error) {
+	return updateBookContributors(db, id, RoleEditor, editorString)
+}
 
-	for _, editor := range newEditorsList {
-		if !slices.Contains(oldEditorsList, editor) {
-			editorsToAdd = append(editorsToAdd, editor)
-		}
-	}
+// DuplicateNameError is returned when a person, publisher or series would be
+// given a name which another already has.
+type DuplicateNameError struct {
+	CallFunc string
+	Kind     string
+	Name     string
+	Id       int
+}
 
-	for _, editor := range oldEditorsList {
-		if !slices.Contains(newEditorsList, editor) {
-			editorsToDelete = append(editorsToDelete, editor)
-		}
-	}
+func (e *DuplicateNameError) Error() string {
+	return fmt.Sprintf("%v: %v \"%v\" already exists, id #%v", e.CallFunc, e.Kind,
+		e.Name, e.Id)
+}
 
-	// start a transaction to make the edit of editors atomic
-	tx, err := db.Begin()
+// otherNameId gives the id of a record other than id which has the given name,
+// looked up with the registered query key, or 0 if there is none.
+func otherNameId(db DBInterface, key stmtKey, name string, id int) (int, error) {
+	rows, err := stmtQuery(db, key, name)
 	if err != nil {
-		return "", fmt.Errorf("updateBookEditor, Couldn't start sql transaction: %v", err)
-	}
-	defer tx.Rollback()
-
-	for _, editor := range editorsToAdd {
-		personId, personIdErr := personId(tx, editor)
-		if personIdErr != nil {
-			return "", fmt.Errorf("updateBookEditor: %v", personIdErr)
-		}
-
-		_, err := tx.Exec("INSERT INTO book_editor (book_id, editor_id) VALUES (?, ?)",
-			id, personId)
-		if err != nil {
-			return "", fmt.Errorf("updateBookEditor: %v", err)
-		}
+		return 0, err
 	}
-
-	for _, editor := range editorsToDelete {
-		personId, personIdErr := personId(tx, editor)
-		if personIdErr != nil {
-			return "", fmt.Errorf("updateBookEditor:, %v", personIdErr)
+	defer rows.Close()
+	for rows.Next() {
+		var other int
+		if err := rows.Scan(&other); err != nil {
+			return 0, err
 		}
-
-		_, err := tx.Exec("DELETE FROM book_editor WHERE book_id = ? AND editor_id = ?",
-			id, personId)
-		if err != nil {
-			return "", fmt.Errorf("updateBookEditor: %v", err)
+		if other != id {
+			return other, nil
 		}
 	}
+	return 0, rows.Err()
+}
 
-	err = tx.Commit()
+func updatePersonName(db DBInterface, id int, newName string) (string, error) {
+	other, err := otherNameId(db, stmtPersonIdByName, newName, id)
 	if err != nil {
-		return "", fmt.Errorf("updateBookEditor, issue updating editors: %v", err)
+		return "", fmt.Errorf("updatePersonName, Couldn't check for duplicate name: %v", err)
 	}
-
-	updatedEditorList, err := getEditorsListById(db, id)
-	if err != nil {
-		return "", fmt.Errorf("updateBookEditor, Couldn't fetch updated editors: %v", err)
+	if other != 0 {
+		return "", &DuplicateNameError{"updatePersonName", "Person", newName, other}
 	}
-	updatedEditor := formatNameList(updatedEditorList)
-
-	return updatedEditor, nil
-}
 
-func updatePersonName(db DBInterface, id int, newName string) (string, error) {
 	sqlStmt := `
       UPDATE people
       SET name = ?
       WHERE person_id = ?
       `
 
-	_, err := db.Exec(sqlStmt, newName, id)
+	_, err = db.Exec(sqlStmt, newName, id)
 	if err != nil {
 		return "", fmt.Errorf("updatePersonName, Couldn't update person #%v to %v: %v",
 			id, newName, err)
@@ -1214,37 +1144,52 @@ func updateBookYear(db DBInterface, id int, year int) (int, error) {
 	return updatedYear, nil
 }
 
-func updateBookEdition(db DBInterface, id int, edition int) (int, error) {
+func updateBookEdition(db DBInterface, id int, edition Edition) (Edition, error) {
+	if edition.number < 0 {
+		return Edition{}, fmt.Errorf("updateBookEdition: Edition number cannot be negative, got %v",
+			edition.number)
+	}
+
 	var bookEdition sql.NullInt64
-	if edition == 0 {
+	if edition.number == 0 {
 		bookEdition.Valid = false
 	} else {
 		bookEdition.Valid = true
-		bookEdition.Int64 = int64(edition)
+		bookEdition.Int64 = int64(edition.number)
+	}
+
+	var bookEditionDesc sql.NullString
+	if len(edition.description) == 0 {
+		bookEditionDesc.Valid = false
+	} else {
+		bookEditionDesc.Valid = true
+		bookEditionDesc.String = edition.description
 	}
 
 	sqlStmt := `
         UPDATE books
-        SET edition = ?
+        SET edition = ?, edition_description = ?
         WHERE book_id = ?
     `
 
-	_, err := db.Exec(sqlStmt, bookEdition, id)
+	_, err := db.Exec(sqlStmt, bookEdition, bookEditionDesc, id)
 	if err != nil {
-		return 0, fmt.Errorf("updateBookEdition, Couldn't update book #%v edition to %v: %v", id, bookEdition, err)
+		return Edition{}, fmt.Errorf("updateBookEdition, Couldn't update book #%v edition to %v: %v", id, edition, err)
 	}
 
 	var updatedEdition sql.NullInt64
-	if err := db.QueryRow("SELECT edition FROM books WHERE book_id = ?",
-		id).Scan(&updatedEdition); err != nil {
-		return 0, fmt.Errorf("updateBookEdition, Couldn't retrieve updated edition value: %v", err)
+	var updatedEditionDesc sql.NullString
+	if err := db.QueryRow("SELECT edition, edition_description FROM books WHERE book_id = ?",
+		id).Scan(&updatedEdition, &updatedEditionDesc); err != nil {
+		return Edition{}, fmt.Errorf("updateBookEdition, Couldn't retrieve updated edition value: %v", err)
 	}
 
-	if updatedEdition != bookEdition {
-		return 0, fmt.Errorf("updateBookEdition, Updated edition %v is not the required edition %v", updatedEdition, bookEdition)
+	if updatedEdition != bookEdition || updatedEditionDesc != bookEditionDesc {
+		return Edition{}, fmt.Errorf("updateBookEdition, Updated edition %v (%v) is not the required edition %v",
+			updatedEdition, updatedEditionDesc, edition)
 	}
 
-	return int(updatedEdition.Int64), nil
+	return Edition{int(updatedEdition.Int64), updatedEditionDesc.String}, nil
 }
 
 type InvalidPublisherIdError struct {
@@ -1363,16 +1308,13 @@ func updatePublisherName(db DBInterface, id int, name string) (string, error) {
 	}
 
 	// check if new name is already a publisher
-	sqlCheckPublisher := "SELECT name FROM publishers WHERE name = ?"
-	rows, err := db.Query(sqlCheckPublisher, name)
+	other, err := otherNameId(db, stmtPublisherIdByName, name, id)
 	if err != nil {
 		return "", fmt.Errorf("Couldn't check database for duplicate name: %v", err)
 	}
-	defer rows.Close()
-	if rows.Next() {
-		return "", fmt.Errorf("updatePublisherName: Publisher %v already exists", name)
+	if other != 0 {
+		return "", &DuplicateNameError{"updatePublisherName", "Publisher", name, other}
 	}
-	rows.Close()
 
 	sqlStmt := `
         UPDATE publishers
@@ -1399,31 +1341,120 @@ func updatePublisherName(db DBInterface, id int, name string) (string, error) {
 	return updatedName, nil
 }
 
-func updateBookIsbn(db DBInterface, id int, isbn string) (string, error) {
+// publisherLocation gives the place a publisher is based, or "" if it isn't
+// known.
+func publisherLocation(db DBInterface, id int) (string, error) {
+	var location sql.NullString
+	err := db.QueryRow("SELECT location FROM publishers WHERE publisher_id = ?",
+		id).Scan(&location)
+	if err == sql.ErrNoRows {
+		return "", &InvalidPublisherIdError{"publisherLocation", id}
+	}
+	if err != nil {
+		return "", fmt.Errorf("publisherLocation, Couldn't get location of publisher #%v: %v",
+			id, err)
+	}
+	return location.String, nil
+}
+
+// updatePublisherLocation sets the place a publisher is based. An empty
+// location removes it.
+func updatePublisherLocation(db DBInterface, id int, location string) error {
+	location = strings.TrimSpace(location)
+	value := sql.NullString{String: location, Valid: len(location) > 0}
+	result, err := db.Exec("UPDATE publishers SET location = ? WHERE publisher_id = ?",
+		value, id)
+	if err != nil {
+		return fmt.Errorf("updatePublisherLocation, Couldn't update publisher #%v: %v", id, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return &InvalidPublisherIdError{"updatePublisherLocation", id}
+	}
+	return nil
+}
+
+func updateBookIsbn(db DBInterface, id int, isbn string) (ISBN, error) {
+	newIsbn, err := parseIsbn(isbn)
+	if err != nil {
+		return "", fmt.Errorf("updateBookIsbn: %w", err)
+	}
+
+	var bookIsbn sql.NullString
+	if len(newIsbn) == 0 {
+		bookIsbn.Valid = false
+	} else {
+		bookIsbn.Valid = true
+		bookIsbn.String = string(newIsbn)
+	}
+
 	sqlStmt := `
         UPDATE books
         SET isbn = ?
         WHERE book_id = ?
     `
 
-	_, err := db.Exec(sqlStmt, isbn, id)
+	_, err = db.Exec(sqlStmt, bookIsbn, id)
 	if err != nil {
 		return "", fmt.Errorf("updateBookIsbn, Couldn't update isbn for book #%v: %v",
 			id, err)
 	}
 
-	var updatedIsbn string
+	var updatedIsbn sql.NullString
 	if err := db.QueryRow("SELECT isbn FROM books WHERE book_id = ?",
 		id).Scan(&updatedIsbn); err != nil {
 		return "", fmt.Errorf("updateBookIsbn, Couldn't retrieve updated value: %v", err)
 	}
 
-	if updatedIsbn != isbn {
+	if updatedIsbn != bookIsbn {
 		return "", fmt.Errorf("updateBookIsbn, Updated isbn %v does not match requested isbn %v",
-			updatedIsbn, isbn)
+			updatedIsbn.String, newIsbn)
 	}
 
-	return updatedIsbn, nil
+	return ISBN(updatedIsbn.String), nil
+}
+
+// StoredIsbnError is a book whose ISBN in the database isn't valid.
+type StoredIsbnError struct {
+	CallFunc string
+	BookId   int
+	Isbn     string
+	Reason   string
+}
+
+func (e *StoredIsbnError) Error() string {
+	return fmt.Sprintf("%v: Book #%v has invalid ISBN \"%v\", %v", e.CallFunc, e.BookId,
+		e.Isbn, e.Reason)
+}
+
+// checkStoredIsbns returns an error for each book whose stored ISBN isn't
+// valid, such as one entered with the wrong check digit before ISBNs were
+// validated. These are kept as they were entered rather than guessed at, so
+// that they can be checked against the book and corrected by hand.
+func checkStoredIsbns(db DBInterface) ([]*StoredIsbnError, error) {
+	rows, err := db.Query(`SELECT book_id, isbn FROM books
+                           WHERE isbn IS NOT NULL ORDER BY book_id`)
+	if err != nil {
+		return nil, fmt.Errorf("checkStoredIsbns, Couldn't query isbns: %v", err)
+	}
+	defer rows.Close()
+
+	var problems []*StoredIsbnError
+	for rows.Next() {
+		var id int
+		var isbn string
+		if err := rows.Scan(&id, &isbn); err != nil {
+			return nil, fmt.Errorf("checkStoredIsbns, Couldn't read isbn: %v", err)
+		}
+		var isbnErr *InvalidIsbnError
+		if _, err := parseIsbn(isbn); errors.As(err, &isbnErr) {
+			problems = append(problems,
+				&StoredIsbnError{"checkStoredIsbns", id, isbn, isbnErr.Reason})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("checkStoredIsbns, Couldn't read isbns: %v", err)
+	}
+	return problems, nil
 }
 
 type InvalidSeriesIdError struct {
@@ -1555,13 +1586,21 @@ func updateSeriesName(db DBInterface, id int, name string) (string, error) {
 		return origName, fmt.Errorf("updateSeriesName: Series cannot have empty name. Perhaps you want to delete the series?")
 	}
 
+	other, err := otherNameId(db, stmtSeriesIdByName, name, id)
+	if err != nil {
+		return origName, fmt.Errorf("updateSeriesName, Couldn't check for duplicate name: %v", err)
+	}
+	if other != 0 {
+		return origName, &DuplicateNameError{"updateSeriesName", "Series", name, other}
+	}
+
 	sqlStmt := `
         UPDATE series
         SET series_name = ?
         WHERE series_id = ?
     `
 
-	_, err := db.Exec(sqlStmt, name, id)
+	_, err = db.Exec(sqlStmt, name, id)
 	if err != nil {
 		return origName, fmt.Errorf("updateSeriesName, Could not update series name: %v", err)
 	}
@@ -1580,34 +1619,215 @@ func updateSeriesName(db DBInterface, id int, name string) (string, error) {
 	return updatedName, nil
 }
 
-func updateBookStatus(db DBInterface, id int, status string) (string, error) {
+type EmptyStatusError struct {
+	CallFunc string
+	Id       int
+}
+
+func (e *EmptyStatusError) Error() string {
+	return fmt.Sprintf("%v: Book status cannot be empty. Book #%v", e.CallFunc, e.Id)
+}
+
+// updateBookStatus sets the full list of statuses for a book. A change of
+// lifecycle status (see transitionBookStatus) must follow an allowed
+// transition, and is recorded in the history as a single change.
+func updateBookStatus(db DBInterface, id int, statusList []string) ([]string, error) {
+	if len(statusList) == 0 || slices.Contains(statusList, "") {
+		return []string{}, &EmptyStatusError{"updateBookStatus", id}
+	}
+
+	oldStatusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, err
+	}
+
+	var statusToAdd, statusToDelete []string
+
+	for _, status := range statusList {
+		if !slices.Contains(oldStatusList, status) {
+			statusToAdd = append(statusToAdd, status)
+		}
+	}
+
+	for _, status := range oldStatusList {
+		if !slices.Contains(statusList, status) {
+			statusToDelete = append(statusToDelete, status)
+		}
+	}
+
+	lifecycle, err := lifecycleStatuses(db)
+	if err != nil {
+		return oldStatusList, fmt.Errorf("updateBookStatus: %w", err)
+	}
+	var newLifecycle []string
+	for _, status := range statusList {
+		if slices.Contains(lifecycle, status) {
+			newLifecycle = append(newLifecycle, status)
+		}
+	}
+	if len(newLifecycle) > 1 {
+		return oldStatusList, &StatusTransitionError{"updateBookStatus", id,
+			newLifecycle[0], newLifecycle[1]}
+	}
+
+	// A change from one lifecycle status to another is a transition, rather
+	// than removing one status and adding another
+	var moveFrom, moveTo string
+	oldLifecycleStatus := bookLifecycleStatus(oldStatusList, lifecycle)
+	if len(newLifecycle) == 0 && len(oldLifecycleStatus) != 0 {
+		return oldStatusList, &StatusTransitionError{"updateBookStatus", id,
+			oldLifecycleStatus, ""}
+	}
+	if len(newLifecycle) == 1 && len(oldLifecycleStatus) != 0 &&
+		newLifecycle[0] != oldLifecycleStatus {
+		moveFrom, moveTo = oldLifecycleStatus, newLifecycle[0]
+		allowed, err := statusTransitionAllowed(db, moveFrom, moveTo)
+		if err != nil {
+			return oldStatusList, fmt.Errorf("updateBookStatus: %w", err)
+		}
+		if !allowed {
+			return oldStatusList, &StatusTransitionError{"updateBookStatus", id,
+				moveFrom, moveTo}
+		}
+		statusToAdd = slices.DeleteFunc(statusToAdd,
+			func(s string) bool { return s == moveTo })
+		statusToDelete = slices.DeleteFunc(statusToDelete,
+			func(s string) bool { return s == moveFrom })
+	}
+
+	// make the edit of statuses atomic
+	err = withTx(db, func(tx DBInterface) error {
+		if len(moveTo) != 0 {
+			if err := moveBookStatus(tx, id, moveFrom, moveTo); err != nil {
+				return err
+			}
+		}
+
+		for _, status := range statusToDelete {
+			if _, err := removeBookStatus(tx, id, status); err != nil {
+				return err
+			}
+		}
+
+		for _, status := range statusToAdd {
+			if _, err := addBookStatus(tx, id, status); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []string{}, fmt.Errorf("updateBookStatus, issue updating status: %w", err)
+	}
+
+	updatedStatusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf("updateBookStatus, Couldn't fetch updated status: %v", err)
+	}
+
+	return updatedStatusList, nil
+}
+
+// addBookStatus adds a status to a book. A book can only be given a
+// lifecycle status if it doesn't already have one; use transitionBookStatus to
+// move between lifecycle statuses.
+func addBookStatus(db DBInterface, id int, status string) ([]string, error) {
 	if len(status) == 0 {
-		return "", fmt.Errorf("updateBookStatus: Book status cannot be empty.")
+		return []string{}, &EmptyStatusError{"addBookStatus", id}
+	}
+
+	statusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf("addBookStatus: %w", err)
+	}
+	if slices.Contains(statusList, status) {
+		return statusList, nil
+	}
+
+	lifecycle, err := lifecycleStatuses(db)
+	if err != nil {
+		return statusList, fmt.Errorf("addBookStatus: %w", err)
+	}
+	if slices.Contains(lifecycle, status) {
+		current := bookLifecycleStatus(statusList, lifecycle)
+		if len(current) != 0 {
+			return statusList, &StatusTransitionError{"addBookStatus", id, current, status}
+		}
+	}
+
+	stId, err := statusId(db, status)
+	if err != nil {
+		return statusList, fmt.Errorf("addBookStatus: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO book_status (book_id, status_id) VALUES (?, ?)",
+		id, stId)
+	if err != nil {
+		return statusList, fmt.Errorf("addBookStatus, Couldn't add status %v to book #%v: %v",
+			status, id, err)
+	}
+
+	if err := recordStatusChange(db, id, "", status); err != nil {
+		return statusList, fmt.Errorf("addBookStatus: %w", err)
+	}
+
+	updatedStatusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf("addBookStatus, Couldn't fetch updated status: %v", err)
+	}
+	if !slices.Contains(updatedStatusList, status) {
+		return updatedStatusList, fmt.Errorf("addBookStatus: Status %v not added to book #%v",
+			status, id)
+	}
+
+	return updatedStatusList, nil
+}
+
+func removeBookStatus(db DBInterface, id int, status string) ([]string, error) {
+	statusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf("removeBookStatus: %w", err)
+	}
+	if !slices.Contains(statusList, status) {
+		return statusList, fmt.Errorf("removeBookStatus: Book #%v does not have status %v",
+			id, status)
+	}
+
+	// a book only leaves a lifecycle status by moving to another
+	lifecycle, err := lifecycleStatuses(db)
+	if err != nil {
+		return statusList, fmt.Errorf("removeBookStatus: %w", err)
+	}
+	if slices.Contains(lifecycle, status) {
+		return statusList, &StatusTransitionError{"removeBookStatus", id, status, ""}
 	}
 
 	sqlStmt := `
-        UPDATE books
-        SET status = ?
+        DELETE FROM book_status
         WHERE book_id = ?
+          AND status_id = (SELECT status_id FROM status WHERE status_name = ?)
     `
 
-	_, err := db.Exec(sqlStmt, status, id)
+	_, err = db.Exec(sqlStmt, id, status)
 	if err != nil {
-		return "", fmt.Errorf("updateBookStatus, Cannot modify book status: %v", err)
+		return statusList, fmt.Errorf("removeBookStatus, Couldn't remove status %v from book #%v: %v",
+			status, id, err)
 	}
 
-	var updatedStatus string
-	if err := db.QueryRow("SELECT status FROM books WHERE book_id = ?",
-		id).Scan(&updatedStatus); err != nil {
-		return "", fmt.Errorf("updateBookStatus, Could not retrieve updated value: %v", err)
+	if err := recordStatusChange(db, id, status, ""); err != nil {
+		return statusList, fmt.Errorf("removeBookStatus: %w", err)
 	}
 
-	if updatedStatus != status {
-		return "", fmt.Errorf("updateBookStatus: updated status %v is not requested status %v",
-			updatedStatus, status)
+	updatedStatusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf("removeBookStatus, Couldn't fetch updated status: %v", err)
+	}
+	if slices.Contains(updatedStatusList, status) {
+		return updatedStatusList, fmt.Errorf("removeBookStatus: Status %v not removed from book #%v",
+			status, id)
 	}
 
-	return updatedStatus, nil
+	return updatedStatusList, nil
 }
 
 func updateBookPurchaseDate(db DBInterface, id int, date PurchasedDate) (PurchasedDate, error) {
@@ -1649,47 +1869,194 @@ func updateBookPurchaseDate(db DBInterface, id int, date PurchasedDate) (Purchas
 	return returnDate, nil
 }
 
+func updateBookRating(db DBInterface, id int, rating Rating) (Rating, error) {
+	if rating.rated {
+		if _, err := newRating(rating.stars()); err != nil {
+			return Rating{}, fmt.Errorf("updateBookRating: %w", err)
+		}
+	}
+
+	var bookRating sql.NullFloat64
+	if rating.rated {
+		bookRating.Valid = true
+		bookRating.Float64 = rating.stars()
+	} else {
+		bookRating.Valid = false
+	}
+
+	sqlStmt := `
+        UPDATE books
+        SET rating = ?
+        WHERE book_id = ?
+    `
+
+	result, err := db.Exec(sqlStmt, bookRating, id)
+	if err != nil {
+		return Rating{}, fmt.Errorf("updateBookRating, Couldn't update book #%v rating to %v: %v", id, rating, err)
+	}
+	if changed, err := result.RowsAffected(); err == nil && changed == 0 {
+		return Rating{}, &InvalidBookIdError{"updateBookRating", id}
+	}
+
+	var updatedRating sql.NullFloat64
+	if err := db.QueryRow("SELECT rating FROM books WHERE book_id = ?",
+		id).Scan(&updatedRating); err != nil {
+		return Rating{}, fmt.Errorf("updateBookRating, Couldn't retrieve updated rating value: %v", err)
+	}
+	if updatedRating != bookRating {
+		return Rating{}, fmt.Errorf("updateBookRating, Updated rating %v is not the required rating %v",
+			updatedRating.Float64, rating)
+	}
+
+	if !updatedRating.Valid {
+		return Rating{}, nil
+	}
+	return newRating(updatedRating.Float64)
+}
+
+// DeletionOrphans are the people, publisher and series which deleteBook
+// removes along with a book, as the book is the only one they have. Publisher
+// and series are empty if they are kept.
+type DeletionOrphans struct {
+	people    []string
+	publisher string
+	series    string
+}
+
+func (o DeletionOrphans) isEmpty() bool {
+	return len(o.people) == 0 && len(o.publisher) == 0 && len(o.series) == 0
+}
+
+// onlyBook reports whether a list of books is just the given book.
+func onlyBook(books []int, id int) bool {
+	return len(books) == 1 && books[0] == id
+}
+
+// deletionOrphans gives what deleteBook would remove along with book #id,
+// without changing anything, e.g. so that a deletion can be confirmed first.
+func deletionOrphans(db DBInterface, id int) (DeletionOrphans, error) {
+	book, err := getBookById(db, id)
+	if err != nil {
+		return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %w", err)
+	}
+
+	var orphans DeletionOrphans
+	for _, role := range contributorRoles {
+		for _, p := range nameListFromString(*book.contributors(role)) {
+			if slices.Contains(orphans.people, p) {
+				continue
+			}
+			pid, err := personId(db, p)
+			if err != nil {
+				return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %v", err)
+			}
+			books, err := booksByPersonId(db, pid)
+			if err != nil {
+				return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %v", err)
+			}
+			if onlyBook(books, id) {
+				orphans.people = append(orphans.people, p)
+			}
+		}
+	}
+
+	pubId, err := publisherId(db, book.publisher)
+	if err != nil {
+		return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %v", err)
+	}
+	books, err := publisherBooks(db, pubId)
+	if err != nil {
+		return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %v", err)
+	}
+	if onlyBook(books, id) {
+		orphans.publisher = book.publisher
+	}
+
+	if book.series != "" {
+		serId, err := seriesId(db, book.series)
+		if err != nil {
+			return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %v", err)
+		}
+		books, err := seriesBooks(db, serId)
+		if err != nil {
+			return DeletionOrphans{}, fmt.Errorf("deletionOrphans: %v", err)
+		}
+		if onlyBook(books, id) {
+			orphans.series = book.series
+		}
+	}
+
+	return orphans, nil
+}
+
 func deleteBook(db *sql.DB, id int) error {
 	book, err := getBookById(db, id)
 	if err != nil {
 		return fmt.Errorf("deleteBook: %w", err)
 	}
 
-	authorList := nameListFromString(book.author)
-	editorList := nameListFromString(book.editor)
 	var peopleList []string
-	for _, p := range authorList {
-		peopleList = append(peopleList, p)
-	}
-	for _, p := range editorList {
-		peopleList = append(peopleList, p)
+	for _, role := range contributorRoles {
+		for _, p := range nameListFromString(*book.contributors(role)) {
+			if !slices.Contains(peopleList, p) {
+				peopleList = append(peopleList, p)
+			}
+		}
 	}
 
 	// use transaction to ensure removal of authors/editors and book is atomic
-	tx, err := db.Begin()
+	tx, err := beginTx(db)
 	if err != nil {
 		return fmt.Errorf("deleteBook: Couldn't start sql transaction: %v", err)
 	}
 	defer tx.Rollback()
 
-	authorDeletion := "DELETE FROM book_author WHERE book_id = ?"
-	editorDeletion := "DELETE FROM book_editor WHERE book_id = ?"
+	contributorDeletion := "DELETE FROM book_contributor WHERE book_id = ?"
+	statusDeletion := "DELETE FROM book_status WHERE book_id = ?"
+	historyDeletion := "DELETE FROM status_history WHERE book_id = ?"
+	noteDeletion := "DELETE FROM book_note WHERE book_id = ?"
+	categoryDeletion := "DELETE FROM book_category WHERE book_id = ?"
 	bookDeletion := "DELETE FROM books       WHERE book_id = ?"
 
-	// Remove author-book association
-	_, err = tx.Exec(authorDeletion, id)
+	// Remove contributor-book associations
+	_, err = tx.Exec(contributorDeletion, id)
 	if err != nil {
 		return fmt.Errorf(
-			"deleteBook: Problem removing book from book_author table: %v",
+			"deleteBook: Problem removing book from book_contributor table: %v",
 			err,
 		)
 	}
 
-	// Remove editor-book association
-	_, err = tx.Exec(editorDeletion, id)
+	// Remove book's statuses and their history
+	_, err = tx.Exec(statusDeletion, id)
 	if err != nil {
 		return fmt.Errorf(
-			"deleteBook: Problem removing book from book_editor table: %v",
+			"deleteBook: Problem removing book from book_status table: %v",
+			err,
+		)
+	}
+	_, err = tx.Exec(historyDeletion, id)
+	if err != nil {
+		return fmt.Errorf(
+			"deleteBook: Problem removing book from status_history table: %v",
+			err,
+		)
+	}
+
+	// Remove book's notes
+	_, err = tx.Exec(noteDeletion, id)
+	if err != nil {
+		return fmt.Errorf(
+			"deleteBook: Problem removing book from book_note table: %v",
+			err,
+		)
+	}
+
+	// Remove book from its categories
+	_, err = tx.Exec(categoryDeletion, id)
+	if err != nil {
+		return fmt.Errorf(
+			"deleteBook: Problem removing book from book_category table: %v",
 			err,
 		)
 	}
@@ -1943,45 +2310,5 @@ func deleteSeries(db DBInterface, id int) error {
 }
 
 func main() {
-	// set up database connection
-	db, err := sql.Open("sqlite3", "../db/books.sqlite")
-	if err != nil {
-		log.Fatal(err)
-	}
-	pingErr := db.Ping()
-	if pingErr != nil {
-		log.Fatal(pingErr)
-	}
-	fmt.Println("Connected to db!")
-	defer db.Close()
-
-	// Count how many books are in library (a single line query)
-	var volumes int
-	volumes, err = countAllBooks(db)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("Found %v books in library:\n", volumes)
-
-	// Count how many books are owned and how many wanted in library
-	var owned, wanted int
-	owned, err = countBooksByStatus(db, "Owned")
-	if err != nil {
-		log.Fatal(err)
-	}
-	wanted, err = countBooksByStatus(db, "Want")
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("%v are owned, %v are wanted. Combined makes %v.\n", owned,
-		wanted, owned+wanted)
-
-	fmt.Println("\nNow get books using functions:")
-
-	_, err = printBookList(db)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	fmt.Println("End of library")
+	os.Exit(runCli(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
@@ -3,15 +3,32 @@ package main
 import (
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var testBackend = flag.String("backend", "sqlite",
+	"database to run the tests against, sqlite or postgres")
+
+// Tests open the test database with testDriver and testDatabase, which are
+// set by TestMain for the backend chosen with -backend, and check testDialect
+// where the backends give different results.
+var (
+	testDriver   = "sqlite3"
+	testDatabase = "testdb.sqlite"
+	testDialect  = SQLite
+)
+
 func TestMain(m *testing.M) {
+	flag.Parse()
 	code, err := setupRunTeardown(m)
 	if err != nil {
 		fmt.Println(err)
@@ -20,21 +37,54 @@ func TestMain(m *testing.M) {
 }
 
 func setupRunTeardown(m *testing.M) (code int, err error) {
-	err = setupTestDatabase()
+	setup, teardown := setupTestDatabase, teardownTestDatabase
+	switch *testBackend {
+	case "sqlite":
+	case "postgres":
+		if reason := postgresUnavailable(); len(reason) != 0 {
+			if len(os.Getenv(requirePostgresEnv)) != 0 {
+				return 1, fmt.Errorf("Can't run PostgreSQL tests, %v", reason)
+			}
+			fmt.Printf("Skipping PostgreSQL tests, %v\n", reason)
+			return 0, nil
+		}
+		setup, teardown = setupPostgresTestDatabase, teardownPostgresTestDatabase
+	default:
+		return 2, fmt.Errorf("Unknown backend %q, must be sqlite or postgres", *testBackend)
+	}
+	err = setup()
 	if err != nil {
 		return 1, err
 	}
 
 	defer func() {
-		if tempErr := teardownTestDatabase(); tempErr != nil {
+		if tempErr := teardown(); tempErr != nil {
+			err = tempErr
+		}
+		if tempErr := removeBenchmarkLibrary(); tempErr != nil {
 			err = tempErr
 		}
 	}()
 
-	return m.Run(), err
+	code = m.Run()
+	if len(postgresSkipped) != 0 {
+		fmt.Printf("Skipped PostgreSQL tests, %v\n", postgresSkipped)
+	}
+	return code, err
 }
 
 func setupTestDatabase() (err error) {
+	// the sqlite3 command has FTS5, but the driver only given the sqlite_fts5 tag
+	db, err := sql.Open(testDriver, ":memory:")
+	if err != nil {
+		return fmt.Errorf("setupTestDatabase, couldn't open SQLite: %v", err)
+	}
+	defer db.Close()
+	if err := checkFts5(db); err != nil {
+		return fmt.Errorf("setupTestDatabase: %w Run the tests with go test -tags sqlite_fts5.",
+			err)
+	}
+
 	cmd := exec.Command("sqlite3", "testdb.sqlite", "-init",
 		"../db/init_test_database.sql", ".quit")
 	if err := cmd.Run(); err != nil {
@@ -65,10 +115,10 @@ func makeTestBook() *Book {
 	itts.author = "Karen H. Jobes and Moisés Silva"
 	itts.title = "Invitation to the Septuagint"
 	itts.year = 2015
-	itts.edition = 2
+	itts.edition = Edition{number: 2}
 	itts.publisher = "Baker Academic"
-	itts.isbn = "978-0-8010-3649-1"
-	itts.status = "Owned"
+	itts.isbn = "9780801036491"
+	itts.status = []string{"Owned"}
 
 	var ittspd PurchasedDate
 	ittspd.setDate("December 2021")
@@ -78,7 +128,7 @@ func makeTestBook() *Book {
 }
 
 func TestPingDatabase(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -91,7 +141,7 @@ func TestPingDatabase(t *testing.T) {
 }
 
 func TestDatabaseQuery(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -208,6 +258,21 @@ func TestPurchasedDateInvalidFormat(t *testing.T) {
 func TestBookStringMethod(t *testing.T) {
 	b := *makeTestBook()
 
+	expected := "Karen H. Jobes and Moisés Silva, Invitation to the Septuagint, 2nd edition (2015), ISBN 978-0-8010-3649-1 [Owned]"
+
+	bkStr := b.String()
+
+	if bkStr != expected {
+		t.Errorf("Wrong value returned by String method on Book: expected %v, got %v",
+			expected, bkStr)
+	}
+}
+
+func TestBookStringMethodNoIsbn(t *testing.T) {
+	b := *makeTestBook()
+	b.isbn = ""
+	b.edition = Edition{}
+
 	expected := "Karen H. Jobes and Moisés Silva, Invitation to the Septuagint (2015) [Owned]"
 
 	bkStr := b.String()
@@ -243,7 +308,7 @@ func TestBookFullTitle(t *testing.T) {
 }
 
 func TestGetListOfBookIDs(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -508,7 +573,7 @@ func TestNameListFromStringQuadruple(t *testing.T) {
 }
 
 func TestGetAuthorsListById(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -542,7 +607,7 @@ func TestGetAuthorsListById(t *testing.T) {
 }
 
 func TestGetAuthorsListByIdEmpty(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -576,7 +641,7 @@ func TestGetAuthorsListByIdEmpty(t *testing.T) {
 }
 
 func TestGetAuthorsListByIdInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -597,7 +662,7 @@ func TestGetAuthorsListByIdInvalidId(t *testing.T) {
 }
 
 func TestGetEditorsListById(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -631,7 +696,7 @@ func TestGetEditorsListById(t *testing.T) {
 }
 
 func TestGetEditorsListByIdEmpty(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -665,7 +730,7 @@ func TestGetEditorsListByIdEmpty(t *testing.T) {
 }
 
 func TestGetEditorsListByIdInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -686,7 +751,7 @@ func TestGetEditorsListByIdInvalidId(t *testing.T) {
 }
 
 func TestBookIDValid(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -704,7 +769,7 @@ func TestBookIDValid(t *testing.T) {
 }
 
 func TestBookIDValidInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -722,7 +787,7 @@ func TestBookIDValidInvalidId(t *testing.T) {
 }
 
 func TestGetBookById(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -739,8 +804,8 @@ func TestGetBookById(t *testing.T) {
 		title:     "Introduction to the Old Testament",
 		year:      1969,
 		publisher: "IVP",
-		isbn:      "0-85111-723-6",
-		status:    "Owned",
+		isbn:      "9780851117232",
+		status:    []string{"Owned"},
 		purchased: purDate,
 	}
 
@@ -750,7 +815,7 @@ func TestGetBookById(t *testing.T) {
 	if err != nil {
 		t.Errorf("getBookById returned error: %v", err)
 	}
-	if returned != expected {
+	if !reflect.DeepEqual(returned, expected) {
 		t.Errorf(
 			"getBookById returned unexpected book. Expected %v, got %v.",
 			expected,
@@ -760,7 +825,7 @@ func TestGetBookById(t *testing.T) {
 }
 
 func TestGetBookByIdInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -781,7 +846,7 @@ func TestGetBookByIdInvalidId(t *testing.T) {
 		}
 	}
 	var expected = Book{}
-	if returned != expected {
+	if !reflect.DeepEqual(returned, expected) {
 		t.Errorf(
 			"getBookById returned unexpected value for invalid id #%v:\n"+
 				"Expected %v, got %v",
@@ -794,7 +859,7 @@ func TestGetBookByIdInvalidId(t *testing.T) {
 }
 
 func TestPersonName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -822,7 +887,7 @@ func TestPersonName(t *testing.T) {
 }
 
 func TestPersonNameInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -858,7 +923,7 @@ func TestPersonNameInvalidId(t *testing.T) {
 }
 
 func TestPersonId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -883,7 +948,7 @@ func TestPersonId(t *testing.T) {
 }
 
 func TestPersonIdNewPerson(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -917,7 +982,7 @@ func TestPersonIdNewPerson(t *testing.T) {
 }
 
 func TestPersonIdEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -942,7 +1007,7 @@ func TestPersonIdEmptyString(t *testing.T) {
 }
 
 func TestBooksByPersonId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -994,7 +1059,7 @@ func TestBooksByPersonId(t *testing.T) {
 }
 
 func TestBooksByPersonIdInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1020,7 +1085,7 @@ func TestBooksByPersonIdInvalidId(t *testing.T) {
 }
 
 func TestPublisherName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1043,7 +1108,7 @@ func TestPublisherName(t *testing.T) {
 }
 
 func TestPublisherNameInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1074,7 +1139,7 @@ func TestPublisherNameInvalidId(t *testing.T) {
 }
 
 func TestPublisherBooks(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1116,7 +1181,7 @@ func TestPublisherBooks(t *testing.T) {
 }
 
 func TestPublisherBooksInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1147,7 +1212,7 @@ func TestPublisherBooksInvalidId(t *testing.T) {
 }
 
 func TestPublisherId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1172,7 +1237,7 @@ func TestPublisherId(t *testing.T) {
 }
 
 func TestPublisherIdNewPublisher(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1206,7 +1271,7 @@ func TestPublisherIdNewPublisher(t *testing.T) {
 }
 
 func TestPublisherIdEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1231,7 +1296,7 @@ func TestPublisherIdEmptyString(t *testing.T) {
 }
 
 func TestSeriesId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1256,7 +1321,7 @@ func TestSeriesId(t *testing.T) {
 }
 
 func TestSeriesIdNewSeries(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1290,7 +1355,7 @@ func TestSeriesIdNewSeries(t *testing.T) {
 }
 
 func TestSeriesIdEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1315,7 +1380,7 @@ func TestSeriesIdEmptyString(t *testing.T) {
 }
 
 func TestSeriesBooks(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1357,7 +1422,7 @@ func TestSeriesBooks(t *testing.T) {
 }
 
 func TestSeriesBooksInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1388,7 +1453,7 @@ func TestSeriesBooksInvalidId(t *testing.T) {
 }
 
 func TestSeriesName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1411,7 +1476,7 @@ func TestSeriesName(t *testing.T) {
 }
 
 func TestSeriesNameInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1442,7 +1507,7 @@ func TestSeriesNameInvalidId(t *testing.T) {
 }
 
 func TestCheckBookInDb(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1462,8 +1527,8 @@ func TestCheckBookInDb(t *testing.T) {
 		title:     "Introduction to the Old Testament",
 		year:      1969,
 		publisher: "IVP",
-		isbn:      "0-85111-723-6",
-		status:    "Owned",
+		isbn:      "9780851117232",
+		status:    []string{"Owned"},
 		purchased: pd,
 	}
 
@@ -1485,7 +1550,7 @@ func TestCheckBookInDb(t *testing.T) {
 // ensure that checking for the book in the database depends only on information
 // about the book, not about the database.
 func TestCheckBookInDbDifferentId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1505,8 +1570,8 @@ func TestCheckBookInDbDifferentId(t *testing.T) {
 		title:     "Introduction to the Old Testament",
 		year:      1969,
 		publisher: "IVP",
-		isbn:      "0-85111-723-6",
-		status:    "Owned",
+		isbn:      "9780851117232",
+		status:    []string{"Owned"},
 		purchased: pd,
 	}
 
@@ -1524,7 +1589,7 @@ func TestCheckBookInDbDifferentId(t *testing.T) {
 }
 
 func TestCheckBookInDbUnknownBook(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1544,8 +1609,8 @@ func TestCheckBookInDbUnknownBook(t *testing.T) {
 		title:     "Biblical Critical Theory",
 		year:      2022,
 		publisher: "Zondervan",
-		isbn:      "978-0-310-12872-4",
-		status:    "Owned",
+		isbn:      "9780310128724",
+		status:    []string{"Owned"},
 		purchased: pd,
 	}
 
@@ -1563,7 +1628,7 @@ func TestCheckBookInDbUnknownBook(t *testing.T) {
 }
 
 func TestCountAllBooks(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1583,7 +1648,7 @@ func TestCountAllBooks(t *testing.T) {
 }
 
 func TestCountOwnedBooks(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1603,7 +1668,7 @@ func TestCountOwnedBooks(t *testing.T) {
 }
 
 func TestCountWantedBooks(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1622,8 +1687,28 @@ func TestCountWantedBooks(t *testing.T) {
 	}
 }
 
+func TestCountReadBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := 1
+
+	var read int
+	read, err = countBooksByStatus(db, "Read")
+	if err != nil {
+		t.Errorf("Could not count books: %v", err)
+	}
+
+	if read != expected {
+		t.Errorf("Wrong number of read books: expected %v, got %v", expected, read)
+	}
+}
+
 func TestAddBook(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1633,10 +1718,10 @@ func TestAddBook(t *testing.T) {
 	itts.author = "Karen H. Jobes and Moisés Silva"
 	itts.title = "Invitation to the Septuagint"
 	itts.year = 2015
-	itts.edition = 2
+	itts.edition = Edition{number: 2}
 	itts.publisher = "Baker Academic"
-	itts.isbn = "978-0-8010-3649-1"
-	itts.status = "Owned"
+	itts.isbn = "9780801036491"
+	itts.status = []string{"Owned"}
 
 	var ittspd PurchasedDate
 	ittspd.setDate("December 2021")
@@ -1665,7 +1750,7 @@ func TestAddBook(t *testing.T) {
 }
 
 func TestAddDuplicateBook(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1676,8 +1761,8 @@ func TestAddDuplicateBook(t *testing.T) {
 	iot.title = "Introduction to the Old Testament"
 	iot.year = 1969
 	iot.publisher = "IVP"
-	iot.isbn = "0-85111-723-6"
-	iot.status = "Owned"
+	iot.isbn = "9780851117232"
+	iot.status = []string{"Owned"}
 
 	var iotpd PurchasedDate
 	iotpd.setDate("May 2023")
@@ -1693,8 +1778,72 @@ func TestAddDuplicateBook(t *testing.T) {
 	}
 }
 
+func TestAddBookInvalidIsbn(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b := makeTestBook()
+	b.isbn = "978-0-8010-3649-2"
+
+	_, err = addBook(db, b)
+	if err == nil {
+		t.Error("Adding book with invalid ISBN did not result in error")
+	} else {
+		var invIsbnErr *InvalidIsbnError
+		if !errors.As(err, &invIsbnErr) {
+			t.Errorf("Adding book with invalid ISBN wrong error: %v", err)
+		}
+	}
+
+	var volumes int
+	volumes, err = countAllBooks(db)
+	if err != nil {
+		t.Errorf("Problem counting books: %v", err)
+	}
+	expected := 6
+	if volumes != expected {
+		t.Errorf("Book with invalid ISBN was added, expected %v books, got %v",
+			expected, volumes)
+	}
+}
+
+func TestAddBookDuplicateStatus(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b := makeTestBook()
+	b.status = []string{"Owned", "Read", "Owned", "Read"}
+
+	id, err := addBook(db, b)
+	if err != nil {
+		t.Fatalf("Adding book with a status given twice returned error: %v", err)
+	}
+	defer deleteBook(db, id)
+
+	statusList, err := getStatusListById(db, id)
+	if err != nil {
+		t.Fatalf("Problem getting statuses: %v", err)
+	}
+	if !reflect.DeepEqual(statusList, []string{"Owned", "Read"}) {
+		t.Errorf("Expected statuses Owned and Read, got %v", statusList)
+	}
+	history, err := getStatusHistoryById(db, id)
+	if err != nil {
+		t.Fatalf("Problem getting status history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Errorf("Expected each status recorded once in history, got %v", history)
+	}
+}
+
 func TestUpdateBookAuthor(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1725,7 +1874,7 @@ func TestUpdateBookAuthor(t *testing.T) {
 }
 
 func TestUpdateBookEditor(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1755,8 +1904,118 @@ func TestUpdateBookEditor(t *testing.T) {
 	}
 }
 
+func TestUpdateBookAuthorReorder(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	newAuthors := "Stephen J. Wellum and Peter J. Gentry"
+	updatedAuthors, err := updateBookAuthor(db, 5, newAuthors)
+	if err != nil {
+		t.Errorf("Problem reordering book authors: %v", err)
+	}
+	if updatedAuthors != newAuthors {
+		t.Errorf("Authors not properly reordered. Authors should be %v, but are %v",
+			newAuthors, updatedAuthors)
+	}
+
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Errorf("Problem getting book #5: %v", err)
+	}
+	if b.author != newAuthors {
+		t.Errorf("Reordered authors not returned by getBookById. Authors should be %v, but are %v",
+			newAuthors, b.author)
+	}
+
+	newAuthors = "Peter J. Gentry and Stephen J. Wellum"
+	updatedAuthors, err = updateBookAuthor(db, 5, newAuthors)
+	if err != nil {
+		t.Errorf("Problem reverting book authors: %v", err)
+	}
+	if updatedAuthors != newAuthors {
+		t.Errorf("Authors not properly reverted. Authors should be %v, but are %v",
+			newAuthors, updatedAuthors)
+	}
+}
+
+func TestUpdateBookEditorReorder(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// reorder while adding and removing people
+	newEditors := "Cory C. Brock, John Bolt and N. Gray Sutanto"
+	updatedEditors, err := updateBookEditor(db, 6, newEditors)
+	if err != nil {
+		t.Errorf("Problem updating book editors: %v", err)
+	}
+	if updatedEditors != newEditors {
+		t.Errorf("Editors not properly updated. Editors should be %v, but are %v",
+			newEditors, updatedEditors)
+	}
+
+	newEditors = "N. Gray Sutanto, James Eglinton and Cory C. Brock"
+	updatedEditors, err = updateBookEditor(db, 6, newEditors)
+	if err != nil {
+		t.Errorf("Problem reverting book editors: %v", err)
+	}
+	if updatedEditors != newEditors {
+		t.Errorf("Editors not properly reverted. Editors should be %v, but are %v",
+			newEditors, updatedEditors)
+	}
+
+	pid, err := personId(db, "John Bolt")
+	if err != nil {
+		t.Errorf("Problem looking up added editor: %v", err)
+	}
+	if err := deletePerson(db, pid); err != nil {
+		t.Errorf("Problem removing added editor: %v", err)
+	}
+}
+
+func TestAddBookAuthorOrder(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// the authors are already in the database, in the opposite order
+	b := Book{
+		author:    "Stephen J. Wellum and Peter J. Gentry",
+		title:     "God's Kingdom through God's Covenants",
+		subtitle:  "A Concise Biblical Theology",
+		year:      2015,
+		publisher: "Crossway",
+		isbn:      "9781433541537",
+		status:    []string{"Want"},
+	}
+	id, err := addBook(db, &b)
+	if err != nil {
+		t.Fatalf("Problem adding book: %v", err)
+	}
+
+	authors, err := getAuthorsListById(db, id)
+	if err != nil {
+		t.Errorf("Problem getting authors of added book: %v", err)
+	}
+	if formatNameList(authors) != b.author {
+		t.Errorf("Authors of added book out of order. Authors should be %v, but are %v",
+			b.author, formatNameList(authors))
+	}
+
+	if err := deleteBook(db, id); err != nil {
+		t.Errorf("Problem deleting added book: %v", err)
+	}
+}
+
 func TestUpdatePersonName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1811,7 +2070,7 @@ func TestUpdatePersonName(t *testing.T) {
 }
 
 func TestUpdateBookTitle(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1843,7 +2102,7 @@ func TestUpdateBookTitle(t *testing.T) {
 }
 
 func TestUpdateBookTitleEmpty(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1867,7 +2126,7 @@ func TestUpdateBookTitleEmpty(t *testing.T) {
 }
 
 func TestUpdateBookSubtitle(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1910,7 +2169,7 @@ func TestUpdateBookSubtitle(t *testing.T) {
 
 // Empty subtitle should set null value in database, not an empty string
 func TestUpdateBookSubtitleEmpty(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -1989,7 +2248,7 @@ func TestUpdateBookSubtitleEmpty(t *testing.T) {
 }
 
 func TestUpdateBookYear(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2028,13 +2287,13 @@ func TestUpdateBookYear(t *testing.T) {
 }
 
 func TestUpdateBookEdition(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
 	defer db.Close()
 
-	var newEdition int = 5
+	var newEdition = Edition{5, "revised and expanded edition"}
 
 	updatedEdition, err := updateBookEdition(db, 5, newEdition)
 	if err != nil {
@@ -2052,7 +2311,7 @@ func TestUpdateBookEdition(t *testing.T) {
 	}
 
 	// Revert database back to original state
-	origEdition := 2
+	origEdition := Edition{number: 2}
 	revertedEdition, err := updateBookEdition(db, 5, origEdition)
 	if err != nil {
 		t.Errorf("Problem reverting edition: %v", err)
@@ -2071,13 +2330,13 @@ func TestUpdateBookEdition(t *testing.T) {
 
 // Empty subtitle should set null value in database, not an empty string
 func TestUpdateBookEditionZero(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
 	defer db.Close()
 
-	var newEdition int = 0
+	var newEdition Edition
 	updatedEdition, err := updateBookEdition(db, 5, newEdition)
 	if err != nil {
 		t.Errorf("Problem updating edition: %v", err)
@@ -2139,7 +2398,7 @@ func TestUpdateBookEditionZero(t *testing.T) {
 	rows.Close()
 
 	// Revert database to original state
-	var origEdition int = 2
+	var origEdition = Edition{number: 2}
 	revertedEdition, err := updateBookEdition(db, 5, origEdition)
 	if err != nil {
 		t.Errorf("Problem reverting edition: %v", err)
@@ -2150,8 +2409,132 @@ func TestUpdateBookEditionZero(t *testing.T) {
 	}
 }
 
+func TestUpdateBookEditionNegative(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = updateBookEdition(db, 5, Edition{number: -1})
+	if err == nil {
+		t.Errorf("Negative edition number did not return error")
+	}
+
+	expected := Edition{number: 2}
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Errorf("Could not retrieve book from database: %v", err)
+	}
+	if b.edition != expected {
+		t.Errorf("Edition wrongly updated in database: expected \"%v\", got \"%v\"",
+			expected, b.edition)
+	}
+}
+
+func TestUpdateBookRating(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	requestedRating := Rating{halfStars: 5, rated: true}
+	updatedRating, err := updateBookRating(db, 5, requestedRating)
+	if err != nil {
+		t.Errorf("Problem updating rating: %v", err)
+	}
+	if updatedRating != requestedRating {
+		t.Errorf("Wrongly updated rating: should be \"%v\" but got \"%v\"",
+			requestedRating, updatedRating)
+	}
+
+	b, err := getBookById(db, 5)
+	if b.rating != requestedRating {
+		t.Errorf("Wrongly updated rating from book: should be \"%v\" but got \"%v\"",
+			requestedRating, b.rating)
+	}
+
+	// Revert database back to original state
+	origRating := Rating{halfStars: 9, rated: true}
+	revertedRating, err := updateBookRating(db, 5, origRating)
+	if err != nil {
+		t.Errorf("Problem reverting rating: %v", err)
+	}
+	if revertedRating != origRating {
+		t.Errorf("Wrongly reverted rating: should be \"%v\" but got \"%v\"",
+			origRating, revertedRating)
+	}
+}
+
+// Unrated book should set null value in database
+func TestUpdateBookRatingUnrated(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	updatedRating, err := updateBookRating(db, 3, Rating{})
+	if err != nil {
+		t.Errorf("Problem clearing rating: %v", err)
+	}
+	if updatedRating.rated {
+		t.Errorf("Cleared rating should be unrated, got %v", updatedRating)
+	}
+
+	var rating sql.NullFloat64
+	if err := db.QueryRow("SELECT rating FROM books WHERE book_id = 3").Scan(&rating); err != nil {
+		t.Errorf("Error querying rating in database: %v", err)
+	}
+	if rating.Valid {
+		t.Errorf("Query returned valid (non-null) rating: %v", rating.Float64)
+	}
+
+	// Revert database back to original state
+	if _, err := updateBookRating(db, 3, Rating{halfStars: 8, rated: true}); err != nil {
+		t.Errorf("Problem reverting rating: %v", err)
+	}
+}
+
+func TestUpdateBookRatingInvalid(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = updateBookRating(db, 5, Rating{halfStars: 12, rated: true})
+	if err == nil {
+		t.Errorf("updateBookRating did not return error for rating above 5")
+	} else {
+		var ratingErr *InvalidRatingError
+		if !errors.As(err, &ratingErr) {
+			t.Errorf("updateBookRating returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestUpdateBookRatingInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = updateBookRating(db, 1000, Rating{halfStars: 6, rated: true})
+	if err == nil {
+		t.Errorf("updateBookRating did not return error for invalid book id")
+	} else {
+		var invIdErr *InvalidBookIdError
+		if !errors.As(err, &invIdErr) {
+			t.Errorf("updateBookRating returned unexpected error: %v", err)
+		}
+	}
+}
+
 func TestUpdateBookPublisherById(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2199,7 +2582,7 @@ func TestUpdateBookPublisherById(t *testing.T) {
 }
 
 func TestUpdateBookPublisherByIdInvalid(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2253,7 +2636,7 @@ func TestUpdateBookPublisherByIdInvalid(t *testing.T) {
 }
 
 func TestUpdateBookPublisherByName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2295,7 +2678,7 @@ func TestUpdateBookPublisherByName(t *testing.T) {
 }
 
 func TestUpdateBookPublisherByNameEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2333,7 +2716,7 @@ func TestUpdateBookPublisherByNameEmptyString(t *testing.T) {
 }
 
 func TestUpdatePublisherName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2378,7 +2761,7 @@ func TestUpdatePublisherName(t *testing.T) {
 }
 
 func TestUpdatePublisherNameEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2392,7 +2775,7 @@ func TestUpdatePublisherNameEmptyString(t *testing.T) {
 }
 
 func TestUpdatePublisherNameDuplicate(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2402,20 +2785,26 @@ func TestUpdatePublisherNameDuplicate(t *testing.T) {
 	_, err = updatePublisherName(db, 1, newName)
 	if err == nil {
 		t.Errorf("Duplicate publisher name did not raise error")
+	} else {
+		var dupNameErr *DuplicateNameError
+		if !errors.As(err, &dupNameErr) {
+			t.Errorf("Duplicate publisher name returned unexpected error: %v", err)
+		}
 	}
 }
 
 func TestUpdateBookIsbn(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
 	defer db.Close()
 
-	origIsbn := "0-85111-723-6"
-	newIsbn := "978-1408855652"
+	origIsbn := ISBN("9780851117232")
+	newIsbn := ISBN("9781408855652")
 
-	updatedIsbn, err := updateBookIsbn(db, 1, newIsbn)
+	// ISBN-10, hyphenated, should be stored in canonical ISBN-13 form
+	updatedIsbn, err := updateBookIsbn(db, 1, "1-4088-5565-8")
 	if err != nil {
 		t.Errorf("Problem updating ISBN: %v", err)
 	}
@@ -2432,7 +2821,7 @@ func TestUpdateBookIsbn(t *testing.T) {
 	}
 
 	// Revert to original state
-	revertedIsbn, err := updateBookIsbn(db, 1, origIsbn)
+	revertedIsbn, err := updateBookIsbn(db, 1, string(origIsbn))
 	if err != nil {
 		t.Errorf("Problem reverting ISBN: %v", err)
 	}
@@ -2442,8 +2831,86 @@ func TestUpdateBookIsbn(t *testing.T) {
 	}
 }
 
+func TestUpdateBookIsbnInvalid(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	origIsbn := ISBN("9780851117232")
+	invalidIsbn := "978-1-4335-5403-8"
+
+	_, err = updateBookIsbn(db, 1, invalidIsbn)
+	if err == nil {
+		t.Errorf("Invalid ISBN %v did not return error", invalidIsbn)
+	} else {
+		var invIsbnErr *InvalidIsbnError
+		if !errors.As(err, &invIsbnErr) {
+			t.Errorf("Invalid ISBN %v returned unexpected error: %v",
+				invalidIsbn, err)
+		}
+	}
+
+	b, err := getBookById(db, 1)
+	if err != nil {
+		t.Errorf("Problem reading book from database: %v", err)
+	}
+	if b.isbn != origIsbn {
+		t.Errorf("ISBN wrongly updated in database. Expected %v, got %v",
+			origIsbn, b.isbn)
+	}
+}
+
+func TestCheckStoredIsbns(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	problems, err := checkStoredIsbns(db)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("checkStoredIsbns of test database: expected none, got %v, error %v",
+			problems, err)
+	}
+
+	// as book 4 is recorded in the catalogue, with the wrong check digit
+	invalidIsbn := "978-1-4335-5403-8"
+	if _, err := db.Exec("UPDATE books SET isbn = ? WHERE book_id = 4", invalidIsbn); err != nil {
+		t.Fatalf("Problem setting invalid ISBN: %v", err)
+	}
+	defer db.Exec("UPDATE books SET isbn = ? WHERE book_id = 4", "9781433554032")
+
+	problems, err = checkStoredIsbns(db)
+	if err != nil {
+		t.Fatalf("checkStoredIsbns returned error: %v", err)
+	}
+	if len(problems) != 1 || problems[0].BookId != 4 || problems[0].Isbn != invalidIsbn ||
+		problems[0].Reason != "check digit is incorrect" {
+		t.Errorf("checkStoredIsbns: expected book #4 to be reported, got %v", problems)
+	}
+
+	b, err := getBookById(db, 4)
+	if err != nil {
+		t.Fatalf("Problem reading book with invalid ISBN: %v", err)
+	}
+	if string(b.isbn) != invalidIsbn || b.isbn.String() != invalidIsbn {
+		t.Errorf("Invalid ISBN not kept as entered, got %v", b.isbn)
+	}
+
+	code, _, stderr := runTestCli("", "show", "4")
+	if code != exitOk || !strings.Contains(stderr, "Book #4 has invalid ISBN "+
+		"\"978-1-4335-5403-8\", check digit is incorrect") {
+		t.Errorf("show didn't report invalid stored ISBN, got %v: %v", code, stderr)
+	}
+	if strings.Contains(stderr, "printed without hyphens") {
+		t.Errorf("Invalid stored ISBN reported as missing from the range data: %v", stderr)
+	}
+}
+
 func TestUpdateBookSeriesById(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2502,7 +2969,7 @@ func TestUpdateBookSeriesById(t *testing.T) {
 }
 
 func TestUpdateBookSeriesByIdNull(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2603,7 +3070,7 @@ func TestUpdateBookSeriesByIdNull(t *testing.T) {
 }
 
 func TestUpdateBookSeriesByIdInvalid(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2656,7 +3123,7 @@ func TestUpdateBookSeriesByIdInvalid(t *testing.T) {
 }
 
 func TestUpdateBookSeriesByName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2710,7 +3177,7 @@ func TestUpdateBookSeriesByName(t *testing.T) {
 }
 
 func TestUpdateBookSeriesByNameEmpty(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2810,7 +3277,7 @@ func TestUpdateBookSeriesByNameEmpty(t *testing.T) {
 }
 
 func TestUpdateSeriesName(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2857,7 +3324,7 @@ func TestUpdateSeriesName(t *testing.T) {
 }
 
 func TestUpdateSeriesNameEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -2886,20 +3353,20 @@ func TestUpdateSeriesNameEmptyString(t *testing.T) {
 }
 
 func TestUpdateBookStatus(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
 	defer db.Close()
 
-	origStatus := "Owned"
-	newStatus := "Want"
+	origStatus := []string{"Owned"}
+	newStatus := []string{"Lent", "Read"}
 
 	updatedStatus, err := updateBookStatus(db, 1, newStatus)
 	if err != nil {
 		t.Errorf("Could not update book status: %v", err)
 	}
-	if updatedStatus != newStatus {
+	if !slices.Equal(updatedStatus, newStatus) {
 		t.Errorf("UpdateBookStatus returned unexpected value. Expected \"%v\", got \"%v\"",
 			newStatus, updatedStatus)
 	}
@@ -2907,7 +3374,7 @@ func TestUpdateBookStatus(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not retrieve book from database: %v", err)
 	}
-	if b.status != newStatus {
+	if !slices.Equal(b.status, newStatus) {
 		t.Errorf("Book status not properly updated in database: expected \"%v\", got \"%v\"",
 			newStatus, b.status)
 	}
@@ -2917,7 +3384,7 @@ func TestUpdateBookStatus(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not revert book status: %v", err)
 	}
-	if revertedStatus != origStatus {
+	if !slices.Equal(revertedStatus, origStatus) {
 		t.Errorf("UpdateBookStatus returned unexpected value. Expected \"%v\", got \"%v\"",
 			origStatus, revertedStatus)
 	}
@@ -2925,39 +3392,209 @@ func TestUpdateBookStatus(t *testing.T) {
 	if err != nil {
 		t.Errorf("Could not retrieve book from database: %v", err)
 	}
-	if b.status != origStatus {
+	if !slices.Equal(b.status, origStatus) {
 		t.Errorf("Book status not properly updated in database: expected \"%v\", got \"%v\"",
 			origStatus, b.status)
 	}
 }
 
 func TestUpdateBookStatusEmptyString(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
 	defer db.Close()
 
-	origStatus := "Owned"
-	newStatus := ""
+	origStatus := []string{"Owned"}
+
+	for _, newStatus := range [][]string{{}, {""}, {"Read", ""}} {
+		_, err = updateBookStatus(db, 1, newStatus)
+		if err == nil {
+			t.Errorf("Book status %q did not return error", newStatus)
+		}
+	}
+
+	b, err := getBookById(db, 1)
+	if err != nil {
+		t.Errorf("Could not retrieve book from database: %v", err)
+	}
+	if !slices.Equal(b.status, origStatus) {
+		t.Errorf("Book status wrongly updated in database: expected \"%v\", got \"%v\"",
+			origStatus, b.status)
+	}
+}
+
+func TestAddBookStatus(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []string{"Owned", "Read"}
+	updatedStatus, err := addBookStatus(db, 4, "Read")
+	if err != nil {
+		t.Errorf("Could not add book status: %v", err)
+	}
+	if !slices.Equal(updatedStatus, expected) {
+		t.Errorf("addBookStatus returned unexpected value. Expected %v, got %v",
+			expected, updatedStatus)
+	}
+
+	// adding a status the book already has should leave it unchanged
+	updatedStatus, err = addBookStatus(db, 4, "Read")
+	if err != nil {
+		t.Errorf("Could not add existing book status: %v", err)
+	}
+	if !slices.Equal(updatedStatus, expected) {
+		t.Errorf("addBookStatus returned unexpected value for existing status. Expected %v, got %v",
+			expected, updatedStatus)
+	}
+
+	// Revert database to original values
+	expected = []string{"Owned"}
+	revertedStatus, err := removeBookStatus(db, 4, "Read")
+	if err != nil {
+		t.Errorf("Could not remove book status: %v", err)
+	}
+	if !slices.Equal(revertedStatus, expected) {
+		t.Errorf("removeBookStatus returned unexpected value. Expected %v, got %v",
+			expected, revertedStatus)
+	}
+}
+
+func TestUpdateBookStatusTransitionNotAllowed(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	origStatus := []string{"Owned"}
+	newStatus := []string{"Want"}
 
 	_, err = updateBookStatus(db, 1, newStatus)
 	if err == nil {
-		t.Errorf("Book status empty string did not return error")
+		t.Errorf("Disallowed status transition did not return error")
+	} else {
+		var transErr *StatusTransitionError
+		if !errors.As(err, &transErr) {
+			t.Errorf("Disallowed status transition returned unexpected error: %v", err)
+		}
+	}
+
+	// a book can't have two lifecycle statuses at once
+	_, err = updateBookStatus(db, 1, []string{"Owned", "Lent"})
+	if err == nil {
+		t.Errorf("Two lifecycle statuses did not return error")
 	}
 
 	b, err := getBookById(db, 1)
 	if err != nil {
 		t.Errorf("Could not retrieve book from database: %v", err)
 	}
-	if b.status != origStatus {
+	if !slices.Equal(b.status, origStatus) {
 		t.Errorf("Book status wrongly updated in database: expected \"%v\", got \"%v\"",
 			origStatus, b.status)
 	}
 }
 
+func TestAddBookStatusInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id := 17
+	_, err = addBookStatus(db, id, "Read")
+	if err == nil {
+		t.Errorf("addBookStatus returned nil error for invalid id #%v", id)
+	} else {
+		var invlBookIdErr *InvalidBookIdError
+		if !errors.As(err, &invlBookIdErr) {
+			t.Errorf("addBookStatus returned unexpected error for invalid id #%v: %v",
+				id, err)
+		}
+	}
+}
+
+func TestRemoveBookStatusMissing(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []string{"Owned"}
+	returnedStatus, err := removeBookStatus(db, 1, "Want")
+	if err == nil {
+		t.Errorf("Removing status book doesn't have did not return error")
+	}
+	if !slices.Equal(returnedStatus, expected) {
+		t.Errorf("removeBookStatus returned unexpected value. Expected %v, got %v",
+			expected, returnedStatus)
+	}
+}
+
+func TestGetStatusListById(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []string{"Owned", "Read"}
+	statusList, err := getStatusListById(db, 5)
+	if err != nil {
+		t.Errorf("getStatusListById returned error: %v", err)
+	}
+	if !slices.Equal(statusList, expected) {
+		t.Errorf("getStatusListById returned unexpected value. Expected %v, got %v",
+			expected, statusList)
+	}
+}
+
+func TestGetStatusListByIdInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id := 17
+	_, err = getStatusListById(db, id)
+	if err == nil {
+		t.Errorf("getStatusListById returned nil error for invalid id #%v", id)
+	} else {
+		var invlBookIdErr *InvalidBookIdError
+		if !errors.As(err, &invlBookIdErr) {
+			t.Errorf("getStatusListById returned unexpected error for invalid id #%v: %v",
+				id, err)
+		}
+	}
+}
+
+func TestListStatuses(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []string{"Given away", "Lent", "Ordered", "Owned", "Read", "Want"}
+	statuses, err := listStatuses(db)
+	if err != nil {
+		t.Errorf("listStatuses returned error: %v", err)
+	}
+	if !slices.Equal(statuses, expected) {
+		t.Errorf("listStatuses returned unexpected value. Expected %v, got %v",
+			expected, statuses)
+	}
+}
+
 func TestUpdateBookPurchaseDate(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3017,7 +3654,7 @@ func TestUpdateBookPurchaseDate(t *testing.T) {
 }
 
 func TestUpdateBookPurchaseDateNull(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3125,7 +3762,7 @@ func TestUpdateBookPurchaseDateNull(t *testing.T) {
 }
 
 func TestDeleteBook(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3143,7 +3780,7 @@ func TestDeleteBook(t *testing.T) {
 		t.Errorf("Issue retrieving book: %v", err)
 	}
 	newBook.id = id
-	if addedBook != *newBook {
+	if !reflect.DeepEqual(addedBook, *newBook) {
 		t.Errorf(
 			"Added book does not match book added.\n"+
 				"Expected: %v, but got %v",
@@ -3179,6 +3816,15 @@ func TestDeleteBook(t *testing.T) {
 		)
 	}
 
+	var statusCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM book_status WHERE book_id = ?",
+		id).Scan(&statusCount); err != nil {
+		t.Errorf("Problem querying book status in DB: %v", err)
+	}
+	if statusCount != 0 {
+		t.Errorf("Book status still in database after deletion of book")
+	}
+
 	// ensure cleanup of people, publishers, series if no books remain
 	checkPeopleSql := `SELECT COUNT(*)
         FROM people
@@ -3231,7 +3877,7 @@ func TestDeleteBook(t *testing.T) {
 }
 
 func TestDeleteBookInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3255,7 +3901,7 @@ func TestDeleteBookInvalidId(t *testing.T) {
 }
 
 func TestDeletePerson(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3296,7 +3942,7 @@ func TestDeletePerson(t *testing.T) {
 }
 
 func TestDeletePersonInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3323,7 +3969,7 @@ func TestDeletePersonInvalidId(t *testing.T) {
 }
 
 func TestDeletePersonInUse(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3356,7 +4002,7 @@ func TestDeletePersonInUse(t *testing.T) {
 }
 
 func TestDeletePublisher(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3397,7 +4043,7 @@ func TestDeletePublisher(t *testing.T) {
 }
 
 func TestDeletePublisherInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3421,7 +4067,7 @@ func TestDeletePublisherInvalidId(t *testing.T) {
 }
 
 func TestDeletePublisherInUse(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3454,7 +4100,7 @@ func TestDeletePublisherInUse(t *testing.T) {
 }
 
 func TestDeleteSeries(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3495,7 +4141,7 @@ func TestDeleteSeries(t *testing.T) {
 }
 
 func TestDeleteSeriesInvalidId(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3519,7 +4165,7 @@ func TestDeleteSeriesInvalidId(t *testing.T) {
 }
 
 func TestDeleteSeriesInUse(t *testing.T) {
-	db, err := sql.Open("sqlite3", "testdb.sqlite")
+	db, err := sql.Open(testDriver, testDatabase)
 	if err != nil {
 		t.Errorf("Problem opening database: %v", err)
 	}
@@ -3550,3 +4196,35 @@ func TestDeleteSeriesInUse(t *testing.T) {
 		}
 	}
 }
+
+func TestDeletionOrphans(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tests := map[int]DeletionOrphans{
+		2: {people: []string{"Robert J. Matz", "A. Chadwick Thornhill"},
+			series: "Spectrum Multiview Books"},
+		3: {people: []string{"Anselm", "Thomas Williams"}, publisher: "Hackett"},
+		4: {},
+		5: {people: []string{"Stephen J. Wellum"}},
+	}
+	for id, expected := range tests {
+		orphans, err := deletionOrphans(db, id)
+		if err != nil {
+			t.Errorf("Problem getting orphans of book #%v: %v", id, err)
+		}
+		if !reflect.DeepEqual(orphans, expected) {
+			t.Errorf("Wrong orphans for book #%v: expected %#v, got %#v", id,
+				expected, orphans)
+		}
+	}
+
+	_, err = deletionOrphans(db, 17)
+	var idErr *InvalidBookIdError
+	if !errors.As(err, &idErr) {
+		t.Errorf("Expected InvalidBookIdError for book #17, got %v", err)
+	}
+}
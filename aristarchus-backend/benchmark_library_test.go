@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// benchmarkLibrarySize is the number of books in the library used by the
+// benchmarks, on top of those in the test database.
+const benchmarkLibrarySize = 5000
+
+// benchmarkLibrary is built the first time a benchmark asks for it, and
+// removed by TestMain once the benchmarks have run.
+var benchmarkLibrary struct {
+	once sync.Once
+	dir  string
+	path string
+	err  error
+}
+
+// openBenchmarkLibrary opens a database holding the test data and
+// benchmarkLibrarySize more books, each with one or two authors, a publisher,
+// a status, and a series for every tenth book.
+func openBenchmarkLibrary(b *testing.B) *sql.DB {
+	b.Helper()
+	benchmarkLibrary.once.Do(func() {
+		benchmarkLibrary.dir, benchmarkLibrary.err = os.MkdirTemp("", "aristarchus-bench")
+		if benchmarkLibrary.err != nil {
+			return
+		}
+		benchmarkLibrary.path = filepath.Join(benchmarkLibrary.dir, "library.sqlite")
+		benchmarkLibrary.err = buildBenchmarkLibrary(benchmarkLibrary.path)
+	})
+	if benchmarkLibrary.err != nil {
+		b.Fatalf("Problem building benchmark library: %v", benchmarkLibrary.err)
+	}
+
+	db, err := sql.Open("sqlite3", benchmarkLibrary.path)
+	if err != nil {
+		b.Fatalf("Problem opening benchmark library: %v", err)
+	}
+	b.Cleanup(func() { db.Close() })
+	return db
+}
+
+func buildBenchmarkLibrary(path string) error {
+	cmd := exec.Command("sqlite3", path, "-init", "../db/init_test_database.sql",
+		".quit")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("buildBenchmarkLibrary, couldn't set up db: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+	}
+	defer tx.Rollback()
+
+	for i := 1; i <= benchmarkLibrarySize; i++ {
+		var series sql.NullString
+		if i%10 == 0 {
+			series = sql.NullString{String: fmt.Sprintf("Series %d", i%50), Valid: true}
+		}
+		var serId sql.NullInt64
+		if series.Valid {
+			id, err := seriesId(tx, series.String)
+			if err != nil {
+				return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+			}
+			serId = sql.NullInt64{Int64: int64(id), Valid: true}
+		}
+		pubId, err := publisherId(tx, fmt.Sprintf("Publisher %d", i%40))
+		if err != nil {
+			return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+		}
+
+		result, err := tx.Exec(`INSERT INTO books (title, subtitle, year,
+                                publisher_id, series_id, purchased_date)
+                                VALUES (?, ?, ?, ?, ?, ?)`,
+			fmt.Sprintf("Benchmark Book %d", i), fmt.Sprintf("Volume %d", i),
+			1900+i%125, pubId, serId, "March 2020")
+		if err != nil {
+			return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+		}
+		bookId, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+		}
+
+		authors := []string{fmt.Sprintf("Author %d", i%1000)}
+		if i%3 == 0 {
+			authors = append(authors, fmt.Sprintf("Author %d", (i+500)%1000))
+		}
+		if err := addBookContributors(tx, int(bookId), RoleAuthor, authors); err != nil {
+			return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+		}
+
+		stId, err := statusId(tx, "Owned")
+		if err != nil {
+			return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+		}
+		if _, err := tx.Exec("INSERT INTO book_status VALUES (?, ?)", bookId,
+			stId); err != nil {
+			return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("buildBenchmarkLibrary: %v", err)
+	}
+	return nil
+}
+
+func removeBenchmarkLibrary() error {
+	if benchmarkLibrary.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(benchmarkLibrary.dir); err != nil {
+		return fmt.Errorf("removeBenchmarkLibrary: %v", err)
+	}
+	return nil
+}
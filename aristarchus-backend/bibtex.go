@@ -0,0 +1,323 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// BibFormat is the dialect of a bibliography file: BibTeX, as read by classic
+// bibtex styles, or BibLaTeX, which has fields for subtitles and for more kinds
+// of contributor.
+type BibFormat int
+
+const (
+	BibTeX BibFormat = iota
+	BibLaTeX
+)
+
+func (f BibFormat) String() string {
+	if f == BibLaTeX {
+		return "biblatex"
+	}
+	return "bibtex"
+}
+
+// Citation keys are made from the surname of the first author (or editor or
+// compiler), the year, and the first word of the title which isn't an article,
+// e.g. gentry2018kingdom. Where books would share a key, the first to be given
+// it has the plain key and the others have a letter added, e.g.
+// gentry2018kingdoma. A book's key is stored in books.citation_key when first
+// given, so it is the same whichever books are exported with it, and is kept
+// as other books are added and deleted. It only changes if its author, year or
+// title change so as to give a different key.
+
+// keyFolds gives ASCII letters for accented letters in citation keys.
+var keyFolds = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a", "ă", "a",
+	"ç", "c", "ć", "c", "č", "c", "ď", "d", "ð", "d",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e", "ė", "e", "ę", "e", "ě", "e",
+	"ğ", "g", "í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i", "ł", "l",
+	"ñ", "n", "ń", "n", "ň", "n",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "ō", "o", "ő", "o",
+	"ř", "r", "ś", "s", "š", "s", "ş", "s", "ť", "t",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u", "ů", "u", "ű", "u",
+	"ý", "y", "ÿ", "y", "ź", "z", "ż", "z", "ž", "z",
+	"æ", "ae", "œ", "oe", "ß", "ss", "þ", "th",
+)
+
+// keyTitleSkip are the words passed over when taking a word of the title for
+// a citation key.
+var keyTitleSkip = []string{"a", "an", "the"}
+
+// keyWord gives a word as it appears in a citation key, in lower case ASCII
+// letters and digits only.
+func keyWord(s string) string {
+	s = keyFolds.Replace(strings.ToLower(s))
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// citationKeyBase gives the citation key for a book before any letter is
+// added to distinguish it from other books, from the name of its first author
+// (or editor or compiler), its year and its title.
+func citationKeyBase(name string, year int, title string) string {
+	_, family, _ := splitPersonName(name)
+	surname := keyWord(family)
+	if len(surname) == 0 {
+		surname = "anon"
+	}
+
+	key := surname
+	if year != 0 {
+		key += strconv.Itoa(year)
+	}
+	first := ""
+	for _, word := range strings.Fields(title) {
+		word = keyWord(word)
+		if len(word) > 0 && !slices.Contains(keyTitleSkip, word) {
+			return key + word
+		}
+		if len(first) == 0 {
+			first = word
+		}
+	}
+	// a title of only articles
+	return key + first
+}
+
+// keySuffix gives the letters added to the nth book sharing a citation key
+// after the first, e.g. "a" for 0, "z" for 25 and "aa" for 26.
+func keySuffix(n int) string {
+	suffix := ""
+	for n++; n > 0; n = (n - 1) / 26 {
+		suffix = string(rune('a'+(n-1)%26)) + suffix
+	}
+	return suffix
+}
+
+// keyHasBase reports whether a stored citation key is still the key for a
+// book whose key would be base, i.e. is base with any letters added.
+func keyHasBase(key string, base string) bool {
+	suffix, found := strings.CutPrefix(key, base)
+	return found && strings.Trim(suffix, "abcdefghijklmnopqrstuvwxyz") == ""
+}
+
+// citationKeys gives the citation key of every book in the library, by id,
+// storing the keys of any books which don't yet have one.
+func citationKeys(db DBInterface) (map[int]string, error) {
+	keys := map[int]string{}
+	err := withTx(db, func(tx DBInterface) error {
+		rows, err := tx.Query(`
+        SELECT books.book_id, books.year, books.title, books.citation_key,
+          (SELECT people.name
+           FROM book_contributor
+           INNER JOIN people
+             ON book_contributor.person_id = people.person_id
+           WHERE book_contributor.book_id = books.book_id
+             AND book_contributor.role IN ('author', 'editor', 'compiler')
+           ORDER BY CASE book_contributor.role
+             WHEN 'author' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END,
+             book_contributor.position
+           LIMIT 1)
+        FROM books
+        ORDER BY books.book_id`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		type unkeyedBook struct {
+			id    int
+			base  string
+			stale bool // has a stored key which no longer fits it
+		}
+		var unkeyed []unkeyedBook
+		used := map[string]bool{}
+		for rows.Next() {
+			var id, year int
+			var title string
+			var stored, name sql.NullString
+			if err := rows.Scan(&id, &year, &title, &stored, &name); err != nil {
+				return err
+			}
+			base := citationKeyBase(name.String, year, title)
+			if stored.Valid && keyHasBase(stored.String, base) {
+				keys[id] = stored.String
+				used[stored.String] = true
+			} else {
+				unkeyed = append(unkeyed, unkeyedBook{id, base, stored.Valid})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		rows.Close()
+
+		// clear stale keys first, so they can be given to other books
+		for _, b := range unkeyed {
+			if !b.stale {
+				continue
+			}
+			_, err := tx.Exec("UPDATE books SET citation_key = NULL WHERE book_id = ?", b.id)
+			if err != nil {
+				return err
+			}
+		}
+		for _, b := range unkeyed {
+			key := b.base
+			for n := 0; used[key]; n++ {
+				key = b.base + keySuffix(n)
+			}
+			used[key] = true
+			keys[b.id] = key
+			_, err := tx.Exec("UPDATE books SET citation_key = ? WHERE book_id = ?", key, b.id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("citationKeys: %v", err)
+	}
+	return keys, nil
+}
+
+// bibEscape escapes the characters which are special to TeX.
+var bibEscape = strings.NewReplacer(
+	`\`, `\textbackslash{}`, "{", `\{`, "}", `\}`, "&", `\&`, "%", `\%`,
+	"$", `\$`, "#", `\#`, "_", `\_`, "~", `\textasciitilde{}`,
+	"^", `\textasciicircum{}`,
+)
+
+// bibNames gives a list of names as a BibTeX name list, e.g. "Peter J. Gentry
+// and Stephen J. Wellum". BibTeX reads the parts of each name itself.
+func bibNames(names string) string {
+	return strings.Join(nameListFromString(names), " and ")
+}
+
+type bibField struct {
+	name  string
+	value string
+	// protect puts the value in a further pair of braces, so that it's read as
+	// it is rather than as a list or with its case changed
+	protect bool
+}
+
+// bibEntry gives the entry type and fields of a book in the given format.
+// Fields with no value are left out.
+func bibEntry(b Book, format BibFormat) (string, []bibField) {
+	entryType := "book"
+	editor, editorType := b.editor, ""
+	if len(editor) == 0 && len(b.compiler) > 0 {
+		editor, editorType = b.compiler, "compiler"
+	}
+
+	var fields []bibField
+	fields = append(fields, bibField{"author", bibNames(b.author), false},
+		bibField{"editor", bibNames(editor), false})
+	if format == BibLaTeX {
+		// an edited volume without an author is a collection in BibLaTeX
+		if len(b.author) == 0 && len(editor) > 0 {
+			entryType = "collection"
+		}
+		fields = append(fields, bibField{"editortype", editorType, false})
+	}
+	fields = append(fields, bibField{"translator", bibNames(b.translator), false})
+	if format == BibLaTeX {
+		fields = append(fields, bibField{"foreword", bibNames(b.foreword), false},
+			bibField{"illustrator", bibNames(b.illustrator), false},
+			bibField{"title", b.title, false}, bibField{"subtitle", b.subtitle, false})
+	} else {
+		fields = append(fields, bibField{"title", b.fullTitle(), false})
+	}
+	fields = append(fields, bibField{"series", b.series, false},
+		bibField{"edition", b.edition.String(), false})
+
+	// BibLaTeX reads the publisher as a list, so "and" would split it
+	fields = append(fields, bibField{"publisher", b.publisher,
+		format == BibLaTeX && strings.Contains(b.publisher, " and ")})
+
+	year := ""
+	if b.year != 0 {
+		year = strconv.Itoa(b.year)
+	}
+	if format == BibLaTeX {
+		fields = append(fields, bibField{"date", year, false})
+	} else {
+		fields = append(fields, bibField{"year", year, false})
+	}
+	fields = append(fields, bibField{"isbn", b.isbn.String(), false})
+
+	var given []bibField
+	for _, f := range fields {
+		if len(f.value) > 0 {
+			given = append(given, f)
+		}
+	}
+	return entryType, given
+}
+
+// writeBibEntry writes a book as a BibTeX or BibLaTeX entry with the given
+// citation key.
+func writeBibEntry(w io.Writer, b Book, key string, format BibFormat) error {
+	entryType, fields := bibEntry(b, format)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@%v{%v,\n", entryType, key)
+	for _, f := range fields {
+		value := bibEscape.Replace(f.value)
+		if f.protect {
+			value = "{" + value + "}"
+		}
+		fmt.Fprintf(&sb, "  %v = {%v},\n", f.name, value)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// exportBib writes the books with the given ids to w as BibTeX or BibLaTeX
+// entries, in the order given, giving the number of books written. Entries are
+// written as the books are loaded, a batch at a time.
+func exportBib(db DBInterface, w io.Writer, ids []int, format BibFormat) (int, error) {
+	keys, err := citationKeys(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportBib: %w", err)
+	}
+
+	count := 0
+	err = eachBook(db, ids, func(b Book) error {
+		if count > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if err := writeBibEntry(w, b, keys[b.id], format); err != nil {
+			return fmt.Errorf("Couldn't write book #%v: %v", b.id, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("exportBib: %w", err)
+	}
+	return count, nil
+}
+
+// exportLibraryBib exports the selected books, in id order.
+func exportLibraryBib(db DBInterface, w io.Writer, sel ExportSelection, format BibFormat) (int, error) {
+	ids, err := sel.bookIds(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportLibraryBib: %w", err)
+	}
+	return exportBib(db, w, ids, format)
+}
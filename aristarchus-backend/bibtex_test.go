@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestCitationKeyBase(t *testing.T) {
+	tests := []struct {
+		name  string
+		year  int
+		title string
+		key   string
+	}{
+		{"Peter J. Gentry", 2018, "Kingdom through Covenant", "gentry2018kingdom"},
+		{"Anselm", 2007, "Basic Writings", "anselm2007basic"},
+		{"R. K. Harrison", 1969, "Introduction to the Old Testament", "harrison1969introduction"},
+		{"Moisés Silva", 2015, "The Invitation", "silva2015invitation"},
+		{"Martin Luther King Jr.", 1963, "A Strength to Love", "king1963strength"},
+		{"Søren Kierkegaard", 1843, "Either/Or", "kierkegaard1843eitheror"},
+		{"", 1611, "The Holy Bible", "anon1611holy"},
+		{"Augustine", 0, "Confessions", "augustineconfessions"},
+		{"Augustine", 400, "The", "augustine400the"},
+	}
+	for _, test := range tests {
+		if key := citationKeyBase(test.name, test.year, test.title); key != test.key {
+			t.Errorf("citationKeyBase(%q, %v, %q): expected %q, got %q", test.name,
+				test.year, test.title, test.key, key)
+		}
+	}
+}
+
+func TestKeySuffix(t *testing.T) {
+	suffixes := map[int]string{0: "a", 1: "b", 25: "z", 26: "aa", 27: "ab", 52: "ba"}
+	for n, expected := range suffixes {
+		if suffix := keySuffix(n); suffix != expected {
+			t.Errorf("keySuffix(%v): expected %q, got %q", n, expected, suffix)
+		}
+	}
+}
+
+func TestCitationKeys(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	keys, err := citationKeys(db)
+	if err != nil {
+		t.Fatalf("citationKeys returned error: %v", err)
+	}
+	expected := map[int]string{1: "harrison1969introduction", 2: "matz2019divine",
+		3: "anselm2007basic", 4: "gentry2017how", 5: "gentry2018kingdom",
+		6: "bavinck2023christianity"}
+	for id, key := range expected {
+		if keys[id] != key {
+			t.Errorf("Book #%v: expected key %q, got %q", id, key, keys[id])
+		}
+	}
+
+	// books which would share a key have letters added, in id order
+	var ids []int
+	for _, title := range []string{"Kingdom Come", "Kingdom Theology"} {
+		b := makeTestBook()
+		b.author = "Peter J. Gentry"
+		b.title = title
+		b.year = 2018
+		b.isbn = ""
+		id, err := addBook(db, b)
+		if err != nil {
+			t.Fatalf("Problem adding test book: %v", err)
+		}
+		defer deleteBook(db, id)
+		ids = append(ids, id)
+	}
+	keys, err = citationKeys(db)
+	if err != nil {
+		t.Fatalf("citationKeys returned error: %v", err)
+	}
+	if keys[5] != "gentry2018kingdom" || keys[ids[0]] != "gentry2018kingdoma" ||
+		keys[ids[1]] != "gentry2018kingdomb" {
+		t.Errorf("Expected keys gentry2018kingdom, a and b, got %v, %v and %v",
+			keys[5], keys[ids[0]], keys[ids[1]])
+	}
+
+	// a book's key doesn't depend on which books are exported
+	var buf bytes.Buffer
+	_, err = exportLibraryBib(db, &buf, ExportSelection{BookId: ids[1]}, BibTeX)
+	if err != nil {
+		t.Fatalf("exportLibraryBib returned error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "@book{gentry2018kingdomb,\n") {
+		t.Errorf("Single book exported with a different key:\n%v", buf.String())
+	}
+}
+
+// Deleting a book mustn't change the keys of the others, which would break
+// citations of them.
+func TestCitationKeysStable(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	addGentry := func(title string) int {
+		b := makeTestBook()
+		b.author = "Peter J. Gentry"
+		b.title = title
+		b.year = 2018
+		b.isbn = ""
+		id, err := addBook(db, b)
+		if err != nil {
+			t.Fatalf("Problem adding test book: %v", err)
+		}
+		return id
+	}
+	first, second := addGentry("Kingdom Come"), addGentry("Kingdom Theology")
+	defer deleteBook(db, first)
+	defer deleteBook(db, second)
+	keys, err := citationKeys(db)
+	if err != nil {
+		t.Fatalf("citationKeys returned error: %v", err)
+	}
+	if keys[first] != "gentry2018kingdoma" || keys[second] != "gentry2018kingdomb" {
+		t.Fatalf("Expected keys gentry2018kingdoma and b, got %v and %v", keys[first],
+			keys[second])
+	}
+
+	if err := deleteBook(db, first); err != nil {
+		t.Fatalf("Problem deleting test book: %v", err)
+	}
+	keys, err = citationKeys(db)
+	if err != nil {
+		t.Fatalf("citationKeys returned error: %v", err)
+	}
+	if keys[5] != "gentry2018kingdom" || keys[second] != "gentry2018kingdomb" {
+		t.Errorf("Keys changed by deleting a book, got %v and %v", keys[5], keys[second])
+	}
+
+	// a new book takes the first key free
+	third := addGentry("Kingdom Ethics")
+	defer deleteBook(db, third)
+	keys, err = citationKeys(db)
+	if err != nil {
+		t.Fatalf("citationKeys returned error: %v", err)
+	}
+	if keys[third] != "gentry2018kingdoma" || keys[second] != "gentry2018kingdomb" {
+		t.Errorf("Expected keys gentry2018kingdoma and b, got %v and %v", keys[third],
+			keys[second])
+	}
+
+	// a book whose title no longer gives its key gets a new one
+	if _, err := updateBookTitle(db, second, "Covenant Theology"); err != nil {
+		t.Fatalf("Problem updating title: %v", err)
+	}
+	keys, err = citationKeys(db)
+	if err != nil {
+		t.Fatalf("citationKeys returned error: %v", err)
+	}
+	if keys[second] != "gentry2018covenant" || keys[third] != "gentry2018kingdoma" {
+		t.Errorf("Expected keys gentry2018covenant and gentry2018kingdoma, got %v and %v",
+			keys[second], keys[third])
+	}
+}
+
+func TestWriteBibEntry(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Fatalf("Problem getting book #5: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeBibEntry(&buf, b, "gentry2018kingdom", BibTeX); err != nil {
+		t.Fatalf("writeBibEntry returned error: %v", err)
+	}
+	expected := `@book{gentry2018kingdom,
+  author = {Peter J. Gentry and Stephen J. Wellum},
+  title = {Kingdom through Covenant: A Biblical-Theological Understanding of the Covenants},
+  edition = {2nd},
+  publisher = {Crossway},
+  year = {2018},
+  isbn = {978-1-4335-5307-3},
+}
+`
+	if buf.String() != expected {
+		t.Errorf("BibTeX entry: expected\n%v\ngot\n%v", expected, buf.String())
+	}
+
+	b, err = getBookById(db, 2)
+	if err != nil {
+		t.Fatalf("Problem getting book #2: %v", err)
+	}
+	b.title = "Divine Impassibility & 100% Emotions"
+	b.publisher = "Wipf and Stock"
+	b.editor = ""
+	b.compiler = "Robert J. Matz"
+	b.edition = Edition{3, "revised"}
+	buf.Reset()
+	if err := writeBibEntry(&buf, b, "matz2019divine", BibLaTeX); err != nil {
+		t.Fatalf("writeBibEntry returned error: %v", err)
+	}
+	expected = `@collection{matz2019divine,
+  editor = {Robert J. Matz},
+  editortype = {compiler},
+  title = {Divine Impassibility \& 100\% Emotions},
+  subtitle = {Four Views of God's Emotions and Suffering},
+  series = {Spectrum Multiview Books},
+  edition = {3rd, revised},
+  publisher = {{Wipf and Stock}},
+  date = {2019},
+  isbn = {978-0-8308-5253-6},
+}
+`
+	if buf.String() != expected {
+		t.Errorf("BibLaTeX entry: expected\n%v\ngot\n%v", expected, buf.String())
+	}
+}
+
+func TestExportBib(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		sel  ExportSelection
+		keys []string
+	}{
+		{ExportSelection{}, []string{"harrison1969introduction", "matz2019divine",
+			"anselm2007basic", "gentry2017how", "gentry2018kingdom",
+			"bavinck2023christianity"}},
+		{ExportSelection{BookId: 3}, []string{"anselm2007basic"}},
+		{ExportSelection{SeriesId: 1}, []string{"matz2019divine"}},
+		{ExportSelection{Query: "author:Gentry"}, []string{"gentry2017how",
+			"gentry2018kingdom"}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		count, err := exportLibraryBib(db, &buf, test.sel, BibLaTeX)
+		if err != nil {
+			t.Errorf("exportLibraryBib(%+v) returned error: %v", test.sel, err)
+			continue
+		}
+		entries := strings.Split(buf.String(), "\n\n")
+		if count != len(test.keys) || len(entries) != len(test.keys) {
+			t.Errorf("exportLibraryBib(%+v): expected %v entries, got %v:\n%v", test.sel,
+				len(test.keys), count, buf.String())
+			continue
+		}
+		for i, key := range test.keys {
+			if !strings.Contains(entries[i], "{"+key+",\n") {
+				t.Errorf("exportLibraryBib(%+v): expected entry %v to be %v, got\n%v",
+					test.sel, i, key, entries[i])
+			}
+		}
+	}
+}
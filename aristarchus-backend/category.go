@@ -0,0 +1,605 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Categories form a tree, e.g. Theology -> Systematic Theology -> Doctrine of
+// God, held in the category table with each category linking to its parent.
+// A category with no parent is at the top level, and is given parent id 0 in
+// the functions below. Books are placed in any number of categories with the
+// book_category table, and a category's books include those of all the
+// categories below it.
+
+type Category struct {
+	id       int
+	name     string
+	parentId int
+}
+
+// CategoryNode is a category within the category tree, along with the number
+// of books placed directly in it and the number of distinct books in it and
+// all its descendants.
+type CategoryNode struct {
+	Category
+	children   []*CategoryNode
+	bookCount  int
+	totalCount int
+}
+
+type InvalidCategoryIdError struct {
+	CallFunc   string
+	CategoryId int
+}
+
+func (e *InvalidCategoryIdError) Error() string {
+	return fmt.Sprintf("%v: Unknown category ID #%v", e.CallFunc, e.CategoryId)
+}
+
+type DuplicateCategoryError struct {
+	CallFunc string
+	Name     string
+	ParentId int
+	Id       int
+}
+
+func (e *DuplicateCategoryError) Error() string {
+	return fmt.Sprintf("%v: Category \"%v\" already exists under parent #%v, id #%v",
+		e.CallFunc, e.Name, e.ParentId, e.Id)
+}
+
+type CategoryCycleError struct {
+	CallFunc   string
+	CategoryId int
+	ParentId   int
+}
+
+func (e *CategoryCycleError) Error() string {
+	return fmt.Sprintf("%v: Cannot place category #%v under #%v, which is itself or one of its descendants",
+		e.CallFunc, e.CategoryId, e.ParentId)
+}
+
+func categoryIdValid(db DBInterface, id int) (bool, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM category WHERE category_id = ?",
+		id).Scan(&count); err != nil {
+		return false, fmt.Errorf("categoryIdValid, problem reading from DB: %v", err)
+	}
+	return count == 1, nil
+}
+
+func nullParent(parentId int) sql.NullInt64 {
+	if parentId == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(parentId), Valid: true}
+}
+
+// siblingCategoryId gives the id of the category with the given name under a
+// parent, or 0 if there isn't one.
+func siblingCategoryId(db DBInterface, name string, parentId int) (int, error) {
+	var id int
+	err := db.QueryRow(`
+        SELECT category_id
+        FROM category
+        WHERE name = ? AND parent_id IS NOT DISTINCT FROM ?`,
+		name, nullParent(parentId)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("siblingCategoryId, %v", err)
+	}
+	return id, nil
+}
+
+func getCategoryById(db DBInterface, id int) (Category, error) {
+	var c Category
+	var parentId sql.NullInt64
+	err := db.QueryRow("SELECT category_id, name, parent_id FROM category WHERE category_id = ?",
+		id).Scan(&c.id, &c.name, &parentId)
+	if err == sql.ErrNoRows {
+		return Category{}, &InvalidCategoryIdError{"getCategoryById", id}
+	}
+	if err != nil {
+		return Category{}, fmt.Errorf("getCategoryById %d: %v", id, err)
+	}
+	c.parentId = int(parentId.Int64)
+	return c, nil
+}
+
+// categoryPath gives the names of the category and its ancestors, starting
+// from the top level.
+func categoryPath(db DBInterface, id int) ([]string, error) {
+	var path []string
+	for id != 0 {
+		c, err := getCategoryById(db, id)
+		if err != nil {
+			return nil, fmt.Errorf("categoryPath: %w", err)
+		}
+		path = append([]string{c.name}, path...)
+		id = c.parentId
+	}
+	return path, nil
+}
+
+func formatCategoryPath(path []string) string {
+	return strings.Join(path, " → ")
+}
+
+func addCategory(db DBInterface, name string, parentId int) (int, error) {
+	if len(name) == 0 {
+		return 0, fmt.Errorf("addCategory: Category name cannot be empty")
+	}
+	if parentId != 0 {
+		parentValid, err := categoryIdValid(db, parentId)
+		if err != nil {
+			return 0, fmt.Errorf("addCategory: %v", err)
+		}
+		if !parentValid {
+			return 0, &InvalidCategoryIdError{"addCategory", parentId}
+		}
+	}
+
+	existing, err := siblingCategoryId(db, name, parentId)
+	if err != nil {
+		return 0, fmt.Errorf("addCategory: %v", err)
+	}
+	if existing != 0 {
+		return existing, &DuplicateCategoryError{"addCategory", name, parentId, existing}
+	}
+
+	var id int
+	err = db.QueryRow(`INSERT INTO category (name, parent_id) VALUES (?, ?)
+                       RETURNING category_id`, name, nullParent(parentId)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("addCategory, Couldn't add category %v: %v", name, err)
+	}
+	return id, nil
+}
+
+func renameCategory(db DBInterface, id int, name string) (string, error) {
+	if len(name) == 0 {
+		return "", fmt.Errorf("renameCategory: Category name cannot be empty")
+	}
+	c, err := getCategoryById(db, id)
+	if err != nil {
+		return "", fmt.Errorf("renameCategory: %w", err)
+	}
+
+	existing, err := siblingCategoryId(db, name, c.parentId)
+	if err != nil {
+		return "", fmt.Errorf("renameCategory: %v", err)
+	}
+	if existing != 0 && existing != id {
+		return c.name, &DuplicateCategoryError{"renameCategory", name, c.parentId, existing}
+	}
+
+	_, err = db.Exec("UPDATE category SET name = ? WHERE category_id = ?", name, id)
+	if err != nil {
+		return "", fmt.Errorf("renameCategory, Couldn't rename category #%v: %v", id, err)
+	}
+
+	var updatedName string
+	if err := db.QueryRow("SELECT name FROM category WHERE category_id = ?",
+		id).Scan(&updatedName); err != nil {
+		return "", fmt.Errorf("renameCategory, Couldn't get updated name: %v", err)
+	}
+	if updatedName != name {
+		return "", fmt.Errorf("renameCategory, Updated name \"%v\" is not desired new name \"%v\"",
+			updatedName, name)
+	}
+	return updatedName, nil
+}
+
+// categoryDescendants gives the ids of the category and all categories below
+// it.
+func categoryDescendants(db DBInterface, id int) ([]int, error) {
+	sqlStmt := `
+        WITH RECURSIVE subtree(category_id) AS (
+          SELECT CAST(? AS INTEGER)
+          UNION
+          SELECT category.category_id
+          FROM category
+          INNER JOIN subtree
+            ON category.parent_id = subtree.category_id
+        )
+        SELECT category_id FROM subtree ORDER BY category_id`
+
+	rows, err := db.Query(sqlStmt, id)
+	if err != nil {
+		return nil, fmt.Errorf("categoryDescendants, %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var cid int
+		if err := rows.Scan(&cid); err != nil {
+			return nil, fmt.Errorf("categoryDescendants, %v", err)
+		}
+		ids = append(ids, cid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("categoryDescendants, rows.Next() error: %v", err)
+	}
+	return ids, nil
+}
+
+// moveCategory places a category, along with everything below it, under a new
+// parent, or at the top level if parentId is 0.
+func moveCategory(db DBInterface, id int, parentId int) (int, error) {
+	c, err := getCategoryById(db, id)
+	if err != nil {
+		return 0, fmt.Errorf("moveCategory: %w", err)
+	}
+
+	if parentId != 0 {
+		parentValid, err := categoryIdValid(db, parentId)
+		if err != nil {
+			return c.parentId, fmt.Errorf("moveCategory: %v", err)
+		}
+		if !parentValid {
+			return c.parentId, &InvalidCategoryIdError{"moveCategory", parentId}
+		}
+		descendants, err := categoryDescendants(db, id)
+		if err != nil {
+			return c.parentId, fmt.Errorf("moveCategory: %v", err)
+		}
+		if slices.Contains(descendants, parentId) {
+			return c.parentId, &CategoryCycleError{"moveCategory", id, parentId}
+		}
+	}
+
+	existing, err := siblingCategoryId(db, c.name, parentId)
+	if err != nil {
+		return c.parentId, fmt.Errorf("moveCategory: %v", err)
+	}
+	if existing != 0 && existing != id {
+		return c.parentId, &DuplicateCategoryError{"moveCategory", c.name, parentId, existing}
+	}
+
+	_, err = db.Exec("UPDATE category SET parent_id = ? WHERE category_id = ?",
+		nullParent(parentId), id)
+	if err != nil {
+		return c.parentId, fmt.Errorf("moveCategory, Couldn't move category #%v: %v", id, err)
+	}
+
+	var updatedParent sql.NullInt64
+	if err := db.QueryRow("SELECT parent_id FROM category WHERE category_id = ?",
+		id).Scan(&updatedParent); err != nil {
+		return 0, fmt.Errorf("moveCategory, Couldn't get updated parent: %v", err)
+	}
+	if int(updatedParent.Int64) != parentId {
+		return 0, fmt.Errorf("moveCategory, Updated parent #%v is not requested parent #%v",
+			updatedParent.Int64, parentId)
+	}
+	return parentId, nil
+}
+
+// mergeCategories moves the books and subcategories of one category into
+// another, and then deletes it. Subcategories with the same name as one
+// already in the target are merged in turn.
+func mergeCategories(db *sql.DB, fromId int, intoId int) error {
+	if fromId == intoId {
+		return &CategoryCycleError{"mergeCategories", fromId, intoId}
+	}
+	for _, id := range []int{fromId, intoId} {
+		valid, err := categoryIdValid(db, id)
+		if err != nil {
+			return fmt.Errorf("mergeCategories: %v", err)
+		}
+		if !valid {
+			return &InvalidCategoryIdError{"mergeCategories", id}
+		}
+	}
+	descendants, err := categoryDescendants(db, fromId)
+	if err != nil {
+		return fmt.Errorf("mergeCategories: %v", err)
+	}
+	if slices.Contains(descendants, intoId) {
+		return &CategoryCycleError{"mergeCategories", fromId, intoId}
+	}
+
+	tx, err := beginTx(db)
+	if err != nil {
+		return fmt.Errorf("mergeCategories, Couldn't start sql transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := mergeCategoryInto(tx, fromId, intoId); err != nil {
+		return fmt.Errorf("mergeCategories: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("mergeCategories, issue merging categories: %v", err)
+	}
+	return nil
+}
+
+func mergeCategoryInto(db DBInterface, fromId int, intoId int) error {
+	_, err := db.Exec(`
+        INSERT INTO book_category (book_id, category_id)
+        SELECT book_id, CAST(? AS INTEGER) FROM book_category WHERE category_id = ?
+        ON CONFLICT DO NOTHING`,
+		intoId, fromId)
+	if err != nil {
+		return fmt.Errorf("Couldn't move books to category #%v: %v", intoId, err)
+	}
+	if _, err := db.Exec("DELETE FROM book_category WHERE category_id = ?",
+		fromId); err != nil {
+		return fmt.Errorf("Couldn't remove books from category #%v: %v", fromId, err)
+	}
+
+	children, err := childCategories(db, fromId)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		existing, err := siblingCategoryId(db, child.name, intoId)
+		if err != nil {
+			return err
+		}
+		if existing != 0 {
+			if err := mergeCategoryInto(db, child.id, existing); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := db.Exec("UPDATE category SET parent_id = ? WHERE category_id = ?",
+			intoId, child.id); err != nil {
+			return fmt.Errorf("Couldn't move category #%v: %v", child.id, err)
+		}
+	}
+
+	if _, err := db.Exec("DELETE FROM category WHERE category_id = ?",
+		fromId); err != nil {
+		return fmt.Errorf("Couldn't delete category #%v: %v", fromId, err)
+	}
+	return nil
+}
+
+func childCategories(db DBInterface, parentId int) ([]Category, error) {
+	rows, err := db.Query(`
+        SELECT category_id, name
+        FROM category
+        WHERE parent_id IS NOT DISTINCT FROM ?
+        ORDER BY name`, nullParent(parentId))
+	if err != nil {
+		return nil, fmt.Errorf("childCategories, %v", err)
+	}
+	defer rows.Close()
+
+	var children []Category
+	for rows.Next() {
+		c := Category{parentId: parentId}
+		if err := rows.Scan(&c.id, &c.name); err != nil {
+			return nil, fmt.Errorf("childCategories, %v", err)
+		}
+		children = append(children, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("childCategories, rows.Next() error: %v", err)
+	}
+	return children, nil
+}
+
+func addBookCategory(db DBInterface, bookId int, categoryId int) error {
+	bookValid, err := BookIDValid(db, bookId)
+	if err != nil {
+		return fmt.Errorf("addBookCategory: %v", err)
+	}
+	if !bookValid {
+		return &InvalidBookIdError{"addBookCategory", bookId}
+	}
+	categoryValid, err := categoryIdValid(db, categoryId)
+	if err != nil {
+		return fmt.Errorf("addBookCategory: %v", err)
+	}
+	if !categoryValid {
+		return &InvalidCategoryIdError{"addBookCategory", categoryId}
+	}
+
+	_, err = db.Exec(`INSERT INTO book_category (book_id, category_id) VALUES (?, ?)
+                      ON CONFLICT DO NOTHING`, bookId, categoryId)
+	if err != nil {
+		return fmt.Errorf("addBookCategory, Couldn't add book #%v to category #%v: %v",
+			bookId, categoryId, err)
+	}
+	return nil
+}
+
+func removeBookCategory(db DBInterface, bookId int, categoryId int) error {
+	result, err := db.Exec("DELETE FROM book_category WHERE book_id = ? AND category_id = ?",
+		bookId, categoryId)
+	if err != nil {
+		return fmt.Errorf("removeBookCategory, Couldn't remove book #%v from category #%v: %v",
+			bookId, categoryId, err)
+	}
+	if changed, err := result.RowsAffected(); err == nil && changed == 0 {
+		return fmt.Errorf("removeBookCategory: Book #%v is not in category #%v",
+			bookId, categoryId)
+	}
+	return nil
+}
+
+// getCategoriesByBookId gives the categories a book is placed in directly,
+// ordered by id.
+func getCategoriesByBookId(db DBInterface, bookId int) ([]Category, error) {
+	bookValid, err := BookIDValid(db, bookId)
+	if err != nil {
+		return nil, fmt.Errorf("getCategoriesByBookId: %v", err)
+	}
+	if !bookValid {
+		return nil, &InvalidBookIdError{"getCategoriesByBookId", bookId}
+	}
+
+	rows, err := db.Query(`
+        SELECT category.category_id, category.name, category.parent_id
+        FROM book_category
+        INNER JOIN category
+          ON book_category.category_id = category.category_id
+        WHERE book_category.book_id = ?
+        ORDER BY category.category_id`, bookId)
+	if err != nil {
+		return nil, fmt.Errorf("getCategoriesByBookId, %v", err)
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		var c Category
+		var parentId sql.NullInt64
+		if err := rows.Scan(&c.id, &c.name, &parentId); err != nil {
+			return nil, fmt.Errorf("getCategoriesByBookId, %v", err)
+		}
+		c.parentId = int(parentId.Int64)
+		categories = append(categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getCategoriesByBookId, rows.Next() error: %v", err)
+	}
+	return categories, nil
+}
+
+// categoryBooks gives the ids of the books in a category or any category below
+// it.
+func categoryBooks(db DBInterface, id int) ([]int, error) {
+	valid, err := categoryIdValid(db, id)
+	if err != nil {
+		return nil, fmt.Errorf("categoryBooks: %v", err)
+	}
+	if !valid {
+		return nil, &InvalidCategoryIdError{"categoryBooks", id}
+	}
+
+	sqlStmt := `
+        WITH RECURSIVE subtree(category_id) AS (
+          SELECT CAST(? AS INTEGER)
+          UNION
+          SELECT category.category_id
+          FROM category
+          INNER JOIN subtree
+            ON category.parent_id = subtree.category_id
+        )
+        SELECT DISTINCT book_id
+        FROM book_category
+        WHERE category_id IN (SELECT category_id FROM subtree)
+        ORDER BY book_id`
+
+	rows, err := db.Query(sqlStmt, id)
+	if err != nil {
+		return nil, fmt.Errorf("categoryBooks, %v", err)
+	}
+	defer rows.Close()
+
+	var bookList []int
+	for rows.Next() {
+		var bookId int
+		if err := rows.Scan(&bookId); err != nil {
+			return nil, fmt.Errorf("categoryBooks, %v", err)
+		}
+		bookList = append(bookList, bookId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("categoryBooks, rows.Next() error: %v", err)
+	}
+	return bookList, nil
+}
+
+// categoryTree gives the top level categories, each with its subcategories
+// and book counts, ordered by name at each level.
+func categoryTree(db DBInterface) ([]*CategoryNode, error) {
+	rows, err := db.Query("SELECT category_id, name, parent_id FROM category ORDER BY name, category_id")
+	if err != nil {
+		return nil, fmt.Errorf("categoryTree, %v", err)
+	}
+	defer rows.Close()
+
+	nodes := make(map[int]*CategoryNode)
+	var order []int
+	for rows.Next() {
+		var n CategoryNode
+		var parentId sql.NullInt64
+		if err := rows.Scan(&n.id, &n.name, &parentId); err != nil {
+			return nil, fmt.Errorf("categoryTree, %v", err)
+		}
+		n.parentId = int(parentId.Int64)
+		nodes[n.id] = &n
+		order = append(order, n.id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("categoryTree, rows.Next() error: %v", err)
+	}
+	rows.Close()
+
+	bookRows, err := db.Query("SELECT book_id, category_id FROM book_category")
+	if err != nil {
+		return nil, fmt.Errorf("categoryTree, %v", err)
+	}
+	defer bookRows.Close()
+
+	booksIn := make(map[int][]int)
+	for bookRows.Next() {
+		var bookId, categoryId int
+		if err := bookRows.Scan(&bookId, &categoryId); err != nil {
+			return nil, fmt.Errorf("categoryTree, %v", err)
+		}
+		booksIn[categoryId] = append(booksIn[categoryId], bookId)
+	}
+	if err := bookRows.Err(); err != nil {
+		return nil, fmt.Errorf("categoryTree, rows.Next() error: %v", err)
+	}
+
+	var roots []*CategoryNode
+	for _, id := range order {
+		n := nodes[id]
+		n.bookCount = len(booksIn[id])
+		if parent, ok := nodes[n.parentId]; ok {
+			parent.children = append(parent.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	// count distinct books below each node, as a book may be in more than one
+	// category of a subtree
+	var countBooks func(n *CategoryNode) map[int]bool
+	countBooks = func(n *CategoryNode) map[int]bool {
+		books := make(map[int]bool)
+		for _, b := range booksIn[n.id] {
+			books[b] = true
+		}
+		for _, child := range n.children {
+			for b := range countBooks(child) {
+				books[b] = true
+			}
+		}
+		n.totalCount = len(books)
+		return books
+	}
+	for _, root := range roots {
+		countBooks(root)
+	}
+
+	return roots, nil
+}
+
+// String gives the node and its descendants as an indented tree, with the
+// total number of books under each node.
+func (n *CategoryNode) String() string {
+	var sb strings.Builder
+	var write func(n *CategoryNode, depth int)
+	write = func(n *CategoryNode, depth int) {
+		fmt.Fprintf(&sb, "%v%v (%v)\n", strings.Repeat("  ", depth), n.name,
+			n.totalCount)
+		for _, child := range n.children {
+			write(child, depth+1)
+		}
+	}
+	write(n, 0)
+	return sb.String()
+}
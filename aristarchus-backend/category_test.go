@@ -0,0 +1,319 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestGetCategoryById(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := Category{3, "Doctrine of God", 2}
+	c, err := getCategoryById(db, 3)
+	if err != nil {
+		t.Errorf("getCategoryById returned error: %v", err)
+	}
+	if c != expected {
+		t.Errorf("getCategoryById returned unexpected category. Expected %v, got %v",
+			expected, c)
+	}
+
+	_, err = getCategoryById(db, 1000)
+	var invIdErr *InvalidCategoryIdError
+	if !errors.As(err, &invIdErr) {
+		t.Errorf("getCategoryById returned unexpected error for invalid id: %v", err)
+	}
+}
+
+func TestCategoryPath(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := "Theology → Systematic Theology → Doctrine of God"
+	path, err := categoryPath(db, 3)
+	if err != nil {
+		t.Errorf("categoryPath returned error: %v", err)
+	}
+	if formatCategoryPath(path) != expected {
+		t.Errorf("categoryPath returned unexpected path. Expected %v, got %v",
+			expected, formatCategoryPath(path))
+	}
+}
+
+func TestCategoryBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	categories := map[int][]int{
+		1: {1, 2, 3, 4, 5, 6},
+		2: {2, 3, 6},
+		3: {2, 3},
+		4: {1, 4, 5},
+		5: {1, 4},
+		6: {3},
+	}
+	for id, expected := range categories {
+		books, err := categoryBooks(db, id)
+		if err != nil {
+			t.Errorf("categoryBooks returned error for category #%v: %v", id, err)
+		}
+		if !slices.Equal(books, expected) {
+			t.Errorf("categoryBooks returned unexpected books for category #%v. Expected %v, got %v",
+				id, expected, books)
+		}
+	}
+
+	_, err = categoryBooks(db, 1000)
+	var invIdErr *InvalidCategoryIdError
+	if !errors.As(err, &invIdErr) {
+		t.Errorf("categoryBooks returned unexpected error for invalid id: %v", err)
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	roots, err := categoryTree(db)
+	if err != nil {
+		t.Fatalf("categoryTree returned error: %v", err)
+	}
+
+	expected := []string{
+		"Philosophy (1)\n",
+		"Theology (6)\n" +
+			"  Biblical Theology (3)\n" +
+			"    Old Testament (2)\n" +
+			"  Systematic Theology (3)\n" +
+			"    Doctrine of God (2)\n",
+	}
+	var trees []string
+	for _, r := range roots {
+		trees = append(trees, r.String())
+	}
+	if !slices.Equal(trees, expected) {
+		t.Errorf("categoryTree returned unexpected tree. Expected\n%v\ngot\n%v",
+			expected, trees)
+	}
+
+	biblical := roots[1].children[0]
+	if biblical.bookCount != 1 || biblical.totalCount != 3 {
+		t.Errorf("Unexpected counts for %v: %v direct, %v total", biblical.name,
+			biblical.bookCount, biblical.totalCount)
+	}
+}
+
+func TestAddRenameMoveCategory(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id, err := addCategory(db, "Ethic", 0)
+	if err != nil {
+		t.Fatalf("addCategory returned error: %v", err)
+	}
+	defer db.Exec("DELETE FROM category WHERE category_id = ?", id)
+
+	name, err := renameCategory(db, id, "Ethics")
+	if err != nil || name != "Ethics" {
+		t.Errorf("renameCategory returned %v, %v", name, err)
+	}
+
+	parent, err := moveCategory(db, id, 1)
+	if err != nil || parent != 1 {
+		t.Errorf("moveCategory returned %v, %v", parent, err)
+	}
+	path, err := categoryPath(db, id)
+	if err != nil || formatCategoryPath(path) != "Theology → Ethics" {
+		t.Errorf("Moved category has unexpected path %v, %v", path, err)
+	}
+
+	parent, err = moveCategory(db, id, 0)
+	if err != nil || parent != 0 {
+		t.Errorf("moveCategory to top level returned %v, %v", parent, err)
+	}
+}
+
+func TestAddCategoryDuplicate(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id, err := addCategory(db, "Doctrine of God", 2)
+	var dupErr *DuplicateCategoryError
+	if !errors.As(err, &dupErr) {
+		t.Errorf("addCategory returned unexpected error for duplicate: %v", err)
+	}
+	if id != 3 {
+		t.Errorf("addCategory returned id %v for duplicate, expected 3", id)
+	}
+
+	// categories with different parents can share a name
+	if _, err := renameCategory(db, 5, "Biblical Theology"); err != nil {
+		t.Errorf("renameCategory returned error for name used elsewhere: %v", err)
+	}
+	if _, err := renameCategory(db, 5, "Old Testament"); err != nil {
+		t.Errorf("renameCategory to same name returned error: %v", err)
+	}
+	_, err = renameCategory(db, 4, "Systematic Theology")
+	if !errors.As(err, &dupErr) {
+		t.Errorf("renameCategory returned unexpected error for duplicate: %v", err)
+	}
+}
+
+func TestMoveCategoryCycle(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, parent := range []int{1, 2, 3} {
+		_, err = moveCategory(db, 1, parent)
+		var cycleErr *CategoryCycleError
+		if !errors.As(err, &cycleErr) {
+			t.Errorf("moveCategory under descendant #%v returned unexpected error: %v",
+				parent, err)
+		}
+	}
+
+	_, err = moveCategory(db, 2, 1000)
+	var invIdErr *InvalidCategoryIdError
+	if !errors.As(err, &invIdErr) {
+		t.Errorf("moveCategory returned unexpected error for invalid parent: %v", err)
+	}
+}
+
+func TestMergeCategories(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// Build a duplicate "Theology" tree with a book and an overlapping
+	// subcategory, and merge it into the original
+	dupId, err := addCategory(db, "Theology (old)", 0)
+	if err != nil {
+		t.Fatalf("Problem adding category: %v", err)
+	}
+	dupSubId, err := addCategory(db, "Biblical Theology", dupId)
+	if err != nil {
+		t.Fatalf("Problem adding category: %v", err)
+	}
+	newSubId, err := addCategory(db, "Historical Theology", dupId)
+	if err != nil {
+		t.Fatalf("Problem adding category: %v", err)
+	}
+	defer db.Exec("DELETE FROM category WHERE category_id IN (?, ?, ?)", dupId,
+		dupSubId, newSubId)
+	if err := addBookCategory(db, 6, dupSubId); err != nil {
+		t.Errorf("addBookCategory returned error: %v", err)
+	}
+	if err := addBookCategory(db, 3, newSubId); err != nil {
+		t.Errorf("addBookCategory returned error: %v", err)
+	}
+
+	if err := mergeCategories(db, dupId, 1); err != nil {
+		t.Fatalf("mergeCategories returned error: %v", err)
+	}
+
+	for _, id := range []int{dupId, dupSubId} {
+		if valid, _ := categoryIdValid(db, id); valid {
+			t.Errorf("Merged category #%v still exists", id)
+		}
+	}
+	books, err := categoryBooks(db, 4)
+	if err != nil || !slices.Equal(books, []int{1, 4, 5, 6}) {
+		t.Errorf("Books not merged into Biblical Theology: %v, %v", books, err)
+	}
+	moved, err := getCategoryById(db, newSubId)
+	if err != nil || moved.parentId != 1 {
+		t.Errorf("Subcategory not moved into Theology: %v, %v", moved, err)
+	}
+
+	// Revert database back to original state
+	if err := removeBookCategory(db, 6, 4); err != nil {
+		t.Errorf("Problem reverting book categories: %v", err)
+	}
+	if err := removeBookCategory(db, 3, newSubId); err != nil {
+		t.Errorf("Problem reverting book categories: %v", err)
+	}
+}
+
+func TestMergeCategoriesCycle(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, into := range []int{1, 3} {
+		err = mergeCategories(db, 1, into)
+		var cycleErr *CategoryCycleError
+		if !errors.As(err, &cycleErr) {
+			t.Errorf("mergeCategories into #%v returned unexpected error: %v", into, err)
+		}
+	}
+}
+
+func TestGetCategoriesByBookId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []Category{{3, "Doctrine of God", 2}, {6, "Philosophy", 0}}
+	categories, err := getCategoriesByBookId(db, 3)
+	if err != nil {
+		t.Errorf("getCategoriesByBookId returned error: %v", err)
+	}
+	if !slices.Equal(categories, expected) {
+		t.Errorf("getCategoriesByBookId returned unexpected categories. Expected %v, got %v",
+			expected, categories)
+	}
+}
+
+func TestDeleteBookRemovesCategories(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id, err := addBook(db, makeTestBook())
+	if err != nil {
+		t.Fatalf("Problem adding book: %v", err)
+	}
+	if err := addBookCategory(db, id, 5); err != nil {
+		t.Errorf("addBookCategory returned error: %v", err)
+	}
+	if err := deleteBook(db, id); err != nil {
+		t.Errorf("deleteBook returned error: %v", err)
+	}
+
+	books, err := categoryBooks(db, 5)
+	if err != nil || !slices.Equal(books, []int{1, 4}) {
+		t.Errorf("Deleted book still in category: %v, %v", books, err)
+	}
+}
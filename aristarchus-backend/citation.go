@@ -0,0 +1,549 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Citations of books are given in three styles:
+//
+//	Chicago  The Chicago Manual of Style, 17th edition, notes and bibliography
+//	SBL      The SBL Handbook of Style, 2nd edition, which follows Chicago for
+//	         books but lists every author in the bibliography
+//	APA      The Publication Manual of the APA, 7th edition, whose reference
+//	         list entries give no place of publication nor series, and whose
+//	         in-text citations are the note form
+//
+// Titles are given as held rather than changed to sentence case for APA, as
+// proper nouns can't be told apart from other words.
+
+type CitationStyle int
+
+const (
+	Chicago CitationStyle = iota
+	SBL
+	APA
+)
+
+var citationStyleNames = []string{"chicago", "sbl", "apa"}
+
+func (s CitationStyle) String() string {
+	return citationStyleNames[s]
+}
+
+// CitationForm is whether a citation is for a bibliography, or reference list,
+// or for a footnote, or in-text citation in APA.
+type CitationForm int
+
+const (
+	BibliographyForm CitationForm = iota
+	NoteForm
+)
+
+var citationFormNames = []string{"bibliography", "note"}
+
+func (f CitationForm) String() string {
+	return citationFormNames[f]
+}
+
+// TextFormat is the markup citations are written in, which shows titles in
+// italics in Markdown and HTML.
+type TextFormat int
+
+const (
+	PlainText TextFormat = iota
+	Markdown
+	HTML
+)
+
+var textFormatNames = []string{"text", "markdown", "html"}
+
+func (f TextFormat) String() string {
+	return textFormatNames[f]
+}
+
+type CitationOptions struct {
+	Style  CitationStyle
+	Form   CitationForm
+	Format TextFormat
+}
+
+func parseCitationStyle(s string) (CitationStyle, error) {
+	i := slices.Index(citationStyleNames, strings.ToLower(s))
+	if i < 0 {
+		return 0, &BadRequestError{"parseCitationStyle", fmt.Sprintf(
+			"Unknown citation style \"%v\", must be one of %v", s,
+			strings.Join(citationStyleNames, ", "))}
+	}
+	return CitationStyle(i), nil
+}
+
+func parseCitationForm(s string) (CitationForm, error) {
+	i := slices.Index(citationFormNames, strings.ToLower(s))
+	if i < 0 {
+		return 0, &BadRequestError{"parseCitationForm", fmt.Sprintf(
+			"Unknown citation form \"%v\", must be one of %v", s,
+			strings.Join(citationFormNames, ", "))}
+	}
+	return CitationForm(i), nil
+}
+
+func parseTextFormat(s string) (TextFormat, error) {
+	i := slices.Index(textFormatNames, strings.ToLower(s))
+	if i < 0 {
+		return 0, &BadRequestError{"parseTextFormat", fmt.Sprintf(
+			"Unknown format \"%v\", must be one of %v", s,
+			strings.Join(textFormatNames, ", "))}
+	}
+	return TextFormat(i), nil
+}
+
+// citationPart is a run of the text of a citation, which is italic for
+// titles.
+type citationPart struct {
+	text   string
+	italic bool
+}
+
+type citation []citationPart
+
+func (c *citation) add(text string) {
+	if len(text) > 0 {
+		*c = append(*c, citationPart{text, false})
+	}
+}
+
+func (c *citation) addTitle(title string) {
+	if len(title) > 0 {
+		*c = append(*c, citationPart{title, true})
+	}
+}
+
+// stop ends a sentence with a full stop, unless the text already ends with a
+// stop, as after an initial or a title ending in a question mark.
+func (c *citation) stop() {
+	if n := len(*c); n > 0 {
+		last := (*c)[n-1].text
+		if strings.HasSuffix(last, ".") || strings.HasSuffix(last, "?") ||
+			strings.HasSuffix(last, "!") {
+			return
+		}
+	}
+	c.add(".")
+}
+
+var markdownEscape = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`",
+	`[`, `\[`, `]`, `\]`, `<`, `\<`)
+
+func (c citation) format(f TextFormat) string {
+	var sb strings.Builder
+	for _, part := range c {
+		switch f {
+		case Markdown:
+			text := markdownEscape.Replace(part.text)
+			if part.italic {
+				text = "*" + text + "*"
+			}
+			sb.WriteString(text)
+		case HTML:
+			text := html.EscapeString(part.text)
+			if part.italic {
+				text = "<i>" + text + "</i>"
+			}
+			sb.WriteString(text)
+		default:
+			sb.WriteString(part.text)
+		}
+	}
+	return sb.String()
+}
+
+// invertedName gives a name surname first, as the first name of a
+// bibliography entry, e.g. "Gentry, Peter J.".
+func invertedName(name string) string {
+	given, family, suffix := splitPersonName(name)
+	if len(given) == 0 {
+		return joinPersonName("", family, suffix)
+	}
+	if len(suffix) > 0 {
+		return fmt.Sprintf("%v, %v, %v", family, given, suffix)
+	}
+	return fmt.Sprintf("%v, %v", family, given)
+}
+
+// initials gives the initials of given names, e.g. "P. J." for "Peter J.", or
+// "J.-P." for "Jean-Paul".
+func initials(given string) string {
+	var words []string
+	for _, word := range strings.Fields(given) {
+		var parts []string
+		for _, part := range strings.Split(word, "-") {
+			if r, _ := utf8.DecodeRuneInString(part); r != utf8.RuneError {
+				parts = append(parts, string(unicode.ToUpper(r))+".")
+			}
+		}
+		words = append(words, strings.Join(parts, "-"))
+	}
+	return strings.Join(words, " ")
+}
+
+// apaName gives a name as APA does, surname first with initials, e.g.
+// "Gentry, P. J.", or with initials first, e.g. "P. J. Gentry".
+func apaName(name string, inverted bool) string {
+	given, family, suffix := splitPersonName(name)
+	given = initials(given)
+	switch {
+	case len(given) == 0:
+		return joinPersonName("", family, suffix)
+	case !inverted:
+		return joinPersonName(given, family, suffix)
+	case len(suffix) > 0:
+		return fmt.Sprintf("%v, %v, %v", family, given, suffix)
+	default:
+		return fmt.Sprintf("%v, %v", family, given)
+	}
+}
+
+// surname gives the name a person is cited by in the text.
+func surname(name string) string {
+	_, family, _ := splitPersonName(name)
+	return family
+}
+
+// joinNames joins names as Chicago does, e.g. "A and B" or "A, B, and C". With
+// the first name inverted, a comma always comes before "and".
+func joinNames(names []string, firstInverted bool) string {
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) == 1:
+		return names[0]
+	case len(names) == 2 && !firstInverted:
+		return names[0] + " and " + names[1]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+	}
+}
+
+// joinApaNames joins names as APA does, with an ampersand before the last.
+// Reference list entries give up to 20 names, and otherwise the first 19, an
+// ellipsis and the last.
+func joinApaNames(names []string, inverted bool) string {
+	if len(names) > 20 {
+		return strings.Join(names[:19], ", ") + ", . . . " + names[len(names)-1]
+	}
+	switch {
+	case len(names) == 0:
+		return ""
+	case len(names) == 1:
+		return names[0]
+	case len(names) == 2 && !inverted:
+		return names[0] + " & " + names[1]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + ", & " + names[len(names)-1]
+	}
+}
+
+// citationEdition gives an edition as citations abbreviate it, e.g. "2nd ed."
+// or "3rd, revised ed.".
+func citationEdition(e Edition) string {
+	if e.isZero() {
+		return ""
+	}
+	description := strings.TrimSpace(e.description)
+	if d, ok := strings.CutSuffix(description, "edition"); ok {
+		description = strings.TrimSpace(d)
+	}
+	var parts []string
+	if e.number > 0 {
+		parts = append(parts, ordinal(e.number))
+	}
+	if len(description) > 0 {
+		parts = append(parts, description)
+	}
+	return strings.Join(parts, ", ") + " ed."
+}
+
+func capitalise(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// citationRole is a contributor role as named in citations, giving the role
+// of the names leading a citation, and of names after the title in notes and
+// bibliographies.
+type citationRole struct {
+	role               ContributorRole
+	lead, leadPlural   string // e.g. "ed." and "eds."
+	note, bibliography string // e.g. "trans." and "Translated by"
+	apa, apaPlural     string // e.g. "Ed." and "Eds."
+}
+
+// citationRoles are in the order names are given after the title. Writers of
+// forewords aren't given in APA.
+var citationRoles = []citationRole{
+	{RoleEditor, "ed.", "eds.", "ed.", "Edited by", "Ed.", "Eds."},
+	{RoleCompiler, "comp.", "comps.", "comp.", "Compiled by", "Comp.", "Comps."},
+	{RoleTranslator, "", "", "trans.", "Translated by", "Trans.", "Trans."},
+	{RoleIllustrator, "", "", "illus.", "Illustrated by", "Illus.", "Illus."},
+	{RoleForeword, "", "", "with a foreword by", "With a foreword by", "", ""},
+}
+
+// citedBook holds the parts of a book a citation is made from.
+type citedBook struct {
+	book     Book
+	location string
+	lead     []string      // the names the citation starts with
+	leadRole *citationRole // the role of the lead names, or nil for authors
+	others   []citationRole
+}
+
+func newCitedBook(b Book, location string) citedBook {
+	c := citedBook{book: b, location: location}
+	c.lead = nameListFromString(b.author)
+	for i := range citationRoles {
+		role := &citationRoles[i]
+		if len(c.lead) == 0 && len(role.lead) > 0 {
+			if names := nameListFromString(*b.contributors(role.role)); len(names) > 0 {
+				c.lead, c.leadRole = names, role
+				continue
+			}
+		}
+		if len(*b.contributors(role.role)) > 0 {
+			c.others = append(c.others, *role)
+		}
+	}
+	return c
+}
+
+func (c citedBook) names(role ContributorRole) []string {
+	return nameListFromString(*c.book.contributors(role))
+}
+
+// publication gives where and when a book was published, e.g. "Wheaton, IL:
+// Crossway, 2018".
+func (c citedBook) publication() string {
+	publisher := c.book.publisher
+	if len(c.location) > 0 {
+		publisher = c.location + ": " + publisher
+	}
+	if c.book.year == 0 {
+		return publisher + ", n.d."
+	}
+	return fmt.Sprintf("%v, %v", publisher, c.book.year)
+}
+
+func (c citedBook) year() string {
+	if c.book.year == 0 {
+		return "n.d."
+	}
+	return fmt.Sprint(c.book.year)
+}
+
+// chicagoBibliography gives an entry for a bibliography, e.g.
+//
+//	Gentry, Peter J., and Stephen J. Wellum. Kingdom through Covenant. 2nd ed.
+//	Wheaton, IL: Crossway, 2018.
+func (c citedBook) chicagoBibliography(style CitationStyle) citation {
+	var cit citation
+	if len(c.lead) > 0 {
+		names := slices.Clone(c.lead)
+		names[0] = invertedName(names[0])
+		if style == Chicago && len(names) > 10 {
+			cit.add(strings.Join(names[:7], ", ") + ", et al.")
+		} else {
+			cit.add(joinNames(names, true))
+		}
+		if c.leadRole != nil {
+			role := c.leadRole.lead
+			if len(c.lead) > 1 {
+				role = c.leadRole.leadPlural
+			}
+			cit.add(", " + role)
+		}
+		cit.stop()
+		cit.add(" ")
+	}
+	cit.addTitle(c.book.fullTitle())
+	cit.stop()
+	for _, role := range c.others {
+		cit.add(fmt.Sprintf(" %v %v", role.bibliography, joinNames(c.names(role.role), false)))
+		cit.stop()
+	}
+	if edition := citationEdition(c.book.edition); len(edition) > 0 {
+		cit.add(" " + capitalise(edition))
+	}
+	if len(c.book.series) > 0 {
+		cit.add(" " + c.book.series)
+		cit.stop()
+	}
+	cit.add(" " + c.publication())
+	cit.stop()
+	return cit
+}
+
+// chicagoNote gives a full note, e.g.
+//
+//	Peter J. Gentry and Stephen J. Wellum, Kingdom through Covenant, 2nd ed.
+//	(Wheaton, IL: Crossway, 2018).
+func (c citedBook) chicagoNote() citation {
+	var cit citation
+	if len(c.lead) > 3 {
+		cit.add(c.lead[0] + " et al.")
+	} else if len(c.lead) > 0 {
+		cit.add(joinNames(c.lead, false))
+	}
+	if c.leadRole != nil {
+		role := c.leadRole.lead
+		if len(c.lead) > 1 {
+			role = c.leadRole.leadPlural
+		}
+		cit.add(", " + role)
+	}
+	if len(c.lead) > 0 {
+		cit.add(", ")
+	}
+	cit.addTitle(c.book.fullTitle())
+	for _, role := range c.others {
+		cit.add(fmt.Sprintf(", %v %v", role.note, joinNames(c.names(role.role), false)))
+	}
+	if edition := citationEdition(c.book.edition); len(edition) > 0 {
+		cit.add(", " + edition)
+	}
+	if len(c.book.series) > 0 {
+		cit.add(", " + c.book.series)
+	}
+	cit.add(" (" + c.publication() + ").")
+	return cit
+}
+
+// apaReference gives an entry for a reference list, e.g.
+//
+//	Gentry, P. J., & Wellum, S. J. (2018). Kingdom through Covenant (2nd ed.).
+//	Crossway.
+func (c citedBook) apaReference() citation {
+	var details []string
+	for _, role := range c.others {
+		if len(role.apa) == 0 {
+			continue
+		}
+		var names []string
+		for _, name := range c.names(role.role) {
+			names = append(names, apaName(name, false))
+		}
+		label := role.apa
+		if len(names) > 1 {
+			label = role.apaPlural
+		}
+		details = append(details, fmt.Sprintf("%v, %v", joinApaNames(names, false), label))
+	}
+	if edition := citationEdition(c.book.edition); len(edition) > 0 {
+		details = append(details, capitalise(edition))
+	}
+
+	var cit citation
+	title := func() {
+		cit.addTitle(c.book.fullTitle())
+		if len(details) > 0 {
+			cit.add(" (" + strings.Join(details, "; ") + ")")
+		}
+		cit.stop()
+	}
+	if len(c.lead) > 0 {
+		var names []string
+		for _, name := range c.lead {
+			names = append(names, apaName(name, true))
+		}
+		cit.add(joinApaNames(names, true))
+		if c.leadRole != nil {
+			role := c.leadRole.apa
+			if len(c.lead) > 1 {
+				role = c.leadRole.apaPlural
+			}
+			cit.add(fmt.Sprintf(" (%v)", role))
+		}
+		cit.stop()
+		cit.add(fmt.Sprintf(" (%v). ", c.year()))
+		title()
+	} else {
+		// without authors or editors, the title takes their place
+		title()
+		cit.add(fmt.Sprintf(" (%v).", c.year()))
+	}
+	cit.add(" " + c.book.publisher)
+	cit.stop()
+	return cit
+}
+
+// apaInText gives a parenthetical citation, e.g. "(Gentry & Wellum, 2018)".
+func (c citedBook) apaInText() citation {
+	var cit citation
+	cit.add("(")
+	switch {
+	case len(c.lead) == 0:
+		cit.addTitle(c.book.title)
+	case len(c.lead) == 1:
+		cit.add(surname(c.lead[0]))
+	case len(c.lead) == 2:
+		cit.add(surname(c.lead[0]) + " & " + surname(c.lead[1]))
+	default:
+		cit.add(surname(c.lead[0]) + " et al.")
+	}
+	cit.add(", " + c.year() + ")")
+	return cit
+}
+
+// formatCitation gives a citation of a book. The location is the place the
+// book's publisher is based, or "" if it isn't known.
+func formatCitation(b Book, location string, opts CitationOptions) string {
+	c := newCitedBook(b, location)
+	var cit citation
+	switch {
+	case opts.Style == APA && opts.Form == NoteForm:
+		cit = c.apaInText()
+	case opts.Style == APA:
+		cit = c.apaReference()
+	case opts.Form == NoteForm:
+		cit = c.chicagoNote()
+	default:
+		cit = c.chicagoBibliography(opts.Style)
+	}
+	return cit.format(opts.Format)
+}
+
+// bookPublisherLocation gives the place the publisher of a book is based, or
+// "" if it isn't known.
+func bookPublisherLocation(db DBInterface, id int) (string, error) {
+	var location string
+	err := db.QueryRow(`
+        SELECT COALESCE(publishers.location, '')
+        FROM books
+        INNER JOIN publishers
+          ON books.publisher_id = publishers.publisher_id
+        WHERE book_id = ?`, id).Scan(&location)
+	if err != nil {
+		return "", fmt.Errorf("bookPublisherLocation, Couldn't get publisher of book #%v: %v",
+			id, err)
+	}
+	return location, nil
+}
+
+// citeBook gives a citation of a book in the library.
+func citeBook(db DBInterface, id int, opts CitationOptions) (string, error) {
+	b, err := getBookById(db, id)
+	if err != nil {
+		return "", fmt.Errorf("citeBook: %w", err)
+	}
+	location, err := bookPublisherLocation(db, id)
+	if err != nil {
+		return "", fmt.Errorf("citeBook: %w", err)
+	}
+	return formatCitation(b, location, opts), nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestCiteBook(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		id       int
+		opts     CitationOptions
+		expected string
+	}{
+		{5, CitationOptions{Chicago, BibliographyForm, PlainText},
+			"Gentry, Peter J., and Stephen J. Wellum. Kingdom through Covenant: A " +
+				"Biblical-Theological Understanding of the Covenants. 2nd ed. Wheaton, IL: " +
+				"Crossway, 2018."},
+		{5, CitationOptions{Chicago, NoteForm, PlainText},
+			"Peter J. Gentry and Stephen J. Wellum, Kingdom through Covenant: A " +
+				"Biblical-Theological Understanding of the Covenants, 2nd ed. (Wheaton, IL: " +
+				"Crossway, 2018)."},
+		{2, CitationOptions{Chicago, BibliographyForm, PlainText},
+			"Matz, Robert J., and A. Chadwick Thornhill, eds. Divine Impassibility: Four " +
+				"Views of God's Emotions and Suffering. Spectrum Multiview Books. Downers " +
+				"Grove, IL: IVP, 2019."},
+		{2, CitationOptions{SBL, NoteForm, PlainText},
+			"Robert J. Matz and A. Chadwick Thornhill, eds., Divine Impassibility: Four " +
+				"Views of God's Emotions and Suffering, Spectrum Multiview Books (Downers " +
+				"Grove, IL: IVP, 2019)."},
+		{3, CitationOptions{SBL, BibliographyForm, PlainText},
+			"Anselm. Basic Writings. Translated by Thomas Williams. Indianapolis: Hackett, 2007."},
+		{3, CitationOptions{Chicago, NoteForm, Markdown},
+			"Anselm, *Basic Writings*, trans. Thomas Williams (Indianapolis: Hackett, 2007)."},
+		{6, CitationOptions{Chicago, BibliographyForm, HTML},
+			"Bavinck, Herman. <i>Christianity and Science</i>. Edited by N. Gray Sutanto, " +
+				"James Eglinton, and Cory C. Brock. Wheaton, IL: Crossway, 2023."},
+		{5, CitationOptions{APA, BibliographyForm, PlainText},
+			"Gentry, P. J., & Wellum, S. J. (2018). Kingdom through Covenant: A " +
+				"Biblical-Theological Understanding of the Covenants (2nd ed.). Crossway."},
+		{5, CitationOptions{APA, NoteForm, PlainText}, "(Gentry & Wellum, 2018)"},
+		{2, CitationOptions{APA, BibliographyForm, Markdown},
+			"Matz, R. J., & Thornhill, A. C. (Eds.). (2019). *Divine Impassibility: Four " +
+				"Views of God's Emotions and Suffering*. IVP."},
+		{3, CitationOptions{APA, BibliographyForm, PlainText},
+			"Anselm. (2007). Basic Writings (T. Williams, Trans.). Hackett."},
+		{6, CitationOptions{APA, BibliographyForm, HTML},
+			"Bavinck, H. (2023). <i>Christianity and Science</i> (N. G. Sutanto, J. " +
+				"Eglinton, &amp; C. C. Brock, Eds.). Crossway."},
+	}
+	for _, test := range tests {
+		result, err := citeBook(db, test.id, test.opts)
+		if err != nil {
+			t.Errorf("citeBook(%v, %+v) returned error: %v", test.id, test.opts, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("citeBook(%v, %+v):\nexpected %v\ngot      %v", test.id, test.opts,
+				test.expected, result)
+		}
+	}
+
+	var bookIdErr *InvalidBookIdError
+	if _, err := citeBook(db, 999, CitationOptions{}); !errors.As(err, &bookIdErr) {
+		t.Errorf("citeBook of book #999: expected InvalidBookIdError, got %v", err)
+	}
+}
+
+func TestFormatCitation(t *testing.T) {
+	b := Book{
+		author:     "Martin Luther King Jr., Jean-Paul Sartre, C. S. Lewis and Anselm",
+		translator: "Thomas Williams",
+		foreword:   "John Stott",
+		title:      "Why Cite?",
+		edition:    Edition{3, "revised edition"},
+		publisher:  "Harper & Row",
+		series:     "Essays",
+	}
+	tests := []struct {
+		opts     CitationOptions
+		expected string
+	}{
+		{CitationOptions{Chicago, BibliographyForm, PlainText},
+			"King, Martin Luther, Jr., Jean-Paul Sartre, C. S. Lewis, and Anselm. Why Cite? " +
+				"Translated by Thomas Williams. With a foreword by John Stott. 3rd, revised " +
+				"ed. Essays. Harper & Row, n.d."},
+		{CitationOptions{Chicago, NoteForm, PlainText},
+			"Martin Luther King Jr. et al., Why Cite?, trans. Thomas Williams, with a " +
+				"foreword by John Stott, 3rd, revised ed., Essays (Harper & Row, n.d.)."},
+		{CitationOptions{APA, BibliographyForm, PlainText},
+			"King, M. L., Jr., Sartre, J.-P., Lewis, C. S., & Anselm. (n.d.). Why Cite? " +
+				"(T. Williams, Trans.; 3rd, revised ed.). Harper & Row."},
+		{CitationOptions{APA, NoteForm, Markdown}, "(King et al., n.d.)"},
+		{CitationOptions{Chicago, BibliographyForm, HTML},
+			"King, Martin Luther, Jr., Jean-Paul Sartre, C. S. Lewis, and Anselm. " +
+				"<i>Why Cite?</i> Translated by Thomas Williams. With a foreword by John " +
+				"Stott. 3rd, revised ed. Essays. Harper &amp; Row, n.d."},
+	}
+	for _, test := range tests {
+		if result := formatCitation(b, "", test.opts); result != test.expected {
+			t.Errorf("formatCitation(%+v):\nexpected %v\ngot      %v", test.opts,
+				test.expected, result)
+		}
+	}
+
+	// without authors or editors, the title comes first
+	b = Book{title: "The_Book", publisher: "Anon Press", year: 1611}
+	tests = []struct {
+		opts     CitationOptions
+		expected string
+	}{
+		{CitationOptions{Chicago, BibliographyForm, Markdown},
+			"*The\\_Book*. London: Anon Press, 1611."},
+		{CitationOptions{APA, BibliographyForm, PlainText}, "The_Book. (1611). Anon Press."},
+		{CitationOptions{APA, NoteForm, Markdown}, "(*The\\_Book*, 1611)"},
+	}
+	for _, test := range tests {
+		if result := formatCitation(b, "London", test.opts); result != test.expected {
+			t.Errorf("formatCitation(%+v) without authors:\nexpected %v\ngot      %v",
+				test.opts, test.expected, result)
+		}
+	}
+}
+
+func TestInitials(t *testing.T) {
+	names := map[string]string{
+		"Peter J.":  "P. J.",
+		"Jean-Paul": "J.-P.",
+		"N. Gray":   "N. G.",
+		"émile":     "É.",
+		"":          "",
+	}
+	for given, expected := range names {
+		if result := initials(given); result != expected {
+			t.Errorf("initials(%q): expected %q, got %q", given, expected, result)
+		}
+	}
+}
+
+func TestPublisherLocation(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	location, err := publisherLocation(db, 3)
+	if err != nil || location != "Wheaton, IL" {
+		t.Errorf("publisherLocation(3): expected \"Wheaton, IL\", got %q, error %v",
+			location, err)
+	}
+
+	if err := updatePublisherLocation(db, 2, ""); err != nil {
+		t.Fatalf("updatePublisherLocation returned error: %v", err)
+	}
+	defer updatePublisherLocation(db, 2, "Indianapolis")
+	result, err := citeBook(db, 3, CitationOptions{Chicago, NoteForm, PlainText})
+	expected := "Anselm, Basic Writings, trans. Thomas Williams (Hackett, 2007)."
+	if err != nil || result != expected {
+		t.Errorf("Citation without location: expected %q, got %q, error %v", expected,
+			result, err)
+	}
+
+	var publisherIdErr *InvalidPublisherIdError
+	if err := updatePublisherLocation(db, 999, "Nowhere"); !errors.As(err, &publisherIdErr) {
+		t.Errorf("updatePublisherLocation of publisher #999: expected InvalidPublisherIdError, got %v",
+			err)
+	}
+	if _, err := publisherLocation(db, 999); !errors.As(err, &publisherIdErr) {
+		t.Errorf("publisherLocation of publisher #999: expected InvalidPublisherIdError, got %v",
+			err)
+	}
+}
@@ -0,0 +1,1271 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The command line interface is
+//
+//	aristarchus [--db path] [--json] [--templates file] [--isbn-ranges file]
+//	            command [arguments]
+//
+// with the commands given by cliCommands. Books are shown through the display
+// templates, with any given in the templates file, and ISBNs are hyphenated
+// with the range data in the ISBN ranges file if one is given. Output is plain
+// text for reading, or with --json the same representations as the JSON API,
+// for use in scripts. Errors are written to stderr, and the exit code gives
+// the kind of error, as below.
+
+const defaultDbPath = "../db/books.sqlite"
+
+const (
+	exitOk       = 0
+	exitError    = 1 // problems with the database, or anything unexpected
+	exitUsage    = 2 // bad arguments or invalid values
+	exitNotFound = 3 // unknown book, person, publisher or series id
+	exitConflict = 4 // duplicate books, or records which are still in use
+)
+
+type UsageError struct {
+	Command string
+	Reason  string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Command, e.Reason)
+}
+
+// errUsageShown is returned when a command's flags couldn't be parsed, in which
+// case the flag package has already reported the problem.
+var errUsageShown = errors.New("usage shown")
+
+// exitCode gives the exit code for an error returned by a command.
+func exitCode(err error) int {
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) || errors.Is(err, errUsageShown) {
+		return exitUsage
+	}
+	switch apiErrorStatus(err) {
+	case http.StatusNotFound:
+		return exitNotFound
+	case http.StatusConflict:
+		return exitConflict
+	case http.StatusBadRequest:
+		return exitUsage
+	default:
+		return exitError
+	}
+}
+
+// Cli holds the state shared by the commands.
+type Cli struct {
+	db     *sql.DB
+	json   bool
+	line   string    // the display template for books in lists
+	input  io.Reader // stdin as given, for the terminal UI
+	stdin  *bufio.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type cliCommand struct {
+	name    string
+	usage   string
+	summary string
+	run     func(c *Cli, args []string) error
+}
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{"add", "[flags]", "add a book", (*Cli).add},
+		{"show", "id [--template name]", "show a book with its notes and categories",
+			(*Cli).show},
+		{"list", "[--query query] [--template name]",
+			"list books, optionally matching a query", (*Cli).list},
+		{"edit", "id [flags]", "change the given fields of a book", (*Cli).edit},
+		{"delete", "id", "delete a book", (*Cli).delete},
+		{"import", "[--format format] [--dry-run] [--columns header=field,...] file",
+			"import books from a CSV, TSV, RIS or CSL-JSON file, or - for stdin",
+			(*Cli).importBooks},
+		{"export", "[--format format] [--query query | --book id | --series id | " +
+			"--person id] [--columns field,...] [--template name] [--output file]",
+			"export books as CSV, TSV, BibTeX, BibLaTeX, RIS, CSL-JSON or through a " +
+				"display template", (*Cli).export},
+		{"cite", "[--style chicago|sbl|apa] [--note] [--format text|markdown|html] id...",
+			"cite books for a bibliography, or with --note for a footnote", (*Cli).cite},
+		{"search", "words...", "search titles, people, publishers, series and notes", (*Cli).search},
+		{"people", "[list | show id | add name | rename id name | delete id]",
+			"list and manage people", (*Cli).people},
+		{"publishers", "[list | show id | add name | rename id name | locate id [place] | " +
+			"delete id]",
+			"list and manage publishers", (*Cli).publishers},
+		{"series", "[list | show id | add name | rename id name | delete id]",
+			"list and manage series", (*Cli).series},
+		{"tui", "", "browse and edit the library in a full-screen terminal UI", (*Cli).tui},
+		{"stats", "", "count the books in the library by status", (*Cli).stats},
+		{"serve", "[address]", "serve the JSON API, by default on :8080", (*Cli).serve},
+		{"help", "[command]", "show help for a command", (*Cli).help},
+	}
+}
+
+func findCommand(name string) (cliCommand, bool) {
+	for _, cmd := range cliCommands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return cliCommand{}, false
+}
+
+func writeCliUsage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: aristarchus [flags] command [arguments]\n\nFlags:\n")
+	global.SetOutput(w)
+	global.PrintDefaults()
+	fmt.Fprintf(w, "\nCommands:\n")
+	for _, cmd := range cliCommands() {
+		fmt.Fprintf(w, "  %-11v%v\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"aristarchus help command\" for more about a command.\n")
+}
+
+// runCli runs the command line given by args, returning the exit code.
+func runCli(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("aristarchus", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	dbPath := global.String("db", defaultDbPath, "path of the library database, or a "+
+		"postgres:// URL")
+	jsonOutput := global.Bool("json", false, "write output as JSON")
+	templatesPath := global.String("templates", "", "file of display templates, by default "+
+		defaultTemplatesPath()+" if it exists")
+	isbnRangesPath := global.String("isbn-ranges", "", "the International ISBN Agency's "+
+		"RangeMessage.xml to hyphenate ISBNs with, by default "+defaultIsbnRangesPath()+
+		" if it exists")
+	global.Usage = func() { writeCliUsage(stderr, global) }
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOk
+		}
+		return exitUsage
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return exitUsage
+	}
+
+	cmd, ok := findCommand(global.Arg(0))
+	if !ok {
+		fmt.Fprintf(stderr, "aristarchus: Unknown command \"%v\"\n", global.Arg(0))
+		global.Usage()
+		return exitUsage
+	}
+	if cmd.name == "help" && global.NArg() == 1 {
+		writeCliUsage(stdout, global)
+		return exitOk
+	}
+
+	if len(*templatesPath) == 0 {
+		if _, err := os.Stat(defaultTemplatesPath()); err == nil {
+			*templatesPath = defaultTemplatesPath()
+		}
+	}
+	if err := loadDisplayTemplates(*templatesPath); err != nil {
+		fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+		return exitCode(err)
+	}
+
+	if len(*isbnRangesPath) == 0 {
+		if _, err := os.Stat(defaultIsbnRangesPath()); err == nil {
+			*isbnRangesPath = defaultIsbnRangesPath()
+		}
+	}
+	if len(*isbnRangesPath) != 0 {
+		if err := loadIsbnRangesFile(*isbnRangesPath); err != nil {
+			fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+			return exitCode(err)
+		}
+	}
+	onUnhyphenatedIsbn(func(err *IsbnRangeError) {
+		fmt.Fprintf(stderr, "aristarchus: ISBN %v printed without hyphens, %v; give the "+
+			"International ISBN Agency's RangeMessage.xml with --isbn-ranges\n",
+			string(err.Isbn), err.Reason)
+	})
+	defer onUnhyphenatedIsbn(nil)
+
+	c := &Cli{
+		json:   *jsonOutput,
+		line:   "line",
+		input:  stdin,
+		stdin:  bufio.NewReader(stdin),
+		stdout: stdout,
+		stderr: stderr,
+	}
+	if cmd.name != "help" {
+		db, err := openLibrary(*dbPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+			return exitError
+		}
+		defer db.Close()
+		from, to, err := migrateDatabase(db)
+		if err != nil {
+			fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+			return exitError
+		}
+		if from != to {
+			fmt.Fprintf(stderr, "aristarchus: Updated library database from schema "+
+				"version %v to %v\n", from, to)
+		}
+		problems, err := checkStoredIsbns(db)
+		if err != nil {
+			fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+			return exitError
+		}
+		for _, p := range problems {
+			fmt.Fprintf(stderr, "aristarchus: %v; correct it with \"aristarchus edit %v "+
+				"--isbn\"\n", p, p.BookId)
+		}
+		stmts, err := prepareStatements(db)
+		if err != nil {
+			fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+			return exitError
+		}
+		defer stmts.Close()
+		c.db = db
+	}
+
+	if err := cmd.run(c, global.Args()[1:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return exitOk
+		case !errors.Is(err, errUsageShown):
+			fmt.Fprintf(stderr, "aristarchus: %v\n", err)
+		}
+		return exitCode(err)
+	}
+	return exitOk
+}
+
+// defaultTemplatesPath gives where display templates are read from if no
+// other file is given, in the user's configuration directory.
+func defaultTemplatesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join("aristarchus", "templates.tmpl")
+	}
+	return filepath.Join(dir, "aristarchus", "templates.tmpl")
+}
+
+// defaultIsbnRangesPath gives where an ISBN range file is read from if no
+// other file is given, in the user's configuration directory.
+func defaultIsbnRangesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join("aristarchus", "RangeMessage.xml")
+	}
+	return filepath.Join(dir, "aristarchus", "RangeMessage.xml")
+}
+
+// openLibrary opens the library database at path, or the PostgreSQL database
+// at a postgres:// URL. An SQLite database must already exist, as otherwise
+// SQLite would create an empty database in its place, but an empty file is
+// given the whole schema when it is migrated, as is an empty PostgreSQL
+// database.
+func openLibrary(path string) (*sql.DB, error) {
+	driverName := "sqlite3"
+	if isPostgresURL(path) {
+		driverName = postgresDriverName
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("openLibrary, Couldn't open library database: %v", err)
+	}
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("openLibrary, Couldn't open library database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("openLibrary, Couldn't connect to library database: %v", err)
+	}
+	return db, nil
+}
+
+func (c *Cli) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	cmd, _ := findCommand(name)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: aristarchus %v %v\n\n%v\n", cmd.name,
+			cmd.usage, cmd.summary)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(fs.Output(), "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parseFlags parses a command's arguments. A leading id is allowed before the
+// flags, so that both "edit 3 --title T" and "edit --title T 3" work; it is
+// put back at the start of the remaining arguments.
+func (c *Cli) parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var lead []string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		lead, args = args[:1:1], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, errUsageShown
+	}
+	return append(lead, fs.Args()...), nil
+}
+
+func parseId(command string, arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, &UsageError{command, fmt.Sprintf("Invalid id \"%v\"", arg)}
+	}
+	return id, nil
+}
+
+// singleId gets the id from arguments which should be just an id.
+func singleId(command string, args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, &UsageError{command, "Expected a single id"}
+	}
+	return parseId(command, args[0])
+}
+
+func (c *Cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("printJSON, Couldn't write output: %v", err)
+	}
+	return nil
+}
+
+func (c *Cli) printBooks(books []Book) error {
+	if c.json {
+		booksJSON := []BookJSON{}
+		for _, b := range books {
+			booksJSON = append(booksJSON, bookToJSON(b))
+		}
+		return c.printJSON(booksJSON)
+	}
+	for _, b := range books {
+		line, err := renderBookString(c.line, newBookView(b))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%4d  %v\n", b.id, line)
+	}
+	return nil
+}
+
+// printBookMessage reports a change to a book, e.g. "Added book #7: ...", or
+// with --json gives the book.
+func (c *Cli) printBookMessage(action string, b Book) error {
+	if c.json {
+		return c.printJSON(bookToJSON(b))
+	}
+	fmt.Fprintf(c.stdout, "%v book #%d: %v\n", action, b.id, b)
+	return nil
+}
+
+// bookFlags are the flags for the fields of a book, shared by add and edit.
+type bookFlags struct {
+	fs           *flag.FlagSet
+	contributors map[ContributorRole]*string
+	title        *string
+	subtitle     *string
+	year         *int
+	edition      *int
+	editionDesc  *string
+	publisher    *string
+	isbn         *string
+	series       *string
+	status       *string
+	purchased    *string
+	rating       *float64
+}
+
+func newBookFlags(fs *flag.FlagSet) *bookFlags {
+	f := &bookFlags{fs: fs, contributors: map[ContributorRole]*string{}}
+	for _, role := range contributorRoles {
+		f.contributors[role] = fs.String(string(role), "",
+			fmt.Sprintf("names of the book's %v people, e.g. \"A and B\"", role))
+	}
+	f.title = fs.String("title", "", "title")
+	f.subtitle = fs.String("subtitle", "", "subtitle")
+	f.year = fs.Int("year", 0, "year of publication")
+	f.edition = fs.Int("edition", 0, "edition number")
+	f.editionDesc = fs.String("edition-description", "",
+		"description of the edition, e.g. \"revised and expanded\"")
+	f.publisher = fs.String("publisher", "", "publisher")
+	f.isbn = fs.String("isbn", "", "ISBN-10 or ISBN-13")
+	f.series = fs.String("series", "", "series")
+	f.status = fs.String("status", "", "comma separated statuses, e.g. \"Owned,Read\"")
+	f.purchased = fs.String("purchased", "", "purchase date, e.g. \"2021\", \"December 2021\" or \"14 December 2021\"")
+	f.rating = fs.Float64("rating", 0, "rating from 0 to 5 in steps of 0.5")
+	return f
+}
+
+func (f *bookFlags) isSet(name string) bool {
+	set := false
+	f.fs.Visit(func(fl *flag.Flag) {
+		if fl.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func splitStatuses(s string) []string {
+	statuses := []string{}
+	for _, status := range strings.Split(s, ",") {
+		if status = strings.TrimSpace(status); len(status) > 0 {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+func (f *bookFlags) bookJSON() BookJSON {
+	bj := BookJSON{
+		Author:             *f.contributors[RoleAuthor],
+		Editor:             *f.contributors[RoleEditor],
+		Translator:         *f.contributors[RoleTranslator],
+		Illustrator:        *f.contributors[RoleIllustrator],
+		Foreword:           *f.contributors[RoleForeword],
+		Compiler:           *f.contributors[RoleCompiler],
+		Title:              *f.title,
+		Subtitle:           *f.subtitle,
+		Year:               *f.year,
+		Edition:            *f.edition,
+		EditionDescription: *f.editionDesc,
+		Publisher:          *f.publisher,
+		Isbn:               *f.isbn,
+		Series:             *f.series,
+		Status:             splitStatuses(*f.status),
+		Purchased:          *f.purchased,
+	}
+	if f.isSet("rating") {
+		bj.Rating = f.rating
+	}
+	return bj
+}
+
+// patch gives a BookPatch changing just the fields whose flags were given.
+func (f *bookFlags) patch() BookPatch {
+	var p BookPatch
+	setString := func(name string, value *string, field **string) {
+		if f.isSet(name) {
+			*field = value
+		}
+	}
+	for _, role := range contributorRoles {
+		setString(string(role), f.contributors[role], p.contributors(role))
+	}
+	setString("title", f.title, &p.Title)
+	setString("subtitle", f.subtitle, &p.Subtitle)
+	setString("edition-description", f.editionDesc, &p.EditionDescription)
+	setString("publisher", f.publisher, &p.Publisher)
+	setString("isbn", f.isbn, &p.Isbn)
+	setString("series", f.series, &p.Series)
+	setString("purchased", f.purchased, &p.Purchased)
+	if f.isSet("year") {
+		p.Year = f.year
+	}
+	if f.isSet("edition") {
+		p.Edition = f.edition
+	}
+	if f.isSet("status") {
+		statuses := splitStatuses(*f.status)
+		p.Status = &statuses
+	}
+	if f.isSet("rating") {
+		p.Rating = f.rating
+	}
+	return p
+}
+
+func (c *Cli) add(args []string) error {
+	fs := c.flagSet("add")
+	f := newBookFlags(fs)
+	lookup := fs.Bool("lookup", false,
+		"look up the details of the book with --isbn, with the other flags taking precedence")
+	yes := fs.Bool("yes", false, "with --lookup, add the book without asking first")
+	metadataUrl := fs.String("metadata-url", openLibraryUrl,
+		"base URL of the Open Library service used by --lookup")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return &UsageError{"add", fmt.Sprintf("Unexpected arguments %v", rest)}
+	}
+
+	bj := f.bookJSON()
+	var id int
+	if *lookup {
+		overrides, err := bookFromJSON(bj)
+		if err != nil {
+			return err
+		}
+		var confirm func(b *Book) bool
+		if !*yes {
+			confirm = c.confirmAdd
+		}
+		id, err = addBookByIsbn(c.db, newOpenLibraryClient(*metadataUrl), bj.Isbn,
+			overrides, confirm)
+		if err != nil {
+			return err
+		}
+	} else {
+		if len(bj.Title) == 0 {
+			return &UsageError{"add", "A book needs a --title, or --lookup with an --isbn"}
+		}
+		if len(bj.Publisher) == 0 {
+			return &UsageError{"add", "A book needs a --publisher"}
+		}
+		b, err := bookFromJSON(bj)
+		if err != nil {
+			return err
+		}
+		id, err = addBook(c.db, &b)
+		if err != nil {
+			return err
+		}
+	}
+
+	added, err := getBookById(c.db, id)
+	if err != nil {
+		return err
+	}
+	return c.printBookMessage("Added", added)
+}
+
+// confirmAdd asks on stdin whether to add a book found by looking up its ISBN.
+// The prompt goes to stderr, keeping stdout for the result.
+func (c *Cli) confirmAdd(b *Book) bool {
+	fmt.Fprintf(c.stderr, "Found: %v\nAdd this book? [y/N] ", b)
+	answer, _ := c.stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func (c *Cli) show(args []string) error {
+	fs := c.flagSet("show")
+	tmpl := fs.String("template", "detail", "the display template to show the book with")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := singleId("show", rest)
+	if err != nil {
+		return err
+	}
+	if err := checkDisplayTemplate(*tmpl); err != nil {
+		return err
+	}
+	b, err := getBookById(c.db, id)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(bookToJSON(b))
+	}
+
+	view, err := bookDetailView(c.db, b)
+	if err != nil {
+		return err
+	}
+	text, err := renderBookString(*tmpl, view)
+	if err != nil {
+		return err
+	}
+	if len(text) > 0 && !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	_, err = io.WriteString(c.stdout, text)
+	return err
+}
+
+func (c *Cli) list(args []string) error {
+	fs := c.flagSet("list")
+	query := fs.String("query", "", "only list books matching the query, "+
+		"e.g. \"author:Bavinck status:Owned\"")
+	fs.StringVar(&c.line, "template", c.line, "the display template for each book")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return &UsageError{"list", fmt.Sprintf("Unexpected arguments %v", rest)}
+	}
+	if err := checkDisplayTemplate(c.line); err != nil {
+		return err
+	}
+
+	var books []Book
+	if len(*query) > 0 {
+		books, err = queryBooks(c.db, *query)
+	} else {
+		books, err = loadAllBooks(c.db)
+	}
+	if err != nil {
+		return err
+	}
+	return c.printBooks(books)
+}
+
+func (c *Cli) edit(args []string) error {
+	fs := c.flagSet("edit")
+	f := newBookFlags(fs)
+	clearRating := fs.Bool("clear-rating", false, "remove the book's rating")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := singleId("edit", rest)
+	if err != nil {
+		return err
+	}
+
+	p := f.patch()
+	p.ClearRating = *clearRating
+	if fs.NFlag() == 0 {
+		return &UsageError{"edit", "No fields given to change"}
+	}
+
+	orig, err := getBookById(c.db, id)
+	if err != nil {
+		return err
+	}
+	err = withTx(c.db, func(tx DBInterface) error {
+		return applyBookPatch(tx, id, orig, p)
+	})
+	if err != nil {
+		return err
+	}
+	updated, err := getBookById(c.db, id)
+	if err != nil {
+		return err
+	}
+	return c.printBookMessage("Updated", updated)
+}
+
+func (c *Cli) delete(args []string) error {
+	fs := c.flagSet("delete")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id, err := singleId("delete", rest)
+	if err != nil {
+		return err
+	}
+	b, err := getBookById(c.db, id)
+	if err != nil {
+		return err
+	}
+	if err := deleteBook(c.db, id); err != nil {
+		return err
+	}
+	return c.printBookMessage("Deleted", b)
+}
+
+// ImportReportJSON is the output of the import command with --json.
+type ImportReportJSON struct {
+	DryRun     bool                 `json:"dry_run"`
+	Added      []ImportedBookJSON   `json:"added"`
+	Duplicates []ImportRowErrorJSON `json:"duplicates"`
+	Invalid    []ImportRowErrorJSON `json:"invalid"`
+	Ignored    []string             `json:"ignored_columns"`
+}
+
+type ImportedBookJSON struct {
+	Row  int      `json:"row"`
+	Book BookJSON `json:"book"`
+}
+
+// ImportRowErrorJSON is a row which wasn't imported. BookId is the book already
+// in the library, for duplicates.
+type ImportRowErrorJSON struct {
+	Row    int    `json:"row"`
+	Error  string `json:"error"`
+	BookId int    `json:"book_id,omitempty"`
+}
+
+func importRowErrorsToJSON(rowErrs []ImportRowError) []ImportRowErrorJSON {
+	errsJSON := []ImportRowErrorJSON{}
+	for _, rowErr := range rowErrs {
+		errJSON := ImportRowErrorJSON{Row: rowErr.Row, Error: rowErr.Err.Error()}
+		var duplicateErr *AddingDuplicateBookError
+		if errors.As(rowErr.Err, &duplicateErr) {
+			errJSON.BookId = duplicateErr.id
+		}
+		errsJSON = append(errsJSON, errJSON)
+	}
+	return errsJSON
+}
+
+func (c *Cli) importBooks(args []string) error {
+	fs := c.flagSet("import")
+	format := fs.String("format", "csv", "the format to read, one of "+
+		strings.Join(importFormats, ", "))
+	dryRun := fs.Bool("dry-run", false, "report what would be imported, without adding anything")
+	columns := fs.String("columns", "", "map CSV headers to fields where they differ, "+
+		"e.g. \"Authors=author,Date Bought=purchased\"; fields are named as for add")
+	tsv := fs.Bool("tsv", false, "read tab separated values, as for --format tsv")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return &UsageError{"import", "Expected a single file"}
+	}
+	if err := checkFormatFlags("import", importFormats, format, *tsv, *columns); err != nil {
+		return err
+	}
+
+	opts := CsvImportOptions{DryRun: *dryRun}
+	if opts.Columns, err = parseCsvColumns(*columns); err != nil {
+		return err
+	}
+	if *format == "tsv" {
+		opts.Comma = '\t'
+	}
+
+	in := c.input
+	if rest[0] != "-" {
+		f, err := os.Open(rest[0])
+		if err != nil {
+			return fmt.Errorf("import, Couldn't open file: %v", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var report ImportReport
+	switch *format {
+	case "ris":
+		report, err = importRis(c.db, in, *dryRun)
+	case "csl-json":
+		report, err = importCslJson(c.db, in, *dryRun)
+	default:
+		report, err = importCsv(c.db, in, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		reportJSON := ImportReportJSON{
+			DryRun:     report.DryRun,
+			Added:      []ImportedBookJSON{},
+			Duplicates: importRowErrorsToJSON(report.Duplicates),
+			Invalid:    importRowErrorsToJSON(report.Invalid),
+			Ignored:    report.Ignored,
+		}
+		for _, added := range report.Added {
+			reportJSON.Added = append(reportJSON.Added,
+				ImportedBookJSON{added.Row, bookToJSON(added.Book)})
+		}
+		if reportJSON.Ignored == nil {
+			reportJSON.Ignored = []string{}
+		}
+		if err := c.printJSON(reportJSON); err != nil {
+			return err
+		}
+		return report.skippedError()
+	}
+
+	action := "Added"
+	if report.DryRun {
+		action = "Would add"
+	}
+	fmt.Fprintf(c.stdout, "%v %d books\n", action, len(report.Added))
+	for _, added := range report.Added {
+		if report.DryRun {
+			fmt.Fprintf(c.stdout, "  row %d: %v\n", added.Row, added.Book)
+		} else {
+			fmt.Fprintf(c.stdout, "  row %d: #%d %v\n", added.Row, added.Book.id,
+				added.Book)
+		}
+	}
+	if len(report.Duplicates) > 0 {
+		fmt.Fprintf(c.stdout, "Skipped %d duplicates\n", len(report.Duplicates))
+		for _, rowErr := range report.Duplicates {
+			fmt.Fprintf(c.stdout, "  row %d: %v\n", rowErr.Row, rowErr.Err)
+		}
+	}
+	if len(report.Invalid) > 0 {
+		fmt.Fprintf(c.stdout, "Skipped %d invalid rows\n", len(report.Invalid))
+		for _, rowErr := range report.Invalid {
+			fmt.Fprintf(c.stdout, "  row %d: %v\n", rowErr.Row, rowErr.Err)
+		}
+	}
+	if len(report.Ignored) > 0 {
+		ignored := "columns"
+		switch *format {
+		case "ris":
+			ignored = "tags"
+		case "csl-json":
+			ignored = "variables"
+		}
+		fmt.Fprintf(c.stdout, "Ignored %v: %v\n", ignored, strings.Join(report.Ignored, ", "))
+	}
+	return report.skippedError()
+}
+
+// importFormats and exportFormats are the formats the import command reads
+// and the export command writes.
+var (
+	importFormats = []string{"csv", "tsv", "ris", "csl-json"}
+	exportFormats = []string{"csv", "tsv", "bibtex", "biblatex", "ris", "csl-json",
+		"template"}
+)
+
+// checkFormatFlags checks the format given to the import or export command
+// against the flags for CSV files, with --tsv setting the format to tsv.
+func checkFormatFlags(command string, formats []string, format *string, tsv bool,
+	columns string) error {
+	if tsv {
+		if *format != "csv" && *format != "tsv" {
+			return &UsageError{command, "--tsv can't be given with --format " + *format}
+		}
+		*format = "tsv"
+	}
+	if !slices.Contains(formats, *format) {
+		return &UsageError{command, fmt.Sprintf("Unknown format \"%v\", must be one of %v",
+			*format, strings.Join(formats, ", "))}
+	}
+	if len(columns) > 0 && *format != "csv" && *format != "tsv" {
+		return &UsageError{command, "--columns can only be given for CSV or TSV"}
+	}
+	return nil
+}
+
+func (c *Cli) export(args []string) error {
+	fs := c.flagSet("export")
+	format := fs.String("format", "csv", "the format to write, one of "+
+		strings.Join(exportFormats, ", "))
+	var sel ExportSelection
+	fs.StringVar(&sel.Query, "query", "", "only export books matching the query, "+
+		"e.g. \"author:Bavinck status:Owned\"")
+	fs.IntVar(&sel.BookId, "book", 0, "only export the book with this id")
+	fs.IntVar(&sel.SeriesId, "series", 0, "only export the books in the series with this id")
+	fs.IntVar(&sel.PersonId, "person", 0, "only export the books by the person with this id")
+	columns := fs.String("columns", "", "comma separated fields to export as CSV, "+
+		"by default "+strings.Join(csvExportFields, ","))
+	tsv := fs.Bool("tsv", false, "write tab separated values, as for --format tsv")
+	tmpl := fs.String("template", "", "the display template for each book, "+
+		"for --format template")
+	output := fs.String("output", "", "write to the file rather than stdout")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return &UsageError{"export", fmt.Sprintf("Unexpected arguments %v", rest)}
+	}
+	if err := checkFormatFlags("export", exportFormats, format, *tsv, *columns); err != nil {
+		return err
+	}
+	switch {
+	case *format == "template" && len(*tmpl) == 0:
+		return &UsageError{"export", "--format template needs a --template"}
+	case *format != "template" && len(*tmpl) > 0:
+		return &UsageError{"export", "--template can only be given for --format template"}
+	case *format == "template":
+		if err := checkDisplayTemplate(*tmpl); err != nil {
+			return err
+		}
+	}
+
+	var opts CsvExportOptions
+	if opts.Columns, err = parseCsvExportColumns(*columns); err != nil {
+		return err
+	}
+	if *format == "tsv" {
+		opts.Comma = '\t'
+	}
+	write := func(w io.Writer) (int, error) {
+		switch *format {
+		case "bibtex":
+			return exportLibraryBib(c.db, w, sel, BibTeX)
+		case "biblatex":
+			return exportLibraryBib(c.db, w, sel, BibLaTeX)
+		case "ris":
+			return exportLibraryRis(c.db, w, sel)
+		case "csl-json":
+			return exportLibraryCslJson(c.db, w, sel)
+		case "template":
+			return exportLibraryTemplate(c.db, w, sel, *tmpl)
+		default:
+			return exportLibraryCsv(c.db, w, sel, opts)
+		}
+	}
+
+	if len(*output) == 0 {
+		_, err := write(c.stdout)
+		return err
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("export, Couldn't create file: %v", err)
+	}
+	count, err := write(f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("export, Couldn't write file: %v", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Exported %d books to %v\n", count, *output)
+	return nil
+}
+
+func (c *Cli) search(args []string) error {
+	fs := c.flagSet("search")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return &UsageError{"search", "Nothing to search for"}
+	}
+
+	results, err := searchBooks(c.db, strings.Join(rest, " "))
+	if err != nil {
+		return err
+	}
+	if c.json {
+		resultsJSON := []SearchResultJSON{}
+		for _, sr := range results {
+			resultsJSON = append(resultsJSON, SearchResultJSON{bookToJSON(sr.Book), sr.Snippet})
+		}
+		return c.printJSON(resultsJSON)
+	}
+	for _, sr := range results {
+		fmt.Fprintf(c.stdout, "%4d  %v\n      %v\n", sr.Book.id, sr.Book, sr.Snippet)
+	}
+	return nil
+}
+
+func (c *Cli) cite(args []string) error {
+	fs := c.flagSet("cite")
+	style := fs.String("style", "chicago", "the citation style, one of "+
+		strings.Join(citationStyleNames, ", "))
+	note := fs.Bool("note", false, "cite for a footnote, or in the text for APA, rather "+
+		"than a bibliography")
+	format := fs.String("format", "text", "the format to write, one of "+
+		strings.Join(textFormatNames, ", "))
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return &UsageError{"cite", "Expected the ids of the books to cite"}
+	}
+
+	var opts CitationOptions
+	if opts.Style, err = parseCitationStyle(*style); err != nil {
+		return err
+	}
+	if *note {
+		opts.Form = NoteForm
+	}
+	if opts.Format, err = parseTextFormat(*format); err != nil {
+		return err
+	}
+	var ids []int
+	for _, arg := range rest {
+		id, err := parseId("cite", arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	citations := []CitationJSON{}
+	for _, id := range ids {
+		citation, err := citeBook(c.db, id, opts)
+		if err != nil {
+			return err
+		}
+		citations = append(citations, CitationJSON{id, opts.Style.String(),
+			opts.Form.String(), opts.Format.String(), citation})
+	}
+	if c.json {
+		return c.printJSON(citations)
+	}
+	for _, citation := range citations {
+		fmt.Fprintln(c.stdout, citation.Citation)
+	}
+	return nil
+}
+
+func (c *Cli) people(args []string) error {
+	return c.named("people", "person", peopleResource, args)
+}
+
+func (c *Cli) publishers(args []string) error {
+	return c.named("publishers", "publisher", publisherResource, args)
+}
+
+func (c *Cli) series(args []string) error {
+	return c.named("series", "series", seriesResource, args)
+}
+
+// named runs the people, publishers and series commands, which all take the
+// same actions on a namedResource.
+func (c *Cli) named(command string, noun string, res namedResource, args []string) error {
+	fs := c.flagSet(command)
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	action := "list"
+	if len(rest) > 0 {
+		action, rest = rest[0], rest[1:]
+	}
+
+	switch action {
+	case "list":
+		if len(rest) > 0 {
+			return &UsageError{command, fmt.Sprintf("Unexpected arguments %v", rest)}
+		}
+		ids, err := res.list(c.db)
+		if err != nil {
+			return err
+		}
+		records := []NamedJSON{}
+		for _, id := range ids {
+			record, err := getNamed(c.db, res, id)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		if c.json {
+			return c.printJSON(records)
+		}
+		for _, record := range records {
+			fmt.Fprintf(c.stdout, "%4d  %v (%d books)\n", record.Id, record.Name,
+				len(record.Books))
+		}
+		return nil
+	case "show":
+		id, err := singleId(command+" show", rest)
+		if err != nil {
+			return err
+		}
+		record, err := getNamed(c.db, res, id)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(record)
+		}
+		books, err := loadBooks(c.db, record.Books)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "%v #%d: %v\n", strings.ToUpper(noun[:1])+noun[1:],
+			record.Id, record.Name)
+		return c.printBooks(books)
+	case "add":
+		if len(rest) != 1 || len(rest[0]) == 0 {
+			return &UsageError{command + " add", "Expected a single name"}
+		}
+		// the get-or-create functions give the existing record if the name is
+		// already present
+		id, err := res.create(c.db, rest[0])
+		if err != nil {
+			return err
+		}
+		return c.printNamed("Added", noun, res, id)
+	case "rename":
+		if len(rest) != 2 || len(rest[1]) == 0 {
+			return &UsageError{command + " rename", "Expected an id and a new name"}
+		}
+		id, err := parseId(command+" rename", rest[0])
+		if err != nil {
+			return err
+		}
+		if _, err := res.name(c.db, id); err != nil {
+			return err
+		}
+		if _, err := res.updateName(c.db, id, rest[1]); err != nil {
+			return err
+		}
+		return c.printNamed("Renamed", noun, res, id)
+	case "locate":
+		if res.updateLocation == nil {
+			return &UsageError{command, fmt.Sprintf("Unknown action \"%v\"", action)}
+		}
+		if len(rest) < 1 || len(rest) > 2 {
+			return &UsageError{command + " locate", "Expected an id and a place, " +
+				"or just an id to remove the place"}
+		}
+		id, err := parseId(command+" locate", rest[0])
+		if err != nil {
+			return err
+		}
+		location := ""
+		if len(rest) == 2 {
+			location = rest[1]
+		}
+		if err := res.updateLocation(c.db, id, location); err != nil {
+			return err
+		}
+		return c.printNamed("Located", noun, res, id)
+	case "delete":
+		id, err := singleId(command+" delete", rest)
+		if err != nil {
+			return err
+		}
+		record, err := getNamed(c.db, res, id)
+		if err != nil {
+			return err
+		}
+		if err := res.delete(c.db, id); err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(record)
+		}
+		fmt.Fprintf(c.stdout, "Deleted %v #%d: %v\n", noun, id, record.Name)
+		return nil
+	default:
+		return &UsageError{command, fmt.Sprintf("Unknown action \"%v\"", action)}
+	}
+}
+
+func (c *Cli) printNamed(action string, noun string, res namedResource, id int) error {
+	record, err := getNamed(c.db, res, id)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(record)
+	}
+	if len(record.Location) > 0 {
+		fmt.Fprintf(c.stdout, "%v %v #%d: %v, %v\n", action, noun, id, record.Name,
+			record.Location)
+	} else {
+		fmt.Fprintf(c.stdout, "%v %v #%d: %v\n", action, noun, id, record.Name)
+	}
+	return nil
+}
+
+// StatsJSON is the output of the stats command with --json.
+type StatsJSON struct {
+	Books    int            `json:"books"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+func (c *Cli) stats(args []string) error {
+	fs := c.flagSet("stats")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return &UsageError{"stats", fmt.Sprintf("Unexpected arguments %v", rest)}
+	}
+
+	total, err := countAllBooks(c.db)
+	if err != nil {
+		return err
+	}
+	statuses, err := listStatuses(c.db)
+	if err != nil {
+		return err
+	}
+	stats := StatsJSON{total, map[string]int{}}
+	for _, status := range statuses {
+		stats.ByStatus[status], err = countBooksByStatus(c.db, status)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.json {
+		return c.printJSON(stats)
+	}
+	fmt.Fprintf(c.stdout, "%v books in library\n", total)
+	for _, status := range statuses {
+		fmt.Fprintf(c.stdout, "%6d  %v\n", stats.ByStatus[status], status)
+	}
+	return nil
+}
+
+func (c *Cli) tui(args []string) error {
+	fs := c.flagSet("tui")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return &UsageError{"tui", fmt.Sprintf("Unexpected arguments %v", rest)}
+	}
+	in, ok := c.input.(*os.File)
+	if !ok {
+		return fmt.Errorf("tui: Input is not a terminal")
+	}
+	return runTui(c.db, in, c.stdout)
+}
+
+func (c *Cli) serve(args []string) error {
+	fs := c.flagSet("serve")
+	rest, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	addr := ":8080"
+	switch len(rest) {
+	case 0:
+	case 1:
+		addr = rest[0]
+	default:
+		return &UsageError{"serve", "Expected a single address"}
+	}
+	return serveApi(c.db, addr)
+}
+
+func (c *Cli) help(args []string) error {
+	if len(args) != 1 {
+		return &UsageError{"help", "Expected a single command"}
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok || cmd.name == "help" {
+		return &UsageError{"help", fmt.Sprintf("Unknown command \"%v\"", args[0])}
+	}
+	// every command stops at -h, before using the database, after writing its
+	// usage to stderr
+	helpCli := *c
+	helpCli.stderr = c.stdout
+	if err := cmd.run(&helpCli, []string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runTestCli runs the command line against the test database, giving the exit
+// code and what was written to stdout and stderr.
+func runTestCli(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := runCli(append([]string{"--db", testDatabase}, args...),
+		strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCliShow(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "show", "5")
+	if code != exitOk {
+		t.Fatalf("show 5 exited with %v: %v", code, stderr)
+	}
+	expected := []string{
+		"Book #5\n",
+		"Title:       Kingdom through Covenant\n",
+		"Author:      Peter J. Gentry and Stephen J. Wellum\n",
+		"Edition:     2nd edition\n",
+		"Publisher:   Crossway\n",
+		"ISBN:        978-1-4335-5307-3\n",
+		"Status:      Owned, Read\n",
+		"Rating:      4.5/5\n",
+		"Categories:  Theology → Biblical Theology\n",
+		"Notes:\n  [2022-02-03 19:30:00] (xiv) Argues for progressive covenantalism",
+	}
+	for _, line := range expected {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("show 5 output missing %q, got:\n%v", line, stdout)
+		}
+	}
+	if !strings.Contains(stdout, "Subtitle:    A Biblical-Theological") {
+		t.Errorf("show 5 output missing subtitle, got:\n%v", stdout)
+	}
+	if strings.Contains(stdout, "Series:") {
+		t.Errorf("show 5 output includes empty series, got:\n%v", stdout)
+	}
+
+	code, stdout, _ = runTestCli("", "show", "3")
+	if code != exitOk || !strings.Contains(stdout, "Translator:  Thomas Williams\n") {
+		t.Errorf("show 3 output missing translator, got %v:\n%v", code, stdout)
+	}
+}
+
+func TestCliShowJson(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	code, stdout, stderr := runTestCli("", "--json", "show", "6")
+	if code != exitOk {
+		t.Fatalf("--json show 6 exited with %v: %v", code, stderr)
+	}
+	var bj BookJSON
+	if err := json.Unmarshal([]byte(stdout), &bj); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	b, err := getBookById(db, 6)
+	if err != nil {
+		t.Errorf("Problem getting book #6: %v", err)
+	}
+	if !reflect.DeepEqual(bj, bookToJSON(b)) {
+		t.Errorf("--json show 6 gave wrong book: expected %v, got %v", bookToJSON(b), bj)
+	}
+}
+
+func TestCliList(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "list")
+	if code != exitOk {
+		t.Fatalf("list exited with %v: %v", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected 6 books listed, got %v:\n%v", len(lines), stdout)
+	}
+	if !strings.HasPrefix(lines[2], "   3  Anselm, Basic Writings") {
+		t.Errorf("Unexpected line for book #3: %q", lines[2])
+	}
+
+	code, stdout, stderr = runTestCli("", "--json", "list", "--query", "publisher:Crossway")
+	if code != exitOk {
+		t.Fatalf("list --query exited with %v: %v", code, stderr)
+	}
+	var books []BookJSON
+	if err := json.Unmarshal([]byte(stdout), &books); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	var ids []int
+	for _, bj := range books {
+		ids = append(ids, bj.Id)
+	}
+	if !reflect.DeepEqual(ids, []int{4, 5, 6}) {
+		t.Errorf("list --query publisher:Crossway: expected books [4 5 6], got %v", ids)
+	}
+
+	code, _, stderr = runTestCli("", "list", "--query", "colour:red")
+	if code != exitUsage {
+		t.Errorf("list with invalid query: expected exit code %v, got %v: %v",
+			exitUsage, code, stderr)
+	}
+}
+
+func TestCliAddEditDelete(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	code, stdout, stderr := runTestCli("", "--json", "add",
+		"--author", "Karen H. Jobes and Moisés Silva",
+		"--title", "Invitation to the Septuagint",
+		"--year", "2015", "--edition", "2",
+		"--publisher", "Baker Academic",
+		"--isbn", "978-0-8010-3649-1",
+		"--status", "Owned",
+		"--purchased", "December 2021")
+	if code != exitOk {
+		t.Fatalf("add exited with %v: %v", code, stderr)
+	}
+	var added BookJSON
+	if err := json.Unmarshal([]byte(stdout), &added); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	id := added.Id
+	defer deleteBook(db, id)
+
+	b, err := getBookById(db, id)
+	if err != nil {
+		t.Fatalf("Problem getting added book: %v", err)
+	}
+	expected := makeTestBook()
+	if b.String() != expected.String() {
+		t.Errorf("add gave wrong book: expected %v, got %v", expected, b)
+	}
+
+	code, _, _ = runTestCli("", "add", "--title", "Invitation to the Septuagint",
+		"--author", "Karen H. Jobes and Moisés Silva", "--publisher", "Baker Academic")
+	if code != exitConflict {
+		t.Errorf("Adding duplicate book: expected exit code %v, got %v", exitConflict, code)
+	}
+
+	code, stdout, stderr = runTestCli("", "edit", strconv.Itoa(id), "--rating", "4",
+		"--subtitle", "Second Edition", "--translator", "Thomas Williams")
+	if code != exitOk {
+		t.Fatalf("edit exited with %v: %v", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "Updated book #"+strconv.Itoa(id)+": ") {
+		t.Errorf("Unexpected edit output: %q", stdout)
+	}
+	b, err = getBookById(db, id)
+	if err != nil {
+		t.Fatalf("Problem getting edited book: %v", err)
+	}
+	if b.rating.stars() != 4 || b.subtitle != "Second Edition" ||
+		b.translator != "Thomas Williams" || b.year != 2015 {
+		t.Errorf("edit didn't change just the given fields, got %#v", b)
+	}
+
+	code, _, _ = runTestCli("", "edit", "--clear-rating", strconv.Itoa(id))
+	if code != exitOk {
+		t.Errorf("edit --clear-rating exited with %v", code)
+	}
+	if b, _ = getBookById(db, id); b.rating.rated {
+		t.Errorf("edit --clear-rating left rating %v", b.rating)
+	}
+
+	code, stdout, stderr = runTestCli("", "delete", strconv.Itoa(id))
+	if code != exitOk {
+		t.Fatalf("delete exited with %v: %v", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "Deleted book #"+strconv.Itoa(id)+": ") {
+		t.Errorf("Unexpected delete output: %q", stdout)
+	}
+	if valid, _ := BookIDValid(db, id); valid {
+		t.Errorf("Book #%v still present after delete", id)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM people WHERE name = ?", "Moisés Silva").Scan(&count)
+	if count != 0 {
+		t.Errorf("delete left person only linked to the deleted book")
+	}
+}
+
+func TestCliEditRollback(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	orig, err := getBookById(db, 1)
+	if err != nil {
+		t.Fatalf("Problem getting book #1: %v", err)
+	}
+
+	code, _, stderr := runTestCli("", "edit", "1", "--title", "Changed title",
+		"--author", "Tremper Longman III", "--edition", "-1")
+	if code != exitUsage || !strings.Contains(stderr, "Edition cannot be negative") {
+		t.Errorf("edit with negative edition exited with %v: %v", code, stderr)
+	}
+
+	b, err := getBookById(db, 1)
+	if err != nil {
+		t.Fatalf("Problem getting book #1: %v", err)
+	}
+	if b.String() != orig.String() {
+		t.Errorf("Failed edit changed book. Expected %v, got %v", orig, b)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM people WHERE name = ?", "Tremper Longman III").
+		Scan(&count)
+	if count != 0 {
+		t.Errorf("Failed edit left new author in database")
+	}
+}
+
+func TestCliAddLookup(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	server := newFakeOpenLibrary(makeLookupBook())
+	defer server.Close()
+
+	code, stdout, stderr := runTestCli("n\n", "add", "--lookup",
+		"--metadata-url", server.URL, "--isbn", "0-85111-285-4")
+	if code != exitError {
+		t.Errorf("Cancelled lookup: expected exit code %v, got %v", exitError, code)
+	}
+	if !strings.Contains(stderr, "Found: Stephen G. Dempster, Dominion and Dynasty") {
+		t.Errorf("Lookup didn't show the book found before asking, got %q", stderr)
+	}
+	if len(stdout) > 0 {
+		t.Errorf("Cancelled lookup wrote output %q", stdout)
+	}
+
+	code, stdout, stderr = runTestCli("y\n", "--json", "add", "--lookup",
+		"--metadata-url", server.URL, "--isbn", "0-85111-285-4", "--status", "Want")
+	if code != exitOk {
+		t.Fatalf("add --lookup exited with %v: %v", code, stderr)
+	}
+	var added BookJSON
+	if err := json.Unmarshal([]byte(stdout), &added); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	defer deleteBook(db, added.Id)
+	if added.Title != "Dominion and Dynasty" || added.Publisher != "Apollos" ||
+		!reflect.DeepEqual(added.Status, []string{"Want"}) {
+		t.Errorf("add --lookup gave wrong book: %v", added)
+	}
+}
+
+func TestCliNamed(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "--json", "people")
+	if code != exitOk {
+		t.Fatalf("people exited with %v: %v", code, stderr)
+	}
+	var people []NamedJSON
+	if err := json.Unmarshal([]byte(stdout), &people); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	if len(people) < 11 || people[2].Name != "Peter J. Gentry" ||
+		!reflect.DeepEqual(people[2].Books, []int{4, 5}) {
+		t.Errorf("Unexpected people list: %v", people)
+	}
+
+	code, stdout, _ = runTestCli("", "publishers", "show", "2")
+	if code != exitOk || stdout != "Publisher #2: Hackett\n   3  Anselm, Basic Writings, trans. Thomas Williams (2007), ISBN 978-0-87220-895-7 [Owned]\n" {
+		t.Errorf("Unexpected output for publishers show 2, exit code %v:\n%v", code, stdout)
+	}
+
+	code, stdout, _ = runTestCli("", "series", "rename", "1", "Spectrum")
+	if code != exitOk || stdout != "Renamed series #1: Spectrum\n" {
+		t.Errorf("Unexpected output for series rename, exit code %v: %q", code, stdout)
+	}
+	code, _, _ = runTestCli("", "series", "rename", "1", "Spectrum Multiview Books")
+	if code != exitOk {
+		t.Errorf("Couldn't revert series rename, exit code %v", code)
+	}
+
+	code, _, _ = runTestCli("", "series", "delete", "1")
+	if code != exitConflict {
+		t.Errorf("Deleting series in use: expected exit code %v, got %v", exitConflict, code)
+	}
+	code, _, _ = runTestCli("", "people", "show", "999")
+	if code != exitNotFound {
+		t.Errorf("Showing unknown person: expected exit code %v, got %v", exitNotFound, code)
+	}
+
+	code, stdout, _ = runTestCli("", "--json", "people", "add", "Benedict Ward")
+	var person NamedJSON
+	if err := json.Unmarshal([]byte(stdout), &person); err != nil || code != exitOk {
+		t.Fatalf("people add exited with %v, output %q", code, stdout)
+	}
+	code, stdout, _ = runTestCli("", "people", "delete", strconv.Itoa(person.Id))
+	if code != exitOk || stdout != "Deleted person #"+strconv.Itoa(person.Id)+": Benedict Ward\n" {
+		t.Errorf("Unexpected output for people delete, exit code %v: %q", code, stdout)
+	}
+}
+
+func TestCliSearch(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "--json", "search", "covenant")
+	if code != exitOk {
+		t.Fatalf("search exited with %v: %v", code, stderr)
+	}
+	var results []SearchResultJSON
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	if len(results) != 1 || results[0].Book.Id != 5 {
+		t.Errorf("Expected search for covenant to find book #5, got %v", results)
+	}
+
+	code, _, _ = runTestCli("", "search")
+	if code != exitUsage {
+		t.Errorf("Empty search: expected exit code %v, got %v", exitUsage, code)
+	}
+}
+
+func TestCliStats(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "--json", "stats")
+	if code != exitOk {
+		t.Fatalf("stats exited with %v: %v", code, stderr)
+	}
+	var stats StatsJSON
+	if err := json.Unmarshal([]byte(stdout), &stats); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	if stats.Books != 6 || stats.ByStatus["Owned"] != 5 || stats.ByStatus["Want"] != 1 {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+}
+
+func TestCliExitCodes(t *testing.T) {
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, exitUsage},
+		{[]string{"frobnicate"}, exitUsage},
+		{[]string{"--frobnicate", "list"}, exitUsage},
+		{[]string{"list", "--frobnicate"}, exitUsage},
+		{[]string{"show"}, exitUsage},
+		{[]string{"show", "abc"}, exitUsage},
+		{[]string{"show", "17"}, exitNotFound},
+		{[]string{"delete", "17"}, exitNotFound},
+		{[]string{"edit", "5"}, exitUsage},
+		{[]string{"edit", "17", "--title", "T"}, exitNotFound},
+		{[]string{"edit", "5", "--rating", "4.2"}, exitUsage},
+		{[]string{"add", "--publisher", "IVP"}, exitUsage},
+		{[]string{"add", "--title", "T", "--publisher", "IVP", "--isbn", "123"}, exitUsage},
+		{[]string{"people", "frobnicate"}, exitUsage},
+		{[]string{"help", "add"}, exitOk},
+		{[]string{"help"}, exitOk},
+		{[]string{"list", "-h"}, exitOk},
+	}
+	for _, test := range tests {
+		code, _, stderr := runTestCli("", test.args...)
+		if code != test.code {
+			t.Errorf("%v: expected exit code %v, got %v: %v", test.args, test.code,
+				code, stderr)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runCli([]string{"--db", "no-such-library.sqlite", "list"},
+		strings.NewReader(""), &stdout, &stderr)
+	if code != exitError {
+		t.Errorf("Missing database: expected exit code %v, got %v", exitError, code)
+	}
+	if !strings.Contains(stderr.String(), "no-such-library.sqlite") {
+		t.Errorf("Missing database error doesn't name the file: %q", stderr.String())
+	}
+}
+
+func TestCliImport(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	file := filepath.Join(t.TempDir(), "books.csv")
+	if err := os.WriteFile(file, []byte(testImportCsv), 0o644); err != nil {
+		t.Fatalf("Problem writing CSV file: %v", err)
+	}
+
+	code, stdout, stderr := runTestCli("", "import", "--dry-run", "--columns",
+		"Authors=author,Date Bought=purchased", file)
+	if code != exitUsage {
+		t.Errorf("Dry run with invalid rows: expected exit code %v, got %v", exitUsage, code)
+	}
+	for _, text := range []string{
+		"Would add 2 books\n  row 2: Karen H. Jobes and Moisés Silva, Invitation to the Septuagint",
+		"Skipped 2 duplicates\n  row 3: Book \"Kingdom through Covenant\" already in database, id #5",
+		"Skipped 2 invalid rows\n  row 4: ",
+		"Ignored columns: Shelf\n",
+	} {
+		if !strings.Contains(stdout, text) {
+			t.Errorf("Dry run output missing %q, got:\n%v", text, stdout)
+		}
+	}
+	if !strings.Contains(stderr, "Skipped 4 of 6 rows") {
+		t.Errorf("Expected skipped rows error, got %q", stderr)
+	}
+	if count, _ := countAllBooks(db); count != 6 {
+		t.Errorf("Dry run changed the library, now %v books", count)
+	}
+
+	// only the new books, read from stdin
+	lines := strings.Split(testImportCsv, "\n")
+	input := strings.Join([]string{lines[0], lines[1], lines[6]}, "\n")
+	code, stdout, stderr = runTestCli(input, "--json", "import", "--columns",
+		"Authors=author,Date Bought=purchased", "-")
+	if code != exitOk {
+		t.Fatalf("import exited with %v: %v", code, stderr)
+	}
+	var report ImportReportJSON
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	for _, added := range report.Added {
+		defer deleteBook(db, added.Book.Id)
+	}
+	if len(report.Added) != 2 || report.Added[1].Row != 3 ||
+		report.Added[1].Book.Title != "Dominion and Dynasty" {
+		t.Errorf("Unexpected import report: %v", report)
+	}
+
+	code, stdout, _ = runTestCli(input, "--json", "import", "--columns",
+		"Authors=author,Date Bought=purchased", "-")
+	if code != exitConflict {
+		t.Errorf("Importing again: expected exit code %v, got %v", exitConflict, code)
+	}
+	var again ImportReportJSON
+	json.Unmarshal([]byte(stdout), &again)
+	if len(again.Duplicates) != 2 || len(report.Added) == 0 ||
+		again.Duplicates[0].BookId != report.Added[0].Book.Id {
+		t.Errorf("Importing again should report duplicates of the books added, got %v",
+			again)
+	}
+}
+
+func TestCliExport(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "export", "--query", "status:Want",
+		"--columns", "id,title,editor")
+	if code != exitOk {
+		t.Fatalf("export exited with %v: %v", code, stderr)
+	}
+	expected := "id,title,editor\n" +
+		"6,Christianity and Science,N. Gray Sutanto; James Eglinton; Cory C. Brock\n"
+	if stdout != expected {
+		t.Errorf("export: expected %q, got %q", expected, stdout)
+	}
+
+	file := filepath.Join(t.TempDir(), "books.tsv")
+	code, stdout, stderr = runTestCli("", "export", "--tsv", "--output", file)
+	if code != exitOk || stdout != "Exported 6 books to "+file+"\n" {
+		t.Errorf("export to file: got exit code %v, %q %q", code, stdout, stderr)
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Problem reading exported file: %v", err)
+	}
+	if lines := strings.Split(string(contents), "\n"); len(lines) != 8 ||
+		!strings.HasPrefix(lines[3], "3\tAnselm\t\tThomas Williams\t") {
+		t.Errorf("Unexpected exported file:\n%s", contents)
+	}
+
+	if code, _, _ := runTestCli("", "export", "--columns", "title,colour"); code != exitUsage {
+		t.Errorf("export with unknown column: expected exit code %v, got %v", exitUsage, code)
+	}
+}
+
+func TestCliExportBib(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "export", "--format", "biblatex", "--series", "1")
+	if code != exitOk {
+		t.Fatalf("export exited with %v: %v", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, "@collection{matz2019divine,\n") ||
+		!strings.Contains(stdout, "  subtitle = {Four Views of God's Emotions and Suffering},\n") {
+		t.Errorf("Unexpected BibLaTeX export:\n%v", stdout)
+	}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"--format", "mods"}, exitUsage},
+		{[]string{"--format", "bibtex", "--columns", "title"}, exitUsage},
+		{[]string{"--format", "bibtex", "--tsv"}, exitUsage},
+		{[]string{"--book", "1", "--series", "1"}, exitUsage},
+		{[]string{"--format", "bibtex", "--book", "999"}, exitNotFound},
+		{[]string{"--format", "bibtex", "--person", "999"}, exitNotFound},
+	}
+	for _, test := range tests {
+		if code, _, _ := runTestCli("", append([]string{"export"}, test.args...)...); code != test.code {
+			t.Errorf("export %v: expected exit code %v, got %v", test.args, test.code, code)
+		}
+	}
+}
+
+func TestCliRisAndCslJson(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, format := range []string{"ris", "csl-json"} {
+		code, exported, stderr := runTestCli("", "export", "--format", format,
+			"--query", "author:Gentry")
+		if code != exitOk {
+			t.Fatalf("export --format %v exited with %v: %v", format, code, stderr)
+		}
+		if !strings.Contains(exported, "gentry2017how") ||
+			!strings.Contains(exported, "gentry2018kingdom") {
+			t.Errorf("export --format %v: expected citation keys as ids, got\n%v", format,
+				exported)
+		}
+
+		code, stdout, _ := runTestCli(exported, "import", "--format", format, "--dry-run", "-")
+		if code != exitConflict {
+			t.Errorf("import --format %v of exported books: expected exit code %v, got %v",
+				format, exitConflict, code)
+		}
+		if !strings.Contains(stdout, "Would add 0 books\nSkipped 2 duplicates\n") {
+			t.Errorf("import --format %v: unexpected output\n%v", format, stdout)
+		}
+	}
+
+	code, _, _ := runTestCli("TY  - BOOK\n", "import", "--format", "ris", "-")
+	if code != exitUsage {
+		t.Errorf("import of invalid RIS: expected exit code %v, got %v", exitUsage, code)
+	}
+	code, _, _ = runTestCli("", "import", "--format", "ris", "--columns", "a=title", "-")
+	if code != exitUsage {
+		t.Errorf("import --columns with RIS: expected exit code %v, got %v", exitUsage, code)
+	}
+	if count, _ := countAllBooks(db); count != 6 {
+		t.Errorf("Imports changed the library, now %v books", count)
+	}
+}
+
+func TestCliCite(t *testing.T) {
+	code, stdout, stderr := runTestCli("", "cite", "--format", "markdown", "3", "4")
+	expected := "Anselm. *Basic Writings*. Translated by Thomas Williams. Indianapolis: " +
+		"Hackett, 2007.\nGentry, Peter J. *How to Read and Understand the Biblical " +
+		"Prophets*. Wheaton, IL: Crossway, 2017.\n"
+	if code != exitOk || stdout != expected {
+		t.Errorf("cite exited with %v: %v\nexpected\n%v\ngot\n%v", code, stderr, expected,
+			stdout)
+	}
+
+	code, stdout, _ = runTestCli("", "--json", "cite", "--style", "apa", "--note", "5")
+	var citations []CitationJSON
+	if err := json.Unmarshal([]byte(stdout), &citations); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	if code != exitOk || len(citations) != 1 ||
+		citations[0].Citation != "(Gentry & Wellum, 2018)" {
+		t.Errorf("cite --json exited with %v, gave %+v", code, citations)
+	}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, exitUsage},
+		{[]string{"--style", "mla", "1"}, exitUsage},
+		{[]string{"--format", "pdf", "1"}, exitUsage},
+		{[]string{"x"}, exitUsage},
+		{[]string{"999"}, exitNotFound},
+	}
+	for _, test := range tests {
+		if code, _, _ := runTestCli("", append([]string{"cite"}, test.args...)...); code != test.code {
+			t.Errorf("cite %v: expected exit code %v, got %v", test.args, test.code, code)
+		}
+	}
+}
+
+func TestCliLocatePublisher(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+	defer updatePublisherLocation(db, 1, "Downers Grove, IL")
+
+	code, stdout, _ := runTestCli("", "publishers", "locate", "1", "Leicester")
+	if code != exitOk || stdout != "Located publisher #1: IVP, Leicester\n" {
+		t.Errorf("publishers locate exited with %v: %q", code, stdout)
+	}
+	code, stdout, _ = runTestCli("", "publishers", "locate", "1")
+	if code != exitOk || stdout != "Located publisher #1: IVP\n" {
+		t.Errorf("publishers locate without place exited with %v: %q", code, stdout)
+	}
+	if location, _ := publisherLocation(db, 1); location != "" {
+		t.Errorf("Expected location removed, got %q", location)
+	}
+
+	if code, _, _ := runTestCli("", "people", "locate", "3", "Louisville"); code != exitUsage {
+		t.Errorf("people locate: expected exit code %v, got %v", exitUsage, code)
+	}
+	if code, _, _ := runTestCli("", "publishers", "locate", "999", "Nowhere"); code != exitNotFound {
+		t.Errorf("publishers locate 999: expected exit code %v, got %v", exitNotFound, code)
+	}
+}
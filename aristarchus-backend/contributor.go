@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ContributorRole is the part a person played in producing a book, as held in
+// the role column of the book_contributor table.
+type ContributorRole string
+
+const (
+	RoleAuthor      ContributorRole = "author"
+	RoleEditor      ContributorRole = "editor"
+	RoleTranslator  ContributorRole = "translator"
+	RoleIllustrator ContributorRole = "illustrator"
+	RoleForeword    ContributorRole = "foreword"
+	RoleCompiler    ContributorRole = "compiler"
+)
+
+// contributorRoles lists the roles in the order they are given in a book's
+// details.
+var contributorRoles = []ContributorRole{
+	RoleAuthor,
+	RoleEditor,
+	RoleTranslator,
+	RoleIllustrator,
+	RoleForeword,
+	RoleCompiler,
+}
+
+type InvalidRoleError struct {
+	CallFunc string
+	Role     ContributorRole
+}
+
+func (e *InvalidRoleError) Error() string {
+	return fmt.Sprintf("%v: Unknown contributor role \"%v\"", e.CallFunc, e.Role)
+}
+
+func (r ContributorRole) valid() bool {
+	return slices.Contains(contributorRoles, r)
+}
+
+// label gives the role's name for headings, e.g. "Translator".
+func (r ContributorRole) label() string {
+	if len(r) == 0 {
+		return ""
+	}
+	return strings.ToUpper(string(r[:1])) + string(r[1:])
+}
+
+// abbreviation gives the short form of the role used when listing the
+// contributor after a title, e.g. "trans." for "trans. Thomas Williams".
+func (r ContributorRole) abbreviation() string {
+	switch r {
+	case RoleEditor:
+		return "ed."
+	case RoleTranslator:
+		return "trans."
+	case RoleIllustrator:
+		return "illus."
+	case RoleForeword:
+		return "foreword by"
+	case RoleCompiler:
+		return "comp."
+	default:
+		return ""
+	}
+}
+
+// nameSuffixes are the words, in lower case and without full stops, which
+// can end a name after the surname, e.g. "Jr." in "Martin Luther King Jr.".
+var nameSuffixes = []string{"jr", "sr", "ii", "iii", "iv"}
+
+// splitPersonName divides a name as held in the people table into the given
+// names, surname and any suffix, for formats which hold them separately. The
+// surname is taken to be the last word, so a single word name is all surname.
+func splitPersonName(name string) (given, family, suffix string) {
+	words := strings.Fields(name)
+	if n := len(words); n > 1 &&
+		slices.Contains(nameSuffixes, strings.ToLower(strings.TrimSuffix(words[n-1], "."))) {
+		suffix, words = words[n-1], words[:n-1]
+	}
+	if len(words) == 0 {
+		return "", "", suffix
+	}
+	return strings.Join(words[:len(words)-1], " "), words[len(words)-1], suffix
+}
+
+// joinPersonName puts the parts of a name back together in the order held in
+// the people table, e.g. "Peter J. Gentry".
+func joinPersonName(given, family, suffix string) string {
+	var parts []string
+	for _, part := range []string{given, family, suffix} {
+		if part = strings.TrimSpace(part); len(part) > 0 {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// contributors gives a pointer to the field of the book holding the names for
+// a role, so that the roles can be handled in a loop.
+func (b *Book) contributors(role ContributorRole) *string {
+	switch role {
+	case RoleAuthor:
+		return &b.author
+	case RoleEditor:
+		return &b.editor
+	case RoleTranslator:
+		return &b.translator
+	case RoleIllustrator:
+		return &b.illustrator
+	case RoleForeword:
+		return &b.foreword
+	case RoleCompiler:
+		return &b.compiler
+	default:
+		return nil
+	}
+}
+
+// getContributorsListById gives the names of the people with a given role on a
+// book, in the book's order for that role.
+func getContributorsListById(db DBInterface, id int, role ContributorRole) ([]string, error) {
+	if !role.valid() {
+		return []string{}, &InvalidRoleError{"getContributorsListById", role}
+	}
+	bookValid, err := BookIDValid(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf(
+			"getContributorsListById, could not validate book id #%v: %v",
+			id, err,
+		)
+	}
+	if !bookValid {
+		return []string{}, &InvalidBookIdError{"getContributorsListById", id}
+	}
+
+	var names []string
+	rows, err := stmtQuery(db, stmtContributorsListById, id, role)
+	if err != nil {
+		return names, fmt.Errorf("getContributorsListById %d: %v", id, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return names, fmt.Errorf("getContributorsListById %d, %v", id, err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return names, fmt.Errorf("getContributorsListById, rows.Next() error: %v", err)
+	}
+	return names, nil
+}
+
+// addBookContributors adds people to a book in a role, placing them after any
+// already in that role.
+func addBookContributors(db DBInterface, id int, role ContributorRole, names []string) error {
+	var lastPosition int
+	err := stmtQueryRow(db, stmtContributorsLastPosition, id, role).Scan(&lastPosition)
+	if err != nil {
+		return fmt.Errorf("addBookContributors: %v", err)
+	}
+
+	for i, name := range names {
+		pid, err := personId(db, name)
+		if err != nil {
+			return fmt.Errorf("addBookContributors: %v", err)
+		}
+		_, err = stmtExec(db, stmtInsertContributor, id, pid, role, lastPosition+i+1)
+		if err != nil {
+			return fmt.Errorf("addBookContributors: %v", err)
+		}
+	}
+	return nil
+}
+
+// updateBookContributors sets the people with a given role on a book, from a
+// name list such as "Peter J. Gentry and Stephen J. Wellum". People are added
+// to or removed from the book as needed, and kept in the order of the list.
+func updateBookContributors(db DBInterface, id int, role ContributorRole, names string) (string, error) {
+	if !role.valid() {
+		return "", &InvalidRoleError{"updateBookContributors", role}
+	}
+	newList := nameListFromString(names)
+	oldList, err := getContributorsListById(db, id, role)
+	if err != nil {
+		return "", err
+	}
+
+	var toAdd, toDelete []string
+	for _, name := range newList {
+		if !slices.Contains(oldList, name) {
+			toAdd = append(toAdd, name)
+		}
+	}
+	for _, name := range oldList {
+		if !slices.Contains(newList, name) {
+			toDelete = append(toDelete, name)
+		}
+	}
+
+	// make the edit of contributors atomic
+	err = withTx(db, func(tx DBInterface) error {
+		for _, name := range toDelete {
+			pid, err := personId(tx, name)
+			if err != nil {
+				return err
+			}
+			if _, err := stmtExec(tx, stmtDeleteContributor, id, pid, role); err != nil {
+				return err
+			}
+		}
+
+		if err := addBookContributors(tx, id, role, toAdd); err != nil {
+			return err
+		}
+
+		// number everyone in the order given, which also handles a list
+		// which only reorders the existing people
+		for i, name := range newList {
+			pid, err := personId(tx, name)
+			if err != nil {
+				return err
+			}
+			_, err = stmtExec(tx, stmtSetContributorPosition, i+1, id, pid, role)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("updateBookContributors, issue updating %vs: %w", role, err)
+	}
+
+	updatedList, err := getContributorsListById(db, id, role)
+	if err != nil {
+		return "", fmt.Errorf("updateBookContributors, Couldn't fetch updated %vs: %v", role, err)
+	}
+	return formatNameList(updatedList), nil
+}
+
+// booksByPersonIdRole gives the ids of the books on which a person has a
+// given role.
+func booksByPersonIdRole(db DBInterface, id int, role ContributorRole) ([]int, error) {
+	if !role.valid() {
+		return nil, &InvalidRoleError{"booksByPersonIdRole", role}
+	}
+	var count int
+	if err := stmtQueryRow(db, stmtPersonIdValid, id).Scan(&count); err != nil {
+		return nil, fmt.Errorf(
+			"booksByPersonIdRole: Could not look up person ID #%v in database: %v",
+			id, err)
+	}
+	if count == 0 {
+		return nil, &InvalidPersonIdError{"booksByPersonIdRole", id}
+	}
+
+	rows, err := db.Query(`
+        SELECT book_id
+        FROM book_contributor
+        WHERE person_id = ? AND role = ?
+        ORDER BY book_id`, id, role)
+	if err != nil {
+		return nil, fmt.Errorf("booksByPersonIdRole: Couldn't retrieve books for person ID #%v, %v",
+			id, err)
+	}
+	defer rows.Close()
+
+	var bookList []int
+	for rows.Next() {
+		var bookId int
+		if err := rows.Scan(&bookId); err != nil {
+			return nil, fmt.Errorf("booksByPersonIdRole: Issue scanning database query result: %v", err)
+		}
+		bookList = append(bookList, bookId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("booksByPersonIdRole, rows.Next() error: %v", err)
+	}
+	return bookList, nil
+}
+
+// contributorNotes lists the contributors shown after a book's title, e.g.
+// "ed. D. A. Carson, trans. Thomas Williams". Editors are only listed here when
+// the book also has an author, as otherwise they are given in place of the
+// author.
+func (b Book) contributorNotes() []string {
+	var notes []string
+	for _, role := range contributorRoles[1:] {
+		names := *b.contributors(role)
+		if len(names) == 0 {
+			continue
+		}
+		if role == RoleEditor && len(b.author) == 0 {
+			continue
+		}
+		if role == RoleCompiler && len(b.author) == 0 && len(b.editor) == 0 {
+			continue
+		}
+		notes = append(notes, fmt.Sprintf("%v %v", role.abbreviation(), names))
+	}
+	return notes
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGetContributorsListById(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		id       int
+		role     ContributorRole
+		expected []string
+	}{
+		{3, RoleAuthor, []string{"Anselm"}},
+		{3, RoleTranslator, []string{"Thomas Williams"}},
+		{3, RoleEditor, nil},
+		{5, RoleAuthor, []string{"Peter J. Gentry", "Stephen J. Wellum"}},
+		{6, RoleEditor, []string{"N. Gray Sutanto", "James Eglinton", "Cory C. Brock"}},
+	}
+	for _, test := range tests {
+		names, err := getContributorsListById(db, test.id, test.role)
+		if err != nil {
+			t.Errorf("Problem getting %vs of book #%v: %v", test.role, test.id, err)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Wrong %vs returned for book #%v: expected %v, got %v",
+				test.role, test.id, test.expected, names)
+		}
+	}
+}
+
+func TestGetContributorsListByIdInvalidRole(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = getContributorsListById(db, 3, ContributorRole("typesetter"))
+	var roleErr *InvalidRoleError
+	if !errors.As(err, &roleErr) {
+		t.Errorf("Expected InvalidRoleError for unknown role, got %v", err)
+	}
+}
+
+func TestBookStringContributors(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := map[int]string{
+		2: "Robert J. Matz and A. Chadwick Thornhill (eds.), Divine Impassibility: Four Views of God's Emotions and Suffering (2019), ISBN 978-0-8308-5253-6 [Owned]",
+		3: "Anselm, Basic Writings, trans. Thomas Williams (2007), ISBN 978-0-87220-895-7 [Owned]",
+		6: "Herman Bavinck, Christianity and Science, ed. N. Gray Sutanto, James Eglinton and Cory C. Brock (2023), ISBN 978-1-4335-7920-2 [Want]",
+	}
+	for id, s := range expected {
+		b, err := getBookById(db, id)
+		if err != nil {
+			t.Errorf("Problem getting book #%v: %v", id, err)
+		}
+		if b.String() != s {
+			t.Errorf("Wrong string for book #%v: expected %v, got %v", id, s, b.String())
+		}
+	}
+}
+
+func TestBookStringOtherContributors(t *testing.T) {
+	b := Book{
+		compiler:    "Arthur Bennett",
+		foreword:    "J. I. Packer",
+		illustrator: "Pauline Baynes",
+		title:       "The Valley of Vision",
+		year:        1975,
+		status:      []string{"Owned"},
+	}
+
+	expected := "Arthur Bennett (comp.), The Valley of Vision, illus. Pauline Baynes, foreword by J. I. Packer (1975) [Owned]"
+	if b.String() != expected {
+		t.Errorf("Wrong value returned by String method on Book: expected %v, got %v",
+			expected, b.String())
+	}
+}
+
+func TestBooksByPersonIdRole(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	books, err := booksByPersonIdRole(db, 8, RoleTranslator)
+	if err != nil {
+		t.Errorf("Problem getting books translated by person #8: %v", err)
+	}
+	if !reflect.DeepEqual(books, []int{3}) {
+		t.Errorf("Wrong books translated by person #8: expected [3], got %v", books)
+	}
+
+	books, err = booksByPersonIdRole(db, 8, RoleEditor)
+	if err != nil {
+		t.Errorf("Problem getting books edited by person #8: %v", err)
+	}
+	if len(books) != 0 {
+		t.Errorf("Expected no books edited by person #8, got %v", books)
+	}
+
+	books, err = booksByPersonId(db, 8)
+	if err != nil {
+		t.Errorf("Problem getting books of person #8: %v", err)
+	}
+	if !reflect.DeepEqual(books, []int{3}) {
+		t.Errorf("Wrong books for person #8: expected [3], got %v", books)
+	}
+
+	_, err = booksByPersonIdRole(db, 999, RoleAuthor)
+	var personErr *InvalidPersonIdError
+	if !errors.As(err, &personErr) {
+		t.Errorf("Expected InvalidPersonIdError for person #999, got %v", err)
+	}
+}
+
+func TestUpdateBookContributors(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	newTranslators := "Thomas Williams and Sandra Visser"
+	updated, err := updateBookContributors(db, 3, RoleTranslator, newTranslators)
+	if err != nil {
+		t.Errorf("Problem updating translators: %v", err)
+	}
+	if updated != newTranslators {
+		t.Errorf("Translators not properly updated, expected %v, got %v",
+			newTranslators, updated)
+	}
+
+	// the other roles are left alone
+	authors, err := getAuthorsListById(db, 3)
+	if err != nil {
+		t.Errorf("Problem getting authors of book #3: %v", err)
+	}
+	if !reflect.DeepEqual(authors, []string{"Anselm"}) {
+		t.Errorf("Authors changed by updating translators: %v", authors)
+	}
+
+	updated, err = updateBookContributors(db, 3, RoleTranslator, "Thomas Williams")
+	if err != nil {
+		t.Errorf("Problem reverting translators: %v", err)
+	}
+	if updated != "Thomas Williams" {
+		t.Errorf("Translators not properly reverted, expected Thomas Williams, got %v",
+			updated)
+	}
+
+	pid, err := personId(db, "Sandra Visser")
+	if err != nil {
+		t.Errorf("Problem looking up added translator: %v", err)
+	}
+	if err := deletePerson(db, pid); err != nil {
+		t.Errorf("Problem removing added translator: %v", err)
+	}
+}
+
+func TestAddBookWithContributors(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b := Book{
+		author:     "Athanasius",
+		translator: "John Behr",
+		foreword:   "Rowan Williams",
+		title:      "On the Incarnation",
+		year:       2011,
+		publisher:  "St Vladimir's Seminary Press",
+		isbn:       "9780881414271",
+		status:     []string{"Want"},
+	}
+	id, err := addBook(db, &b)
+	if err != nil {
+		t.Fatalf("Problem adding book with contributors: %v", err)
+	}
+
+	added, err := getBookById(db, id)
+	if err != nil {
+		t.Errorf("Problem getting added book: %v", err)
+	}
+	for _, role := range contributorRoles {
+		if *added.contributors(role) != *b.contributors(role) {
+			t.Errorf("Wrong %v for added book: expected %v, got %v",
+				role, *b.contributors(role), *added.contributors(role))
+		}
+	}
+
+	if err := deleteBook(db, id); err != nil {
+		t.Errorf("Problem deleting added book: %v", err)
+	}
+	for _, name := range []string{"Athanasius", "John Behr", "Rowan Williams"} {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM people WHERE name = ?",
+			name).Scan(&count); err != nil {
+			t.Errorf("Problem checking for %v: %v", name, err)
+		}
+		if count != 0 {
+			t.Errorf("%v not removed when their only book was deleted", name)
+		}
+	}
+}
+
+func TestSplitPersonName(t *testing.T) {
+	tests := []struct {
+		name, given, family, suffix string
+	}{
+		{"Peter J. Gentry", "Peter J.", "Gentry", ""},
+		{"Anselm", "", "Anselm", ""},
+		{"Martin Luther King Jr.", "Martin Luther", "King", "Jr."},
+		{"John Smith III", "John", "Smith", "III"},
+		{"", "", "", ""},
+	}
+	for _, test := range tests {
+		given, family, suffix := splitPersonName(test.name)
+		if given != test.given || family != test.family || suffix != test.suffix {
+			t.Errorf("splitPersonName(%q): expected %q, %q, %q, got %q, %q, %q", test.name,
+				test.given, test.family, test.suffix, given, family, suffix)
+		}
+		if name := joinPersonName(given, family, suffix); name != test.name {
+			t.Errorf("joinPersonName(%q, %q, %q): expected %q, got %q", given, family,
+				suffix, test.name, name)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CSL-JSON files are an array of items, one per book, using the variables of
+// the Citation Style Language. As for RIS, the title variable holds the title
+// with the subtitle and title-short the title alone. CSL has no variable for
+// writers of forewords, so they are given as contributors. The statuses,
+// purchase date and rating go in the item's custom object, which CSL keeps for
+// data of the program writing the file. As with RIS, only statuses already in
+// the library are read back.
+
+// cslName is a name as held in CSL-JSON, either in parts or, for names of a
+// single word, as a literal.
+type cslName struct {
+	Family              string `json:"family,omitempty"`
+	Given               string `json:"given,omitempty"`
+	DroppingParticle    string `json:"dropping-particle,omitempty"`
+	NonDroppingParticle string `json:"non-dropping-particle,omitempty"`
+	Suffix              string `json:"suffix,omitempty"`
+	Literal             string `json:"literal,omitempty"`
+}
+
+// cslString is a CSL variable which may be given as a string or a number, such
+// as an edition or the parts of a date.
+type cslString string
+
+func (s *cslString) UnmarshalJSON(data []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = cslString(n)
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return fmt.Errorf("cslString, Expected a string or number, got %s", data)
+	}
+	*s = cslString(str)
+	return nil
+}
+
+type cslDate struct {
+	DateParts [][]cslString `json:"date-parts,omitempty"`
+	Raw       string        `json:"raw,omitempty"`
+	Literal   string        `json:"literal,omitempty"`
+}
+
+// cslCustom holds the fields of a book which have no CSL variable.
+type cslCustom struct {
+	Status    []string `json:"status,omitempty"`
+	Purchased string   `json:"purchased,omitempty"`
+	Rating    *float64 `json:"rating,omitempty"`
+}
+
+type cslItem struct {
+	Id              any        `json:"id"`
+	Type            string     `json:"type"`
+	Author          []cslName  `json:"author,omitempty"`
+	Editor          []cslName  `json:"editor,omitempty"`
+	Translator      []cslName  `json:"translator,omitempty"`
+	Illustrator     []cslName  `json:"illustrator,omitempty"`
+	Contributor     []cslName  `json:"contributor,omitempty"`
+	Compiler        []cslName  `json:"compiler,omitempty"`
+	Title           string     `json:"title,omitempty"`
+	TitleShort      string     `json:"title-short,omitempty"`
+	CollectionTitle string     `json:"collection-title,omitempty"`
+	Edition         cslString  `json:"edition,omitempty"`
+	Publisher       string     `json:"publisher,omitempty"`
+	Issued          *cslDate   `json:"issued,omitempty"`
+	ISBN            string     `json:"ISBN,omitempty"`
+	Custom          *cslCustom `json:"custom,omitempty"`
+}
+
+// cslVariables are the variables of an item read when importing.
+var cslVariables = []string{"id", "type", "author", "editor", "translator",
+	"illustrator", "contributor", "compiler", "title", "title-short",
+	"collection-title", "edition", "publisher", "issued", "ISBN", "custom"}
+
+// names gives a pointer to the item's variable for the names of a role.
+func (item *cslItem) names(role ContributorRole) *[]cslName {
+	switch role {
+	case RoleAuthor:
+		return &item.Author
+	case RoleEditor:
+		return &item.Editor
+	case RoleTranslator:
+		return &item.Translator
+	case RoleIllustrator:
+		return &item.Illustrator
+	case RoleForeword:
+		return &item.Contributor
+	case RoleCompiler:
+		return &item.Compiler
+	default:
+		return nil
+	}
+}
+
+func newCslName(name string) cslName {
+	given, family, suffix := splitPersonName(name)
+	if len(given) == 0 && len(suffix) == 0 {
+		return cslName{Literal: family}
+	}
+	return cslName{Family: family, Given: given, Suffix: suffix}
+}
+
+func (n cslName) String() string {
+	if len(n.Literal) > 0 {
+		return n.Literal
+	}
+	given := joinPersonName(n.Given, n.DroppingParticle, "")
+	family := joinPersonName(n.NonDroppingParticle, n.Family, "")
+	return joinPersonName(given, family, n.Suffix)
+}
+
+// cslBookItem gives a book as a CSL-JSON item with the given id.
+func cslBookItem(b Book, id string) cslItem {
+	item := cslItem{
+		Id:              id,
+		Type:            "book",
+		Title:           b.fullTitle(),
+		CollectionTitle: b.series,
+		Edition:         cslString(b.edition.String()),
+		Publisher:       b.publisher,
+		ISBN:            b.isbn.String(),
+	}
+	for _, role := range contributorRoles {
+		for _, name := range nameListFromString(*b.contributors(role)) {
+			names := item.names(role)
+			*names = append(*names, newCslName(name))
+		}
+	}
+	if len(b.subtitle) > 0 {
+		item.TitleShort = b.title
+	}
+	if b.year != 0 {
+		item.Issued = &cslDate{DateParts: [][]cslString{{cslString(strconv.Itoa(b.year))}}}
+	}
+
+	custom := cslCustom{Status: b.status, Purchased: b.purchased.String()}
+	if b.rating.rated {
+		stars := b.rating.stars()
+		custom.Rating = &stars
+	}
+	if len(custom.Status) > 0 || len(custom.Purchased) > 0 || custom.Rating != nil {
+		item.Custom = &custom
+	}
+	return item
+}
+
+// exportCslJson writes the books with the given ids to w as a CSL-JSON array,
+// in the order given, giving the number of books written. The items' ids are
+// the books' citation keys. Items are written as the books are loaded, a batch
+// at a time.
+func exportCslJson(db DBInterface, w io.Writer, ids []int) (int, error) {
+	keys, err := citationKeys(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportCslJson: %w", err)
+	}
+
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, fmt.Errorf("exportCslJson, Couldn't write file: %v", err)
+	}
+	count := 0
+	err = eachBook(db, ids, func(b Book) error {
+		data, err := json.MarshalIndent(cslBookItem(b, keys[b.id]), "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("Couldn't write book #%v: %v", b.id, err)
+		}
+		sep := ",\n  "
+		if count == 0 {
+			sep = "\n  "
+		}
+		if _, err := io.WriteString(w, sep+string(data)); err != nil {
+			return fmt.Errorf("Couldn't write book #%v: %v", b.id, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("exportCslJson: %w", err)
+	}
+	end := "\n]\n"
+	if count == 0 {
+		end = "]\n"
+	}
+	if _, err := io.WriteString(w, end); err != nil {
+		return count, fmt.Errorf("exportCslJson, Couldn't write file: %v", err)
+	}
+	return count, nil
+}
+
+// exportLibraryCslJson exports the selected books, in id order.
+func exportLibraryCslJson(db DBInterface, w io.Writer, sel ExportSelection) (int, error) {
+	ids, err := sel.bookIds(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportLibraryCslJson: %w", err)
+	}
+	return exportCslJson(db, w, ids)
+}
+
+// cslItemBook reads a book from a CSL-JSON item.
+func cslItemBook(item cslItem) (Book, error) {
+	if item.Type != "book" {
+		return Book{}, &UnsupportedTypeError{"cslItemBook", item.Type}
+	}
+
+	var b Book
+	for _, role := range contributorRoles {
+		var names []string
+		for _, name := range *item.names(role) {
+			names = append(names, name.String())
+		}
+		*b.contributors(role) = formatNameList(names)
+	}
+
+	b.title = item.Title
+	if short := item.TitleShort; len(short) > 0 && strings.HasPrefix(b.title, short+": ") {
+		b.title, b.subtitle = short, strings.TrimPrefix(b.title, short+": ")
+	}
+	b.series = item.CollectionTitle
+	b.edition = parseEdition(string(item.Edition))
+	b.publisher = item.Publisher
+	if item.Issued != nil {
+		switch {
+		case len(item.Issued.DateParts) > 0 && len(item.Issued.DateParts[0]) > 0:
+			b.year = publishYear(string(item.Issued.DateParts[0][0]))
+		case len(item.Issued.Raw) > 0:
+			b.year = publishYear(item.Issued.Raw)
+		default:
+			b.year = publishYear(item.Issued.Literal)
+		}
+	}
+
+	if len(b.title) == 0 {
+		return Book{}, &MissingFieldError{"cslItemBook", "title"}
+	}
+	if len(b.publisher) == 0 {
+		return Book{}, &MissingFieldError{"cslItemBook", "publisher"}
+	}
+
+	var err error
+	if b.isbn, err = firstIsbn(item.ISBN); err != nil {
+		return Book{}, fmt.Errorf("cslItemBook: %w", err)
+	}
+	if item.Custom != nil {
+		b.status = item.Custom.Status
+		if len(item.Custom.Purchased) > 0 {
+			if err := b.purchased.setDate(item.Custom.Purchased); err != nil {
+				return Book{}, fmt.Errorf("cslItemBook: %w", err)
+			}
+		}
+		if item.Custom.Rating != nil {
+			if b.rating, err = newRating(*item.Custom.Rating); err != nil {
+				return Book{}, fmt.Errorf("cslItemBook: %w", err)
+			}
+		}
+	}
+	return b, nil
+}
+
+// importCslJson adds the books in a CSL-JSON file to the library, reporting the
+// items added and those which weren't, by their positions in the array. An
+// error is only returned for problems with the file as a whole or with the
+// database.
+func importCslJson(db *sql.DB, r io.Reader, dryRun bool) (ImportReport, error) {
+	imp := newBookImporter(db, dryRun)
+
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\ufeff")) {
+		br.Discard(3)
+	}
+	dec := json.NewDecoder(br)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return imp.report, &BadRequestError{"importCslJson",
+			"Invalid CSL-JSON, expected an array of items"}
+	}
+
+	for row := 1; dec.More(); row++ {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return imp.report, &BadRequestError{"importCslJson",
+				fmt.Sprintf("Invalid CSL-JSON in item %v: %v", row, err)}
+		}
+
+		var variables map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &variables); err != nil {
+			imp.invalid(row, &BadRequestError{"importCslJson",
+				fmt.Sprintf("Item isn't an object: %v", err)})
+			continue
+		}
+		for name := range variables {
+			if !slices.Contains(cslVariables, name) {
+				imp.ignore(name)
+			}
+		}
+
+		var item cslItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			imp.invalid(row, &BadRequestError{"importCslJson",
+				fmt.Sprintf("Invalid item: %v", err)})
+			continue
+		}
+		b, err := cslItemBook(item)
+		if err != nil {
+			imp.invalid(row, err)
+			continue
+		}
+		if b.status, err = imp.existingStatuses("custom.status", b.status); err != nil {
+			return imp.report, fmt.Errorf("importCslJson: %w", err)
+		}
+		if err := imp.add(row, b); err != nil {
+			return imp.report, fmt.Errorf("importCslJson: %w", err)
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return imp.report, &BadRequestError{"importCslJson",
+			fmt.Sprintf("Invalid CSL-JSON at the end of the array: %v", err)}
+	}
+	slices.Sort(imp.report.Ignored)
+	return imp.report, nil
+}
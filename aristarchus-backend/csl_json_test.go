@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCslName(t *testing.T) {
+	names := map[string]cslName{
+		"Peter J. Gentry":        {Family: "Gentry", Given: "Peter J."},
+		"Anselm":                 {Literal: "Anselm"},
+		"Martin Luther King Jr.": {Family: "King", Given: "Martin Luther", Suffix: "Jr."},
+	}
+	for name, expected := range names {
+		if result := newCslName(name); result != expected {
+			t.Errorf("newCslName(%q): expected %+v, got %+v", name, expected, result)
+		}
+		if result := expected.String(); result != name {
+			t.Errorf("cslName %+v String: expected %q, got %q", expected, name, result)
+		}
+	}
+
+	particles := cslName{Family: "Gogh", Given: "Vincent", NonDroppingParticle: "van"}
+	if name := particles.String(); name != "Vincent van Gogh" {
+		t.Errorf("cslName with particle: expected \"Vincent van Gogh\", got %q", name)
+	}
+}
+
+func TestCslBookItem(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Fatalf("Problem getting book #5: %v", err)
+	}
+	data, err := json.Marshal(cslBookItem(b, "gentry2018kingdom"))
+	if err != nil {
+		t.Fatalf("Problem writing CSL-JSON item: %v", err)
+	}
+	expected := `{"id":"gentry2018kingdom","type":"book",` +
+		`"author":[{"family":"Gentry","given":"Peter J."},{"family":"Wellum","given":"Stephen J."}],` +
+		`"title":"Kingdom through Covenant: A Biblical-Theological Understanding of the Covenants",` +
+		`"title-short":"Kingdom through Covenant","edition":"2nd","publisher":"Crossway",` +
+		`"issued":{"date-parts":[["2018"]]},"ISBN":"978-1-4335-5307-3",` +
+		`"custom":{"status":["Owned","Read"],"purchased":"January 2022","rating":4.5}}`
+	if string(data) != expected {
+		t.Errorf("CSL-JSON item: expected\n%v\ngot\n%v", expected, string(data))
+	}
+}
+
+func TestExportCslJsonRoundTrip(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := exportLibraryCslJson(db, &buf, ExportSelection{})
+	if err != nil {
+		t.Fatalf("exportLibraryCslJson returned error: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("exportLibraryCslJson: expected 6 books, got %v", count)
+	}
+	var items []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &items); err != nil || len(items) != 6 {
+		t.Fatalf("Expected an array of 6 items, got %v items, error %v", len(items), err)
+	}
+
+	report, err := importCslJson(db, &buf, true)
+	if err != nil {
+		t.Fatalf("importCslJson returned error: %v", err)
+	}
+	if len(report.Added) != 0 || len(report.Invalid) != 0 || len(report.Ignored) != 0 ||
+		len(report.Duplicates) != 6 {
+		t.Errorf("Expected exported books to be read back as duplicates, got %v", report)
+	}
+	for i, rowErr := range report.Duplicates {
+		var duplicateErr *AddingDuplicateBookError
+		if !errors.As(rowErr.Err, &duplicateErr) {
+			t.Errorf("Item %v: expected AddingDuplicateBookError, got %v", rowErr.Row,
+				rowErr.Err)
+			continue
+		}
+		expected, err := getBookById(db, i+1)
+		if err != nil {
+			t.Errorf("Problem getting book #%v: %v", i+1, err)
+		}
+		expected.id = 0
+		if !reflect.DeepEqual(*duplicateErr.book, expected) {
+			t.Errorf("Item %v read differently:\nexpected %#v\ngot      %#v", rowErr.Row,
+				expected, *duplicateErr.book)
+		}
+	}
+}
+
+func TestExportCslJsonEmpty(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := exportLibraryCslJson(db, &buf, ExportSelection{Query: "publisher:Nobody"})
+	if err != nil {
+		t.Fatalf("exportLibraryCslJson returned error: %v", err)
+	}
+	if count != 0 || buf.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %v books: %q", count, buf.String())
+	}
+}
+
+// testImportCslJson has an item for a new book, written as other programs
+// write it, an article, an item without a publisher and a duplicate.
+const testImportCslJson = `[
+  {
+    "id": 17,
+    "type": "book",
+    "author": [{"family": "Jobes", "given": "Karen H."}, {"literal": "Moisés Silva"}],
+    "title": "Invitation to the Septuagint",
+    "publisher": "Baker Academic",
+    "publisher-place": "Grand Rapids",
+    "issued": {"date-parts": [[2015, 1, 1]]},
+    "edition": 2,
+    "ISBN": "9780801036491 0801036496",
+    "note": "A note"
+  },
+  {
+    "id": "gentry2019septuagint",
+    "type": "article-journal",
+    "author": [{"family": "Gentry", "given": "Peter J."}],
+    "title": "The Septuagint and the Text of the Old Testament"
+  },
+  {"id": "barth", "type": "book", "title": "Church Dogmatics"},
+  {
+    "id": "gentry2018kingdom",
+    "type": "book",
+    "author": [{"family": "Gentry", "given": "Peter J."}],
+    "title": "Kingdom through Covenant",
+    "publisher": "Crossway"
+  }
+]`
+
+func TestImportCslJson(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := importCslJson(db, strings.NewReader(testImportCslJson), false)
+	if err != nil {
+		t.Fatalf("importCslJson returned error: %v", err)
+	}
+	for _, added := range report.Added {
+		defer deleteBook(db, added.Book.id)
+	}
+
+	if len(report.Added) != 1 || report.Added[0].Row != 1 {
+		t.Fatalf("Expected one book added from item 1, got %v", report.Added)
+	}
+	b, err := getBookById(db, report.Added[0].Book.id)
+	if err != nil {
+		t.Fatalf("Problem getting imported book: %v", err)
+	}
+	if b.author != "Karen H. Jobes and Moisés Silva" || b.year != 2015 ||
+		b.edition != (Edition{number: 2}) || b.isbn != "9780801036491" {
+		t.Errorf("Book imported unexpectedly: %#v", b)
+	}
+
+	var typeErr *UnsupportedTypeError
+	var missingErr *MissingFieldError
+	if len(report.Invalid) != 2 || report.Invalid[0].Row != 2 ||
+		!errors.As(report.Invalid[0].Err, &typeErr) || report.Invalid[1].Row != 3 ||
+		!errors.As(report.Invalid[1].Err, &missingErr) {
+		t.Errorf("Expected article as item 2 and missing publisher as item 3, got %v",
+			report.Invalid)
+	}
+	var duplicateErr *AddingDuplicateBookError
+	if len(report.Duplicates) != 1 || report.Duplicates[0].Row != 4 ||
+		!errors.As(report.Duplicates[0].Err, &duplicateErr) {
+		t.Errorf("Expected duplicate as item 4, got %v", report.Duplicates)
+	}
+	if !reflect.DeepEqual(report.Ignored, []string{"note", "publisher-place"}) {
+		t.Errorf("Expected note and publisher-place to be ignored, got %v", report.Ignored)
+	}
+}
+
+func TestImportCslJsonStatuses(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	csl := `[{"type": "book", "title": "Dominion and Dynasty", "publisher": "Apollos",
+		"author": [{"family": "Dempster", "given": "Stephen G."}],
+		"custom": {"status": ["Want", "Lent out", "Owned"]}}]`
+	report, err := importCslJson(db, strings.NewReader(csl), true)
+	if err != nil {
+		t.Fatalf("importCslJson returned error: %v", err)
+	}
+
+	if len(report.Added) != 1 ||
+		!reflect.DeepEqual(report.Added[0].Book.status, []string{"Want"}) {
+		t.Errorf("Expected book to be added with status Want, got %+v", report)
+	}
+	expected := []string{`custom.status "Lent out"`, `custom.status "Owned"`}
+	if !reflect.DeepEqual(report.Ignored, expected) {
+		t.Errorf("Expected ignored %v, got %v", expected, report.Ignored)
+	}
+}
+
+func TestImportCslJsonInvalid(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, file := range []string{`{"type": "book"}`, `[{"type": "book"`, `not JSON`} {
+		report, err := importCslJson(db, strings.NewReader(file), false)
+		var badRequestErr *BadRequestError
+		if !errors.As(err, &badRequestErr) {
+			t.Errorf("importCslJson(%q): expected BadRequestError, got %v", file, err)
+		}
+		if len(report.Added) != 0 {
+			t.Errorf("importCslJson(%q) added books: %v", file, report.Added)
+		}
+	}
+
+	// an item of the wrong shape is reported, without stopping the import
+	report, err := importCslJson(db, strings.NewReader(`[{"title": ["A", "B"]}, 3]`), true)
+	if err != nil {
+		t.Fatalf("importCslJson returned error: %v", err)
+	}
+	if len(report.Invalid) != 2 {
+		t.Errorf("Expected both items to be invalid, got %v", report)
+	}
+}
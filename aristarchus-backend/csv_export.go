@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Books are exported to CSV with a header row of field names, as used by the
+// JSON API and by importCsv, so that an exported file can be imported again
+// without a column mapping. Lists of names and statuses are separated by
+// semicolons, which importCsv also reads.
+
+// csvExportFields are the columns which can be exported, in their default
+// order. The id column is ignored when a file is imported.
+var csvExportFields = append([]string{"id"}, csvFields...)
+
+// CsvExportOptions control how books are exported to CSV.
+type CsvExportOptions struct {
+	// Columns are the fields exported, in order, all of csvExportFields if not
+	// given.
+	Columns []string
+	// Comma is the field delimiter, ',' if not given.
+	Comma rune
+}
+
+// parseCsvExportColumns parses a comma separated list of fields to export,
+// e.g. "id,title,author".
+func parseCsvExportColumns(s string) ([]string, error) {
+	var columns []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		if !slices.Contains(csvExportFields, field) {
+			return nil, &CsvColumnError{"parseCsvExportColumns", field,
+				fmt.Sprintf("isn't a field, must be one of %v",
+					strings.Join(csvExportFields, ", "))}
+		}
+		columns = append(columns, field)
+	}
+	return columns, nil
+}
+
+// csvCell gives the value of a book's field as written to a CSV file.
+func csvCell(b Book, field string) string {
+	for _, role := range contributorRoles {
+		if field == string(role) {
+			return strings.Join(nameListFromString(*b.contributors(role)), "; ")
+		}
+	}
+
+	switch field {
+	case "id":
+		return strconv.Itoa(b.id)
+	case "title":
+		return b.title
+	case "subtitle":
+		return b.subtitle
+	case "year":
+		if b.year == 0 {
+			return ""
+		}
+		return strconv.Itoa(b.year)
+	case "edition":
+		if b.edition.number == 0 {
+			return ""
+		}
+		return strconv.Itoa(b.edition.number)
+	case "edition_description":
+		return b.edition.description
+	case "publisher":
+		return b.publisher
+	case "isbn":
+		return b.isbn.String()
+	case "series":
+		return b.series
+	case "status":
+		return strings.Join(b.status, "; ")
+	case "purchased":
+		return b.purchased.String()
+	case "rating":
+		if !b.rating.rated {
+			return ""
+		}
+		return strconv.FormatFloat(b.rating.stars(), 'f', -1, 64)
+	}
+	return ""
+}
+
+// exportCsv writes the books with the given ids to w as CSV, in the order
+// given, giving the number of books written. Rows are written as the books
+// are loaded, a batch at a time, rather than loading every book first.
+func exportCsv(db DBInterface, w io.Writer, ids []int, opts CsvExportOptions) (int, error) {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = csvExportFields
+	}
+	for _, field := range columns {
+		if !slices.Contains(csvExportFields, field) {
+			return 0, &CsvColumnError{"exportCsv", field, "isn't a field"}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+	if err := writer.Write(columns); err != nil {
+		return 0, fmt.Errorf("exportCsv, Couldn't write header: %v", err)
+	}
+
+	count := 0
+	record := make([]string, len(columns))
+	err := eachBook(db, ids, func(b Book) error {
+		for i, field := range columns {
+			record[i] = csvCell(b, field)
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("Couldn't write book #%v: %v", b.id, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("exportCsv: %w", err)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return count, fmt.Errorf("exportCsv, Couldn't write file: %v", err)
+	}
+	return count, nil
+}
+
+// exportLibraryCsv exports the selected books, in id order.
+func exportLibraryCsv(db DBInterface, w io.Writer, sel ExportSelection, opts CsvExportOptions) (int, error) {
+	ids, err := sel.bookIds(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportLibraryCsv: %w", err)
+	}
+	return exportCsv(db, w, ids, opts)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportCsv(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := exportLibraryCsv(db, &buf, ExportSelection{}, CsvExportOptions{})
+	if err != nil {
+		t.Fatalf("exportLibraryCsv returned error: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("Expected 6 books exported, got %v", count)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Problem reading exported CSV: %v", err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("Expected a header and 6 rows, got %v rows", len(records))
+	}
+	if !reflect.DeepEqual(records[0], csvExportFields) {
+		t.Errorf("Unexpected header: %v", records[0])
+	}
+	expected := []string{"5", "Peter J. Gentry; Stephen J. Wellum", "", "", "", "", "",
+		"Kingdom through Covenant",
+		"A Biblical-Theological Understanding of the Covenants", "2018", "2", "",
+		"Crossway", "978-1-4335-5307-3", "", "Owned; Read", "January 2022", "4.5"}
+	if !reflect.DeepEqual(records[5], expected) {
+		t.Errorf("Unexpected row for book #5:\nexpected %q\ngot      %q", expected,
+			records[5])
+	}
+	if records[3][3] != "Thomas Williams" || records[6][2] !=
+		"N. Gray Sutanto; James Eglinton; Cory C. Brock" {
+		t.Errorf("Translator or editors not exported: %q, %q", records[3], records[6])
+	}
+}
+
+func TestExportCsvRoundTrip(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if _, err := exportLibraryCsv(db, &buf, ExportSelection{}, CsvExportOptions{}); err != nil {
+		t.Fatalf("exportLibraryCsv returned error: %v", err)
+	}
+	report, err := importCsv(db, &buf, CsvImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("importCsv returned error: %v", err)
+	}
+
+	if len(report.Added) != 0 || len(report.Invalid) != 0 ||
+		!reflect.DeepEqual(report.Ignored, []string{"id"}) {
+		t.Errorf("Expected exported books to be read back as duplicates, got %v", report)
+	}
+	for i, rowErr := range report.Duplicates {
+		var duplicateErr *AddingDuplicateBookError
+		if !errors.As(rowErr.Err, &duplicateErr) {
+			t.Errorf("Row %v: expected AddingDuplicateBookError, got %v", rowErr.Row,
+				rowErr.Err)
+			continue
+		}
+		expected, err := getBookById(db, i+1)
+		if err != nil {
+			t.Errorf("Problem getting book #%v: %v", i+1, err)
+		}
+		// the book read from the row, compared without its id
+		expected.id = 0
+		if !reflect.DeepEqual(*duplicateErr.book, expected) {
+			t.Errorf("Row %v read differently:\nexpected %#v\ngot      %#v", rowErr.Row,
+				expected, *duplicateErr.book)
+		}
+	}
+}
+
+func TestExportCsvColumns(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	columns, err := parseCsvExportColumns("title, author,,status")
+	if err != nil {
+		t.Fatalf("parseCsvExportColumns returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	count, err := exportLibraryCsv(db, &buf, ExportSelection{Query: "publisher:Crossway"},
+		CsvExportOptions{Columns: columns, Comma: '\t'})
+	if err != nil {
+		t.Fatalf("exportLibraryCsv returned error: %v", err)
+	}
+	expected := "title\tauthor\tstatus\n" +
+		"How to Read and Understand the Biblical Prophets\tPeter J. Gentry\tOwned\n" +
+		"Kingdom through Covenant\tPeter J. Gentry; Stephen J. Wellum\tOwned; Read\n" +
+		"Christianity and Science\tHerman Bavinck\tWant\n"
+	if count != 3 || buf.String() != expected {
+		t.Errorf("Unexpected export of 3 books, got %v:\n%v", count, buf.String())
+	}
+
+	var columnErr *CsvColumnError
+	if _, err := parseCsvExportColumns("title,authors"); !errors.As(err, &columnErr) {
+		t.Errorf("Expected CsvColumnError for unknown field, got %v", err)
+	}
+	_, err = exportCsv(db, &buf, []int{1}, CsvExportOptions{Columns: []string{"notes"}})
+	if !errors.As(err, &columnErr) {
+		t.Errorf("Expected CsvColumnError for unknown field, got %v", err)
+	}
+	var syntaxErr *QuerySyntaxError
+	if _, err := exportLibraryCsv(db, &buf, ExportSelection{Query: "colour:red"},
+		CsvExportOptions{}); !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected QuerySyntaxError for invalid query, got %v", err)
+	}
+}
+
+func TestExportCsvEmpty(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := exportLibraryCsv(db, &buf, ExportSelection{Query: "title:nonexistent"},
+		CsvExportOptions{Columns: []string{"id", "title"}})
+	if err != nil {
+		t.Fatalf("exportLibraryCsv returned error: %v", err)
+	}
+	if count != 0 || strings.TrimSpace(buf.String()) != "id,title" {
+		t.Errorf("Expected only a header, got %v books: %q", count, buf.String())
+	}
+}
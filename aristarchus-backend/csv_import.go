@@ -0,0 +1,246 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Books are imported from CSV files, e.g. exported from a spreadsheet, with
+// one book per row after a header row. Columns are matched to book fields by
+// their headers, either through a mapping given by the user or, for headers
+// not in the mapping, by the header being the name of the field, ignoring case
+// and with spaces or hyphens for underscores (e.g. "Edition Description").
+// Columns matching no field are ignored. Rows are added as for the other
+// import formats, with a bookImporter.
+
+// csvFields are the names of the book fields which columns can be mapped to,
+// as used by the JSON API.
+var csvFields = []string{
+	"author", "editor", "translator", "illustrator", "foreword", "compiler",
+	"title", "subtitle", "year", "edition", "edition_description", "publisher",
+	"isbn", "series", "status", "purchased", "rating",
+}
+
+// CsvImportOptions control how a CSV file is imported.
+type CsvImportOptions struct {
+	// Columns maps headers in the file to the names of fields in csvFields.
+	Columns map[string]string
+	// Comma is the field delimiter, ',' if not given.
+	Comma rune
+	// DryRun reports what would be imported without changing the database.
+	DryRun bool
+}
+
+// CsvColumnError is a problem with the columns of a file or their mapping,
+// which stops the file being imported.
+type CsvColumnError struct {
+	CallFunc string
+	Column   string
+	Reason   string
+}
+
+func (e *CsvColumnError) Error() string {
+	return fmt.Sprintf("%v: Column \"%v\" %v", e.CallFunc, e.Column, e.Reason)
+}
+
+// parseCsvColumns parses a column mapping given as a comma separated list of
+// header=field pairs, e.g. "Authors=author,Date Bought=purchased".
+func parseCsvColumns(s string) (map[string]string, error) {
+	columns := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(pair)) == 0 {
+			continue
+		}
+		header, field, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, &CsvColumnError{"parseCsvColumns", pair,
+				"mapping must be given as header=field"}
+		}
+		columns[strings.TrimSpace(header)] = strings.TrimSpace(field)
+	}
+	return columns, nil
+}
+
+// csvFieldName gives the field a header names, if it does, e.g. "author" for
+// "Author" and "edition_description" for "Edition Description".
+func csvFieldName(header string) (string, bool) {
+	name := strings.ToLower(strings.TrimSpace(header))
+	name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+	return name, slices.Contains(csvFields, name)
+}
+
+// csvColumnFields matches the columns of a file to fields, giving the field of
+// each column ("" for ignored columns) and the headers of ignored columns.
+func csvColumnFields(headers []string, mapping map[string]string) ([]string, []string, error) {
+	for header, field := range mapping {
+		if !slices.Contains(csvFields, field) {
+			return nil, nil, &CsvColumnError{"csvColumnFields", header,
+				fmt.Sprintf("is mapped to unknown field \"%v\"", field)}
+		}
+		if !slices.Contains(headers, header) {
+			return nil, nil, &CsvColumnError{"csvColumnFields", header,
+				"is mapped but isn't in the file"}
+		}
+	}
+
+	fields := make([]string, len(headers))
+	var ignored []string
+	for i, header := range headers {
+		field, ok := mapping[header]
+		if !ok {
+			field, ok = csvFieldName(header)
+		}
+		if !ok {
+			ignored = append(ignored, header)
+			continue
+		}
+		if j := slices.Index(fields, field); j >= 0 {
+			return nil, nil, &CsvColumnError{"csvColumnFields", header,
+				fmt.Sprintf("is for field %v, as is column \"%v\"", field, headers[j])}
+		}
+		fields[i] = field
+	}
+	if !slices.Contains(fields, "title") {
+		return nil, nil, &CsvColumnError{"csvColumnFields", "title",
+			"is needed, but no column is for the title"}
+	}
+	return fields, ignored, nil
+}
+
+// csvNames reads a list of names from a cell, which may be written as for
+// nameListFromString, e.g. "A, B and C", or separated by semicolons.
+func csvNames(cell string) string {
+	var names []string
+	for _, part := range strings.Split(cell, ";") {
+		for _, name := range nameListFromString(strings.TrimSpace(part)) {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	return formatNameList(names)
+}
+
+// csvRowBook reads a book from the cells of a row, by field.
+func csvRowBook(cells map[string]string) (Book, error) {
+	var b Book
+	var err error
+	for _, role := range contributorRoles {
+		*b.contributors(role) = csvNames(cells[string(role)])
+	}
+	b.title = cells["title"]
+	b.subtitle = cells["subtitle"]
+	b.edition.description = cells["edition_description"]
+	b.publisher = cells["publisher"]
+	b.series = cells["series"]
+	if len(cells["status"]) > 0 {
+		b.status = splitStatuses(strings.ReplaceAll(cells["status"], ";", ","))
+	}
+
+	if len(b.title) == 0 {
+		return Book{}, &MissingFieldError{"csvRowBook", "title"}
+	}
+	if len(b.publisher) == 0 {
+		return Book{}, &MissingFieldError{"csvRowBook", "publisher"}
+	}
+
+	numbers := []struct {
+		field string
+		value *int
+	}{
+		{"year", &b.year},
+		{"edition", &b.edition.number},
+	}
+	for _, n := range numbers {
+		if len(cells[n.field]) == 0 {
+			continue
+		}
+		if *n.value, err = strconv.Atoi(cells[n.field]); err != nil || *n.value < 0 {
+			return Book{}, &BadRequestError{"csvRowBook",
+				fmt.Sprintf("Invalid %v \"%v\"", n.field, cells[n.field])}
+		}
+	}
+	if b.isbn, err = parseIsbn(cells["isbn"]); err != nil {
+		return Book{}, fmt.Errorf("csvRowBook: %w", err)
+	}
+	if len(cells["purchased"]) > 0 {
+		if err := b.purchased.setDate(cells["purchased"]); err != nil {
+			return Book{}, fmt.Errorf("csvRowBook: %w", err)
+		}
+	}
+	if b.rating, err = parseRating(cells["rating"]); err != nil {
+		return Book{}, fmt.Errorf("csvRowBook: %w", err)
+	}
+	return b, nil
+}
+
+// importCsv adds the books in a CSV file to the library, reporting the rows
+// added and those which weren't, either as their books are already in the
+// library or as they are invalid. An error is only returned for problems with
+// the file as a whole or with the database.
+func importCsv(db *sql.DB, r io.Reader, opts CsvImportOptions) (ImportReport, error) {
+	imp := newBookImporter(db, opts.DryRun)
+
+	reader := csv.NewReader(r)
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return imp.report, &CsvColumnError{"importCsv", "title",
+			"is needed, but the file is empty"}
+	}
+	if err != nil {
+		return imp.report, fmt.Errorf("importCsv, Couldn't read header: %v", err)
+	}
+	if len(headers) > 0 {
+		// spreadsheets often start UTF-8 files with a byte order mark
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	}
+	fields, ignored, err := csvColumnFields(headers, opts.Columns)
+	if err != nil {
+		return imp.report, fmt.Errorf("importCsv: %w", err)
+	}
+	imp.report.Ignored = ignored
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return imp.report, fmt.Errorf("importCsv, Couldn't read row %v: %v", row, err)
+		}
+
+		cells := map[string]string{}
+		empty := true
+		for i, cell := range record {
+			if i < len(fields) && len(fields[i]) > 0 {
+				cells[fields[i]] = strings.TrimSpace(cell)
+			}
+			empty = empty && len(strings.TrimSpace(cell)) == 0
+		}
+		if empty {
+			continue
+		}
+
+		b, err := csvRowBook(cells)
+		if err != nil {
+			imp.invalid(row, err)
+			continue
+		}
+
+		if err := imp.add(row, b); err != nil {
+			return imp.report, fmt.Errorf("importCsv: %w", err)
+		}
+	}
+	return imp.report, nil
+}
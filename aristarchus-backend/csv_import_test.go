@@ -0,0 +1,296 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testImportCsv = `Title,Authors,Editor,Publisher,Year,Edition,ISBN,Date Bought,Status,Shelf
+Invitation to the Septuagint,Karen H. Jobes; Moisés Silva,,Baker Academic,2015,2,978-0-8010-3649-1,December 2021,Owned,B2
+Kingdom through Covenant,Peter J. Gentry and Stephen J. Wellum,,Crossway,2018,2,,,Owned,A1
+The Trinity,Augustine,,New City Press,1991,,,Spring 2020,Owned,A3
+Confessions,Augustine,,,1991,,,,Owned,A3
+,,,,,,,,,
+"Dominion and Dynasty",Stephen G. Dempster,,Apollos,2003,,,,Want,
+Invitation to the Septuagint,Karen H. Jobes and Moisés Silva,,Baker Academic,2015,2,,,Owned,B2
+`
+
+var testImportColumns = map[string]string{
+	"Authors":     "author",
+	"Date Bought": "purchased",
+}
+
+func addedRows(report ImportReport) []int {
+	rows := []int{}
+	for _, added := range report.Added {
+		rows = append(rows, added.Row)
+	}
+	return rows
+}
+
+func errorRows(rowErrs []ImportRowError) []int {
+	rows := []int{}
+	for _, rowErr := range rowErrs {
+		rows = append(rows, rowErr.Row)
+	}
+	return rows
+}
+
+func TestImportCsv(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := importCsv(db, strings.NewReader(testImportCsv),
+		CsvImportOptions{Columns: testImportColumns})
+	if err != nil {
+		t.Fatalf("importCsv returned error: %v", err)
+	}
+	for _, added := range report.Added {
+		defer deleteBook(db, added.Book.id)
+	}
+
+	if !reflect.DeepEqual(addedRows(report), []int{2, 7}) {
+		t.Errorf("Expected rows 2 and 7 added, got %v", addedRows(report))
+	}
+	if !reflect.DeepEqual(errorRows(report.Duplicates), []int{3, 8}) {
+		t.Errorf("Expected rows 3 and 8 reported as duplicates, got %v",
+			errorRows(report.Duplicates))
+	}
+	if !reflect.DeepEqual(errorRows(report.Invalid), []int{4, 5}) {
+		t.Fatalf("Expected rows 4 and 5 reported as invalid, got %v",
+			errorRows(report.Invalid))
+	}
+	if !reflect.DeepEqual(report.Ignored, []string{"Shelf"}) {
+		t.Errorf("Expected Shelf column ignored, got %v", report.Ignored)
+	}
+
+	var duplicateErr *AddingDuplicateBookError
+	if !errors.As(report.Duplicates[0].Err, &duplicateErr) || duplicateErr.id != 5 {
+		t.Errorf("Expected row 3 to be a duplicate of book #5, got %v",
+			report.Duplicates[0].Err)
+	}
+	if !errors.As(report.Duplicates[1].Err, &duplicateErr) ||
+		duplicateErr.id != report.Added[0].Book.id {
+		t.Errorf("Expected row 8 to be a duplicate of row 2, got %v",
+			report.Duplicates[1].Err)
+	}
+	var dateErr *DateParsingError
+	if !errors.As(report.Invalid[0].Err, &dateErr) {
+		t.Errorf("Expected DateParsingError for row 4, got %v", report.Invalid[0].Err)
+	}
+	var missingErr *MissingFieldError
+	if !errors.As(report.Invalid[1].Err, &missingErr) || missingErr.Field != "publisher" {
+		t.Errorf("Expected missing publisher for row 5, got %v", report.Invalid[1].Err)
+	}
+
+	added, err := getBookById(db, report.Added[0].Book.id)
+	if err != nil {
+		t.Fatalf("Problem getting imported book: %v", err)
+	}
+	if added.String() != makeTestBook().String() {
+		t.Errorf("Imported book differs: expected %v, got %v", makeTestBook(), added)
+	}
+
+	err = report.skippedError()
+	var skippedErr *SkippedRowsError
+	if !errors.As(err, &skippedErr) || skippedErr.Skipped != 4 ||
+		skippedErr.Rows != 6 || !errors.As(err, &dateErr) {
+		t.Errorf("Unexpected skipped rows error: %v", err)
+	}
+}
+
+func TestImportCsvDryRun(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := importCsv(db, strings.NewReader(testImportCsv),
+		CsvImportOptions{Columns: testImportColumns, DryRun: true})
+	if err != nil {
+		t.Fatalf("importCsv returned error: %v", err)
+	}
+
+	if !report.DryRun {
+		t.Errorf("Report not marked as a dry run")
+	}
+	if !reflect.DeepEqual(addedRows(report), []int{2, 7}) {
+		t.Errorf("Expected rows 2 and 7 to be added, got %v", addedRows(report))
+	}
+	if report.Added[1].Book.title != "Dominion and Dynasty" ||
+		report.Added[1].Book.author != "Stephen G. Dempster" {
+		t.Errorf("Unexpected book for row 7: %v", report.Added[1].Book)
+	}
+	if !reflect.DeepEqual(errorRows(report.Duplicates), []int{3, 8}) {
+		t.Errorf("Expected rows 3 and 8 reported as duplicates, got %v",
+			errorRows(report.Duplicates))
+	}
+	var rowErr *DuplicateRowError
+	if len(report.Duplicates) == 2 &&
+		(!errors.As(report.Duplicates[1].Err, &rowErr) || rowErr.FirstRow != 2) {
+		t.Errorf("Expected row 8 to be a duplicate of row 2, got %v",
+			report.Duplicates[1].Err)
+	}
+	if !reflect.DeepEqual(errorRows(report.Invalid), []int{4, 5}) {
+		t.Errorf("Expected rows 4 and 5 reported as invalid, got %v",
+			errorRows(report.Invalid))
+	}
+
+	count, err := countAllBooks(db)
+	if err != nil {
+		t.Errorf("Problem counting books: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("Dry run changed the library, now %v books", count)
+	}
+}
+
+func TestImportCsvDryRunMatchesImport(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// rows 2 and 3 have no author or editor, so are never duplicates; row 5's
+	// author is the second author of row 4, and row 7's editor the second
+	// editor of row 6
+	csv := `Title,Author,Editor,Publisher,Edition
+Psalms of the Ancient Church,,,Apollos,
+Psalms of the Ancient Church,,,Apollos,
+A Shared Title,Alice Writer and Bob Writer,,Apollos,
+A Shared Title,Bob Writer,,Apollos,
+An Edited Title,,Carol Editor and Dan Editor,Apollos,
+An Edited Title,,Dan Editor,Apollos,
+A Negative Edition,Erin Writer,,Apollos,-1
+`
+
+	dryRun, err := importCsv(db, strings.NewReader(csv), CsvImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("importCsv dry run returned error: %v", err)
+	}
+	report, err := importCsv(db, strings.NewReader(csv), CsvImportOptions{})
+	if err != nil {
+		t.Fatalf("importCsv returned error: %v", err)
+	}
+	for _, added := range report.Added {
+		defer deleteBook(db, added.Book.id)
+	}
+
+	for _, r := range []ImportReport{dryRun, report} {
+		if !reflect.DeepEqual(addedRows(r), []int{2, 3, 4, 6}) {
+			t.Errorf("Expected rows 2, 3, 4 and 6 added in %+v, got %v", r,
+				addedRows(r))
+		}
+		if !reflect.DeepEqual(errorRows(r.Duplicates), []int{5, 7}) {
+			t.Errorf("Expected rows 5 and 7 reported as duplicates in %+v, got %v", r,
+				errorRows(r.Duplicates))
+		}
+		if !reflect.DeepEqual(errorRows(r.Invalid), []int{8}) {
+			t.Errorf("Expected row 8 reported as invalid in %+v, got %v", r,
+				errorRows(r.Invalid))
+		}
+	}
+}
+
+func TestBookImporterDatabaseError(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	imp := newBookImporter(db, false)
+	db.Close()
+
+	b := Book{title: "Dominion and Dynasty", author: "Stephen G. Dempster",
+		publisher: "Apollos"}
+	if err := imp.add(2, b); err == nil {
+		t.Errorf("bookImporter.add with closed database did not return error")
+	}
+	if len(imp.report.Invalid) != 0 || len(imp.report.Added) != 0 {
+		t.Errorf("Database error recorded as an invalid row: %+v", imp.report)
+	}
+}
+
+func TestImportCsvColumns(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		csv     string
+		columns map[string]string
+	}{
+		{"Title,Authors\n", map[string]string{"Authors": "writer"}},
+		{"Title,Author\n", map[string]string{"Authors": "author"}},
+		{"Name,Author\n", nil},
+		{"Title,Author,Writer\n", map[string]string{"Writer": "author"}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		_, err := importCsv(db, strings.NewReader(test.csv),
+			CsvImportOptions{Columns: test.columns, DryRun: true})
+		var columnErr *CsvColumnError
+		if !errors.As(err, &columnErr) {
+			t.Errorf("Expected CsvColumnError for %q with %v, got %v", test.csv,
+				test.columns, err)
+		}
+	}
+
+	// headers are matched to fields ignoring case, spaces and hyphens
+	report, err := importCsv(db, strings.NewReader(
+		"\ufeffTITLE\tPublisher\tEdition Description\tedition-description2\n"+
+			"A Title\tIVP\trevised\tx\n"),
+		CsvImportOptions{Comma: '\t', DryRun: true})
+	if err != nil {
+		t.Fatalf("importCsv returned error: %v", err)
+	}
+	if len(report.Added) != 1 || report.Added[0].Book.edition.description != "revised" {
+		t.Errorf("Unexpected book from tab separated file: %v", report.Added)
+	}
+	if !reflect.DeepEqual(report.Ignored, []string{"edition-description2"}) {
+		t.Errorf("Unexpected ignored columns: %v", report.Ignored)
+	}
+}
+
+func TestParseCsvColumns(t *testing.T) {
+	columns, err := parseCsvColumns("Authors=author, Date Bought = purchased,")
+	if err != nil {
+		t.Errorf("parseCsvColumns returned error: %v", err)
+	}
+	expected := map[string]string{"Authors": "author", "Date Bought": "purchased"}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Errorf("parseCsvColumns: expected %v, got %v", expected, columns)
+	}
+
+	_, err = parseCsvColumns("Authors")
+	var columnErr *CsvColumnError
+	if !errors.As(err, &columnErr) {
+		t.Errorf("Expected CsvColumnError for mapping without field, got %v", err)
+	}
+}
+
+func TestCsvNames(t *testing.T) {
+	names := map[string]string{
+		"":                                      "",
+		"Anselm":                                "Anselm",
+		"Peter J. Gentry and Stephen J. Wellum": "Peter J. Gentry and Stephen J. Wellum",
+		"Peter J. Gentry; Stephen J. Wellum":    "Peter J. Gentry and Stephen J. Wellum",
+		"A, B and C":                            "A, B and C",
+		"A; B; C":                               "A, B and C",
+		" A ;B; ":                               "A and B",
+	}
+	for cell, expected := range names {
+		if got := csvNames(cell); got != expected {
+			t.Errorf("csvNames(%q): expected %q, got %q", cell, expected, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// The library database is either an SQLite file or a PostgreSQL database,
+// given by a postgres:// URL. Queries are written once, with ? placeholders,
+// in SQL which both understand; the few which can't be are chosen by the
+// Dialect of the database they are run on. PostgreSQL databases are opened
+// through postgresDriverName, which wraps lib/pq to rewrite the ?
+// placeholders as PostgreSQL's $1, $2, ...
+
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "SQLite"
+	case Postgres:
+		return "PostgreSQL"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+const postgresDriverName = "aristarchus-postgres"
+
+func init() {
+	sql.Register(postgresDriverName, postgresDriver{})
+}
+
+// isPostgresURL reports whether a library database path is a PostgreSQL
+// connection URL, e.g. postgres://user@localhost/library.
+func isPostgresURL(path string) bool {
+	return strings.HasPrefix(path, "postgres://") ||
+		strings.HasPrefix(path, "postgresql://")
+}
+
+// dialectOf gives the dialect of the database behind db. Transactions only
+// know their dialect if started with beginTx.
+func dialectOf(db DBInterface) Dialect {
+	switch d := db.(type) {
+	case *sql.DB:
+		if _, ok := d.Driver().(postgresDriver); ok {
+			return Postgres
+		}
+	case *StmtTx:
+		return d.dialect
+	}
+	return SQLite
+}
+
+// rebindPlaceholders rewrites the ? placeholders of a query as $1, $2, ...
+// leaving alone any ? in string literals, quoted identifiers and comments.
+func rebindPlaceholders(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '?':
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		case c == '\'' || c == '"':
+			// quotes are escaped by doubling, which reads as two quoted
+			// sections one after the other
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			sb.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				sb.WriteString(query[i:])
+				return sb.String()
+			}
+			sb.WriteString(query[i : i+end+4])
+			i += end + 3
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// pqConn is what database/sql uses of a lib/pq connection.
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+type postgresDriver struct{}
+
+func (postgresDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := pq.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	pc, ok := conn.(pqConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("postgresDriver.Open, Unexpected connection type %T", conn)
+	}
+	return postgresConn{pc}, nil
+}
+
+// postgresConn is a lib/pq connection which takes queries with ?
+// placeholders.
+type postgresConn struct {
+	pqConn
+}
+
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.pqConn.Prepare(rebindPlaceholders(query))
+}
+
+func (c postgresConn) ExecContext(ctx context.Context, query string,
+	args []driver.NamedValue) (driver.Result, error) {
+	return c.pqConn.ExecContext(ctx, rebindPlaceholders(query), args)
+}
+
+func (c postgresConn) QueryContext(ctx context.Context, query string,
+	args []driver.NamedValue) (driver.Rows, error) {
+	return c.pqConn.QueryContext(ctx, rebindPlaceholders(query), args)
+}
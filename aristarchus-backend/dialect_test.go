@@ -0,0 +1,99 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestRebindPlaceholders(t *testing.T) {
+	queries := map[string]string{
+		"SELECT name FROM people WHERE person_id = ?": "SELECT name FROM people WHERE person_id = $1",
+		"INSERT INTO book_status VALUES (?, ?)":       "INSERT INTO book_status VALUES ($1, $2)",
+		"SELECT COUNT(*) FROM books":                  "SELECT COUNT(*) FROM books",
+		`WHERE note LIKE ? ESCAPE '\' AND title = ?`:  `WHERE note LIKE $1 ESCAPE '\' AND title = $2`,
+		`SELECT 'What?', 'It''s ?', "odd?" FROM t WHERE a = ?`: `SELECT 'What?', 'It''s ?', ` +
+			`"odd?" FROM t WHERE a = $1`,
+		"SELECT ? -- why?\nFROM t WHERE a = ? /* or ? */ AND b = ?": "SELECT $1 -- why?\n" +
+			"FROM t WHERE a = $2 /* or ? */ AND b = $3",
+		"SELECT 'unterminated ?":     "SELECT 'unterminated ?",
+		"SELECT ? /* unterminated ?": "SELECT $1 /* unterminated ?",
+	}
+
+	for query, expected := range queries {
+		if result := rebindPlaceholders(query); result != expected {
+			t.Errorf("rebindPlaceholders(%q) returned %q, expected %q", query, result,
+				expected)
+		}
+	}
+}
+
+func TestIsPostgresURL(t *testing.T) {
+	paths := map[string]bool{
+		"postgres://aristarchus@localhost/library":   true,
+		"postgresql://localhost/library?sslmode=off": true,
+		"../db/books.sqlite":                         false,
+		"postgres.sqlite":                            false,
+		"":                                           false,
+	}
+
+	for path, expected := range paths {
+		if result := isPostgresURL(path); result != expected {
+			t.Errorf("isPostgresURL(%q) returned %v, expected %v", path, result, expected)
+		}
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	if d := dialectOf(db); d != testDialect {
+		t.Errorf("dialectOf test database: expected %v, got %v", testDialect, d)
+	}
+	tx, err := beginTx(db)
+	if err != nil {
+		t.Fatalf("Problem starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if d := dialectOf(tx); d != testDialect {
+		t.Errorf("dialectOf transaction: expected %v, got %v", testDialect, d)
+	}
+
+	// opening doesn't connect, so needs no server
+	pg, err := sql.Open(postgresDriverName, "postgres://aristarchus@localhost/library")
+	if err != nil {
+		t.Fatalf("Problem opening PostgreSQL database: %v", err)
+	}
+	defer pg.Close()
+	if d := dialectOf(pg); d != Postgres {
+		t.Errorf("dialectOf PostgreSQL database: expected %v, got %v", Postgres, d)
+	}
+}
+
+func TestQueryCompileDialects(t *testing.T) {
+	q, err := parseQuery(`covenant "God's emotions" -title:Kingdom`)
+	if err != nil {
+		t.Fatalf("parseQuery returned error: %v", err)
+	}
+
+	sqlStmt, args := q.compile(SQLite)
+	if !strings.Contains(sqlStmt, "book_search MATCH ?") || len(args) != 3 ||
+		args[0] != `"covenant"*` || args[1] != `"God's emotions"` {
+		t.Errorf("Unexpected SQLite query %v with arguments %v", sqlStmt, args)
+	}
+
+	sqlStmt, args = q.compile(Postgres)
+	if !strings.Contains(sqlStmt, "document @@ to_tsquery('aristarchus', ?)") ||
+		strings.Contains(sqlStmt, "MATCH") || len(args) != 3 ||
+		args[0] != "(covenant:*)" || args[1] != "God <-> s <-> emotions" {
+		t.Errorf("Unexpected PostgreSQL query %v with arguments %v", sqlStmt, args)
+	}
+	if rebound := rebindPlaceholders(sqlStmt); strings.Contains(rebound, "?") ||
+		!strings.Contains(rebound, "$3") {
+		t.Errorf("Unexpected PostgreSQL query after rebinding placeholders: %v", rebound)
+	}
+}
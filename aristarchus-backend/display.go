@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Books are displayed through text/template templates, which are given a
+// BookView. The built-in templates are
+//
+//	line      a book on one line, as in lists, which Book.String gives
+//	detail    a book's fields one per line, with its categories and notes
+//	markdown  a book as an item of a Markdown list, for exports
+//
+// and a templates file may define more with {{define "name"}}, or redefine the
+// built-in ones, e.g. to change how every list shows a book by redefining
+// "line".
+
+// BookView is the view of a book given to display templates.
+type BookView struct {
+	Id       int
+	Title    string
+	Subtitle string
+	// FullTitle is the title with any subtitle, e.g. "Divine Impassibility:
+	// Four Views of God's Emotions and Suffering"
+	FullTitle string
+
+	// names of contributors formatted as a list, e.g. "Peter J. Gentry and
+	// Stephen J. Wellum"
+	Author      string
+	Editor      string
+	Translator  string
+	Illustrator string
+	Foreword    string
+	Compiler    string
+	Authors     []string
+	Editors     []string
+	// AuthorEditor is whoever the book is by, e.g. "Anselm", or "Robert J.
+	// Matz and A. Chadwick Thornhill (eds.)" when it has no author
+	AuthorEditor string
+	// Contributors are the roles with names, in the order of contributorRoles
+	Contributors []ContributorView
+	// ContributorNotes are the contributors given after the title, e.g.
+	// "trans. Thomas Williams"
+	ContributorNotes []string
+
+	Year int
+	// Edition is the edition in full, e.g. "2nd edition", and EditionShort
+	// in brief, e.g. "2nd"
+	Edition       string
+	EditionShort  string
+	EditionNumber int
+
+	Publisher string
+	// Isbn is hyphenated, e.g. "978-1-4335-5307-3", and IsbnDigits not
+	Isbn       string
+	IsbnDigits string
+	Series     string
+	Status     []string
+	Purchased  string
+	// Rating is e.g. "4.5/5", or "" if the book isn't rated
+	Rating string
+	Stars  float64
+
+	// only for detail views
+	Categories []string // the path of each category, e.g. "Theology → Biblical"
+	Notes      []string
+}
+
+type ContributorView struct {
+	Role         string // e.g. "translator"
+	Label        string // e.g. "Translator"
+	Abbreviation string // e.g. "trans."
+	Names        []string
+	List         string // e.g. "Peter J. Gentry and Stephen J. Wellum"
+}
+
+func newBookView(b Book) BookView {
+	v := BookView{
+		Id:               b.id,
+		Title:            b.title,
+		Subtitle:         b.subtitle,
+		FullTitle:        b.fullTitle(),
+		Author:           b.author,
+		Editor:           b.editor,
+		Translator:       b.translator,
+		Illustrator:      b.illustrator,
+		Foreword:         b.foreword,
+		Compiler:         b.compiler,
+		Authors:          nameListFromString(b.author),
+		Editors:          nameListFromString(b.editor),
+		AuthorEditor:     b.authorEditor(),
+		ContributorNotes: b.contributorNotes(),
+		Year:             b.year,
+		Edition:          b.edition.long(),
+		EditionShort:     b.edition.String(),
+		EditionNumber:    b.edition.number,
+		Publisher:        b.publisher,
+		Isbn:             b.isbn.String(),
+		IsbnDigits:       string(b.isbn),
+		Series:           b.series,
+		Status:           b.status,
+		Purchased:        b.purchased.String(),
+		Rating:           b.rating.String(),
+	}
+	if b.rating.rated {
+		v.Stars = b.rating.stars()
+	}
+	for _, role := range contributorRoles {
+		if names := *b.contributors(role); len(names) > 0 {
+			v.Contributors = append(v.Contributors, ContributorView{string(role),
+				role.label(), role.abbreviation(), nameListFromString(names), names})
+		}
+	}
+	return v
+}
+
+// bookDetailView gives the view of a book with its categories and notes.
+func bookDetailView(db DBInterface, b Book) (BookView, error) {
+	v := newBookView(b)
+	categories, err := getCategoriesByBookId(db, b.id)
+	if err != nil {
+		return v, fmt.Errorf("bookDetailView: %w", err)
+	}
+	for _, category := range categories {
+		path, err := categoryPath(db, category.id)
+		if err != nil {
+			return v, fmt.Errorf("bookDetailView: %w", err)
+		}
+		v.Categories = append(v.Categories, formatCategoryPath(path))
+	}
+	notes, err := getNotesByBookId(db, b.id)
+	if err != nil {
+		return v, fmt.Errorf("bookDetailView: %w", err)
+	}
+	for _, n := range notes {
+		v.Notes = append(v.Notes, n.String())
+	}
+	return v, nil
+}
+
+// detailLine gives a line of a detail view, with the label padded so that the
+// values line up, or "" if there is no value.
+func detailLine(label string, value any) string {
+	s := fmt.Sprint(value)
+	if len(s) == 0 {
+		return ""
+	}
+	if len(label) > 0 {
+		label += ":"
+	}
+	return fmt.Sprintf("%-13v%v\n", label, s)
+}
+
+// detailLines gives a line of a detail view for each value, labelling only
+// the first.
+func detailLines(label string, values []string) string {
+	var sb strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			label = ""
+		}
+		sb.WriteString(detailLine(label, value))
+	}
+	return sb.String()
+}
+
+var displayFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"names":    formatNameList,
+	"inverted": invertedName,
+	"surname":  surname,
+	"ordinal":  ordinal,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"field":    detailLine,
+	"fields":   detailLines,
+}
+
+const builtinDisplayTemplates = `
+{{- define "line" -}}
+{{.AuthorEditor}}, {{.FullTitle}}
+{{- with .Edition}}, {{.}}{{end}}
+{{- with .ContributorNotes}}, {{join . ", "}}{{end}} ({{.Year}})
+{{- with .Isbn}}, ISBN {{.}}{{end}} [{{join .Status ", "}}]
+{{- end}}
+
+{{- define "detail" -}}
+Book #{{.Id}}
+{{field "Title" .Title}}{{field "Subtitle" .Subtitle}}
+{{- range .Contributors}}{{field .Label .List}}{{end}}
+{{- if .Year}}{{field "Year" .Year}}{{end}}
+{{- field "Edition" .Edition}}{{field "Publisher" .Publisher}}
+{{- field "ISBN" .Isbn}}{{field "Series" .Series}}
+{{- field "Status" (join .Status ", ")}}{{field "Purchased" .Purchased}}
+{{- field "Rating" .Rating}}{{fields "Categories" .Categories}}
+{{- with .Notes}}Notes:
+{{range .}}  {{.}}
+{{end}}{{end}}
+{{- end}}
+
+{{- define "markdown" -}}
+- {{.AuthorEditor}}, *{{.FullTitle}}*
+{{- with .Edition}}, {{.}}{{end}}
+{{- with .ContributorNotes}}, {{join . ", "}}{{end}} ({{with .Publisher}}{{.}}, {{end}}{{.Year}})
+{{- end}}
+`
+
+// TemplateError is a display template which couldn't be read, is unknown, or
+// couldn't be rendered.
+type TemplateError struct {
+	CallFunc string
+	Name     string
+	Err      error
+}
+
+func (e *TemplateError) Error() string {
+	if len(e.Name) == 0 {
+		return fmt.Sprintf("%v: %v", e.CallFunc, e.Err)
+	}
+	return fmt.Sprintf("%v: Template \"%v\": %v", e.CallFunc, e.Name, e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+var builtinTemplates = template.Must(
+	template.New("builtin").Funcs(displayFuncs).Parse(builtinDisplayTemplates))
+
+// displayTemplates are the templates books are displayed with, which are the
+// built-in templates until a templates file is loaded.
+var displayTemplates = builtinTemplates
+
+// exampleBookView is a view of a book with every field set, which templates
+// are checked against when loaded.
+var exampleBookView = func() BookView {
+	b := Book{
+		id:          1,
+		author:      "Karen H. Jobes and Moisés Silva",
+		editor:      "Andrew Fowler",
+		translator:  "Thomas Williams",
+		illustrator: "Pauline Baynes",
+		foreword:    "John Stott",
+		compiler:    "Robert J. Matz",
+		title:       "Invitation to the Septuagint",
+		subtitle:    "An Introduction",
+		year:        2015,
+		edition:     Edition{2, "revised"},
+		publisher:   "Baker Academic",
+		isbn:        "9780801036491",
+		series:      "Studies",
+		status:      []string{"Owned", "Read"},
+		purchased:   PurchasedDate{2022, 1, 5},
+		rating:      Rating{9, true},
+	}
+	v := newBookView(b)
+	v.Categories = []string{"Theology → Biblical"}
+	v.Notes = []string{"[2022-01-05 12:00] (p. 3) A note"}
+	return v
+}()
+
+// parseDisplayTemplates gives the built-in templates with those defined in
+// the given text, checking that each renders.
+func parseDisplayTemplates(text string) (*template.Template, error) {
+	tmpl, err := builtinTemplates.Clone()
+	if err != nil {
+		return nil, &TemplateError{"parseDisplayTemplates", "", err}
+	}
+	if _, err := tmpl.Parse(text); err != nil {
+		return nil, &TemplateError{"parseDisplayTemplates", "", err}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Name() == "builtin" {
+			continue
+		}
+		if err := t.Execute(io.Discard, exampleBookView); err != nil {
+			return nil, &TemplateError{"parseDisplayTemplates", t.Name(), err}
+		}
+	}
+	return tmpl, nil
+}
+
+// loadDisplayTemplates reads templates from a file, to be used along with the
+// built-in ones in place of any templates already loaded. An empty path goes
+// back to just the built-in templates.
+func loadDisplayTemplates(path string) error {
+	if len(path) == 0 {
+		displayTemplates = builtinTemplates
+		return nil
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("loadDisplayTemplates, Couldn't read templates: %v", err)
+	}
+	tmpl, err := parseDisplayTemplates(string(text))
+	if err != nil {
+		return fmt.Errorf("loadDisplayTemplates, In %v: %w", path, err)
+	}
+	displayTemplates = tmpl
+	return nil
+}
+
+// displayTemplateNames gives the names of the templates which can be chosen.
+func displayTemplateNames() []string {
+	var names []string
+	for _, t := range displayTemplates.Templates() {
+		if t.Name() != "builtin" {
+			names = append(names, t.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// checkDisplayTemplate checks that a template has been defined.
+func checkDisplayTemplate(name string) error {
+	if displayTemplates.Lookup(name) == nil || name == "builtin" {
+		return &TemplateError{"checkDisplayTemplate", name, fmt.Errorf(
+			"No such template, must be one of %v", strings.Join(displayTemplateNames(), ", "))}
+	}
+	return nil
+}
+
+// renderBook writes a view of a book through the named template.
+func renderBook(w io.Writer, name string, v BookView) error {
+	if err := checkDisplayTemplate(name); err != nil {
+		return fmt.Errorf("renderBook: %w", err)
+	}
+	if err := displayTemplates.ExecuteTemplate(w, name, v); err != nil {
+		return &TemplateError{"renderBook", name, err}
+	}
+	return nil
+}
+
+// renderBookString gives a view of a book through the named template.
+func renderBookString(name string, v BookView) (string, error) {
+	var sb strings.Builder
+	err := renderBook(&sb, name, v)
+	return sb.String(), err
+}
+
+// exportTemplate writes the books with the given ids to w through the named
+// display template, in the order given, giving the number of books written.
+// Each book is followed by a newline, unless the template ends with one.
+func exportTemplate(db DBInterface, w io.Writer, ids []int, name string) (int, error) {
+	if err := checkDisplayTemplate(name); err != nil {
+		return 0, fmt.Errorf("exportTemplate: %w", err)
+	}
+	count := 0
+	err := eachBook(db, ids, func(b Book) error {
+		s, err := renderBookString(name, newBookView(b))
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(s, "\n") {
+			s += "\n"
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return fmt.Errorf("Couldn't write book #%v: %v", b.id, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("exportTemplate: %w", err)
+	}
+	return count, nil
+}
+
+// exportLibraryTemplate exports the selected books, in id order.
+func exportLibraryTemplate(db DBInterface, w io.Writer, sel ExportSelection,
+	name string) (int, error) {
+	ids, err := sel.bookIds(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportLibraryTemplate: %w", err)
+	}
+	return exportTemplate(db, w, ids, name)
+}
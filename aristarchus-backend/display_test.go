@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewBookView(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Fatalf("Problem getting book #5: %v", err)
+	}
+	v := newBookView(b)
+	if v.Edition != "2nd edition" || v.EditionShort != "2nd" || v.EditionNumber != 2 {
+		t.Errorf("Wrong edition in view: %q, %q, %v", v.Edition, v.EditionShort,
+			v.EditionNumber)
+	}
+	if !reflect.DeepEqual(v.Authors, []string{"Peter J. Gentry", "Stephen J. Wellum"}) {
+		t.Errorf("Wrong authors in view: %v", v.Authors)
+	}
+	if v.Isbn != "978-1-4335-5307-3" || v.IsbnDigits != "9781433553073" {
+		t.Errorf("Wrong ISBN in view: %q, %q", v.Isbn, v.IsbnDigits)
+	}
+	if v.Purchased != "January 2022" || v.Rating != "4.5/5" || v.Stars != 4.5 {
+		t.Errorf("Wrong purchase date or rating in view: %q, %q, %v", v.Purchased,
+			v.Rating, v.Stars)
+	}
+
+	b, err = getBookById(db, 6)
+	if err != nil {
+		t.Fatalf("Problem getting book #6: %v", err)
+	}
+	v = newBookView(b)
+	expected := []ContributorView{
+		{"author", "Author", "", []string{"Herman Bavinck"}, "Herman Bavinck"},
+		{"editor", "Editor", "ed.", []string{"N. Gray Sutanto", "James Eglinton",
+			"Cory C. Brock"}, "N. Gray Sutanto, James Eglinton and Cory C. Brock"},
+	}
+	if !reflect.DeepEqual(v.Contributors, expected) {
+		t.Errorf("Wrong contributors in view:\nexpected %+v\ngot      %+v", expected,
+			v.Contributors)
+	}
+}
+
+func TestBookDetailView(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Fatalf("Problem getting book #5: %v", err)
+	}
+	v, err := bookDetailView(db, b)
+	if err != nil {
+		t.Fatalf("bookDetailView returned error: %v", err)
+	}
+	if !reflect.DeepEqual(v.Categories, []string{"Theology → Biblical Theology"}) {
+		t.Errorf("Wrong categories in detail view: %v", v.Categories)
+	}
+	if len(v.Notes) != 2 || !strings.Contains(v.Notes[0], "progressive covenantalism") {
+		t.Errorf("Wrong notes in detail view: %v", v.Notes)
+	}
+}
+
+func TestRenderBook(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b, err := getBookById(db, 3)
+	if err != nil {
+		t.Fatalf("Problem getting book #3: %v", err)
+	}
+	tests := map[string]string{
+		"line": "Anselm, Basic Writings, trans. Thomas Williams (2007), " +
+			"ISBN 978-0-87220-895-7 [Owned]",
+		"markdown": "- Anselm, *Basic Writings*, trans. Thomas Williams (Hackett, 2007)",
+	}
+	for name, expected := range tests {
+		result, err := renderBookString(name, newBookView(b))
+		if err != nil || result != expected {
+			t.Errorf("renderBookString(%q): expected %q, got %q, error %v", name, expected,
+				result, err)
+		}
+	}
+
+	var templateErr *TemplateError
+	for _, name := range []string{"nothing", "builtin"} {
+		if _, err := renderBookString(name, newBookView(b)); !errors.As(err, &templateErr) {
+			t.Errorf("renderBookString(%q): expected TemplateError, got %v", name, err)
+		}
+	}
+}
+
+func TestParseDisplayTemplates(t *testing.T) {
+	tmpl, err := parseDisplayTemplates(`{{define "line"}}{{.Title}} ({{ordinal ` +
+		`.EditionNumber}} ed.){{end}}{{define "short"}}{{surname (index .Authors 0)}}` +
+		`{{end}}`)
+	if err != nil {
+		t.Fatalf("parseDisplayTemplates returned error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, "line", exampleBookView); err != nil ||
+		buf.String() != "Invitation to the Septuagint (2nd ed.)" {
+		t.Errorf("Redefined line template: got %q, error %v", buf.String(), err)
+	}
+	buf.Reset()
+	if err := tmpl.ExecuteTemplate(&buf, "short", exampleBookView); err != nil ||
+		buf.String() != "Jobes" {
+		t.Errorf("New short template: got %q, error %v", buf.String(), err)
+	}
+	// the built-in templates are unchanged
+	if displayTemplates.Lookup("short") != nil {
+		t.Errorf("Parsing templates changed the templates in use")
+	}
+
+	invalid := []string{
+		`{{define "line"}}{{.Title}`,
+		`{{define "line"}}{{.Nothing}}{{end}}`,
+		`{{define "line"}}{{unknown .Title}}{{end}}`,
+	}
+	for _, text := range invalid {
+		var templateErr *TemplateError
+		if _, err := parseDisplayTemplates(text); !errors.As(err, &templateErr) {
+			t.Errorf("parseDisplayTemplates(%q): expected TemplateError, got %v", text, err)
+		}
+	}
+}
+
+func TestLoadDisplayTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.tmpl")
+	if err := os.WriteFile(path, []byte(`{{define "line"}}{{.Title}}{{end}}`),
+		0644); err != nil {
+		t.Fatalf("Problem writing templates: %v", err)
+	}
+	if err := loadDisplayTemplates(path); err != nil {
+		t.Fatalf("loadDisplayTemplates returned error: %v", err)
+	}
+	defer loadDisplayTemplates("")
+
+	b := *makeTestBook()
+	if s := b.String(); s != "Invitation to the Septuagint" {
+		t.Errorf("Book.String with redefined line template: got %q", s)
+	}
+	loadDisplayTemplates("")
+	if s := b.String(); !strings.HasPrefix(s, "Karen H. Jobes") {
+		t.Errorf("Book.String after going back to built-in templates: got %q", s)
+	}
+
+	var templateErr *TemplateError
+	if err := loadDisplayTemplates(filepath.Join(t.TempDir(), "none.tmpl")); err == nil ||
+		errors.As(err, &templateErr) {
+		t.Errorf("loadDisplayTemplates of missing file: expected error reading it, got %v",
+			err)
+	}
+}
+
+func TestExportTemplate(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := exportLibraryTemplate(db, &buf, ExportSelection{Query: "publisher:Crossway"},
+		"markdown")
+	if err != nil {
+		t.Fatalf("exportLibraryTemplate returned error: %v", err)
+	}
+	expected := "- Peter J. Gentry, *How to Read and Understand the Biblical Prophets* " +
+		"(Crossway, 2017)\n" +
+		"- Peter J. Gentry and Stephen J. Wellum, *Kingdom through Covenant: A " +
+		"Biblical-Theological Understanding of the Covenants*, 2nd edition (Crossway, 2018)\n" +
+		"- Herman Bavinck, *Christianity and Science*, ed. N. Gray Sutanto, James Eglinton " +
+		"and Cory C. Brock (Crossway, 2023)\n"
+	if count != 3 || buf.String() != expected {
+		t.Errorf("exportLibraryTemplate: expected 3 books\n%v\ngot %v books\n%v", expected,
+			count, buf.String())
+	}
+
+	// the detail template ends with a newline, so isn't given another
+	buf.Reset()
+	if _, err := exportTemplate(db, &buf, []int{1}, "detail"); err != nil ||
+		strings.HasSuffix(buf.String(), "\n\n") {
+		t.Errorf("exportTemplate with detail template: got %q, error %v", buf.String(), err)
+	}
+
+	var templateErr *TemplateError
+	if _, err := exportTemplate(db, &buf, []int{1}, "nothing"); !errors.As(err, &templateErr) {
+		t.Errorf("exportTemplate with unknown template: expected TemplateError, got %v", err)
+	}
+	var bookIdErr *InvalidBookIdError
+	if _, err := exportTemplate(db, &buf, []int{999}, "line"); !errors.As(err, &bookIdErr) {
+		t.Errorf("exportTemplate of book #999: expected InvalidBookIdError, got %v", err)
+	}
+}
+
+func TestCliTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.tmpl")
+	if err := os.WriteFile(path, []byte(`{{define "line"}}{{.Title}} [{{join .Status ", "}}]`+
+		`{{end}}{{define "cite"}}{{surname (index .Authors 0)}} {{.Year}}{{end}}`),
+		0644); err != nil {
+		t.Fatalf("Problem writing templates: %v", err)
+	}
+	defer loadDisplayTemplates("")
+
+	code, stdout, stderr := runTestCli("", "--templates", path, "list", "--query",
+		"author:Gentry")
+	expected := "   4  How to Read and Understand the Biblical Prophets [Owned]\n" +
+		"   5  Kingdom through Covenant [Owned, Read]\n"
+	if code != exitOk || stdout != expected {
+		t.Errorf("list with redefined line template: expected\n%v\ngot %v\n%v%v", expected,
+			code, stdout, stderr)
+	}
+
+	code, stdout, stderr = runTestCli("", "--templates", path, "show", "--template", "cite",
+		"5")
+	if code != exitOk || stdout != "Gentry 2018\n" {
+		t.Errorf("show --template cite: expected \"Gentry 2018\", got %v %q %v", code, stdout,
+			stderr)
+	}
+
+	// without --templates, only the built-in templates are defined
+	code, _, stderr = runTestCli("", "show", "--template", "cite", "5")
+	if code != exitUsage || !strings.Contains(stderr, "No such template") {
+		t.Errorf("show with unknown template: expected exit code %v, got %v: %v", exitUsage,
+			code, stderr)
+	}
+
+	code, stdout, stderr = runTestCli("", "list", "--template", "markdown", "--query",
+		"publisher:Hackett")
+	expected = "   3  - Anselm, *Basic Writings*, trans. Thomas Williams (Hackett, 2007)\n"
+	if code != exitOk || stdout != expected {
+		t.Errorf("list --template markdown: expected %q, got %v %q %v", expected, code,
+			stdout, stderr)
+	}
+
+	code, stdout, stderr = runTestCli("", "--templates", path, "export", "--format",
+		"template", "--template", "cite", "--person", "3")
+	if code != exitOk || stdout != "Gentry 2017\nGentry 2018\n" {
+		t.Errorf("export --format template: got %v %q %v", code, stdout, stderr)
+	}
+
+	for _, args := range [][]string{
+		{"export", "--format", "template"},
+		{"export", "--template", "line"},
+		{"--templates", filepath.Join(t.TempDir(), "none.tmpl"), "list"},
+	} {
+		if code, _, _ := runTestCli("", args...); code == exitOk {
+			t.Errorf("%v: expected failure, exited with %v", args, code)
+		}
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.tmpl")
+	if err := os.WriteFile(invalid, []byte(`{{define "line"}}{{.Nothing}}{{end}}`),
+		0644); err != nil {
+		t.Fatalf("Problem writing templates: %v", err)
+	}
+	code, _, stderr = runTestCli("", "--templates", invalid, "list")
+	if code != exitUsage || !strings.Contains(stderr, "Nothing") {
+		t.Errorf("list with invalid templates: expected exit code %v, got %v: %v",
+			exitUsage, code, stderr)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Edition holds a book's edition number, where it has one, along with any
+// description of the edition which can't be captured by the number alone,
+// e.g. "revised and expanded edition". The zero value means no edition is
+// recorded.
+type Edition struct {
+	number      int
+	description string
+}
+
+// ordinal gives the English ordinal form of a positive integer, e.g. 1 ->
+// "1st", 2 -> "2nd", 11 -> "11th", 112 -> "112th".
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 100 {
+	case 11, 12, 13:
+	default:
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%v", n, suffix)
+}
+
+// String gives the edition number as an ordinal, e.g. "2nd", followed by the
+// description if there is one.
+func (e Edition) String() string {
+	switch {
+	case e.number == 0:
+		return e.description
+	case len(e.description) == 0:
+		return ordinal(e.number)
+	default:
+		return fmt.Sprintf("%v, %v", ordinal(e.number), e.description)
+	}
+}
+
+// long gives the edition in a form for displaying alongside a title, e.g.
+// "2nd edition".
+func (e Edition) long() string {
+	if e.number != 0 && len(e.description) == 0 {
+		return fmt.Sprintf("%v edition", ordinal(e.number))
+	}
+	return e.String()
+}
+
+func (e Edition) isZero() bool {
+	return e.number == 0 && len(e.description) == 0
+}
+
+// editionPattern matches editions written with a number, e.g. "2", "2nd",
+// "2nd edition" or "2nd, revised".
+var editionPattern = regexp.MustCompile(`^(?i)(\d+)(?:st|nd|rd|th)?(?:\s+(?:edition|ed\.?))?(?:,\s*(.*))?$`)
+
+// parseEdition reads an edition written as by Edition.String, or as a plain
+// number. Anything not starting with a number is taken as a description.
+func parseEdition(s string) Edition {
+	s = strings.TrimSpace(s)
+	m := editionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Edition{description: s}
+	}
+	number, err := strconv.Atoi(m[1])
+	if err != nil {
+		return Edition{description: s}
+	}
+	return Edition{number, strings.TrimSpace(m[2])}
+}
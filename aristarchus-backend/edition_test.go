@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOrdinal(t *testing.T) {
+	ordinals := map[int]string{
+		1:   "1st",
+		2:   "2nd",
+		3:   "3rd",
+		4:   "4th",
+		10:  "10th",
+		11:  "11th",
+		12:  "12th",
+		13:  "13th",
+		21:  "21st",
+		22:  "22nd",
+		23:  "23rd",
+		101: "101st",
+		111: "111th",
+		112: "112th",
+		113: "113th",
+		122: "122nd",
+	}
+
+	for n, expected := range ordinals {
+		if result := ordinal(n); result != expected {
+			t.Errorf("ordinal(%v) returned unexpected value. Expected %v, got %v",
+				n, expected, result)
+		}
+	}
+}
+
+func TestEditionString(t *testing.T) {
+	editions := map[Edition]string{
+		{}:                                  "",
+		{number: 2}:                         "2nd",
+		{description: "revised edition"}:    "revised edition",
+		{3, "revised and expanded edition"}: "3rd, revised and expanded edition",
+		{number: 11}:                        "11th",
+		{112, "anniversary edition"}:        "112th, anniversary edition",
+	}
+
+	for e, expected := range editions {
+		if result := e.String(); result != expected {
+			t.Errorf("Edition String returned unexpected value. Expected %v, got %v",
+				expected, result)
+		}
+	}
+}
+
+func TestEditionLong(t *testing.T) {
+	editions := map[Edition]string{
+		{}:                                  "",
+		{number: 2}:                         "2nd edition",
+		{description: "revised edition"}:    "revised edition",
+		{3, "revised and expanded edition"}: "3rd, revised and expanded edition",
+	}
+
+	for e, expected := range editions {
+		if result := e.long(); result != expected {
+			t.Errorf("Edition long returned unexpected value. Expected %v, got %v",
+				expected, result)
+		}
+	}
+}
+
+func TestParseEdition(t *testing.T) {
+	editions := map[string]Edition{
+		"":                  {},
+		"2":                 {number: 2},
+		"2nd":               {number: 2},
+		"2nd edition":       {number: 2},
+		"3rd Ed.":           {number: 3},
+		"3rd, revised":      {3, "revised"},
+		"11th, anniversary": {11, "anniversary"},
+		"revised edition":   {description: "revised edition"},
+		" 2nd, expanded ":   {2, "expanded"},
+		"Second, expanded":  {description: "Second, expanded"},
+	}
+
+	for s, expected := range editions {
+		if result := parseEdition(s); result != expected {
+			t.Errorf("parseEdition(%q) returned unexpected value. Expected %#v, got %#v",
+				s, expected, result)
+		}
+	}
+	// editions read back as written
+	for _, e := range []Edition{{number: 2}, {3, "revised and expanded edition"}} {
+		if result := parseEdition(e.String()); result != e {
+			t.Errorf("parseEdition(%q) didn't give edition back. Expected %#v, got %#v",
+				e.String(), e, result)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ExportSelection chooses the books to export: those matching a query, a
+// single book, the books in a series, or those a person contributed to. If
+// none of these is given, the whole library is exported.
+type ExportSelection struct {
+	Query    string
+	BookId   int
+	SeriesId int
+	PersonId int
+}
+
+// bookIds gives the ids of the selected books, in id order.
+func (s ExportSelection) bookIds(db DBInterface) ([]int, error) {
+	given := 0
+	for _, set := range []bool{len(s.Query) > 0, s.BookId != 0, s.SeriesId != 0,
+		s.PersonId != 0} {
+		if set {
+			given++
+		}
+	}
+	if given > 1 {
+		return nil, &BadRequestError{"ExportSelection.bookIds",
+			"Only one of a query, book, series or person can be selected"}
+	}
+
+	var ids []int
+	var err error
+	switch {
+	case len(s.Query) > 0:
+		ids, err = queryBookIds(db, s.Query)
+	case s.BookId != 0:
+		var valid bool
+		valid, err = BookIDValid(db, s.BookId)
+		if err == nil && !valid {
+			err = &InvalidBookIdError{"ExportSelection.bookIds", s.BookId}
+		}
+		ids = []int{s.BookId}
+	case s.SeriesId != 0:
+		ids, err = seriesBooks(db, s.SeriesId)
+	case s.PersonId != 0:
+		ids, err = booksByPersonId(db, s.PersonId)
+	default:
+		ids, err = getListOfBookIDs(db)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ExportSelection.bookIds: %w", err)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExportSelection(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	gentry, err := personId(db, "Peter J. Gentry")
+	if err != nil {
+		t.Fatalf("Problem getting id of Peter J. Gentry: %v", err)
+	}
+	tests := []struct {
+		sel ExportSelection
+		ids []int
+	}{
+		{ExportSelection{}, []int{1, 2, 3, 4, 5, 6}},
+		{ExportSelection{Query: "status:Owned publisher:Crossway"}, []int{4, 5}},
+		{ExportSelection{BookId: 6}, []int{6}},
+		{ExportSelection{SeriesId: 1}, []int{2}},
+		{ExportSelection{PersonId: gentry}, []int{4, 5}},
+	}
+	for _, test := range tests {
+		ids, err := test.sel.bookIds(db)
+		if err != nil {
+			t.Errorf("bookIds(%+v) returned error: %v", test.sel, err)
+		}
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("bookIds(%+v): expected %v, got %v", test.sel, test.ids, ids)
+		}
+	}
+
+	var bookIdErr *InvalidBookIdError
+	if _, err := (ExportSelection{BookId: 17}).bookIds(db); !errors.As(err, &bookIdErr) {
+		t.Errorf("Expected InvalidBookIdError for book #17, got %v", err)
+	}
+	var seriesIdErr *InvalidSeriesIdError
+	if _, err := (ExportSelection{SeriesId: 17}).bookIds(db); !errors.As(err, &seriesIdErr) {
+		t.Errorf("Expected InvalidSeriesIdError for series #17, got %v", err)
+	}
+	var badRequestErr *BadRequestError
+	_, err = ExportSelection{BookId: 1, PersonId: gentry}.bookIds(db)
+	if !errors.As(err, &badRequestErr) {
+		t.Errorf("Expected BadRequestError selecting a book and a person, got %v", err)
+	}
+}
@@ -2,4 +2,7 @@ module AndyHolt/aristarchus
 
 go 1.21.1
 
-require github.com/mattn/go-sqlite3 v1.14.17
+require (
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.17
+)
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Books are imported from CSV, RIS and CSL-JSON files in the same way: each
+// book read from the file is added with addBook, and a book which can't be
+// added is reported rather than stopping the import. In a dry run, the books
+// are checked with checkBookInDb instead of being added. Statuses read from RIS
+// and CSL-JSON files are limited to those already in the library by
+// existingStatuses, as other programs use the same fields for subject tags.
+
+// ImportReport is the outcome of importing a file. Rows are the rows of a CSV
+// file, numbered as in a spreadsheet with the header as row 1, the lines RIS
+// entries start on, or the positions of CSL-JSON items, from 1. Ignored lists
+// the columns, tags or variables matching no field, and the statuses left out
+// by existingStatuses.
+type ImportReport struct {
+	DryRun     bool
+	Added      []ImportedBook   // books added, or which would be added in a dry run
+	Duplicates []ImportRowError // rows whose books are already in the library
+	Invalid    []ImportRowError // rows which couldn't be read or added
+	Ignored    []string         // columns, tags, variables or statuses not read
+}
+
+func (r ImportReport) skipped() int {
+	return len(r.Duplicates) + len(r.Invalid)
+}
+
+type ImportedBook struct {
+	Row  int
+	Book Book
+}
+
+type ImportRowError struct {
+	Row int
+	Err error
+}
+
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("Row %v: %v", e.Row, e.Err)
+}
+
+func (e *ImportRowError) Unwrap() error {
+	return e.Err
+}
+
+type MissingFieldError struct {
+	CallFunc string
+	Field    string
+}
+
+func (e *MissingFieldError) Error() string {
+	return fmt.Sprintf("%v: No %v given", e.CallFunc, e.Field)
+}
+
+// UnsupportedTypeError is an entry of a file for something other than a book,
+// such as a journal article.
+type UnsupportedTypeError struct {
+	CallFunc string
+	Type     string
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("%v: Can only import books, not \"%v\"", e.CallFunc, e.Type)
+}
+
+// DuplicateRowError is a row found in a dry run to have the same book as an
+// earlier row of the file, which would be added first.
+type DuplicateRowError struct {
+	CallFunc string
+	Title    string
+	FirstRow int
+}
+
+func (e *DuplicateRowError) Error() string {
+	return fmt.Sprintf("%v: Book \"%v\" already imported from row %v", e.CallFunc,
+		e.Title, e.FirstRow)
+}
+
+// SkippedRowsError reports that some rows of a file weren't imported. It
+// wraps the error of the first invalid row, or if none were invalid, the first
+// duplicate.
+type SkippedRowsError struct {
+	CallFunc string
+	Skipped  int
+	Rows     int
+	First    error
+}
+
+func (e *SkippedRowsError) Error() string {
+	return fmt.Sprintf("%v: Skipped %v of %v rows", e.CallFunc, e.Skipped, e.Rows)
+}
+
+func (e *SkippedRowsError) Unwrap() error {
+	return e.First
+}
+
+// skippedError gives a SkippedRowsError if any rows were skipped, and
+// otherwise nil.
+func (r ImportReport) skippedError() error {
+	if r.skipped() == 0 {
+		return nil
+	}
+	var first error
+	if len(r.Invalid) > 0 {
+		first = &r.Invalid[0]
+	} else {
+		first = &r.Duplicates[0]
+	}
+	return &SkippedRowsError{"import", r.skipped(), len(r.Added) + r.skipped(), first}
+}
+
+// duplicateKey identifies a book by one of its authors or editors and its
+// title. checkBookInDb finds a book to be a duplicate of one with the same
+// title which has its first author among its authors, or its first editor
+// among its editors, so a book added in a dry run is recorded under a key for
+// each of its authors and editors, and later books are looked up by their
+// first author and first editor. A book with neither is never a duplicate.
+func duplicateKey(role ContributorRole, name, title string) string {
+	return string(role) + "\x00" + name + "\x00" + title
+}
+
+// bookImporter adds the books read from a file to the library, recording the
+// outcome for each in its report.
+type bookImporter struct {
+	db        *sql.DB
+	report    ImportReport
+	seen      map[string]int // rows of the books a dry run would add, by duplicateKey
+	ignored   map[string]bool
+	statuses  []string // the library's statuses, once read by existingStatuses
+	lifecycle []string
+}
+
+func newBookImporter(db *sql.DB, dryRun bool) *bookImporter {
+	return &bookImporter{
+		db:      db,
+		report:  ImportReport{DryRun: dryRun},
+		seen:    map[string]int{},
+		ignored: map[string]bool{},
+	}
+}
+
+// ignore lists something read from the file in the report as ignored, once.
+func (imp *bookImporter) ignore(name string) {
+	if !imp.ignored[name] {
+		imp.ignored[name] = true
+		imp.report.Ignored = append(imp.report.Ignored, name)
+	}
+}
+
+// existingStatuses gives those of a book's statuses, read from the given field
+// of a file, which are already in the library, rather than having addBook
+// create the others, as reference managers write subject tags as RIS keywords.
+// Of the lifecycle statuses only the first is kept, so that the book isn't
+// rejected for having two. Those left out are listed in the report as ignored,
+// as the field and value, e.g. KW "Theology".
+func (imp *bookImporter) existingStatuses(field string, statuses []string) ([]string, error) {
+	if imp.statuses == nil {
+		var err error
+		if imp.statuses, err = listStatuses(imp.db); err != nil {
+			return nil, fmt.Errorf("existingStatuses: %w", err)
+		}
+		if imp.lifecycle, err = lifecycleStatuses(imp.db); err != nil {
+			return nil, fmt.Errorf("existingStatuses: %w", err)
+		}
+	}
+
+	var kept []string
+	hasLifecycle := false
+	for _, status := range statuses {
+		if slices.Contains(kept, status) {
+			continue
+		}
+		isLifecycle := slices.Contains(imp.lifecycle, status)
+		if !slices.Contains(imp.statuses, status) || (isLifecycle && hasLifecycle) {
+			imp.ignore(fmt.Sprintf("%v %q", field, status))
+			continue
+		}
+		hasLifecycle = hasLifecycle || isLifecycle
+		kept = append(kept, status)
+	}
+	return kept, nil
+}
+
+// invalid records a row which couldn't be read.
+func (imp *bookImporter) invalid(row int, err error) {
+	imp.report.Invalid = append(imp.report.Invalid, ImportRowError{row, err})
+}
+
+// add adds the book read from a row, or in a dry run checks whether it would
+// be added. Books which can't be added are recorded in the report, and an
+// error is only returned for problems with the database.
+func (imp *bookImporter) add(row int, b Book) error {
+	if imp.report.DryRun {
+		for _, role := range []ContributorRole{RoleAuthor, RoleEditor} {
+			names := nameListFromString(*b.contributors(role))
+			if len(names) == 0 {
+				continue
+			}
+			if first, ok := imp.seen[duplicateKey(role, names[0], b.title)]; ok {
+				imp.report.Duplicates = append(imp.report.Duplicates, ImportRowError{row,
+					&DuplicateRowError{"bookImporter.add", b.title, first}})
+				return nil
+			}
+		}
+		id, err := checkBookInDb(imp.db, &b)
+		if err != nil {
+			return fmt.Errorf("bookImporter.add, Couldn't check row %v: %v", row, err)
+		}
+		if id != 0 {
+			imp.report.Duplicates = append(imp.report.Duplicates,
+				ImportRowError{row, &AddingDuplicateBookError{&b, id}})
+			return nil
+		}
+		for _, role := range []ContributorRole{RoleAuthor, RoleEditor} {
+			for _, name := range nameListFromString(*b.contributors(role)) {
+				key := duplicateKey(role, name, b.title)
+				if _, ok := imp.seen[key]; !ok {
+					imp.seen[key] = row
+				}
+			}
+		}
+		imp.report.Added = append(imp.report.Added, ImportedBook{row, b})
+		return nil
+	}
+
+	id, err := addBook(imp.db, &b)
+	var duplicateErr *AddingDuplicateBookError
+	switch {
+	case errors.As(err, &duplicateErr):
+		imp.report.Duplicates = append(imp.report.Duplicates, ImportRowError{row, err})
+	case err != nil && apiErrorStatus(err) == http.StatusInternalServerError:
+		// not a problem with the book, so the rest of the file would fail too
+		return fmt.Errorf("bookImporter.add, Couldn't add row %v: %w", row, err)
+	case err != nil:
+		imp.invalid(row, err)
+	default:
+		b.id = id
+		imp.report.Added = append(imp.report.Added, ImportedBook{row, b})
+	}
+	return nil
+}
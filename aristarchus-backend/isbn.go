@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ISBN is an International Standard Book Number, held in canonical form: the
+// thirteen digits of the ISBN-13 with no hyphens or spaces. ISBN-10 values are
+// converted to ISBN-13 when parsed, so two forms of the same number compare
+// equal. The zero value means the book has no ISBN.
+type ISBN string
+
+type InvalidIsbnError struct {
+	CallFunc string
+	Isbn     string
+	Reason   string
+}
+
+func (e *InvalidIsbnError) Error() string {
+	return fmt.Sprintf("%v: Invalid ISBN \"%v\", %v", e.CallFunc, e.Isbn, e.Reason)
+}
+
+// parseIsbn accepts an ISBN-10 or ISBN-13, with or without hyphens or spaces,
+// checks its length, characters and check digit, and returns it in canonical
+// ISBN-13 form. An empty string is accepted and returns the empty ISBN.
+func parseIsbn(s string) (ISBN, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+
+	digits := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(s))
+
+	switch len(digits) {
+	case 10:
+		for i, c := range digits {
+			if c == 'X' && i == 9 {
+				continue
+			}
+			if c < '0' || c > '9' {
+				return "", &InvalidIsbnError{"parseIsbn", s,
+					fmt.Sprintf("unexpected character '%c'", c)}
+			}
+		}
+		if isbn10CheckDigit(digits[:9]) != digits[9] {
+			return "", &InvalidIsbnError{"parseIsbn", s, "check digit is incorrect"}
+		}
+		prefixed := "978" + digits[:9]
+		return ISBN(prefixed + string(isbn13CheckDigit(prefixed))), nil
+	case 13:
+		for _, c := range digits {
+			if c < '0' || c > '9' {
+				return "", &InvalidIsbnError{"parseIsbn", s,
+					fmt.Sprintf("unexpected character '%c'", c)}
+			}
+		}
+		if !strings.HasPrefix(digits, "978") && !strings.HasPrefix(digits, "979") {
+			return "", &InvalidIsbnError{"parseIsbn", s,
+				"ISBN-13 must begin with 978 or 979"}
+		}
+		if isbn13CheckDigit(digits[:12]) != digits[12] {
+			return "", &InvalidIsbnError{"parseIsbn", s, "check digit is incorrect"}
+		}
+		return ISBN(digits), nil
+	default:
+		return "", &InvalidIsbnError{"parseIsbn", s,
+			fmt.Sprintf("expected 10 or 13 digits, got %v", len(digits))}
+	}
+}
+
+// isbn10CheckDigit calculates the check digit for the first nine digits of an
+// ISBN-10, which is 'X' where the check value is 10.
+func isbn10CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(digits[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// isbn13CheckDigit calculates the check digit for the first twelve digits of
+// an ISBN-13.
+func isbn13CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(digits[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func (i ISBN) isbn13() string {
+	return string(i)
+}
+
+// isbn10 converts the ISBN to ISBN-10 form. Only ISBNs in the 978 prefix have
+// an ISBN-10 equivalent.
+func (i ISBN) isbn10() (string, error) {
+	if len(i) == 0 {
+		return "", nil
+	}
+	if !strings.HasPrefix(string(i), "978") {
+		return "", &InvalidIsbnError{"isbn10", string(i),
+			"only ISBNs with the 978 prefix have an ISBN-10 form"}
+	}
+	digits := string(i)[3:12]
+	return digits + string(isbn10CheckDigit(digits)), nil
+}
+
+// String returns the ISBN hyphenated as printed on the book where the range
+// data allows, falling back to the plain digits otherwise, which is reported
+// through onUnhyphenatedIsbn.
+func (i ISBN) String() string {
+	if len(i) == 0 {
+		return ""
+	}
+	h, err := i.hyphenated()
+	if err != nil {
+		// an invalid ISBN kept from before validation isn't a gap in the range
+		// data, and is reported by checkStoredIsbns instead
+		if len(i) == 13 {
+			reportUnhyphenatedIsbn(err)
+		}
+		return string(i)
+	}
+	return h
+}
+
+// firstIsbn parses the first ISBN in a field which may list several, as
+// reference managers write them, separated by spaces, commas or semicolons. An
+// empty field gives an empty ISBN.
+func firstIsbn(s string) (ISBN, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == ';'
+	})
+	var firstErr error
+	for _, field := range fields {
+		isbn, err := parseIsbn(field)
+		if err == nil {
+			return isbn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", firstErr
+}
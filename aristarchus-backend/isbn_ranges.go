@@ -0,0 +1,269 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// isbnRangeData is the ISBN range message used for hyphenating ISBNs when no
+// other range file has been loaded. As committed it is a partial sample in the
+// format of the International ISBN Agency's RangeMessage.xml, covering only a
+// few registration groups, which go generate replaces with the agency's
+// current export. ISBNs outside the groups it covers are reported through
+// onUnhyphenatedIsbn.
+//
+//go:generate curl -fsSL -o isbn_ranges.xml https://www.isbn-international.org/export_rangemessage.xml
+//go:embed isbn_ranges.xml
+var isbnRangeData []byte
+
+type isbnRangeMessage struct {
+	XMLName  xml.Name         `xml:"ISBNRangeMessage"`
+	Date     string           `xml:"MessageDate"`
+	Prefixes []isbnRangeGroup `xml:"EAN.UCCPrefixes>EAN.UCC"`
+	Groups   []isbnRangeGroup `xml:"RegistrationGroups>Group"`
+}
+
+type isbnRangeGroup struct {
+	Prefix string          `xml:"Prefix"`
+	Agency string          `xml:"Agency"`
+	Rules  []isbnRangeRule `xml:"Rules>Rule"`
+}
+
+type isbnRangeRule struct {
+	Range  string `xml:"Range"`
+	Length int    `xml:"Length"`
+}
+
+type isbnRule struct {
+	low, high string
+	length    int
+}
+
+// isbnRangeTable maps an EAN prefix (e.g. "978") to the rules giving the
+// length of its registration groups, and a registration group (e.g. "978-1")
+// to the rules giving the length of its registrants.
+type isbnRangeTable struct {
+	date     string
+	prefixes map[string][]isbnRule
+	groups   map[string][]isbnRule
+}
+
+type IsbnRangeError struct {
+	CallFunc string
+	Isbn     ISBN
+	Reason   string
+}
+
+func (e *IsbnRangeError) Error() string {
+	return fmt.Sprintf("%v: Cannot hyphenate ISBN %v, %v",
+		e.CallFunc, string(e.Isbn), e.Reason)
+}
+
+var (
+	isbnRangesMu sync.RWMutex
+	isbnRanges   *isbnRangeTable
+)
+
+var (
+	unhyphenatedMu     sync.Mutex
+	unhyphenatedReport func(err *IsbnRangeError)
+	unhyphenatedSeen   map[string]bool
+)
+
+// onUnhyphenatedIsbn sets report to be called when an ISBN is printed without
+// hyphens because the range data doesn't cover it, once for each reason, so
+// that gaps in the range data aren't passed over silently. A nil report stops
+// the reports.
+func onUnhyphenatedIsbn(report func(err *IsbnRangeError)) {
+	unhyphenatedMu.Lock()
+	defer unhyphenatedMu.Unlock()
+	unhyphenatedReport = report
+	unhyphenatedSeen = make(map[string]bool)
+}
+
+func reportUnhyphenatedIsbn(err error) {
+	var rangeErr *IsbnRangeError
+	if !errors.As(err, &rangeErr) {
+		return
+	}
+	unhyphenatedMu.Lock()
+	defer unhyphenatedMu.Unlock()
+	if unhyphenatedReport == nil || unhyphenatedSeen[rangeErr.Reason] {
+		return
+	}
+	unhyphenatedSeen[rangeErr.Reason] = true
+	unhyphenatedReport(rangeErr)
+}
+
+func parseIsbnRanges(data []byte) (*isbnRangeTable, error) {
+	var msg isbnRangeMessage
+	if err := xml.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("parseIsbnRanges, Couldn't read range message: %v", err)
+	}
+
+	table := isbnRangeTable{
+		date:     msg.Date,
+		prefixes: make(map[string][]isbnRule),
+		groups:   make(map[string][]isbnRule),
+	}
+	for _, p := range msg.Prefixes {
+		rules, err := parseIsbnRules(p)
+		if err != nil {
+			return nil, err
+		}
+		table.prefixes[p.Prefix] = rules
+	}
+	for _, g := range msg.Groups {
+		rules, err := parseIsbnRules(g)
+		if err != nil {
+			return nil, err
+		}
+		table.groups[g.Prefix] = rules
+	}
+	if len(table.prefixes) == 0 {
+		return nil, fmt.Errorf("parseIsbnRanges: Range message has no EAN.UCC prefixes")
+	}
+
+	return &table, nil
+}
+
+func parseIsbnRules(g isbnRangeGroup) ([]isbnRule, error) {
+	var rules []isbnRule
+	for _, r := range g.Rules {
+		low, high, found := strings.Cut(strings.TrimSpace(r.Range), "-")
+		if !found || len(low) != 7 || len(high) != 7 {
+			return nil, fmt.Errorf("parseIsbnRules: Invalid range \"%v\" for %v",
+				r.Range, g.Prefix)
+		}
+		rules = append(rules, isbnRule{low, high, r.Length})
+	}
+	return rules, nil
+}
+
+// loadIsbnRangesFile replaces the range table used for hyphenation with the
+// contents of an ISBN range message file, such as a RangeMessage.xml
+// downloaded from the International ISBN Agency.
+func loadIsbnRangesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("loadIsbnRangesFile, Couldn't read %v: %v", path, err)
+	}
+	table, err := parseIsbnRanges(data)
+	if err != nil {
+		return fmt.Errorf("loadIsbnRangesFile, %v: %w", path, err)
+	}
+
+	isbnRangesMu.Lock()
+	defer isbnRangesMu.Unlock()
+	isbnRanges = table
+	return nil
+}
+
+func currentIsbnRanges() (*isbnRangeTable, error) {
+	isbnRangesMu.RLock()
+	table := isbnRanges
+	isbnRangesMu.RUnlock()
+	if table != nil {
+		return table, nil
+	}
+
+	isbnRangesMu.Lock()
+	defer isbnRangesMu.Unlock()
+	if isbnRanges == nil {
+		table, err := parseIsbnRanges(isbnRangeData)
+		if err != nil {
+			return nil, fmt.Errorf("currentIsbnRanges, embedded range data: %w", err)
+		}
+		isbnRanges = table
+	}
+	return isbnRanges, nil
+}
+
+func lookupIsbnRule(rules []isbnRule, digits string) (int, bool) {
+	for _, r := range rules {
+		if digits >= r.low && digits <= r.high {
+			return r.length, true
+		}
+	}
+	return 0, false
+}
+
+// IsbnParts holds the elements of an ISBN-13 as printed on a book.
+type IsbnParts struct {
+	Prefix      string
+	Group       string
+	Registrant  string
+	Publication string
+	Check       string
+}
+
+func (p IsbnParts) String() string {
+	return strings.Join(
+		[]string{p.Prefix, p.Group, p.Registrant, p.Publication, p.Check}, "-")
+}
+
+// split divides a canonical ISBN into its prefix, registration group,
+// registrant, publication and check digit elements.
+func (t *isbnRangeTable) split(i ISBN) (IsbnParts, error) {
+	digits := string(i)
+	if len(digits) != 13 {
+		return IsbnParts{}, &IsbnRangeError{"split", i, "not a canonical ISBN-13"}
+	}
+
+	prefix := digits[:3]
+	prefixRules, ok := t.prefixes[prefix]
+	if !ok {
+		return IsbnParts{}, &IsbnRangeError{"split", i,
+			fmt.Sprintf("unknown prefix %v", prefix)}
+	}
+	groupLength, ok := lookupIsbnRule(prefixRules, digits[3:10])
+	if !ok || groupLength == 0 {
+		return IsbnParts{}, &IsbnRangeError{"split", i,
+			"registration group not defined"}
+	}
+	group := digits[3 : 3+groupLength]
+
+	groupRules, ok := t.groups[prefix+"-"+group]
+	if !ok {
+		return IsbnParts{}, &IsbnRangeError{"split", i,
+			fmt.Sprintf("no range data for registration group %v-%v", prefix, group)}
+	}
+	rest := digits[3+groupLength : 12]
+	registrantLength, ok := lookupIsbnRule(groupRules, (rest + "0000000")[:7])
+	if !ok || registrantLength == 0 || registrantLength >= len(rest) {
+		return IsbnParts{}, &IsbnRangeError{"split", i,
+			"registrant range not defined"}
+	}
+
+	return IsbnParts{
+		Prefix:      prefix,
+		Group:       group,
+		Registrant:  rest[:registrantLength],
+		Publication: rest[registrantLength:],
+		Check:       digits[12:],
+	}, nil
+}
+
+// isbnParts splits the ISBN using the current range table.
+func (i ISBN) isbnParts() (IsbnParts, error) {
+	table, err := currentIsbnRanges()
+	if err != nil {
+		return IsbnParts{}, err
+	}
+	return table.split(i)
+}
+
+// hyphenated returns the ISBN-13 with its elements separated by hyphens, as
+// printed on the book, e.g. 978-1-4335-5403-2.
+func (i ISBN) hyphenated() (string, error) {
+	parts, err := i.isbnParts()
+	if err != nil {
+		return "", err
+	}
+	return parts.String(), nil
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseIsbn13(t *testing.T) {
+	isbnString := "978-1-4335-5307-3"
+	expected := ISBN("9781433553073")
+
+	isbn, err := parseIsbn(isbnString)
+	if err != nil {
+		t.Errorf("parseIsbn returned error for valid ISBN-13 %v: %v", isbnString, err)
+	}
+	if isbn != expected {
+		t.Errorf("parseIsbn returned unexpected value. Expected %v, got %v",
+			expected, isbn)
+	}
+}
+
+func TestParseIsbn10(t *testing.T) {
+	isbnString := "0-85111-723-6"
+	expected := ISBN("9780851117232")
+
+	isbn, err := parseIsbn(isbnString)
+	if err != nil {
+		t.Errorf("parseIsbn returned error for valid ISBN-10 %v: %v", isbnString, err)
+	}
+	if isbn != expected {
+		t.Errorf("parseIsbn returned unexpected value. Expected %v, got %v",
+			expected, isbn)
+	}
+}
+
+func TestParseIsbn10CheckDigitX(t *testing.T) {
+	isbnString := "0 8044 2957 x"
+	expected := ISBN("9780804429573")
+
+	isbn, err := parseIsbn(isbnString)
+	if err != nil {
+		t.Errorf("parseIsbn returned error for valid ISBN-10 %v: %v", isbnString, err)
+	}
+	if isbn != expected {
+		t.Errorf("parseIsbn returned unexpected value. Expected %v, got %v",
+			expected, isbn)
+	}
+}
+
+func TestParseIsbnEmptyString(t *testing.T) {
+	isbn, err := parseIsbn("")
+	if err != nil {
+		t.Errorf("parseIsbn returned error for empty string: %v", err)
+	}
+	if isbn != "" {
+		t.Errorf("parseIsbn returned non-empty ISBN %v for empty string", isbn)
+	}
+}
+
+func TestParseIsbnInvalid(t *testing.T) {
+	invalidIsbns := []string{
+		"978-1-4335-5403-8", // wrong check digit
+		"0-85111-723-5",     // wrong check digit
+		"978-1-4335-5403",   // too short
+		"978-1-4335-5403-22",
+		"978-1-4335-540A-2",
+		"X-85111-723-6",
+		"123-4-5678-9012-8", // neither 978 nor 979 prefix
+	}
+
+	for _, isbnString := range invalidIsbns {
+		_, err := parseIsbn(isbnString)
+		if err == nil {
+			t.Errorf("parseIsbn did not return error for invalid ISBN %v", isbnString)
+		} else {
+			var invIsbnErr *InvalidIsbnError
+			if !errors.As(err, &invIsbnErr) {
+				t.Errorf("parseIsbn returned unexpected error for invalid ISBN %v: %v",
+					isbnString, err)
+			}
+		}
+	}
+}
+
+func TestIsbnToIsbn10(t *testing.T) {
+	isbn := ISBN("9780851117232")
+	expected := "0851117236"
+
+	isbn10, err := isbn.isbn10()
+	if err != nil {
+		t.Errorf("isbn10 returned error for ISBN %v: %v", isbn, err)
+	}
+	if isbn10 != expected {
+		t.Errorf("isbn10 returned unexpected value. Expected %v, got %v",
+			expected, isbn10)
+	}
+}
+
+func TestIsbnToIsbn10CheckDigitX(t *testing.T) {
+	isbn := ISBN("9780804429573")
+	expected := "080442957X"
+
+	isbn10, err := isbn.isbn10()
+	if err != nil {
+		t.Errorf("isbn10 returned error for ISBN %v: %v", isbn, err)
+	}
+	if isbn10 != expected {
+		t.Errorf("isbn10 returned unexpected value. Expected %v, got %v",
+			expected, isbn10)
+	}
+}
+
+func TestIsbnToIsbn10Prefix979(t *testing.T) {
+	isbn := ISBN("9791032305690")
+
+	_, err := isbn.isbn10()
+	if err == nil {
+		t.Errorf("isbn10 did not return error for 979 prefixed ISBN %v", isbn)
+	} else {
+		var invIsbnErr *InvalidIsbnError
+		if !errors.As(err, &invIsbnErr) {
+			t.Errorf("isbn10 returned unexpected error for ISBN %v: %v", isbn, err)
+		}
+	}
+}
+
+func TestIsbnHyphenated(t *testing.T) {
+	isbns := map[ISBN]string{
+		"9781433554032": "978-1-4335-5403-2",
+		"9780851117232": "978-0-85111-723-2",
+		"9780872208957": "978-0-87220-895-7",
+		"9780310128724": "978-0-310-12872-4",
+		"9781408855652": "978-1-4088-5565-2",
+		"9782070360024": "978-2-07-036002-4",
+		"9791032305690": "979-10-323-0569-0",
+	}
+
+	for isbn, expected := range isbns {
+		h, err := isbn.hyphenated()
+		if err != nil {
+			t.Errorf("hyphenated returned error for ISBN %v: %v", string(isbn), err)
+		}
+		if h != expected {
+			t.Errorf("hyphenated returned unexpected value. Expected %v, got %v",
+				expected, h)
+		}
+	}
+}
+
+func TestIsbnHyphenatedUnknownGroup(t *testing.T) {
+	// 978-99936 is Bhutan, which is not included in the embedded range data
+	isbn := ISBN("9789993600015")
+
+	_, err := isbn.hyphenated()
+	if err == nil {
+		t.Errorf("hyphenated did not return error for ISBN %v", string(isbn))
+	} else {
+		var rangeErr *IsbnRangeError
+		if !errors.As(err, &rangeErr) {
+			t.Errorf("hyphenated returned unexpected error for ISBN %v: %v",
+				string(isbn), err)
+		}
+	}
+
+	if isbn.String() != string(isbn) {
+		t.Errorf("String for unknown group did not fall back to digits. Expected %v, got %v",
+			string(isbn), isbn.String())
+	}
+}
+
+func TestIsbnStringReportsUnknownGroup(t *testing.T) {
+	var reported []*IsbnRangeError
+	onUnhyphenatedIsbn(func(err *IsbnRangeError) { reported = append(reported, err) })
+	defer onUnhyphenatedIsbn(nil)
+
+	// 978-84 (Spain) and 978-88 (Italy) are not in the embedded sample
+	isbns := []ISBN{"9788437604947", "9788437604947", "9788806222154", "9781433554032"}
+	for _, isbn := range isbns {
+		_ = isbn.String()
+	}
+
+	if len(reported) != 2 {
+		t.Fatalf("Expected 2 reports of unhyphenated ISBNs, got %v", reported)
+	}
+	if reported[0].Isbn != "9788437604947" ||
+		!strings.Contains(reported[0].Reason, "registration group 978-84") {
+		t.Errorf("Unexpected report for ISBN in group 978-84: %v", reported[0])
+	}
+	if !strings.Contains(reported[1].Reason, "registration group 978-88") {
+		t.Errorf("Unexpected report for ISBN in group 978-88: %v", reported[1])
+	}
+}
+
+func TestCliReportsUnhyphenatedIsbn(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	code, stdout, stderr := runTestCli("", "--json", "add", "--title", "Cien años de soledad",
+		"--author", "Gabriel García Márquez", "--publisher", "Crossway",
+		"--isbn", "978-84-376-0494-7")
+	if code != exitOk {
+		t.Fatalf("add exited with %v: %v", code, stderr)
+	}
+	var added BookJSON
+	if err := json.Unmarshal([]byte(stdout), &added); err != nil {
+		t.Fatalf("Problem reading JSON output %q: %v", stdout, err)
+	}
+	defer deleteBook(db, added.Id)
+
+	code, stdout, stderr = runTestCli("", "show", strconv.Itoa(added.Id))
+	if code != exitOk {
+		t.Fatalf("show exited with %v: %v", code, stderr)
+	}
+	if !strings.Contains(stdout, "9788437604947") {
+		t.Errorf("show output missing unhyphenated ISBN, got:\n%v", stdout)
+	}
+	if !strings.Contains(stderr, "ISBN 9788437604947 printed without hyphens") ||
+		!strings.Contains(stderr, "978-84") {
+		t.Errorf("show didn't report the unhyphenated ISBN, got stderr:\n%v", stderr)
+	}
+}
+
+func TestIsbnParts(t *testing.T) {
+	isbn := ISBN("9781433554032")
+	expected := IsbnParts{
+		Prefix:      "978",
+		Group:       "1",
+		Registrant:  "4335",
+		Publication: "5403",
+		Check:       "2",
+	}
+
+	parts, err := isbn.isbnParts()
+	if err != nil {
+		t.Errorf("isbnParts returned error for ISBN %v: %v", string(isbn), err)
+	}
+	if parts != expected {
+		t.Errorf("isbnParts returned unexpected value. Expected %#v, got %#v",
+			expected, parts)
+	}
+}
+
+// writeTestIsbnRangesFile writes a range file defining only 978-99936, and
+// restores the embedded range data after the test.
+func writeTestIsbnRangesFile(t *testing.T) string {
+	rangeFile := filepath.Join(t.TempDir(), "RangeMessage.xml")
+	rangeXml := `<?xml version="1.0" encoding="utf-8"?>
+<ISBNRangeMessage>
+  <MessageDate>Mon, 2 Oct 2023 12:00:00 GMT</MessageDate>
+  <EAN.UCCPrefixes>
+    <EAN.UCC>
+      <Prefix>978</Prefix>
+      <Rules>
+        <Rule><Range>0000000-5999999</Range><Length>1</Length></Rule>
+        <Rule><Range>9990000-9999999</Range><Length>5</Length></Rule>
+      </Rules>
+    </EAN.UCC>
+  </EAN.UCCPrefixes>
+  <RegistrationGroups>
+    <Group>
+      <Prefix>978-99936</Prefix>
+      <Rules>
+        <Rule><Range>0000000-0999999</Range><Length>1</Length></Rule>
+      </Rules>
+    </Group>
+  </RegistrationGroups>
+</ISBNRangeMessage>
+`
+	if err := os.WriteFile(rangeFile, []byte(rangeXml), 0o644); err != nil {
+		t.Fatalf("Couldn't write range file: %v", err)
+	}
+
+	// restore the embedded range data afterwards
+	t.Cleanup(func() {
+		isbnRangesMu.Lock()
+		isbnRanges = nil
+		isbnRangesMu.Unlock()
+	})
+	return rangeFile
+}
+
+func TestLoadIsbnRangesFile(t *testing.T) {
+	rangeFile := writeTestIsbnRangesFile(t)
+	if err := loadIsbnRangesFile(rangeFile); err != nil {
+		t.Fatalf("loadIsbnRangesFile returned error: %v", err)
+	}
+
+	isbn := ISBN("9789993600015")
+	expected := "978-99936-0-001-5"
+	h, err := isbn.hyphenated()
+	if err != nil {
+		t.Errorf("hyphenated returned error after loading range file: %v", err)
+	}
+	if h != expected {
+		t.Errorf("hyphenated returned unexpected value. Expected %v, got %v",
+			expected, h)
+	}
+}
+
+func TestCliIsbnRanges(t *testing.T) {
+	rangeFile := writeTestIsbnRangesFile(t)
+
+	code, _, stderr := runTestCli("", "--isbn-ranges", rangeFile, "list")
+	if code != exitOk {
+		t.Errorf("list with --isbn-ranges returned %v: %v", code, stderr)
+	}
+	if h := ISBN("9789993600015").String(); h != "978-99936-0-001-5" {
+		t.Errorf("ISBN not hyphenated with ranges given by --isbn-ranges, got %v", h)
+	}
+
+	missing := filepath.Join(t.TempDir(), "none.xml")
+	code, _, stderr = runTestCli("", "--isbn-ranges", missing, "list")
+	if code != exitError || !strings.Contains(stderr, "none.xml") {
+		t.Errorf("Missing --isbn-ranges file returned %v: %v", code, stderr)
+	}
+}
+
+func TestLoadIsbnRangesFileInvalid(t *testing.T) {
+	rangeFile := filepath.Join(t.TempDir(), "RangeMessage.xml")
+	if err := os.WriteFile(rangeFile, []byte("not xml"), 0o644); err != nil {
+		t.Fatalf("Couldn't write range file: %v", err)
+	}
+
+	if err := loadIsbnRangesFile(rangeFile); err == nil {
+		t.Errorf("loadIsbnRangesFile did not return error for invalid file")
+	}
+
+	// the embedded data should still be in use
+	isbn := ISBN("9781433554032")
+	if isbn.String() != "978-1-4335-5403-2" {
+		t.Errorf("Range data changed after failed load, got %v", isbn.String())
+	}
+}
+
+func TestFirstIsbn(t *testing.T) {
+	tests := map[string]ISBN{
+		"":                                 "",
+		"978-1-4335-5307-3":                "9781433553073",
+		"9781433553073 (hbk.); 1433553074": "9781433553073",
+		"1234, 0-85111-723-6":              "9780851117232",
+		"0-85111-723-6 9781433553073":      "9780851117232",
+	}
+	for s, expected := range tests {
+		isbn, err := firstIsbn(s)
+		if err != nil {
+			t.Errorf("firstIsbn(%q) returned error: %v", s, err)
+		}
+		if isbn != expected {
+			t.Errorf("firstIsbn(%q) returned unexpected value. Expected %v, got %v",
+				s, expected, isbn)
+		}
+	}
+
+	var isbnErr *InvalidIsbnError
+	if _, err := firstIsbn("1234; 5678"); !errors.As(err, &isbnErr) {
+		t.Errorf("firstIsbn with no valid ISBN: expected InvalidIsbnError, got %v", err)
+	}
+}
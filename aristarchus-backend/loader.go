@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// bookSelect selects the columns of books read by scanBook, with the names of
+// their publishers and series.
+const bookSelect = `
+        SELECT books.book_id, title, subtitle, year, edition,
+        edition_description, publishers.name, isbn, series.series_name,
+        purchased_date, rating
+        FROM books
+        INNER JOIN publishers
+          ON books.publisher_id = publishers.publisher_id
+        LEFT JOIN series
+          ON books.series_id = series.series_id`
+
+// loadBatchSize is the most ids put in a single IN (...) list, keeping well
+// within SQLite's limit on the number of parameters in a statement.
+const loadBatchSize = 500
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanBook reads a book from a row selected with bookSelect. The contributors
+// and statuses are left to the caller.
+func scanBook(row rowScanner) (Book, error) {
+	var b Book
+	var subtitle sql.NullString
+	var seriesName sql.NullString
+	var edition sql.NullInt64
+	var editionDesc sql.NullString
+	var isbn sql.NullString
+	var purDate sql.NullString
+	var rating sql.NullFloat64
+
+	if err := row.Scan(&b.id, &b.title, &subtitle, &b.year, &edition,
+		&editionDesc, &b.publisher, &isbn, &seriesName, &purDate,
+		&rating); err != nil {
+		return Book{}, err
+	}
+
+	if subtitle.Valid {
+		b.subtitle = subtitle.String
+	}
+	if seriesName.Valid {
+		b.series = seriesName.String
+	}
+	if edition.Valid {
+		b.edition.number = int(edition.Int64)
+	}
+	if editionDesc.Valid {
+		b.edition.description = editionDesc.String
+	}
+	if isbn.Valid {
+		// Rows stored before ISBNs were validated may hold hyphenated or
+		// invalid values. Normalise those that parse, and keep the rest as they
+		// are so that the book can still be read.
+		canonical, err := parseIsbn(isbn.String)
+		if err != nil {
+			b.isbn = ISBN(isbn.String)
+		} else {
+			b.isbn = canonical
+		}
+	}
+	if purDate.Valid {
+		b.purchased.setDate(purDate.String)
+	}
+	if rating.Valid {
+		var err error
+		b.rating, err = newRating(rating.Float64)
+		if err != nil {
+			return Book{}, fmt.Errorf("scanBook, book #%v: %w", b.id, err)
+		}
+	}
+	return b, nil
+}
+
+// loadAllBooks gives every book in the library, in id order. Unlike calling
+// getBookById for each book, it takes the same three queries however many
+// books there are.
+func loadAllBooks(db DBInterface) ([]Book, error) {
+	books, byId, err := loadBookRows(db, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("loadAllBooks: %w", err)
+	}
+	if err := loadBookDetails(db, byId, "", nil); err != nil {
+		return nil, fmt.Errorf("loadAllBooks: %w", err)
+	}
+
+	bookList := make([]Book, len(books))
+	for i, b := range books {
+		bookList[i] = *b
+	}
+	return bookList, nil
+}
+
+// loadBooks gives the books with the given ids, in the order given. The books
+// are fetched in batches of loadBatchSize, with three queries for each batch.
+func loadBooks(db DBInterface, ids []int) ([]Book, error) {
+	bookList := make([]Book, 0, len(ids))
+	err := eachBook(db, ids, func(b Book) error {
+		bookList = append(bookList, b)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loadBooks: %w", err)
+	}
+	return bookList, nil
+}
+
+// eachBook calls fn with each of the books with the given ids, in the order
+// given, stopping at the first error. Books are loaded a batch of
+// loadBatchSize at a time, so only one batch is held in memory however many
+// books there are.
+func eachBook(db DBInterface, ids []int, fn func(Book) error) error {
+	for start := 0; start < len(ids); start += loadBatchSize {
+		batch := ids[start:min(start+loadBatchSize, len(ids))]
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+
+		_, byId, err := loadBookRows(db,
+			"WHERE books.book_id IN ("+placeholders+")", args)
+		if err != nil {
+			return fmt.Errorf("eachBook: %w", err)
+		}
+		err = loadBookDetails(db, byId,
+			"WHERE book_id IN ("+placeholders+")", args)
+		if err != nil {
+			return fmt.Errorf("eachBook: %w", err)
+		}
+
+		for _, id := range batch {
+			b, ok := byId[id]
+			if !ok {
+				return &InvalidBookIdError{"eachBook", id}
+			}
+			if err := fn(*b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadBookRows reads the books matching a WHERE clause, giving them in id
+// order and by id.
+func loadBookRows(db DBInterface, where string, args []any) ([]*Book, map[int]*Book, error) {
+	rows, err := db.Query(bookSelect+"\n        "+where+"\n        ORDER BY books.book_id",
+		args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loadBookRows: %v", err)
+	}
+	defer rows.Close()
+
+	var books []*Book
+	byId := map[int]*Book{}
+	for rows.Next() {
+		b, err := scanBook(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loadBookRows: %w", err)
+		}
+		books = append(books, &b)
+		byId[b.id] = &b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("loadBookRows, rows.Next() error: %v", err)
+	}
+	return books, byId, nil
+}
+
+// loadBookDetails fills in the contributors and statuses of books, matching
+// their book_id columns with a WHERE clause.
+func loadBookDetails(db DBInterface, books map[int]*Book, where string, args []any) error {
+	rows, err := db.Query(`
+        SELECT book_id, role, people.name
+        FROM book_contributor
+        INNER JOIN people
+          ON book_contributor.person_id = people.person_id
+        `+where+`
+        ORDER BY book_id, role, position`, args...)
+	if err != nil {
+		return fmt.Errorf("loadBookDetails: %v", err)
+	}
+	defer rows.Close()
+
+	names := map[int]map[ContributorRole][]string{}
+	for rows.Next() {
+		var id int
+		var role ContributorRole
+		var name string
+		if err := rows.Scan(&id, &role, &name); err != nil {
+			return fmt.Errorf("loadBookDetails: %v", err)
+		}
+		if names[id] == nil {
+			names[id] = map[ContributorRole][]string{}
+		}
+		names[id][role] = append(names[id][role], name)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadBookDetails, rows.Next() error: %v", err)
+	}
+	rows.Close()
+
+	for id, roles := range names {
+		b, ok := books[id]
+		if !ok {
+			continue
+		}
+		for role, nameList := range roles {
+			if field := b.contributors(role); field != nil {
+				*field = formatNameList(nameList)
+			}
+		}
+	}
+
+	rows, err = db.Query(`
+        SELECT book_id, status.status_name
+        FROM book_status
+        INNER JOIN status
+          ON book_status.status_id = status.status_id
+        `+where+`
+        ORDER BY book_id, status.status_name`, args...)
+	if err != nil {
+		return fmt.Errorf("loadBookDetails: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return fmt.Errorf("loadBookDetails: %v", err)
+		}
+		if b, ok := books[id]; ok {
+			b.status = append(b.status, status)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("loadBookDetails, rows.Next() error: %v", err)
+	}
+	return nil
+}
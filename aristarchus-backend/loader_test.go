@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLoadAllBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	books, err := loadAllBooks(db)
+	if err != nil {
+		t.Fatalf("Problem loading all books: %v", err)
+	}
+	if len(books) != 6 {
+		t.Fatalf("Expected 6 books, got %v", len(books))
+	}
+	for i, b := range books {
+		expected, err := getBookById(db, i+1)
+		if err != nil {
+			t.Errorf("Problem getting book #%v: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(b, expected) {
+			t.Errorf("loadAllBooks gave a different book #%v: expected %#v, got %#v",
+				i+1, expected, b)
+		}
+	}
+}
+
+func TestLoadBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	ids := []int{5, 3, 6, 5}
+	books, err := loadBooks(db, ids)
+	if err != nil {
+		t.Fatalf("Problem loading books %v: %v", ids, err)
+	}
+	if len(books) != len(ids) {
+		t.Fatalf("Expected %v books, got %v", len(ids), len(books))
+	}
+	for i, id := range ids {
+		expected, err := getBookById(db, id)
+		if err != nil {
+			t.Errorf("Problem getting book #%v: %v", id, err)
+		}
+		if !reflect.DeepEqual(books[i], expected) {
+			t.Errorf("loadBooks gave a different book #%v at index %v: expected %#v, got %#v",
+				id, i, expected, books[i])
+		}
+	}
+}
+
+func TestLoadBooksBatches(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var ids []int
+	for len(ids) < 2*loadBatchSize+10 {
+		ids = append(ids, len(ids)%6+1)
+	}
+	books, err := loadBooks(db, ids)
+	if err != nil {
+		t.Fatalf("Problem loading %v books: %v", len(ids), err)
+	}
+	if len(books) != len(ids) {
+		t.Fatalf("Expected %v books, got %v", len(ids), len(books))
+	}
+	for i, id := range ids {
+		if books[i].id != id {
+			t.Fatalf("Wrong book at index %v: expected #%v, got #%v", i, id,
+				books[i].id)
+		}
+	}
+}
+
+func TestLoadBooksInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = loadBooks(db, []int{1, 17})
+	var idErr *InvalidBookIdError
+	if !errors.As(err, &idErr) {
+		t.Errorf("Expected InvalidBookIdError loading book #17, got %v", err)
+	} else if idErr.BookId != 17 {
+		t.Errorf("Wrong id in InvalidBookIdError: expected 17, got %v", idErr.BookId)
+	}
+}
+
+func TestLoadBooksEmpty(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	books, err := loadBooks(db, nil)
+	if err != nil {
+		t.Errorf("Problem loading no books: %v", err)
+	}
+	if len(books) != 0 {
+		t.Errorf("Expected no books, got %v", books)
+	}
+}
+
+func TestEachBookStops(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	stop := errors.New("stop")
+	var ids []int
+	err = eachBook(db, []int{2, 4, 6}, func(b Book) error {
+		ids = append(ids, b.id)
+		if b.id == 4 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("Expected the error from fn, got %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int{2, 4}) {
+		t.Errorf("Expected books 2 and 4 before stopping, got %v", ids)
+	}
+}
+
+// getBooksFanOut gets books with getBookById from a number of goroutines at
+// once, for comparison with loadBooks.
+func getBooksFanOut(db *sql.DB, ids []int, workers int) ([]Book, error) {
+	books := make([]Book, len(ids))
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(ids); i += workers {
+				b, err := getBookById(db, ids[i])
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				books[i] = b
+			}
+		}(w)
+	}
+	wg.Wait()
+	return books, errors.Join(errs...)
+}
+
+func BenchmarkLoadLibrary(b *testing.B) {
+	b.Run("getBookById", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ids, err := getListOfBookIDs(db)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, id := range ids {
+				if _, err := getBookById(db, id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("fan-out", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			ids, err := getListOfBookIDs(db)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := getBooksFanOut(db, ids, 8); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("loadAllBooks", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := loadAllBooks(db); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("loadBooks", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		ids, err := getListOfBookIDs(db)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := loadBooks(db, ids); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// countingDB counts the queries run through it.
+type countingDB struct {
+	DBInterface
+	queries int
+}
+
+func (c *countingDB) Exec(query string, args ...any) (sql.Result, error) {
+	c.queries++
+	return c.DBInterface.Exec(query, args...)
+}
+
+func (c *countingDB) Query(query string, args ...any) (*sql.Rows, error) {
+	c.queries++
+	return c.DBInterface.Query(query, args...)
+}
+
+func (c *countingDB) QueryRow(query string, args ...any) *sql.Row {
+	c.queries++
+	return c.DBInterface.QueryRow(query, args...)
+}
+
+func TestGetBookByIdQueries(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// the book, its contributors and its statuses
+	counter := &countingDB{DBInterface: db}
+	b, err := getBookById(counter, 5)
+	if err != nil {
+		t.Fatalf("getBookById returned error: %v", err)
+	}
+	if counter.queries != 3 {
+		t.Errorf("getBookById ran %v queries, expected 3", counter.queries)
+	}
+	if b.author != "Peter J. Gentry and Stephen J. Wellum" {
+		t.Errorf("getBookById returned unexpected author %v", b.author)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// MetadataProvider looks up bibliographic details of a book from an outside
+// source, such as a library catalogue, so that they don't need to be typed in
+// by hand. The returned Book has no id or status, which are for the caller to
+// decide.
+type MetadataProvider interface {
+	LookupIsbn(isbn ISBN) (Book, error)
+	LookupTitle(title string, author string) (Book, error)
+}
+
+type MetadataNotFoundError struct {
+	CallFunc string
+	Query    string
+}
+
+func (e *MetadataNotFoundError) Error() string {
+	return fmt.Sprintf("%v: No book found for %v", e.CallFunc, e.Query)
+}
+
+type AddCancelledError struct {
+	CallFunc string
+	Isbn     ISBN
+}
+
+func (e *AddCancelledError) Error() string {
+	return fmt.Sprintf("%v: Adding book with ISBN %v was cancelled", e.CallFunc,
+		e.Isbn)
+}
+
+// mergeBook fills in a book from fetched metadata, with any field which is set
+// in overrides taking precedence over the fetched value.
+func mergeBook(fetched Book, overrides Book) Book {
+	b := fetched
+	if len(overrides.author) > 0 {
+		b.author = overrides.author
+	}
+	if len(overrides.editor) > 0 {
+		b.editor = overrides.editor
+	}
+	if len(overrides.translator) > 0 {
+		b.translator = overrides.translator
+	}
+	if len(overrides.illustrator) > 0 {
+		b.illustrator = overrides.illustrator
+	}
+	if len(overrides.foreword) > 0 {
+		b.foreword = overrides.foreword
+	}
+	if len(overrides.compiler) > 0 {
+		b.compiler = overrides.compiler
+	}
+	if len(overrides.title) > 0 {
+		b.title = overrides.title
+	}
+	if len(overrides.subtitle) > 0 {
+		b.subtitle = overrides.subtitle
+	}
+	if overrides.year != 0 {
+		b.year = overrides.year
+	}
+	if !overrides.edition.isZero() {
+		b.edition = overrides.edition
+	}
+	if len(overrides.publisher) > 0 {
+		b.publisher = overrides.publisher
+	}
+	if len(overrides.isbn) > 0 {
+		b.isbn = overrides.isbn
+	}
+	if len(overrides.series) > 0 {
+		b.series = overrides.series
+	}
+	if len(overrides.status) > 0 {
+		b.status = overrides.status
+	}
+	if overrides.purchased != (PurchasedDate{}) {
+		b.purchased = overrides.purchased
+	}
+	if overrides.rating.rated {
+		b.rating = overrides.rating
+	}
+	return b
+}
+
+// addBookByIsbn looks up a book's details from the metadata provider, merges
+// in any fields given in overrides, and adds the result to the database. If
+// confirm is not nil, it is called with the merged book before it is added, and
+// can change any of its fields; returning false cancels the addition.
+func addBookByIsbn(db *sql.DB, provider MetadataProvider, isbn string,
+	overrides Book, confirm func(b *Book) bool) (int, error) {
+	bookIsbn, err := parseIsbn(isbn)
+	if err != nil {
+		return 0, fmt.Errorf("addBookByIsbn: %w", err)
+	}
+	if len(bookIsbn) == 0 {
+		return 0, &InvalidIsbnError{"addBookByIsbn", isbn, "no ISBN given"}
+	}
+
+	fetched, err := provider.LookupIsbn(bookIsbn)
+	if err != nil {
+		return 0, fmt.Errorf("addBookByIsbn, Couldn't look up ISBN %v: %w",
+			bookIsbn, err)
+	}
+	fetched.isbn = bookIsbn
+
+	b := mergeBook(fetched, overrides)
+	if confirm != nil && !confirm(&b) {
+		return 0, &AddCancelledError{"addBookByIsbn", bookIsbn}
+	}
+
+	id, err := addBook(db, &b)
+	if err != nil {
+		return id, fmt.Errorf("addBookByIsbn: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergeBook(t *testing.T) {
+	fetched := makeLookupBook()
+	overrides := Book{
+		subtitle: "A Theology of the Hebrew Bible (NSBT 15)",
+		series:   "New Studies in Biblical Theology",
+		status:   []string{"Want"},
+	}
+
+	expected := fetched
+	expected.subtitle = overrides.subtitle
+	expected.series = overrides.series
+	expected.status = overrides.status
+
+	merged := mergeBook(fetched, overrides)
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("mergeBook returned unexpected book. Expected %v, got %v",
+			expected, merged)
+	}
+}
+
+func TestAddBookByIsbn(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	server := newFakeOpenLibrary(makeLookupBook())
+	defer server.Close()
+	client := newOpenLibraryClient(server.URL)
+
+	expected := makeLookupBook()
+	expected.status = []string{"Want"}
+	expected.series = "New Studies in Biblical Theology"
+
+	var confirmed Book
+	id, err := addBookByIsbn(db, client, "0-85111-285-4",
+		Book{status: []string{"Want"}},
+		func(b *Book) bool {
+			confirmed = *b
+			b.series = "New Studies in Biblical Theology"
+			return true
+		})
+	if err != nil {
+		t.Fatalf("addBookByIsbn returned error: %v", err)
+	}
+	defer deleteBook(db, id)
+
+	if confirmed.title != expected.title || !reflect.DeepEqual(confirmed.status, expected.status) {
+		t.Errorf("confirm was not given merged book, got %v", confirmed)
+	}
+
+	expected.id = id
+	b, err := getBookById(db, id)
+	if err != nil {
+		t.Errorf("Couldn't get added book: %v", err)
+	}
+	if !reflect.DeepEqual(b, expected) {
+		t.Errorf("addBookByIsbn added unexpected book. Expected %v, got %v",
+			expected, b)
+	}
+}
+
+func TestAddBookByIsbnCancelled(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	server := newFakeOpenLibrary(makeLookupBook())
+	defer server.Close()
+	client := newOpenLibraryClient(server.URL)
+
+	before, err := countAllBooks(db)
+	if err != nil {
+		t.Errorf("Couldn't count books: %v", err)
+	}
+
+	_, err = addBookByIsbn(db, client, "9780851112855", Book{},
+		func(b *Book) bool { return false })
+	if err == nil {
+		t.Errorf("addBookByIsbn did not return error when cancelled")
+	} else {
+		var cancelErr *AddCancelledError
+		if !errors.As(err, &cancelErr) {
+			t.Errorf("addBookByIsbn returned unexpected error: %v", err)
+		}
+	}
+
+	after, err := countAllBooks(db)
+	if err != nil {
+		t.Errorf("Couldn't count books: %v", err)
+	}
+	if after != before {
+		t.Errorf("Cancelled addBookByIsbn changed number of books from %v to %v",
+			before, after)
+	}
+}
+
+func TestAddBookByIsbnNotFound(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	server := newFakeOpenLibrary()
+	defer server.Close()
+	client := newOpenLibraryClient(server.URL)
+
+	_, err = addBookByIsbn(db, client, "9780851112855", Book{}, nil)
+	if err == nil {
+		t.Errorf("addBookByIsbn did not return error for unknown ISBN")
+	} else {
+		var notFoundErr *MetadataNotFoundError
+		if !errors.As(err, &notFoundErr) {
+			t.Errorf("addBookByIsbn returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestAddBookByIsbnInvalidIsbn(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	server := newFakeOpenLibrary()
+	defer server.Close()
+	client := newOpenLibraryClient(server.URL)
+
+	for _, isbn := range []string{"", "978-0-85111-285-4"} {
+		_, err = addBookByIsbn(db, client, isbn, Book{}, nil)
+		if err == nil {
+			t.Errorf("addBookByIsbn did not return error for invalid ISBN %q", isbn)
+		} else {
+			var invIsbnErr *InvalidIsbnError
+			if !errors.As(err, &invIsbnErr) {
+				t.Errorf("addBookByIsbn returned unexpected error: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The schema is built up by numbered migrations in the migrations directory,
+// named e.g. 0004_edition_description.sql, which are embedded in the binary.
+// The schema_version table records each migration applied to a database, and
+// the library database is brought up to date whenever it is opened, so that
+// schema changes never need the database recreating.
+//
+// Migrations are run in a single transaction, so a database is either brought
+// fully up to date or left as it was. They mustn't have transactions of their
+// own. A new schema change needs a migration numbered one after the last, with
+// the same change made to db/setup_books_db.sql and db/init_test_database.sql,
+// and the version recorded there updated.
+//
+// PostgreSQL databases have their own migrations, in migrations/postgres,
+// sharing the version numbers. They start at version 10, the first with
+// PostgreSQL support, which creates the whole schema as it was then, so each
+// schema change after that needs a PostgreSQL migration of the same number.
+
+//go:embed migrations/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string // e.g. "edition_description"
+	sql     string
+}
+
+// migrations are the schema migrations for each dialect, in order of version.
+var migrations = map[Dialect][]migration{
+	SQLite:   mustLoadMigrations(migrationFiles, "migrations", 1),
+	Postgres: mustLoadMigrations(migrationFiles, "migrations/postgres", 10),
+}
+
+// legacySchemaMarkers are queries which count something added by each
+// migration, by version. Databases from before schema_version was introduced
+// were brought up to date with the same migrations as scripts, run by hand,
+// and are at the version before the first whose marker isn't found.
+var legacySchemaMarkers = []string{
+	1:  tableMarker("books"),
+	2:  tableMarker("status"),
+	3:  tableMarker("status_history"),
+	4:  columnMarker("books", "edition_description"),
+	5:  tableMarker("book_note"),
+	6:  tableMarker("book_search"),
+	7:  tableMarker("category"),
+	8:  tableMarker("book_contributor"),
+	9:  schemaObjectMarker("trigger", "book_search_contributor_update"),
+	10: columnMarker("publishers", "location"),
+	11: columnMarker("books", "citation_key"),
+}
+
+func schemaObjectMarker(kind, name string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) FROM sqlite_master WHERE type = '%v' AND name = '%v'`,
+		kind, name)
+}
+
+func tableMarker(table string) string {
+	return schemaObjectMarker("table", table)
+}
+
+func columnMarker(table, column string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) FROM pragma_table_info('%v') WHERE name = '%v'`,
+		table, column)
+}
+
+type MigrationError struct {
+	CallFunc string
+	Version  int
+	Name     string
+	Err      error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("%v: Migration %04d_%v failed, database left unchanged: %v",
+		e.CallFunc, e.Version, e.Name, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+type SchemaTooNewError struct {
+	CallFunc string
+	Version  int
+	Latest   int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("%v: Library database has schema version %v, but this version "+
+		"of aristarchus only knows up to version %v. Please upgrade aristarchus.",
+		e.CallFunc, e.Version, e.Latest)
+}
+
+type Fts5MissingError struct {
+	CallFunc string
+}
+
+func (e *Fts5MissingError) Error() string {
+	return fmt.Sprintf("%v: This build of aristarchus has SQLite without FTS5, which the "+
+		"library's search index needs. Please rebuild it with go build -tags sqlite_fts5.",
+		e.CallFunc)
+}
+
+// checkFts5 returns an Fts5MissingError if SQLite lacks FTS5, which go-sqlite3
+// only builds it with given the sqlite_fts5 tag. Without FTS5 the migration
+// creating the search index fails, as do the triggers keeping it up to date on
+// every change to a book, so this is checked before a SQLite library is used.
+// PostgreSQL has its own full-text search, and doesn't need the tag.
+func checkFts5(db DBInterface) error {
+	var enabled int
+	err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	if err != nil {
+		return fmt.Errorf("checkFts5, Couldn't read SQLite compile options: %v", err)
+	}
+	if enabled == 0 {
+		return &Fts5MissingError{"checkFts5"}
+	}
+	return nil
+}
+
+// loadMigrations reads the migrations from a directory of fsys, checking that
+// they are numbered from first without gaps.
+func loadMigrations(fsys fs.FS, dir string, first int) ([]migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("loadMigrations: %v", err)
+	}
+	var ms []migration
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !found || err != nil || len(name) == 0 {
+			return nil, fmt.Errorf("loadMigrations, Migration %v isn't named as "+
+				"e.g. 0001_name.sql", file)
+		}
+		text, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("loadMigrations: %v", err)
+		}
+		ms = append(ms, migration{version, name, string(text)})
+	}
+	slices.SortFunc(ms, func(a, b migration) int { return a.version - b.version })
+	for i, m := range ms {
+		if m.version != first+i {
+			return nil, fmt.Errorf("loadMigrations, Expected migration %v, got %04d_%v",
+				first+i, m.version, m.name)
+		}
+	}
+	return ms, nil
+}
+
+func mustLoadMigrations(fsys fs.FS, dir string, first int) []migration {
+	ms, err := loadMigrations(fsys, dir, first)
+	if err != nil {
+		panic(err)
+	}
+	return ms
+}
+
+// latestSchemaVersion gives the version of the schema this binary uses for a
+// dialect.
+func latestSchemaVersion(d Dialect) int {
+	ms := migrations[d]
+	if len(ms) == 0 {
+		return 0
+	}
+	return ms[len(ms)-1].version
+}
+
+// schemaVersion gives the version of a database's schema, 0 for an empty
+// database.
+func schemaVersion(db DBInterface) (int, error) {
+	d := dialectOf(db)
+	marker := tableMarker("schema_version")
+	if d == Postgres {
+		marker = `SELECT COUNT(*) FROM information_schema.tables
+                  WHERE table_schema = current_schema() AND table_name = 'schema_version'`
+	}
+	var tables int
+	err := db.QueryRow(marker).Scan(&tables)
+	if err != nil {
+		return 0, fmt.Errorf("schemaVersion: %v", err)
+	}
+	if tables == 0 && d == Postgres {
+		return 0, nil
+	}
+	if tables == 0 {
+		version, err := legacySchemaVersion(db)
+		if err != nil {
+			return 0, fmt.Errorf("schemaVersion: %v", err)
+		}
+		return version, nil
+	}
+
+	var version sql.NullInt64
+	err = db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("schemaVersion: %v", err)
+	}
+	return int(version.Int64), nil
+}
+
+// legacySchemaVersion gives the version of an SQLite database without a
+// schema_version table.
+func legacySchemaVersion(db DBInterface) (int, error) {
+	for version := 1; version < len(legacySchemaMarkers); version++ {
+		var count int
+		err := db.QueryRow(legacySchemaMarkers[version]).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("legacySchemaVersion: %v", err)
+		}
+		if count == 0 {
+			return version - 1, nil
+		}
+	}
+	return len(legacySchemaMarkers) - 1, nil
+}
+
+// migrateDatabase brings a database's schema up to date, giving the versions
+// it was at before and after. A database newer than this binary is refused
+// with a SchemaTooNewError, and a SQLite database when this binary was built
+// without FTS5 with an Fts5MissingError.
+func migrateDatabase(db *sql.DB) (from int, to int, err error) {
+	tx, err := beginTx(db)
+	if err != nil {
+		return 0, 0, fmt.Errorf("migrateDatabase, Couldn't start sql transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if tx.dialect == SQLite {
+		if err := checkFts5(tx); err != nil {
+			return 0, 0, fmt.Errorf("migrateDatabase: %w", err)
+		}
+	}
+
+	from, err = schemaVersion(tx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("migrateDatabase: %w", err)
+	}
+	latest := latestSchemaVersion(tx.dialect)
+	if from > latest {
+		return from, from, &SchemaTooNewError{"migrateDatabase", from, latest}
+	}
+	if from == latest {
+		return from, from, nil
+	}
+
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+                      version INTEGER PRIMARY KEY,
+                      name TEXT NOT NULL,
+                      applied_at TEXT NOT NULL)`)
+	if err != nil {
+		return from, from, fmt.Errorf("migrateDatabase: %v", err)
+	}
+	appliedAt := clock().UTC().Format(timestampFormat)
+	// versions reached by hand before schema_version are recorded as applied
+	// now, so that every version up to the current one has a row
+	for _, m := range migrations[tx.dialect] {
+		if m.version > from {
+			if _, err := tx.Exec(m.sql); err != nil {
+				return from, from, &MigrationError{"migrateDatabase", m.version, m.name, err}
+			}
+		}
+		_, err = tx.Exec(`INSERT INTO schema_version (version, name, applied_at)
+                          VALUES (?, ?, ?)
+                          ON CONFLICT DO NOTHING`, m.version, m.name, appliedAt)
+		if err != nil {
+			return from, from, fmt.Errorf("migrateDatabase: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return from, from, fmt.Errorf("migrateDatabase, Couldn't commit migrations: %v", err)
+	}
+	return from, latest, nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// openTempDatabase opens a new, empty database which is removed after the
+// test, giving its path or URL.
+func openTempDatabase(t *testing.T) (*sql.DB, string) {
+	path := filepath.Join(t.TempDir(), "library.sqlite")
+	if testDialect == Postgres {
+		path = tempPostgresDatabase(t)
+	}
+	db, err := sql.Open(testDriver, path)
+	if err != nil {
+		t.Fatalf("Problem opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+// describeSchema lists the tables, views, indexes and triggers of an SQLite
+// database, with the columns of each table. Columns are sorted by name, as those added by
+// migrations come after the others.
+func describeSchema(t *testing.T, db *sql.DB) []string {
+	rows, err := db.Query(`SELECT type, name FROM sqlite_master
+                           WHERE name NOT LIKE 'sqlite_%' ORDER BY type, name`)
+	if err != nil {
+		t.Fatalf("Problem reading schema: %v", err)
+	}
+	var objects, tables []string
+	for rows.Next() {
+		var kind, name string
+		if err := rows.Scan(&kind, &name); err != nil {
+			t.Fatalf("Problem reading schema: %v", err)
+		}
+		objects = append(objects, kind+" "+name)
+		if kind == "table" {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		rows, err := db.Query(`SELECT name, type, "notnull", pk FROM pragma_table_info(?)`,
+			table)
+		if err != nil {
+			t.Fatalf("Problem reading columns of %v: %v", table, err)
+		}
+		for rows.Next() {
+			var name, kind string
+			var notNull, pk int
+			if err := rows.Scan(&name, &kind, &notNull, &pk); err != nil {
+				t.Fatalf("Problem reading columns of %v: %v", table, err)
+			}
+			objects = append(objects, fmt.Sprintf("column %v.%v %v %v %v", table, name,
+				kind, notNull, pk))
+		}
+		rows.Close()
+	}
+	slices.Sort(objects)
+	return objects
+}
+
+func TestLoadMigrations(t *testing.T) {
+	if len(migrations[SQLite]) != 11 || latestSchemaVersion(SQLite) != 11 {
+		t.Errorf("Expected 11 migrations, got %v", len(migrations[SQLite]))
+	}
+	for i, m := range migrations[SQLite] {
+		if m.version != i+1 || strings.Contains(strings.ToUpper(m.sql), "BEGIN TRANSACTION") {
+			t.Errorf("Migration %04d_%v out of order or with its own transaction",
+				m.version, m.name)
+		}
+	}
+	if len(legacySchemaMarkers) != len(migrations[SQLite])+1 {
+		t.Errorf("Expected a legacy schema marker for each of %v migrations, got %v",
+			len(migrations[SQLite]), len(legacySchemaMarkers)-1)
+	}
+
+	// the PostgreSQL migrations start from version 10, and keep up with SQLite
+	postgres := migrations[Postgres]
+	if len(postgres) == 0 || postgres[0].version != 10 ||
+		latestSchemaVersion(Postgres) != latestSchemaVersion(SQLite) {
+		t.Errorf("Expected PostgreSQL migrations from 10 to %v, got %v", latestSchemaVersion(SQLite),
+			postgres)
+	}
+	for _, m := range postgres {
+		if strings.Contains(strings.ToUpper(m.sql), "BEGIN TRANSACTION") {
+			t.Errorf("PostgreSQL migration %04d_%v has its own transaction", m.version, m.name)
+		}
+	}
+	later := fstest.MapFS{"postgres/0010_a.sql": {}, "postgres/0011_b.sql": {}}
+	if ms, err := loadMigrations(later, "postgres", 10); err != nil || len(ms) != 2 {
+		t.Errorf("loadMigrations from version 10: expected 2 migrations, got %v, error %v",
+			ms, err)
+	}
+
+	invalid := []fstest.MapFS{
+		{"migrations/0001_a.sql": {}, "migrations/0003_c.sql": {}},
+		{"migrations/0002_b.sql": {}},
+		{"migrations/0001_a.sql": {}, "migrations/0001_b.sql": {}},
+		{"migrations/first.sql": {}},
+		{"migrations/0001.sql": {}},
+	}
+	for _, fsys := range invalid {
+		if ms, err := loadMigrations(fsys, "migrations", 1); err == nil {
+			t.Errorf("loadMigrations(%v): expected error, got %v", fsys, ms)
+		}
+	}
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	// recorded in UTC, whatever the local time zone
+	appliedAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("NZDT", 13*60*60))
+	clock = func() time.Time { return appliedAt }
+	defer func() { clock = time.Now }()
+
+	db, _ := openTempDatabase(t)
+	from, to, err := migrateDatabase(db)
+	if err != nil || from != 0 || to != 11 {
+		t.Fatalf("migrateDatabase of empty database: expected 0 to 11, got %v to %v, error %v",
+			from, to, err)
+	}
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&count)
+	if err != nil || count != len(migrations[testDialect]) {
+		t.Errorf("Expected a schema_version row for each migration, got %v, error %v", count,
+			err)
+	}
+	var recorded string
+	err = db.QueryRow("SELECT applied_at FROM schema_version WHERE version = 11").
+		Scan(&recorded)
+	if err != nil || recorded != "2024-02-29 20:30:00" {
+		t.Errorf("Expected migration recorded as applied at 2024-02-29 20:30:00 UTC, got %v, "+
+			"error %v", recorded, err)
+	}
+
+	// the migrations give the same schema as the test database and
+	// db/setup_books_db.sql, which are only set up from the migrations for
+	// PostgreSQL
+	if testDialect == SQLite {
+		testDb, err := sql.Open(testDriver, testDatabase)
+		if err != nil {
+			t.Errorf("Problem opening database: %v", err)
+		}
+		defer testDb.Close()
+		expected := describeSchema(t, testDb)
+		if result := describeSchema(t, db); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Migrated schema differs from test database:\nexpected %v\ngot      %v",
+				expected, result)
+		}
+
+		setupPath := filepath.Join(t.TempDir(), "setup.sqlite")
+		cmd := exec.Command("sqlite3", setupPath, "-init", "../db/setup_books_db.sql", ".quit")
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("Problem running setup_books_db.sql: %v", err)
+		}
+		setupDb, err := sql.Open("sqlite3", setupPath)
+		if err != nil {
+			t.Fatalf("Problem opening database: %v", err)
+		}
+		defer setupDb.Close()
+		if result := describeSchema(t, setupDb); !reflect.DeepEqual(result, expected) {
+			t.Errorf("setup_books_db.sql schema differs from test database:\nexpected %v\ngot      %v",
+				expected, result)
+		}
+		if version, err := schemaVersion(setupDb); err != nil || version != 11 {
+			t.Errorf("setup_books_db.sql: expected schema version 11, got %v, error %v", version,
+				err)
+		}
+	}
+
+	// an up to date database is left alone
+	from, to, err = migrateDatabase(db)
+	if err != nil || from != 11 || to != 11 {
+		t.Errorf("migrateDatabase of migrated database: expected 11 to 11, got %v to %v, "+
+			"error %v", from, to, err)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	if testDialect != SQLite {
+		t.Skip("Only SQLite databases predate schema_version")
+	}
+	db, _ := openTempDatabase(t)
+
+	// a database set up before migrations were numbered, and brought up to
+	// date by hand as far as ratings and notes
+	if _, err := db.Exec(migrations[SQLite][0].sql); err != nil {
+		t.Fatalf("Problem setting up initial schema: %v", err)
+	}
+	_, err := db.Exec(`
+        INSERT INTO people (name) VALUES ("Anselm"), ("Thomas Williams");
+        INSERT INTO publishers (name) VALUES ("Hackett");
+        INSERT INTO books (title, year, publisher_id, status)
+        VALUES ("Basic Writings", 2007, 1, "Owned");
+        INSERT INTO book_author VALUES (1, 1);
+        INSERT INTO book_editor VALUES (1, 2);`)
+	if err != nil {
+		t.Fatalf("Problem adding book: %v", err)
+	}
+	for _, m := range migrations[SQLite][1:5] {
+		if _, err := db.Exec(m.sql); err != nil {
+			t.Fatalf("Problem running migration %v: %v", m.name, err)
+		}
+	}
+
+	if version, err := schemaVersion(db); err != nil || version != 5 {
+		t.Errorf("schemaVersion of legacy database: expected 5, got %v, error %v", version,
+			err)
+	}
+	from, to, err := migrateDatabase(db)
+	if err != nil || from != 5 || to != 11 {
+		t.Fatalf("migrateDatabase of legacy database: expected 5 to 11, got %v to %v, "+
+			"error %v", from, to, err)
+	}
+
+	b, err := getBookById(db, 1)
+	if err != nil {
+		t.Fatalf("Problem getting migrated book: %v", err)
+	}
+	if b.author != "Anselm" || b.editor != "Thomas Williams" || b.publisher != "Hackett" ||
+		!reflect.DeepEqual(b.status, []string{"Owned"}) {
+		t.Errorf("Book migrated unexpectedly: %#v", b)
+	}
+	results, err := searchBooks(db, "anselm")
+	if err != nil || len(results) != 1 {
+		t.Errorf("Expected migrated book in search index, got %v, error %v", results, err)
+	}
+}
+
+func TestMigrateTooNewDatabase(t *testing.T) {
+	db, path := openTempDatabase(t)
+	if _, _, err := migrateDatabase(db); err != nil {
+		t.Fatalf("migrateDatabase returned error: %v", err)
+	}
+	_, err := db.Exec(`INSERT INTO schema_version (version, name, applied_at)
+                       VALUES (12, 'future', '2030-01-01 00:00:00')`)
+	if err != nil {
+		t.Fatalf("Problem recording future version: %v", err)
+	}
+
+	var tooNewErr *SchemaTooNewError
+	from, to, err := migrateDatabase(db)
+	if !errors.As(err, &tooNewErr) || tooNewErr.Version != 12 || tooNewErr.Latest != 11 {
+		t.Errorf("migrateDatabase of newer database: expected SchemaTooNewError, got %v", err)
+	}
+	if from != 12 || to != 12 {
+		t.Errorf("migrateDatabase of newer database: expected to stay at 12, got %v to %v",
+			from, to)
+	}
+
+	code, _, stderr := runTestCli("", "--db", path, "list")
+	if code != exitError || !strings.Contains(stderr, "upgrade aristarchus") {
+		t.Errorf("list with newer database: expected exit code %v, got %v: %v", exitError,
+			code, stderr)
+	}
+}
+
+func TestCheckFts5(t *testing.T) {
+	if testDialect != SQLite {
+		t.Skip("Only SQLite needs to be built with FTS5")
+	}
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	if err := checkFts5(db); err != nil {
+		t.Errorf("checkFts5 returned error: %v", err)
+	}
+
+	// the tests themselves need FTS5, so only the error for its absence can be
+	// checked here
+	err = fmt.Errorf("migrateDatabase: %w", &Fts5MissingError{"checkFts5"})
+	if exitCode(err) != exitError || !strings.Contains(err.Error(), "-tags sqlite_fts5") {
+		t.Errorf("Unexpected error for SQLite without FTS5: %v", err)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db, _ := openTempDatabase(t)
+
+	defer func(ms []migration) { migrations[testDialect] = ms }(migrations[testDialect])
+	migrations[testDialect] = append(slices.Clone(migrations[testDialect]), migration{11,
+		"broken", "CREATE TABLE extra (extra_id INTEGER PRIMARY KEY); SELECT * FROM nothing;"})
+
+	var migrationErr *MigrationError
+	from, to, err := migrateDatabase(db)
+	if !errors.As(err, &migrationErr) || migrationErr.Version != 11 {
+		t.Errorf("migrateDatabase with broken migration: expected MigrationError, got %v",
+			err)
+	}
+	if from != 0 || to != 0 {
+		t.Errorf("migrateDatabase with broken migration: expected to stay at 0, got %v to %v",
+			from, to)
+	}
+	countTables := "SELECT COUNT(*) FROM sqlite_master"
+	if testDialect == Postgres {
+		countTables = `SELECT COUNT(*) FROM information_schema.tables
+                       WHERE table_schema = current_schema()`
+	}
+	var tables int
+	if err := db.QueryRow(countTables).Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("Expected database to be left empty, got %v objects, error %v", tables, err)
+	}
+}
+
+func TestCliMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library.sqlite")
+	if testDialect == Postgres {
+		path = tempPostgresDatabase(t)
+	} else if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("Problem creating database: %v", err)
+	}
+
+	code, stdout, stderr := runTestCli("", "--db", path, "add", "--title", "Basic Writings",
+		"--author", "Anselm", "--publisher", "Hackett")
+	if code != exitOk || !strings.Contains(stderr, "from schema version 0 to 11") {
+		t.Errorf("add to empty database: expected migration to version 11, got %v: %v%v",
+			code, stdout, stderr)
+	}
+
+	code, stdout, stderr = runTestCli("", "--db", path, "list")
+	if code != exitOk || len(stderr) != 0 || !strings.Contains(stdout, "Basic Writings") {
+		t.Errorf("list of migrated database: got %v: %v%v", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,66 @@
+-- The schema as it was before migrations were numbered: books with a single
+-- status column, and separate book_author and book_editor tables.
+
+CREATE TABLE people (
+       person_id INTEGER PRIMARY KEY,
+       name TEXT
+);
+
+CREATE TABLE publishers (
+       publisher_id INTEGER PRIMARY KEY,
+       name TEXT
+);
+
+CREATE TABLE series (
+       series_id INTEGER PRIMARY KEY,
+       series_name TEXT
+);
+
+CREATE TABLE books (
+       book_id INTEGER PRIMARY KEY,
+       title TEXT NOT NULL,
+       subtitle TEXT,
+       year INTEGER,
+       edition INTEGER,
+       publisher_id INTEGER,
+       isbn TEXT,
+       series_id INTEGER,
+       status TEXT NOT NULL,
+       purchased_date TEXT,
+       FOREIGN KEY (publisher_id)
+         REFERENCES publishers (publisher_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE,
+       FOREIGN KEY (series_id)
+         REFERENCES series (series_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE book_author (
+       book_id INTEGER,
+       author_id INTEGER,
+       PRIMARY KEY (book_id, author_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (author_id)
+         REFERENCES people (person_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE book_editor (
+       book_id INTEGER,
+       editor_id INTEGER,
+       PRIMARY KEY (book_id, editor_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (editor_id)
+         REFERENCES people (person_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
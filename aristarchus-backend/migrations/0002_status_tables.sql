@@ -0,0 +1,38 @@
+-- Migrate an existing database from the single books.status column to the
+-- status and book_status tables, so that a book can have several statuses.
+-- Each distinct value of books.status becomes a row in status, and each book
+-- is linked to the status it had before.
+CREATE TABLE status (
+       status_id INTEGER PRIMARY KEY,
+       status_name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE book_status (
+       book_id INTEGER,
+       status_id INTEGER,
+       PRIMARY KEY (book_id, status_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+INSERT INTO status (status_name)
+SELECT status
+FROM books
+WHERE status IS NOT NULL
+  AND status != ""
+GROUP BY status
+ORDER BY MIN(book_id);
+
+INSERT INTO book_status (book_id, status_id)
+SELECT books.book_id, status.status_id
+FROM books
+INNER JOIN status
+  ON books.status = status.status_name;
+
+ALTER TABLE books DROP COLUMN status;
@@ -0,0 +1,66 @@
+-- Add the status_transition and status_history tables to a database which
+-- already has the status and book_status tables (see
+-- migrate_status_tables.sql), and set up the default acquisition lifecycle:
+--
+--   Want -> Ordered -> Owned -> Given away
+--   Want -> Owned, Ordered -> Want, Owned -> Lent -> Owned
+--
+-- History is only recorded from this point onwards; existing statuses are
+-- not given history entries.
+CREATE TABLE status_transition (
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       PRIMARY KEY (from_status_id, to_status_id),
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE status_history (
+       history_id INTEGER PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       changed_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+INSERT OR IGNORE INTO status (status_name)
+VALUES
+  ("Want"),
+  ("Ordered"),
+  ("Owned"),
+  ("Lent"),
+  ("Given away");
+
+INSERT INTO status_transition (from_status_id, to_status_id)
+SELECT from_status.status_id, to_status.status_id
+FROM (
+  SELECT "Want" AS from_name, "Ordered" AS to_name
+  UNION ALL SELECT "Want", "Owned"
+  UNION ALL SELECT "Ordered", "Owned"
+  UNION ALL SELECT "Ordered", "Want"
+  UNION ALL SELECT "Owned", "Lent"
+  UNION ALL SELECT "Lent", "Owned"
+  UNION ALL SELECT "Owned", "Given away"
+) AS transitions
+INNER JOIN status AS from_status
+  ON transitions.from_name = from_status.status_name
+INNER JOIN status AS to_status
+  ON transitions.to_name = to_status.status_name;
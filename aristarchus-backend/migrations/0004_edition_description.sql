@@ -0,0 +1,3 @@
+-- Add a column for describing a book's edition, e.g. "revised and expanded
+-- edition", as a complement to the edition number.
+ALTER TABLE books ADD COLUMN edition_description TEXT;
@@ -0,0 +1,16 @@
+-- Add a rating column to books, and the book_note table for timestamped
+-- reading notes, to a database created before they were introduced.
+ALTER TABLE books ADD COLUMN rating REAL CHECK (rating >= 0 AND rating <= 5);
+
+CREATE TABLE book_note (
+       note_id INTEGER PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       page TEXT,
+       note TEXT NOT NULL,
+       created_at TEXT NOT NULL,
+       updated_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
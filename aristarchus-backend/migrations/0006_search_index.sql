@@ -0,0 +1,132 @@
+-- Add the full-text search index to a database created before it was
+-- introduced, and fill it from the existing books.
+
+-- Full-text search index, one row per book with rowid = book_id. It is kept in
+-- sync with the tables it draws on by the triggers below.
+DROP VIEW IF EXISTS book_search_source;
+CREATE VIEW book_search_source AS
+SELECT books.book_id,
+       books.title,
+       COALESCE(books.subtitle, '') AS subtitle,
+       TRIM(
+         COALESCE((SELECT group_concat(people.name, ' ')
+                   FROM book_author
+                   INNER JOIN people
+                     ON book_author.author_id = people.person_id
+                   WHERE book_author.book_id = books.book_id), '')
+         || ' ' ||
+         COALESCE((SELECT group_concat(people.name, ' ')
+                   FROM book_editor
+                   INNER JOIN people
+                     ON book_editor.editor_id = people.person_id
+                   WHERE book_editor.book_id = books.book_id), '')
+       ) AS people,
+       COALESCE(publishers.name, '') AS publisher,
+       COALESCE(series.series_name, '') AS series,
+       COALESCE((SELECT group_concat(COALESCE(book_note.page, '') || ' ' || book_note.note, ' ')
+                 FROM book_note
+                 WHERE book_note.book_id = books.book_id), '') AS notes
+FROM books
+LEFT JOIN publishers
+  ON books.publisher_id = publishers.publisher_id
+LEFT JOIN series
+  ON books.series_id = series.series_id;
+
+DROP TABLE IF EXISTS book_search;
+CREATE VIRTUAL TABLE book_search USING fts5(
+       title,
+       subtitle,
+       people,
+       publisher,
+       series,
+       notes,
+       tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER book_search_books_insert AFTER INSERT ON books BEGIN
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_books_update AFTER UPDATE ON books BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_books_delete AFTER DELETE ON books BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_author_insert AFTER INSERT ON book_author BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_author_delete AFTER DELETE ON book_author BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_editor_insert AFTER INSERT ON book_editor BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_editor_delete AFTER DELETE ON book_editor BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_note_insert AFTER INSERT ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_note_update AFTER UPDATE ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid IN (OLD.book_id, NEW.book_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (OLD.book_id, NEW.book_id);
+END;
+
+CREATE TRIGGER book_search_note_delete AFTER DELETE ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_people_update AFTER UPDATE OF name ON people BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM book_author WHERE author_id = NEW.person_id
+    UNION
+    SELECT book_id FROM book_editor WHERE editor_id = NEW.person_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM book_author WHERE author_id = NEW.person_id
+      UNION
+      SELECT book_id FROM book_editor WHERE editor_id = NEW.person_id);
+END;
+
+CREATE TRIGGER book_search_publishers_update AFTER UPDATE OF name ON publishers BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM books WHERE publisher_id = NEW.publisher_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM books WHERE publisher_id = NEW.publisher_id);
+END;
+
+CREATE TRIGGER book_search_series_update AFTER UPDATE OF series_name ON series BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM books WHERE series_id = NEW.series_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM books WHERE series_id = NEW.series_id);
+END;
+
+INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+  SELECT * FROM book_search_source;
@@ -0,0 +1,26 @@
+-- Add the category tree and book_category tables to a database created before
+-- categories were introduced.
+
+CREATE TABLE category (
+       category_id INTEGER PRIMARY KEY,
+       name TEXT NOT NULL,
+       parent_id INTEGER,
+       FOREIGN KEY (parent_id)
+         REFERENCES category (category_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE book_category (
+       book_id INTEGER,
+       category_id INTEGER,
+       PRIMARY KEY (book_id, category_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (category_id)
+         REFERENCES category (category_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
@@ -0,0 +1,92 @@
+-- Replace the book_author and book_editor tables with book_contributor, which
+-- records each person's role on a book, and rebuild the full-text search view
+-- and triggers that drew on the old tables.
+--
+-- Everyone is copied across as an author or editor, in the order they were
+-- added. Translators and other contributors previously entered as editors
+-- need their role correcting by hand, e.g.
+--
+--   UPDATE book_contributor SET role = 'translator'
+--   WHERE book_id = 3 AND person_id = 8;
+
+CREATE TABLE book_contributor (
+       book_id INTEGER,
+       person_id INTEGER,
+       role TEXT NOT NULL
+         CHECK (role IN ('author', 'editor', 'translator', 'illustrator',
+                         'foreword', 'compiler')),
+       position INTEGER NOT NULL,
+       PRIMARY KEY (book_id, person_id, role),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (person_id)
+         REFERENCES people (person_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+INSERT INTO book_contributor (book_id, person_id, role, position)
+SELECT book_id, author_id, 'author',
+       ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY rowid)
+FROM book_author;
+
+INSERT INTO book_contributor (book_id, person_id, role, position)
+SELECT book_id, editor_id, 'editor',
+       ROW_NUMBER() OVER (PARTITION BY book_id ORDER BY rowid)
+FROM book_editor;
+
+DROP TRIGGER IF EXISTS book_search_author_insert;
+DROP TRIGGER IF EXISTS book_search_author_delete;
+DROP TRIGGER IF EXISTS book_search_editor_insert;
+DROP TRIGGER IF EXISTS book_search_editor_delete;
+DROP TRIGGER IF EXISTS book_search_people_update;
+
+DROP TABLE book_author;
+DROP TABLE book_editor;
+
+DROP VIEW IF EXISTS book_search_source;
+CREATE VIEW book_search_source AS
+SELECT books.book_id,
+       books.title,
+       COALESCE(books.subtitle, '') AS subtitle,
+       COALESCE((SELECT group_concat(people.name, ' ')
+                 FROM book_contributor
+                 INNER JOIN people
+                   ON book_contributor.person_id = people.person_id
+                 WHERE book_contributor.book_id = books.book_id), '') AS people,
+       COALESCE(publishers.name, '') AS publisher,
+       COALESCE(series.series_name, '') AS series,
+       COALESCE((SELECT group_concat(COALESCE(book_note.page, '') || ' ' || book_note.note, ' ')
+                 FROM book_note
+                 WHERE book_note.book_id = books.book_id), '') AS notes
+FROM books
+LEFT JOIN publishers
+  ON books.publisher_id = publishers.publisher_id
+LEFT JOIN series
+  ON books.series_id = series.series_id;
+
+CREATE TRIGGER book_search_contributor_insert AFTER INSERT ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_delete AFTER DELETE ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_people_update AFTER UPDATE OF name ON people BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM book_contributor WHERE person_id = NEW.person_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM book_contributor WHERE person_id = NEW.person_id);
+END;
+
+DELETE FROM book_search;
+INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+  SELECT * FROM book_search_source;
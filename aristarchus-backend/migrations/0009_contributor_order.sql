@@ -0,0 +1,44 @@
+-- Keep the people in the full-text search index in the order they are given
+-- on each book, and refresh a book's entry when its contributors are
+-- reordered.
+
+DROP VIEW IF EXISTS book_search_source;
+CREATE VIEW book_search_source AS
+SELECT books.book_id,
+       books.title,
+       COALESCE(books.subtitle, '') AS subtitle,
+       COALESCE((SELECT group_concat(name, ' ')
+                 FROM (SELECT people.name
+                       FROM book_contributor
+                       INNER JOIN people
+                         ON book_contributor.person_id = people.person_id
+                       WHERE book_contributor.book_id = books.book_id
+                       ORDER BY CASE book_contributor.role
+                                  WHEN 'author' THEN 1
+                                  WHEN 'editor' THEN 2
+                                  WHEN 'translator' THEN 3
+                                  WHEN 'illustrator' THEN 4
+                                  WHEN 'foreword' THEN 5
+                                  ELSE 6
+                                END,
+                                book_contributor.position)), '') AS people,
+       COALESCE(publishers.name, '') AS publisher,
+       COALESCE(series.series_name, '') AS series,
+       COALESCE((SELECT group_concat(COALESCE(book_note.page, '') || ' ' || book_note.note, ' ')
+                 FROM book_note
+                 WHERE book_note.book_id = books.book_id), '') AS notes
+FROM books
+LEFT JOIN publishers
+  ON books.publisher_id = publishers.publisher_id
+LEFT JOIN series
+  ON books.series_id = series.series_id;
+
+CREATE TRIGGER book_search_contributor_update AFTER UPDATE ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid IN (OLD.book_id, NEW.book_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (OLD.book_id, NEW.book_id);
+END;
+
+DELETE FROM book_search;
+INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+  SELECT * FROM book_search_source;
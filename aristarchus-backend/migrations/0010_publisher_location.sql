@@ -0,0 +1,3 @@
+-- Add a column for the place a publisher is based, e.g. "Wheaton, IL", which
+-- citations give before the publisher's name.
+ALTER TABLE publishers ADD COLUMN location TEXT;
@@ -0,0 +1,229 @@
+-- The whole schema as of version 10, the first with PostgreSQL support, with
+-- the default acquisition lifecycle set up as by the SQLite migration
+-- 0003_status_lifecycle.sql. Ids are identity columns in place of SQLite's
+-- INTEGER PRIMARY KEY, and the full-text search index is a view (see
+-- book_search below).
+
+CREATE TABLE people (
+       person_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       name TEXT
+);
+
+CREATE TABLE publishers (
+       publisher_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       name TEXT,
+       location TEXT
+);
+
+CREATE TABLE series (
+       series_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       series_name TEXT
+);
+
+CREATE TABLE books (
+       book_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       title TEXT NOT NULL,
+       subtitle TEXT,
+       year INTEGER,
+       edition INTEGER,
+       edition_description TEXT,
+       publisher_id INTEGER,
+       isbn TEXT,
+       series_id INTEGER,
+       purchased_date TEXT,
+       rating DOUBLE PRECISION CHECK (rating >= 0 AND rating <= 5),
+       FOREIGN KEY (publisher_id)
+         REFERENCES publishers (publisher_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE,
+       FOREIGN KEY (series_id)
+         REFERENCES series (series_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE book_contributor (
+       book_id INTEGER,
+       person_id INTEGER,
+       role TEXT NOT NULL
+         CHECK (role IN ('author', 'editor', 'translator', 'illustrator',
+                         'foreword', 'compiler')),
+       position INTEGER NOT NULL,
+       PRIMARY KEY (book_id, person_id, role),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (person_id)
+         REFERENCES people (person_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE status (
+       status_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       status_name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE book_status (
+       book_id INTEGER,
+       status_id INTEGER,
+       PRIMARY KEY (book_id, status_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE status_transition (
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       PRIMARY KEY (from_status_id, to_status_id),
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE status_history (
+       history_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       changed_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE book_note (
+       note_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       page TEXT,
+       note TEXT NOT NULL,
+       created_at TEXT NOT NULL,
+       updated_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE category (
+       category_id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+       name TEXT NOT NULL,
+       parent_id INTEGER,
+       FOREIGN KEY (parent_id)
+         REFERENCES category (category_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+CREATE TABLE book_category (
+       book_id INTEGER,
+       category_id INTEGER,
+       PRIMARY KEY (book_id, category_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (category_id)
+         REFERENCES category (category_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+INSERT INTO status (status_name)
+VALUES
+  ('Want'),
+  ('Ordered'),
+  ('Owned'),
+  ('Lent'),
+  ('Given away');
+
+INSERT INTO status_transition (from_status_id, to_status_id)
+SELECT from_status.status_id, to_status.status_id
+FROM (
+  VALUES
+    ('Want', 'Ordered'),
+    ('Want', 'Owned'),
+    ('Ordered', 'Owned'),
+    ('Ordered', 'Want'),
+    ('Owned', 'Lent'),
+    ('Lent', 'Owned'),
+    ('Owned', 'Given away')
+) AS transitions (from_name, to_name)
+INNER JOIN status AS from_status
+  ON transitions.from_name = from_status.status_name
+INNER JOIN status AS to_status
+  ON transitions.to_name = to_status.status_name;
+
+-- Full-text search ignores diacritics, so that e.g. "Moises" finds "Moisés",
+-- with the aristarchus configuration: the simple configuration, which doesn't
+-- stem or drop stop words, with unaccent applied to words first.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE TEXT SEARCH CONFIGURATION aristarchus (COPY = simple);
+ALTER TEXT SEARCH CONFIGURATION aristarchus
+  ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+
+CREATE VIEW book_search_source AS
+SELECT books.book_id,
+       books.title,
+       COALESCE(books.subtitle, '') AS subtitle,
+       COALESCE((SELECT string_agg(people.name, ' '
+                                   ORDER BY CASE book_contributor.role
+                                              WHEN 'author' THEN 1
+                                              WHEN 'editor' THEN 2
+                                              WHEN 'translator' THEN 3
+                                              WHEN 'illustrator' THEN 4
+                                              WHEN 'foreword' THEN 5
+                                              ELSE 6
+                                            END,
+                                            book_contributor.position)
+                 FROM book_contributor
+                 INNER JOIN people
+                   ON book_contributor.person_id = people.person_id
+                 WHERE book_contributor.book_id = books.book_id), '') AS people,
+       COALESCE(publishers.name, '') AS publisher,
+       COALESCE(series.series_name, '') AS series,
+       COALESCE((SELECT string_agg(COALESCE(book_note.page, '') || ' ' || book_note.note, ' '
+                                   ORDER BY book_note.note_id)
+                 FROM book_note
+                 WHERE book_note.book_id = books.book_id), '') AS notes
+FROM books
+LEFT JOIN publishers
+  ON books.publisher_id = publishers.publisher_id
+LEFT JOIN series
+  ON books.series_id = series.series_id;
+
+-- The search index, one row per book with the fields of book_search_source
+-- and a document of them all, weighted so that matches in the title and people
+-- count for most and those in the notes least. Being a view, it is always up to
+-- date, at the cost of building each document when searching.
+CREATE VIEW book_search AS
+SELECT book_search_source.*,
+       setweight(to_tsvector('aristarchus', title), 'A') ||
+       setweight(to_tsvector('aristarchus', people), 'A') ||
+       setweight(to_tsvector('aristarchus', subtitle), 'B') ||
+       setweight(to_tsvector('aristarchus', series), 'C') ||
+       setweight(to_tsvector('aristarchus', publisher), 'C') ||
+       setweight(to_tsvector('aristarchus', notes), 'D') AS document
+FROM book_search_source;
@@ -0,0 +1,4 @@
+-- Add a column for each book's citation key, stored when the key is first
+-- given so that adding or deleting other books never changes it.
+ALTER TABLE books ADD COLUMN citation_key TEXT;
+CREATE UNIQUE INDEX books_citation_key ON books (citation_key);
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Note is a timestamped reading note on a book. The page reference is free
+// text, so that it can hold e.g. "xiv", "p. 42" or "ch. 3".
+type Note struct {
+	id      int
+	bookId  int
+	page    string
+	text    string
+	created time.Time
+	updated time.Time
+}
+
+func (n Note) String() string {
+	if len(n.page) == 0 {
+		return fmt.Sprintf("[%v] %v", n.created.Format(timestampFormat), n.text)
+	}
+	return fmt.Sprintf("[%v] (%v) %v", n.created.Format(timestampFormat), n.page,
+		n.text)
+}
+
+type InvalidNoteIdError struct {
+	CallFunc string
+	NoteId   int
+}
+
+func (e *InvalidNoteIdError) Error() string {
+	return fmt.Sprintf("%v: Unknown note ID #%v", e.CallFunc, e.NoteId)
+}
+
+type EmptyNoteError struct {
+	CallFunc string
+	BookId   int
+}
+
+func (e *EmptyNoteError) Error() string {
+	return fmt.Sprintf("%v: Can't add empty note to book #%v", e.CallFunc, e.BookId)
+}
+
+func noteIdValid(db DBInterface, id int) (bool, error) {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM book_note WHERE note_id = ?",
+		id).Scan(&count); err != nil {
+		return false, fmt.Errorf("noteIdValid, problem reading from DB: %v", err)
+	}
+	return count == 1, nil
+}
+
+func addNote(db DBInterface, bookId int, page string, text string) (int, error) {
+	if len(text) == 0 {
+		return 0, &EmptyNoteError{"addNote", bookId}
+	}
+
+	bookValid, err := BookIDValid(db, bookId)
+	if err != nil {
+		return 0, fmt.Errorf("addNote, could not validate book id #%v: %v", bookId, err)
+	}
+	if !bookValid {
+		return 0, &InvalidBookIdError{"addNote", bookId}
+	}
+
+	var notePage sql.NullString
+	if len(page) == 0 {
+		notePage.Valid = false
+	} else {
+		notePage.Valid = true
+		notePage.String = page
+	}
+
+	now := clock().UTC().Format(timestampFormat)
+	sqlStmt := `
+        INSERT INTO book_note (book_id, page, note, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?)
+        RETURNING note_id
+    `
+	var id int
+	err = db.QueryRow(sqlStmt, bookId, notePage, text, now, now).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("addNote, Couldn't add note to book #%v: %v", bookId, err)
+	}
+	return id, nil
+}
+
+const noteSelect = `
+        SELECT note_id, book_id, page, note, created_at, updated_at
+        FROM book_note`
+
+func scanNote(scan func(dest ...any) error) (Note, error) {
+	var n Note
+	var page sql.NullString
+	var created, updated string
+	if err := scan(&n.id, &n.bookId, &page, &n.text, &created, &updated); err != nil {
+		return Note{}, err
+	}
+	n.page = page.String
+
+	var err error
+	n.created, err = time.Parse(timestampFormat, created)
+	if err != nil {
+		return Note{}, fmt.Errorf("Problem parsing time %v: %v", created, err)
+	}
+	n.updated, err = time.Parse(timestampFormat, updated)
+	if err != nil {
+		return Note{}, fmt.Errorf("Problem parsing time %v: %v", updated, err)
+	}
+	return n, nil
+}
+
+func getNoteById(db DBInterface, id int) (Note, error) {
+	n, err := scanNote(db.QueryRow(noteSelect+" WHERE note_id = ?", id).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Note{}, &InvalidNoteIdError{"getNoteById", id}
+		}
+		return Note{}, fmt.Errorf("getNoteById %d: %v", id, err)
+	}
+	return n, nil
+}
+
+// getNotesByBookId returns all notes on a book, oldest first.
+func getNotesByBookId(db DBInterface, bookId int) ([]Note, error) {
+	bookValid, err := BookIDValid(db, bookId)
+	if err != nil {
+		return nil, fmt.Errorf("getNotesByBookId, could not validate book id #%v: %v",
+			bookId, err)
+	}
+	if !bookValid {
+		return nil, &InvalidBookIdError{"getNotesByBookId", bookId}
+	}
+
+	rows, err := db.Query(noteSelect+`
+        WHERE book_id = ?
+        ORDER BY created_at, note_id`, bookId)
+	if err != nil {
+		return nil, fmt.Errorf("getNotesByBookId %d: %v", bookId, err)
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		n, err := scanNote(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("getNotesByBookId %d: %v", bookId, err)
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getNotesByBookId, rows.Next() error: %v", err)
+	}
+	return notes, nil
+}
+
+func updateNoteText(db DBInterface, id int, text string) (string, error) {
+	if len(text) == 0 {
+		n, err := getNoteById(db, id)
+		if err != nil {
+			return "", fmt.Errorf("updateNoteText, Empty note, could not get original note: %w", err)
+		}
+		return n.text, &EmptyNoteError{"updateNoteText", n.bookId}
+	}
+
+	sqlStmt := `
+        UPDATE book_note
+        SET note = ?, updated_at = ?
+        WHERE note_id = ?
+    `
+
+	result, err := db.Exec(sqlStmt, text, clock().UTC().Format(timestampFormat), id)
+	if err != nil {
+		return "", fmt.Errorf("updateNoteText, Couldn't update note #%v: %v", id, err)
+	}
+	if changed, err := result.RowsAffected(); err == nil && changed == 0 {
+		return "", &InvalidNoteIdError{"updateNoteText", id}
+	}
+
+	var updatedText string
+	if err := db.QueryRow("SELECT note FROM book_note WHERE note_id = ?",
+		id).Scan(&updatedText); err != nil {
+		return "", fmt.Errorf("updateNoteText, Couldn't get updated note: %v", err)
+	}
+	if updatedText != text {
+		return "", fmt.Errorf("updateNoteText: Updated note \"%v\" does not match requested note \"%v\"",
+			updatedText, text)
+	}
+
+	return updatedText, nil
+}
+
+func updateNotePage(db DBInterface, id int, page string) (string, error) {
+	var notePage sql.NullString
+	if len(page) == 0 {
+		notePage.Valid = false
+	} else {
+		notePage.Valid = true
+		notePage.String = page
+	}
+
+	sqlStmt := `
+        UPDATE book_note
+        SET page = ?, updated_at = ?
+        WHERE note_id = ?
+    `
+
+	result, err := db.Exec(sqlStmt, notePage, clock().UTC().Format(timestampFormat), id)
+	if err != nil {
+		return "", fmt.Errorf("updateNotePage, Couldn't update note #%v page to %v: %v",
+			id, page, err)
+	}
+	if changed, err := result.RowsAffected(); err == nil && changed == 0 {
+		return "", &InvalidNoteIdError{"updateNotePage", id}
+	}
+
+	var updatedPage sql.NullString
+	if err := db.QueryRow("SELECT page FROM book_note WHERE note_id = ?",
+		id).Scan(&updatedPage); err != nil {
+		return "", fmt.Errorf("updateNotePage, Couldn't get updated page: %v", err)
+	}
+	if updatedPage != notePage {
+		return "", fmt.Errorf("updateNotePage: Updated page \"%v\" does not match requested page \"%v\"",
+			updatedPage.String, page)
+	}
+
+	return updatedPage.String, nil
+}
+
+func deleteNote(db DBInterface, id int) error {
+	noteValid, err := noteIdValid(db, id)
+	if err != nil {
+		return fmt.Errorf("deleteNote: %v", err)
+	}
+	if !noteValid {
+		return &InvalidNoteIdError{"deleteNote", id}
+	}
+
+	_, err = db.Exec("DELETE FROM book_note WHERE note_id = ?", id)
+	if err != nil {
+		return fmt.Errorf("deleteNote, Couldn't delete note #%v: %v", id, err)
+	}
+	return nil
+}
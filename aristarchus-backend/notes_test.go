@@ -0,0 +1,252 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetNotesByBookId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	first := time.Date(2022, time.February, 3, 19, 30, 0, 0, time.UTC)
+	second := time.Date(2022, time.June, 1, 21, 15, 0, 0, time.UTC)
+	expected := []Note{
+		{1, 5, "xiv", "Argues for progressive covenantalism as a via media between dispensationalism and covenant theology.", first, first},
+		{2, 5, "", "Worth re-reading the chapter on the new covenant alongside Hebrews.", second, second},
+	}
+
+	notes, err := getNotesByBookId(db, 5)
+	if err != nil {
+		t.Errorf("getNotesByBookId returned error: %v", err)
+	}
+	if !reflect.DeepEqual(notes, expected) {
+		t.Errorf("getNotesByBookId returned unexpected notes. Expected %v, got %v",
+			expected, notes)
+	}
+}
+
+func TestGetNotesByBookIdNoNotes(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	notes, err := getNotesByBookId(db, 1)
+	if err != nil {
+		t.Errorf("getNotesByBookId returned error: %v", err)
+	}
+	if len(notes) != 0 {
+		t.Errorf("getNotesByBookId returned notes for book without any: %v", notes)
+	}
+}
+
+func TestGetNotesByBookIdInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = getNotesByBookId(db, 1000)
+	if err == nil {
+		t.Errorf("getNotesByBookId did not return error for invalid book id")
+	} else {
+		var invIdErr *InvalidBookIdError
+		if !errors.As(err, &invIdErr) {
+			t.Errorf("getNotesByBookId returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestNoteString(t *testing.T) {
+	created := time.Date(2022, time.February, 3, 19, 30, 0, 0, time.UTC)
+	n := Note{1, 5, "xiv", "Progressive covenantalism.", created, created}
+	expected := "[2022-02-03 19:30:00] (xiv) Progressive covenantalism."
+	if n.String() != expected {
+		t.Errorf("Note String returned unexpected value. Expected %v, got %v",
+			expected, n.String())
+	}
+
+	n.page = ""
+	expected = "[2022-02-03 19:30:00] Progressive covenantalism."
+	if n.String() != expected {
+		t.Errorf("Note String returned unexpected value. Expected %v, got %v",
+			expected, n.String())
+	}
+}
+
+func TestAddUpdateDeleteNote(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	created := time.Date(2024, time.March, 2, 10, 30, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+	clock = func() time.Time { return created }
+	defer func() { clock = time.Now }()
+
+	id, err := addNote(db, 3, "p. 12", "Euthyphro dilemma.")
+	if err != nil {
+		t.Fatalf("addNote returned error: %v", err)
+	}
+
+	expected := Note{id, 3, "p. 12", "Euthyphro dilemma.", created, created}
+	n, err := getNoteById(db, id)
+	if err != nil {
+		t.Errorf("getNoteById returned error: %v", err)
+	}
+	if n != expected {
+		t.Errorf("getNoteById returned unexpected note. Expected %v, got %v",
+			expected, n)
+	}
+
+	clock = func() time.Time { return updated }
+	text, err := updateNoteText(db, id, "The Euthyphro dilemma.")
+	if err != nil {
+		t.Errorf("updateNoteText returned error: %v", err)
+	}
+	if text != "The Euthyphro dilemma." {
+		t.Errorf("updateNoteText returned unexpected text: %v", text)
+	}
+	page, err := updateNotePage(db, id, "")
+	if err != nil {
+		t.Errorf("updateNotePage returned error: %v", err)
+	}
+	if page != "" {
+		t.Errorf("updateNotePage returned unexpected page: %v", page)
+	}
+
+	expected = Note{id, 3, "", "The Euthyphro dilemma.", created, updated}
+	n, err = getNoteById(db, id)
+	if err != nil {
+		t.Errorf("getNoteById returned error: %v", err)
+	}
+	if n != expected {
+		t.Errorf("Note not updated as expected. Expected %v, got %v", expected, n)
+	}
+
+	if err := deleteNote(db, id); err != nil {
+		t.Errorf("deleteNote returned error: %v", err)
+	}
+	_, err = getNoteById(db, id)
+	var invNoteErr *InvalidNoteIdError
+	if !errors.As(err, &invNoteErr) {
+		t.Errorf("Note not deleted, getNoteById returned: %v", err)
+	}
+}
+
+func TestAddNoteEmpty(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = addNote(db, 3, "p. 12", "")
+	if err == nil {
+		t.Errorf("addNote did not return error for empty note")
+	} else {
+		var emptyErr *EmptyNoteError
+		if !errors.As(err, &emptyErr) {
+			t.Errorf("addNote returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestAddNoteInvalidBookId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = addNote(db, 1000, "", "A note")
+	if err == nil {
+		t.Errorf("addNote did not return error for invalid book id")
+	} else {
+		var invIdErr *InvalidBookIdError
+		if !errors.As(err, &invIdErr) {
+			t.Errorf("addNote returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestUpdateNoteTextEmpty(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := "Worth re-reading the chapter on the new covenant alongside Hebrews."
+	text, err := updateNoteText(db, 2, "")
+	if err == nil {
+		t.Errorf("updateNoteText did not return error for empty note")
+	} else {
+		var emptyErr *EmptyNoteError
+		if !errors.As(err, &emptyErr) {
+			t.Errorf("updateNoteText returned unexpected error: %v", err)
+		}
+	}
+	if text != expected {
+		t.Errorf("updateNoteText should return original text. Expected %v, got %v",
+			expected, text)
+	}
+}
+
+func TestDeleteNoteInvalidId(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	err = deleteNote(db, 1000)
+	if err == nil {
+		t.Errorf("deleteNote did not return error for invalid id")
+	} else {
+		var invNoteErr *InvalidNoteIdError
+		if !errors.As(err, &invNoteErr) {
+			t.Errorf("deleteNote returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestDeleteBookRemovesNotes(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id, err := addBook(db, makeTestBook())
+	if err != nil {
+		t.Fatalf("Problem adding book to test note cleanup: %v", err)
+	}
+	if _, err := addNote(db, id, "", "Useful introduction."); err != nil {
+		t.Errorf("addNote returned error: %v", err)
+	}
+
+	if err := deleteBook(db, id); err != nil {
+		t.Errorf("deleteBook returned error: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM book_note WHERE book_id = ?",
+		id).Scan(&count); err != nil {
+		t.Errorf("Problem counting notes: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("deleteBook left %v notes for deleted book", count)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const openLibraryUrl = "https://openlibrary.org"
+
+// OpenLibraryClient is a MetadataProvider using the Open Library JSON API, or
+// any server providing the same /api/books and /search.json endpoints.
+type OpenLibraryClient struct {
+	baseUrl    string
+	httpClient *http.Client
+}
+
+func newOpenLibraryClient(baseUrl string) *OpenLibraryClient {
+	return &OpenLibraryClient{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// openLibraryBook is a book as given by the /api/books endpoint with
+// jscmd=data.
+type openLibraryBook struct {
+	Title       string `json:"title"`
+	Subtitle    string `json:"subtitle"`
+	PublishDate string `json:"publish_date"`
+	Authors     []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+}
+
+// openLibrarySearch is the response from the /search.json endpoint.
+type openLibrarySearch struct {
+	NumFound int `json:"numFound"`
+	Docs     []struct {
+		Title            string   `json:"title"`
+		Subtitle         string   `json:"subtitle"`
+		AuthorName       []string `json:"author_name"`
+		FirstPublishYear int      `json:"first_publish_year"`
+		Publisher        []string `json:"publisher"`
+		Isbn             []string `json:"isbn"`
+	} `json:"docs"`
+}
+
+func (c *OpenLibraryClient) getJson(callFunc string, path string,
+	query url.Values, v any) error {
+	reqUrl := c.baseUrl + path + "?" + query.Encode()
+	resp, err := c.httpClient.Get(reqUrl)
+	if err != nil {
+		return fmt.Errorf("%v, Request to %v failed: %v", callFunc, c.baseUrl, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v, Request to %v returned status %v", callFunc,
+			c.baseUrl, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%v, Couldn't read response from %v: %v", callFunc,
+			c.baseUrl, err)
+	}
+	return nil
+}
+
+var yearPattern = regexp.MustCompile(`\b\d{4}\b`)
+
+// publishYear picks the year out of an Open Library publish date, which may be
+// given as e.g. "2015", "March 2015" or "Mar 02, 2015".
+func publishYear(date string) int {
+	var year int
+	fmt.Sscan(yearPattern.FindString(date), &year)
+	return year
+}
+
+func (c *OpenLibraryClient) LookupIsbn(isbn ISBN) (Book, error) {
+	bibkey := "ISBN:" + isbn.isbn13()
+	query := url.Values{
+		"bibkeys": {bibkey},
+		"format":  {"json"},
+		"jscmd":   {"data"},
+	}
+
+	var results map[string]openLibraryBook
+	if err := c.getJson("LookupIsbn", "/api/books", query, &results); err != nil {
+		return Book{}, err
+	}
+	olb, ok := results[bibkey]
+	if !ok {
+		return Book{}, &MetadataNotFoundError{"LookupIsbn",
+			fmt.Sprintf("ISBN %v", isbn)}
+	}
+
+	var authors []string
+	for _, a := range olb.Authors {
+		authors = append(authors, a.Name)
+	}
+	b := Book{
+		author:   formatNameList(authors),
+		title:    olb.Title,
+		subtitle: olb.Subtitle,
+		year:     publishYear(olb.PublishDate),
+		isbn:     isbn,
+	}
+	if len(olb.Publishers) > 0 {
+		b.publisher = olb.Publishers[0].Name
+	}
+	return b, nil
+}
+
+// LookupTitle searches for a book by title and author, and returns the first
+// match. Where the match has an ISBN, the book's details are taken from the
+// ISBN lookup, which is more complete than the search result.
+func (c *OpenLibraryClient) LookupTitle(title string, author string) (Book, error) {
+	query := url.Values{
+		"title": {title},
+		"limit": {"1"},
+	}
+	if len(author) > 0 {
+		query.Set("author", author)
+	}
+
+	var results openLibrarySearch
+	if err := c.getJson("LookupTitle", "/search.json", query, &results); err != nil {
+		return Book{}, err
+	}
+	if len(results.Docs) == 0 {
+		return Book{}, &MetadataNotFoundError{"LookupTitle",
+			fmt.Sprintf("\"%v\" by %v", title, author)}
+	}
+	doc := results.Docs[0]
+
+	for _, s := range doc.Isbn {
+		isbn, err := parseIsbn(s)
+		if err != nil {
+			continue
+		}
+		if b, err := c.LookupIsbn(isbn); err == nil {
+			return b, nil
+		}
+		break
+	}
+
+	b := Book{
+		author:   formatNameList(doc.AuthorName),
+		title:    doc.Title,
+		subtitle: doc.Subtitle,
+		year:     doc.FirstPublishYear,
+	}
+	if len(doc.Publisher) > 0 {
+		b.publisher = doc.Publisher[0]
+	}
+	return b, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type fakeAuthor struct {
+	Name string `json:"name"`
+}
+
+// newFakeOpenLibrary starts an in-process server implementing the parts of the
+// Open Library API used by OpenLibraryClient, serving the given books. The
+// caller should Close the server when done.
+func newFakeOpenLibrary(books ...Book) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/books", func(w http.ResponseWriter, r *http.Request) {
+		results := make(map[string]any)
+		for _, key := range strings.Split(r.URL.Query().Get("bibkeys"), ",") {
+			isbn, err := parseIsbn(strings.TrimPrefix(key, "ISBN:"))
+			if err != nil {
+				continue
+			}
+			for _, b := range books {
+				if b.isbn != isbn {
+					continue
+				}
+				var authors []fakeAuthor
+				for _, name := range nameListFromString(b.author) {
+					authors = append(authors, fakeAuthor{name})
+				}
+				results[key] = map[string]any{
+					"title":        b.title,
+					"subtitle":     b.subtitle,
+					"publish_date": fmt.Sprintf("March %d", b.year),
+					"authors":      authors,
+					"publishers":   []fakeAuthor{{b.publisher}},
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	})
+
+	mux.HandleFunc("/search.json", func(w http.ResponseWriter, r *http.Request) {
+		title := strings.ToLower(r.URL.Query().Get("title"))
+		author := strings.ToLower(r.URL.Query().Get("author"))
+		var docs []map[string]any
+		for _, b := range books {
+			if !strings.Contains(strings.ToLower(b.title), title) ||
+				!strings.Contains(strings.ToLower(b.author), author) {
+				continue
+			}
+			doc := map[string]any{
+				"title":              b.title,
+				"author_name":        nameListFromString(b.author),
+				"first_publish_year": b.year,
+				"publisher":          []string{b.publisher},
+			}
+			if len(b.isbn) > 0 {
+				doc["isbn"] = []string{string(b.isbn)}
+			}
+			docs = append(docs, doc)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"numFound": len(docs),
+			"docs":     docs,
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
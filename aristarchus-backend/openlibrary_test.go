@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func makeLookupBook() Book {
+	return Book{
+		author:    "Stephen G. Dempster",
+		title:     "Dominion and Dynasty",
+		subtitle:  "A Theology of the Hebrew Bible",
+		year:      2003,
+		publisher: "Apollos",
+		isbn:      "9780851112855",
+	}
+}
+
+func TestPublishYear(t *testing.T) {
+	dates := map[string]int{
+		"2015":         2015,
+		"March 2015":   2015,
+		"Mar 02, 2015": 2015,
+		"":             0,
+		"unknown":      0,
+	}
+
+	for date, expected := range dates {
+		if year := publishYear(date); year != expected {
+			t.Errorf("publishYear(%q) returned %v, expected %v", date, year,
+				expected)
+		}
+	}
+}
+
+func TestOpenLibraryLookupIsbn(t *testing.T) {
+	expected := makeLookupBook()
+	server := newFakeOpenLibrary(expected)
+	defer server.Close()
+
+	client := newOpenLibraryClient(server.URL)
+	b, err := client.LookupIsbn(expected.isbn)
+	if err != nil {
+		t.Errorf("LookupIsbn returned error: %v", err)
+	}
+	if b.String() != expected.String() || b.publisher != expected.publisher {
+		t.Errorf("LookupIsbn returned unexpected book. Expected %v, got %v",
+			expected, b)
+	}
+}
+
+func TestOpenLibraryLookupIsbnNotFound(t *testing.T) {
+	server := newFakeOpenLibrary(makeLookupBook())
+	defer server.Close()
+
+	client := newOpenLibraryClient(server.URL)
+	_, err := client.LookupIsbn("9780851117232")
+	if err == nil {
+		t.Errorf("LookupIsbn did not return error for unknown ISBN")
+	} else {
+		var notFoundErr *MetadataNotFoundError
+		if !errors.As(err, &notFoundErr) {
+			t.Errorf("LookupIsbn returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestOpenLibraryLookupIsbnServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+	defer server.Close()
+
+	client := newOpenLibraryClient(server.URL)
+	_, err := client.LookupIsbn("9780851112855")
+	if err == nil {
+		t.Errorf("LookupIsbn did not return error for server error")
+	} else {
+		var notFoundErr *MetadataNotFoundError
+		if errors.As(err, &notFoundErr) {
+			t.Errorf("LookupIsbn reported server error as book not found: %v", err)
+		}
+	}
+}
+
+func TestOpenLibraryLookupTitle(t *testing.T) {
+	expected := makeLookupBook()
+	server := newFakeOpenLibrary(expected)
+	defer server.Close()
+
+	client := newOpenLibraryClient(server.URL)
+	b, err := client.LookupTitle("dominion and dynasty", "Dempster")
+	if err != nil {
+		t.Errorf("LookupTitle returned error: %v", err)
+	}
+	// the subtitle only comes from the ISBN lookup, so checks that the full
+	// record was fetched
+	if b.String() != expected.String() {
+		t.Errorf("LookupTitle returned unexpected book. Expected %v, got %v",
+			expected, b)
+	}
+}
+
+func TestOpenLibraryLookupTitleNoIsbn(t *testing.T) {
+	expected := makeLookupBook()
+	expected.isbn = ""
+	expected.subtitle = ""
+	server := newFakeOpenLibrary(expected)
+	defer server.Close()
+
+	client := newOpenLibraryClient(server.URL)
+	b, err := client.LookupTitle("Dominion and Dynasty", "")
+	if err != nil {
+		t.Errorf("LookupTitle returned error: %v", err)
+	}
+	if b.String() != expected.String() || b.publisher != expected.publisher {
+		t.Errorf("LookupTitle returned unexpected book. Expected %v, got %v",
+			expected, b)
+	}
+}
+
+func TestOpenLibraryLookupTitleNotFound(t *testing.T) {
+	server := newFakeOpenLibrary(makeLookupBook())
+	defer server.Close()
+
+	client := newOpenLibraryClient(server.URL)
+	_, err := client.LookupTitle("Dominion and Dynasty", "Gentry")
+	if err == nil {
+		t.Errorf("LookupTitle did not return error for unknown book")
+	} else {
+		var notFoundErr *MetadataNotFoundError
+		if !errors.As(err, &notFoundErr) {
+			t.Errorf("LookupTitle returned unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// With -backend postgres, the tests run against a PostgreSQL cluster made for
+// them in a temporary directory by initdb and run by pg_ctl, which must be on
+// the PATH, e.g.
+//
+//	PATH=/usr/lib/postgresql/16/bin:$PATH go test -tags sqlite_fts5 -backend postgres
+//
+// The cluster only listens on a Unix socket in that directory, and is stopped
+// and removed once the tests have run. The test database is given the schema
+// by migrateDatabase and then the test data from
+// db/init_test_database_postgres.sql. PostgreSQL refuses to run as root.
+//
+// The SQLite run includes TestPostgresBackend, which runs the whole suite
+// again with -backend postgres, so a plain go test covers both backends where
+// PostgreSQL is available. Where it isn't, the PostgreSQL tests are skipped
+// with a message saying why, unless requirePostgresEnv is set, as it is in CI.
+
+const requirePostgresEnv = "ARISTARCHUS_REQUIRE_POSTGRES"
+
+// postgresSkipped is why TestPostgresBackend was skipped, which TestMain
+// reports, as go test only shows skipped tests with -v.
+var postgresSkipped string
+
+// postgresUnavailable gives the reason the PostgreSQL tests can't be run, or
+// "" if they can.
+func postgresUnavailable() string {
+	for _, cmd := range []string{"initdb", "pg_ctl"} {
+		if _, err := exec.LookPath(cmd); err != nil {
+			return cmd + " isn't on the PATH"
+		}
+	}
+	if os.Geteuid() == 0 {
+		return "PostgreSQL won't run as root"
+	}
+	return ""
+}
+
+func TestPostgresBackend(t *testing.T) {
+	if *testBackend == "postgres" {
+		t.Skip("Already running against PostgreSQL")
+	}
+	if reason := postgresUnavailable(); len(reason) != 0 {
+		if len(os.Getenv(requirePostgresEnv)) != 0 {
+			t.Fatalf("Can't run PostgreSQL tests, %v", reason)
+		}
+		postgresSkipped = reason
+		t.Skipf("Skipping PostgreSQL tests, %v", reason)
+	}
+	if testing.Short() {
+		postgresSkipped = "in short mode"
+		t.Skip("Skipping PostgreSQL tests in short mode")
+	}
+
+	// run this test binary again, without the benchmarks
+	cmd := exec.Command(os.Args[0], "-backend", "postgres", "-test.count=1",
+		"-test.bench=^$")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("Tests failed against PostgreSQL: %v\n%s", err, out)
+	}
+}
+
+var postgresCluster struct {
+	dir       string
+	databases int // number of databases made by tempPostgresDatabase
+}
+
+// postgresTestURL gives the URL of a database in the test cluster.
+func postgresTestURL(name string) string {
+	return fmt.Sprintf("postgres://aristarchus@/%v?host=%v&sslmode=disable", name,
+		url.QueryEscape(postgresCluster.dir))
+}
+
+// execPostgresCluster runs an SQL statement against the cluster's postgres
+// database, for those such as CREATE DATABASE which can't be run in the
+// database they affect.
+func execPostgresCluster(stmt string) error {
+	db, err := sql.Open(postgresDriverName, postgresTestURL("postgres"))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec(stmt)
+	return err
+}
+
+func setupPostgresTestDatabase() (err error) {
+	postgresCluster.dir, err = os.MkdirTemp("", "aristarchus-pg")
+	if err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			teardownPostgresTestDatabase()
+		}
+	}()
+
+	data := filepath.Join(postgresCluster.dir, "data")
+	cmd := exec.Command("initdb", "-D", data, "-U", "aristarchus", "--auth=trust",
+		"-E", "UTF8", "--no-locale")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase, couldn't create cluster: %v\n%s",
+			err, out)
+	}
+	cmd = exec.Command("pg_ctl", "-D", data, "-l", filepath.Join(postgresCluster.dir, "log"),
+		"-o", fmt.Sprintf("-k %v -c listen_addresses='' -c fsync=off", postgresCluster.dir),
+		"-w", "start")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase, couldn't start cluster: %v\n%s",
+			err, out)
+	}
+
+	if err := execPostgresCluster("CREATE DATABASE aristarchus_test"); err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase, couldn't create db: %v", err)
+	}
+	testDriver, testDatabase, testDialect = postgresDriverName,
+		postgresTestURL("aristarchus_test"), Postgres
+
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase: %v", err)
+	}
+	defer db.Close()
+	if _, _, err := migrateDatabase(db); err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase, couldn't set up db: %v", err)
+	}
+	testData, err := os.ReadFile("../db/init_test_database_postgres.sql")
+	if err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase: %v", err)
+	}
+	if _, err := db.Exec(string(testData)); err != nil {
+		return fmt.Errorf("setupPostgresTestDatabase, couldn't add test data: %v", err)
+	}
+	return nil
+}
+
+func teardownPostgresTestDatabase() error {
+	if postgresCluster.dir == "" {
+		return nil
+	}
+	data := filepath.Join(postgresCluster.dir, "data")
+	if _, err := os.Stat(filepath.Join(data, "postmaster.pid")); err == nil {
+		cmd := exec.Command("pg_ctl", "-D", data, "-m", "immediate", "-w", "stop")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("teardownPostgresTestDatabase, couldn't stop cluster: %v\n%s",
+				err, out)
+		}
+	}
+	if err := os.RemoveAll(postgresCluster.dir); err != nil {
+		return fmt.Errorf("teardownPostgresTestDatabase, issue removing cluster: %v", err)
+	}
+	return nil
+}
+
+// tempPostgresDatabase makes a new, empty database in the test cluster,
+// giving its URL. The database is dropped after the test.
+func tempPostgresDatabase(t *testing.T) string {
+	postgresCluster.databases++
+	name := fmt.Sprintf("aristarchus_temp_%d", postgresCluster.databases)
+	if err := execPostgresCluster("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("Problem creating database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := execPostgresCluster("DROP DATABASE " + name + " WITH (FORCE)"); err != nil {
+			t.Errorf("Problem dropping database %v: %v", name, err)
+		}
+	})
+	return postgresTestURL(name)
+}
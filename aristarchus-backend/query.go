@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The query language finds books by their fields, e.g.
+//
+//	author:Gentry publisher:Crossway year:2015..2020 status:Owned -series:"Spectrum Multiview Books"
+//
+// A query is a list of terms separated by spaces, all of which a book must
+// match. A term is either field:value, or a bare word or "quoted phrase" which
+// is looked for with full-text search. Values containing spaces are quoted,
+// with \" and \\ for a quote or backslash within the quotes. A term starting
+// with - excludes the books it matches.
+//
+// Text fields (author, editor, translator, illustrator, foreword, compiler,
+// person, title, subtitle, publisher, series, note) match any part of the
+// value, ignoring case; person matches a contributor in any role. status must
+// match a status name exactly, ignoring case, and isbn must be a valid ISBN.
+// The numeric fields (year, edition, rating) take either a value or a range:
+// a..b, a.. or ..b, inclusive.
+
+type QuerySyntaxError struct {
+	Query    string
+	Position int
+	Message  string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("Query syntax error at position %v: %v", e.Position,
+		e.Message)
+}
+
+type queryFieldKind int
+
+const (
+	textField queryFieldKind = iota
+	statusField
+	isbnField
+	intField
+	ratingField
+)
+
+type queryField struct {
+	kind queryFieldKind
+	// condition is the SQL condition for the field, with a single ? for a text
+	// value, or the column to compare for a numeric field
+	condition string
+}
+
+// contributorSubquery gives the condition for books with a contributor whose
+// name matches, in any role when role is empty.
+func contributorSubquery(role ContributorRole) string {
+	roleCondition := ""
+	if role != "" {
+		roleCondition = fmt.Sprintf("\n            AND book_contributor.role = '%v'", role)
+	}
+	return `books.book_id IN (
+          SELECT book_contributor.book_id
+          FROM book_contributor
+          INNER JOIN people
+            ON book_contributor.person_id = people.person_id
+          WHERE LOWER(people.name) LIKE LOWER(?) ESCAPE '\'` + roleCondition + `)`
+}
+
+var queryFields = map[string]queryField{
+	"author":      {textField, contributorSubquery(RoleAuthor)},
+	"editor":      {textField, contributorSubquery(RoleEditor)},
+	"translator":  {textField, contributorSubquery(RoleTranslator)},
+	"illustrator": {textField, contributorSubquery(RoleIllustrator)},
+	"foreword":    {textField, contributorSubquery(RoleForeword)},
+	"compiler":    {textField, contributorSubquery(RoleCompiler)},
+	"person":      {textField, contributorSubquery("")},
+	"title":       {textField, `LOWER(books.title) LIKE LOWER(?) ESCAPE '\'`},
+	"subtitle":    {textField, `LOWER(books.subtitle) LIKE LOWER(?) ESCAPE '\'`},
+	"publisher": {textField, `books.publisher_id IN (
+          SELECT publisher_id
+          FROM publishers
+          WHERE LOWER(name) LIKE LOWER(?) ESCAPE '\')`},
+	"series": {textField, `books.series_id IN (
+          SELECT series_id
+          FROM series
+          WHERE LOWER(series_name) LIKE LOWER(?) ESCAPE '\')`},
+	"note": {textField, `books.book_id IN (
+          SELECT book_id
+          FROM book_note
+          WHERE LOWER(note) LIKE LOWER(?) ESCAPE '\')`},
+	"status": {statusField, `books.book_id IN (
+          SELECT book_status.book_id
+          FROM book_status
+          INNER JOIN status
+            ON book_status.status_id = status.status_id
+          WHERE LOWER(status.status_name) = LOWER(?))`},
+	"isbn":    {isbnField, `books.isbn = ?`},
+	"year":    {intField, `books.year`},
+	"edition": {intField, `books.edition`},
+	"rating":  {ratingField, `books.rating`},
+}
+
+// queryTerm is a single term of a parsed query. For numeric fields, low and
+// high hold the bounds of the range, with hasLow and hasHigh false for an open
+// end.
+type queryTerm struct {
+	field    string
+	value    string
+	phrase   bool
+	negated  bool
+	position int
+
+	low, high       float64
+	hasLow, hasHigh bool
+}
+
+// Query is a parsed query, ready to be compiled to SQL.
+type Query struct {
+	text  string
+	terms []queryTerm
+}
+
+type queryParser struct {
+	text  string
+	runes []rune
+	pos   int
+}
+
+func (p *queryParser) errorAt(pos int, format string, a ...any) error {
+	return &QuerySyntaxError{p.text, pos + 1, fmt.Sprintf(format, a...)}
+}
+
+func (p *queryParser) atEnd() bool {
+	return p.pos >= len(p.runes)
+}
+
+func (p *queryParser) atSpace() bool {
+	return p.atEnd() || unicode.IsSpace(p.runes[p.pos])
+}
+
+// readQuoted reads a quoted string starting at the current position.
+func (p *queryParser) readQuoted() (string, error) {
+	start := p.pos
+	p.pos++
+	var sb strings.Builder
+	for !p.atEnd() {
+		r := p.runes[p.pos]
+		switch {
+		case r == '\\' && p.pos+1 < len(p.runes):
+			sb.WriteRune(p.runes[p.pos+1])
+			p.pos += 2
+		case r == '"':
+			p.pos++
+			if !p.atSpace() {
+				return "", p.errorAt(p.pos, "expected space after closing quote")
+			}
+			return sb.String(), nil
+		default:
+			sb.WriteRune(r)
+			p.pos++
+		}
+	}
+	return "", p.errorAt(start, "unterminated quote")
+}
+
+func (p *queryParser) readWord() string {
+	start := p.pos
+	for !p.atSpace() {
+		p.pos++
+	}
+	return string(p.runes[start:p.pos])
+}
+
+// fieldName reads a field name followed by a colon, if there is one at the
+// current position. Otherwise, the position is left unchanged.
+func (p *queryParser) fieldName() (string, bool) {
+	end := p.pos
+	for end < len(p.runes) && unicode.IsLetter(p.runes[end]) {
+		end++
+	}
+	if end == p.pos || end >= len(p.runes) || p.runes[end] != ':' {
+		return "", false
+	}
+	name := string(p.runes[p.pos:end])
+	p.pos = end + 1
+	return strings.ToLower(name), true
+}
+
+func (p *queryParser) parseTerm() (queryTerm, error) {
+	var t queryTerm
+	t.position = p.pos + 1
+
+	if p.runes[p.pos] == '-' {
+		t.negated = true
+		p.pos++
+		if p.atSpace() {
+			return t, p.errorAt(p.pos-1, "expected a term after '-'")
+		}
+	}
+
+	fieldPos := p.pos
+	field, hasField := p.fieldName()
+	if hasField {
+		if _, ok := queryFields[field]; !ok {
+			return t, p.errorAt(fieldPos, "unknown field \"%v\"", field)
+		}
+		t.field = field
+		if p.atSpace() {
+			return t, p.errorAt(p.pos, "missing value for field \"%v\"", field)
+		}
+	}
+
+	valuePos := p.pos
+	var err error
+	if p.runes[p.pos] == '"' {
+		t.value, err = p.readQuoted()
+		if err != nil {
+			return t, err
+		}
+		t.phrase = true
+	} else {
+		t.value = p.readWord()
+	}
+	if len(strings.TrimSpace(t.value)) == 0 {
+		return t, p.errorAt(valuePos, "empty value")
+	}
+
+	if hasField {
+		if err := p.checkValue(&t, valuePos); err != nil {
+			return t, err
+		}
+	} else if len(ftsQuery(t.value)) == 0 {
+		return t, p.errorAt(valuePos, "\"%v\" has no words to search for", t.value)
+	}
+	return t, nil
+}
+
+// checkValue validates the value of a field term, and parses the range of a
+// numeric field.
+func (p *queryParser) checkValue(t *queryTerm, valuePos int) error {
+	switch queryFields[t.field].kind {
+	case isbnField:
+		isbn, err := parseIsbn(t.value)
+		if err != nil {
+			return p.errorAt(valuePos, "invalid ISBN \"%v\"", t.value)
+		}
+		t.value = string(isbn)
+	case intField, ratingField:
+		lowText, highText, isRange := strings.Cut(t.value, "..")
+		if !isRange {
+			highText = lowText
+		}
+		if len(lowText) == 0 && len(highText) == 0 {
+			return p.errorAt(valuePos, "range needs at least one bound")
+		}
+
+		parse := func(s string, offset int) (float64, error) {
+			var v float64
+			var err error
+			if queryFields[t.field].kind == intField {
+				var i int
+				i, err = strconv.Atoi(s)
+				v = float64(i)
+			} else {
+				v, err = strconv.ParseFloat(s, 64)
+			}
+			if err != nil {
+				return 0, p.errorAt(valuePos+offset, "invalid %v \"%v\"", t.field, s)
+			}
+			return v, nil
+		}
+
+		var err error
+		if len(lowText) > 0 {
+			if t.low, err = parse(lowText, 0); err != nil {
+				return err
+			}
+			t.hasLow = true
+		}
+		if len(highText) > 0 {
+			offset := 0
+			if isRange {
+				offset = len([]rune(lowText)) + 2
+			}
+			if t.high, err = parse(highText, offset); err != nil {
+				return err
+			}
+			t.hasHigh = true
+		}
+		if t.hasLow && t.hasHigh && t.low > t.high {
+			return p.errorAt(valuePos, "range %v is empty, %v is after %v",
+				t.value, lowText, highText)
+		}
+	}
+	return nil
+}
+
+// parseQuery parses a query string, returning a QuerySyntaxError giving the
+// position of the problem if it isn't valid. An empty query matches every
+// book.
+func parseQuery(s string) (*Query, error) {
+	p := queryParser{text: s, runes: []rune(s)}
+	q := Query{text: s}
+	for {
+		for !p.atEnd() && unicode.IsSpace(p.runes[p.pos]) {
+			p.pos++
+		}
+		if p.atEnd() {
+			break
+		}
+		t, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, t)
+	}
+	return &q, nil
+}
+
+func likePattern(s string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + escaped + "%"
+}
+
+// condition gives the SQL condition and arguments for a single term, in the
+// given dialect.
+func (t queryTerm) condition(d Dialect) (string, []any) {
+	if len(t.field) == 0 && d == Postgres {
+		match := tsQuery(t.value, "&")
+		if t.phrase {
+			match = tsPhraseQuery(t.value)
+		}
+		return `books.book_id IN (
+          SELECT book_id
+          FROM book_search
+          WHERE document @@ to_tsquery('aristarchus', ?))`, []any{match}
+	}
+	if len(t.field) == 0 {
+		var match string
+		if t.phrase {
+			match = `"` + strings.ReplaceAll(t.value, `"`, `""`) + `"`
+		} else {
+			match = ftsQuery(t.value)
+		}
+		return `books.book_id IN (
+          SELECT rowid
+          FROM book_search
+          WHERE book_search MATCH ?)`, []any{match}
+	}
+
+	field := queryFields[t.field]
+	switch field.kind {
+	case textField:
+		var args []any
+		for i := 0; i < strings.Count(field.condition, "?"); i++ {
+			args = append(args, likePattern(t.value))
+		}
+		return field.condition, args
+	case statusField, isbnField:
+		return field.condition, []any{t.value}
+	default:
+		switch {
+		case !t.hasLow:
+			return field.condition + " <= ?", []any{t.high}
+		case !t.hasHigh:
+			return field.condition + " >= ?", []any{t.low}
+		case t.low == t.high:
+			return field.condition + " = ?", []any{t.low}
+		default:
+			return field.condition + " BETWEEN ? AND ?", []any{t.low, t.high}
+		}
+	}
+}
+
+// compile turns the query into a parameterised SQL statement in the given
+// dialect selecting the ids of matching books, and the arguments for it.
+func (q *Query) compile(d Dialect) (string, []any) {
+	conditions := []string{}
+	args := []any{}
+	for _, t := range q.terms {
+		cond, condArgs := t.condition(d)
+		if t.negated {
+			// a NULL column means the book doesn't match the term, so should
+			// be included when the term is negated
+			cond = fmt.Sprintf("NOT COALESCE((%v), FALSE)", cond)
+		}
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+
+	sqlStmt := `
+        SELECT books.book_id
+        FROM books`
+	if len(conditions) > 0 {
+		sqlStmt += `
+        WHERE ` + strings.Join(conditions, `
+          AND `)
+	}
+	sqlStmt += `
+        ORDER BY books.book_id`
+	return sqlStmt, args
+}
+
+// queryBookIds gives the ids of the books matching a query, in id order.
+func queryBookIds(db DBInterface, query string) ([]int, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	sqlStmt, args := q.compile(dialectOf(db))
+
+	rows, err := db.Query(sqlStmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("queryBookIds, Couldn't run query \"%v\": %v",
+			query, err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("queryBookIds, Issue scanning query result: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("queryBookIds, rows.Next() error: %v", err)
+	}
+	return ids, nil
+}
+
+// queryBooks gives the books matching a query, in id order.
+func queryBooks(db DBInterface, query string) ([]Book, error) {
+	ids, err := queryBookIds(db, query)
+	if err != nil {
+		return nil, err
+	}
+	books, err := loadBooks(db, ids)
+	if err != nil {
+		return nil, fmt.Errorf("queryBooks: %w", err)
+	}
+	return books, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	q, err := parseQuery(`author:Gentry -series:"Spectrum Multiview Books" year:2015.. God's`)
+	if err != nil {
+		t.Fatalf("parseQuery returned error: %v", err)
+	}
+
+	expected := []queryTerm{
+		{field: "author", value: "Gentry", position: 1},
+		{field: "series", value: "Spectrum Multiview Books", phrase: true,
+			negated: true, position: 15},
+		{field: "year", value: "2015..", position: 50, low: 2015, hasLow: true},
+		{value: "God's", position: 62},
+	}
+	if !slices.Equal(q.terms, expected) {
+		t.Errorf("parseQuery returned unexpected terms.\nExpected %+v\ngot      %+v",
+			expected, q.terms)
+	}
+}
+
+func TestParseQueryQuoteEscapes(t *testing.T) {
+	q, err := parseQuery(`title:"The \"Best\" \\ Worst"`)
+	if err != nil {
+		t.Fatalf("parseQuery returned error: %v", err)
+	}
+	expected := `The "Best" \ Worst`
+	if len(q.terms) != 1 || q.terms[0].value != expected {
+		t.Errorf("parseQuery returned unexpected terms, expected value %v, got %+v",
+			expected, q.terms)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	queries := map[string]int{
+		`author:`:                  8,
+		`colour:red`:               1,
+		`title:Covenant -`:         16,
+		`series:"Spectrum`:         8,
+		`series:"Spectrum"Books`:   18,
+		`year:twenty`:              6,
+		`year:2015..20x0`:          12,
+		`year:2020..2015`:          6,
+		`year:..`:                  6,
+		`rating:four`:              8,
+		`isbn:978-0-8010-3649-2`:   6,
+		`Gentry title:""`:          14,
+		`Gentry & Wellum`:          8,
+		`author:Gentry   status:"`: 24,
+	}
+
+	for query, position := range queries {
+		_, err := parseQuery(query)
+		if err == nil {
+			t.Errorf("parseQuery(%q) did not return error", query)
+			continue
+		}
+		var syntaxErr *QuerySyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("parseQuery(%q) returned unexpected error: %v", query, err)
+			continue
+		}
+		if syntaxErr.Position != position {
+			t.Errorf("parseQuery(%q) reported error at position %v, expected %v: %v",
+				query, syntaxErr.Position, position, err)
+		}
+	}
+}
+
+func TestQueryBookIds(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	queries := map[string][]int{
+		``: {1, 2, 3, 4, 5, 6},
+		`author:Gentry publisher:Crossway year:2015..2020 status:Owned -series:"Spectrum Multiview Books"`: {4, 5},
+		`publisher:ivp`:                  {1, 2},
+		`publisher:IVP -series:Spectrum`: {1},
+		`-series:Spectrum`:               {1, 3, 4, 5, 6},
+		`editor:Williams`:                {},
+		`translator:Williams`:            {3},
+		`editor:Sutanto`:                 {6},
+		`person:Williams`:                {3},
+		`person:Gentry`:                  {4, 5},
+		`author:"Stephen J. Wellum"`:     {5},
+		`title:covenant`:                 {5},
+		`-subtitle:covenants`:            {1, 2, 3, 4, 6},
+		`status:read`:                    {5},
+		`-status:Owned`:                  {6},
+		`year:..2007`:                    {1, 3},
+		`year:2018`:                      {5},
+		`edition:2`:                      {5},
+		`rating:4..`:                     {3, 5},
+		`-rating:4.5`:                    {1, 2, 3, 4, 6},
+		`isbn:0-85111-723-6`:             {1},
+		`note:Hebrews`:                   {5},
+		`covenant Gentry`:                {5},
+		`God's`:                          {2},
+		`"Biblical Prophets"`:            {4},
+		`-Gentry publisher:Crossway`:     {6},
+		`title:100%`:                     {},
+		`author:Gentry year:2000..2010`:  {},
+	}
+
+	for query, expected := range queries {
+		ids, err := queryBookIds(db, query)
+		if err != nil {
+			t.Errorf("queryBookIds(%q) returned error: %v", query, err)
+			continue
+		}
+		if len(ids) == 0 && len(expected) == 0 {
+			continue
+		}
+		if !slices.Equal(ids, expected) {
+			t.Errorf("queryBookIds(%q) returned %v, expected %v", query, ids, expected)
+		}
+	}
+}
+
+func TestQueryBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	books, err := queryBooks(db, "author:Wellum")
+	if err != nil {
+		t.Fatalf("queryBooks returned error: %v", err)
+	}
+	if len(books) != 1 || books[0].title != "Kingdom through Covenant" {
+		t.Errorf("queryBooks returned unexpected books: %v", books)
+	}
+}
+
+func TestQueryBooksSyntaxError(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = queryBooks(db, "author:Gentry colour:red")
+	var syntaxErr *QuerySyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("queryBooks returned unexpected error for invalid query: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Rating is a book's rating out of five stars, in half-star steps. The zero
+// value means the book hasn't been rated, which is distinct from a rating of
+// zero stars.
+type Rating struct {
+	halfStars int
+	rated     bool
+}
+
+type InvalidRatingError struct {
+	CallFunc string
+	Stars    float64
+}
+
+func (e *InvalidRatingError) Error() string {
+	return fmt.Sprintf("%v: Invalid rating %v, must be from 0 to 5 in steps of 0.5",
+		e.CallFunc, e.Stars)
+}
+
+// newRating creates a rating from a number of stars, which must be between 0
+// and 5 inclusive and a multiple of 0.5.
+func newRating(stars float64) (Rating, error) {
+	halfStars := stars * 2
+	if stars < 0 || stars > 5 || halfStars != math.Trunc(halfStars) {
+		return Rating{}, &InvalidRatingError{"newRating", stars}
+	}
+	return Rating{int(halfStars), true}, nil
+}
+
+// parseRating creates a rating from a string such as "3.5". An empty string
+// gives an unrated Rating.
+func parseRating(s string) (Rating, error) {
+	if len(s) == 0 {
+		return Rating{}, nil
+	}
+	stars, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Rating{}, fmt.Errorf("parseRating, Couldn't read rating \"%v\": %v", s, err)
+	}
+	return newRating(stars)
+}
+
+func (r Rating) stars() float64 {
+	return float64(r.halfStars) / 2
+}
+
+func (r Rating) String() string {
+	if !r.rated {
+		return ""
+	}
+	return fmt.Sprintf("%v/5", strconv.FormatFloat(r.stars(), 'f', -1, 64))
+}
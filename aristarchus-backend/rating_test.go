@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewRating(t *testing.T) {
+	ratings := map[float64]string{
+		0:   "0/5",
+		0.5: "0.5/5",
+		3:   "3/5",
+		4.5: "4.5/5",
+		5:   "5/5",
+	}
+
+	for stars, expected := range ratings {
+		r, err := newRating(stars)
+		if err != nil {
+			t.Errorf("newRating returned error for %v stars: %v", stars, err)
+		}
+		if r.stars() != stars {
+			t.Errorf("newRating gave wrong number of stars. Expected %v, got %v",
+				stars, r.stars())
+		}
+		if r.String() != expected {
+			t.Errorf("Rating String returned unexpected value. Expected %v, got %v",
+				expected, r.String())
+		}
+	}
+}
+
+func TestNewRatingInvalid(t *testing.T) {
+	for _, stars := range []float64{-0.5, 5.5, 3.2, 10} {
+		_, err := newRating(stars)
+		if err == nil {
+			t.Errorf("newRating did not return error for invalid rating %v", stars)
+		} else {
+			var ratingErr *InvalidRatingError
+			if !errors.As(err, &ratingErr) {
+				t.Errorf("newRating returned unexpected error for rating %v: %v",
+					stars, err)
+			}
+		}
+	}
+}
+
+func TestParseRating(t *testing.T) {
+	r, err := parseRating("3.5")
+	if err != nil {
+		t.Errorf("parseRating returned error: %v", err)
+	}
+	if r != (Rating{7, true}) {
+		t.Errorf("parseRating returned unexpected value. Expected 3.5/5, got %v", r)
+	}
+
+	r, err = parseRating("")
+	if err != nil {
+		t.Errorf("parseRating returned error for empty string: %v", err)
+	}
+	if r.rated || r.String() != "" {
+		t.Errorf("parseRating of empty string should be unrated, got %v", r)
+	}
+
+	if _, err := parseRating("four"); err == nil {
+		t.Errorf("parseRating did not return error for non-numeric rating")
+	}
+}
+
+func TestRatingZeroStarsIsRated(t *testing.T) {
+	r, err := newRating(0)
+	if err != nil {
+		t.Errorf("newRating returned error for 0 stars: %v", err)
+	}
+	if r == (Rating{}) {
+		t.Errorf("Rating of zero stars should differ from an unrated book")
+	}
+}
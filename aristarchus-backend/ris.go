@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RIS files hold one entry per book, each a list of tagged lines from a TY line
+// giving the type to an ER line, e.g.
+//
+//	TY  - BOOK
+//	AU  - Gentry, Peter J.
+//	TI  - Kingdom through Covenant: A Biblical-Theological Understanding ...
+//	ER  -
+//
+// Names are written surname first. The title is written with the subtitle, as
+// reference managers expect, and the short title (ST) holds the title alone so
+// that the subtitle can be read back. Statuses are written as keywords, which
+// reference managers show as tags. As reference managers also use keywords for
+// subjects, only keywords naming statuses in the library are read back. RIS has no tags for illustrators, writers of
+// forewords or compilers, nor for the purchase date and rating, so these go in
+// the custom fields C1 to C5, which reference managers keep but don't show as
+// anything in particular.
+
+const (
+	risIllustrator = "C1"
+	risForeword    = "C2"
+	risCompiler    = "C3"
+	risPurchased   = "C4"
+	risRating      = "C5"
+)
+
+// risRoleTags gives the tag written for each contributor role.
+var risRoleTags = map[ContributorRole]string{
+	RoleAuthor:      "AU",
+	RoleEditor:      "ED",
+	RoleTranslator:  "A4",
+	RoleIllustrator: risIllustrator,
+	RoleForeword:    risForeword,
+	RoleCompiler:    risCompiler,
+}
+
+// risImportRoleTags gives the role of each tag read for contributors, which
+// also includes the alternative tags other programs write, in the order read.
+var risImportRoleTags = []struct {
+	tag  string
+	role ContributorRole
+}{
+	{"AU", RoleAuthor}, {"A1", RoleAuthor},
+	{"ED", RoleEditor}, {"A2", RoleEditor},
+	{"A4", RoleTranslator},
+	{risIllustrator, RoleIllustrator},
+	{risForeword, RoleForeword},
+	{risCompiler, RoleCompiler},
+}
+
+func isRisRoleTag(tag string) bool {
+	for _, t := range risImportRoleTags {
+		if t.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// risTypes are the entry types read as books.
+var risTypes = []string{"BOOK", "EDBOOK", "EBOOK"}
+
+// risKnownTags are the tags read when importing, other than those for
+// contributors.
+var risKnownTags = []string{"TY", "ID", "ER", "TI", "T1", "ST", "T2", "T3", "ET",
+	"PB", "PY", "Y1", "DA", "SN", "KW", risPurchased, risRating}
+
+var risLinePattern = regexp.MustCompile(`^([A-Z][A-Z0-9])  -(?: (.*))?$`)
+
+// RisSyntaxError is a line of an RIS file which can't be read, which stops the
+// file being imported.
+type RisSyntaxError struct {
+	CallFunc string
+	Line     int
+	Reason   string
+}
+
+func (e *RisSyntaxError) Error() string {
+	return fmt.Sprintf("%v: Line %v: %v", e.CallFunc, e.Line, e.Reason)
+}
+
+// risName gives a name surname first, e.g. "Gentry, Peter J.", or "King,
+// Martin Luther, Jr." with a suffix.
+func risName(name string) string {
+	given, family, suffix := splitPersonName(name)
+	parts := []string{family}
+	if len(given) > 0 || len(suffix) > 0 {
+		parts = append(parts, given)
+	}
+	if len(suffix) > 0 {
+		parts = append(parts, suffix)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// risNameFromString reads a name written by risName. A name without a comma is
+// taken as it is.
+func risNameFromString(s string) string {
+	parts := strings.Split(s, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	switch len(parts) {
+	case 1:
+		return parts[0]
+	case 2:
+		return joinPersonName(parts[1], parts[0], "")
+	default:
+		return joinPersonName(parts[1], parts[0], strings.Join(parts[2:], " "))
+	}
+}
+
+// writeRisEntry writes a book as an RIS entry with the given ID.
+func writeRisEntry(w io.Writer, b Book, id string) error {
+	var sb strings.Builder
+	line := func(tag, value string) {
+		if len(value) > 0 {
+			fmt.Fprintf(&sb, "%v  - %v\n", tag, value)
+		}
+	}
+
+	line("TY", "BOOK")
+	line("ID", id)
+	for _, role := range contributorRoles {
+		for _, name := range nameListFromString(*b.contributors(role)) {
+			line(risRoleTags[role], risName(name))
+		}
+	}
+	line("TI", b.fullTitle())
+	if len(b.subtitle) > 0 {
+		line("ST", b.title)
+	}
+	line("T2", b.series)
+	line("ET", b.edition.String())
+	line("PB", b.publisher)
+	if b.year != 0 {
+		line("PY", strconv.Itoa(b.year))
+	}
+	line("SN", b.isbn.String())
+	for _, status := range b.status {
+		line("KW", status)
+	}
+	line(risPurchased, b.purchased.String())
+	if b.rating.rated {
+		line(risRating, strconv.FormatFloat(b.rating.stars(), 'f', -1, 64))
+	}
+	sb.WriteString("ER  - \n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// exportRis writes the books with the given ids to w as RIS entries, in the
+// order given, giving the number of books written. The entries' IDs are the
+// books' citation keys. Entries are written as the books are loaded, a batch at
+// a time.
+func exportRis(db DBInterface, w io.Writer, ids []int) (int, error) {
+	keys, err := citationKeys(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportRis: %w", err)
+	}
+
+	count := 0
+	err = eachBook(db, ids, func(b Book) error {
+		if count > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if err := writeRisEntry(w, b, keys[b.id]); err != nil {
+			return fmt.Errorf("Couldn't write book #%v: %v", b.id, err)
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("exportRis: %w", err)
+	}
+	return count, nil
+}
+
+// exportLibraryRis exports the selected books, in id order.
+func exportLibraryRis(db DBInterface, w io.Writer, sel ExportSelection) (int, error) {
+	ids, err := sel.bookIds(db)
+	if err != nil {
+		return 0, fmt.Errorf("exportLibraryRis: %w", err)
+	}
+	return exportRis(db, w, ids)
+}
+
+// risEntryBook reads a book from the values of an entry's tags.
+func risEntryBook(entry map[string][]string) (Book, error) {
+	first := func(tags ...string) string {
+		for _, tag := range tags {
+			if len(entry[tag]) > 0 {
+				return entry[tag][0]
+			}
+		}
+		return ""
+	}
+
+	if ty := first("TY"); !slices.Contains(risTypes, ty) {
+		return Book{}, &UnsupportedTypeError{"risEntryBook", ty}
+	}
+
+	var b Book
+	names := map[ContributorRole][]string{}
+	for _, t := range risImportRoleTags {
+		for _, name := range entry[t.tag] {
+			names[t.role] = append(names[t.role], risNameFromString(name))
+		}
+	}
+	for role, nameList := range names {
+		*b.contributors(role) = formatNameList(nameList)
+	}
+
+	b.title = first("TI", "T1")
+	if short := first("ST"); len(short) > 0 && strings.HasPrefix(b.title, short+": ") {
+		b.title, b.subtitle = short, strings.TrimPrefix(b.title, short+": ")
+	}
+	b.series = first("T2", "T3")
+	b.edition = parseEdition(first("ET"))
+	b.publisher = first("PB")
+	b.year = publishYear(first("PY", "Y1", "DA"))
+	b.status = entry["KW"]
+
+	if len(b.title) == 0 {
+		return Book{}, &MissingFieldError{"risEntryBook", "title"}
+	}
+	if len(b.publisher) == 0 {
+		return Book{}, &MissingFieldError{"risEntryBook", "publisher"}
+	}
+
+	var err error
+	if b.isbn, err = firstIsbn(first("SN")); err != nil {
+		return Book{}, fmt.Errorf("risEntryBook: %w", err)
+	}
+	if purchased := first(risPurchased); len(purchased) > 0 {
+		if err := b.purchased.setDate(purchased); err != nil {
+			return Book{}, fmt.Errorf("risEntryBook: %w", err)
+		}
+	}
+	if b.rating, err = parseRating(first(risRating)); err != nil {
+		return Book{}, fmt.Errorf("risEntryBook: %w", err)
+	}
+	return b, nil
+}
+
+// importRis adds the books in an RIS file to the library, reporting the entries
+// added and those which weren't, by the lines they start on. An error is only
+// returned for problems with the file as a whole or with the database.
+func importRis(db *sql.DB, r io.Reader, dryRun bool) (ImportReport, error) {
+	imp := newBookImporter(db, dryRun)
+
+	var entry map[string][]string
+	var entryLine int
+	lastTag := ""
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if len(strings.TrimSpace(text)) == 0 {
+			continue
+		}
+
+		m := risLinePattern.FindStringSubmatch(text)
+		if m == nil {
+			// values may be continued on the following lines
+			if entry == nil || len(lastTag) == 0 {
+				return imp.report, &RisSyntaxError{"importRis", line,
+					"Expected a tag, e.g. \"TY  - BOOK\""}
+			}
+			values := entry[lastTag]
+			values[len(values)-1] += " " + strings.TrimSpace(text)
+			continue
+		}
+		tag, value := m[1], strings.TrimSpace(m[2])
+
+		switch {
+		case tag == "TY" && entry != nil:
+			return imp.report, &RisSyntaxError{"importRis", line,
+				fmt.Sprintf("Entry from line %v has no ER", entryLine)}
+		case tag == "TY":
+			entry, entryLine = map[string][]string{}, line
+		case entry == nil:
+			return imp.report, &RisSyntaxError{"importRis", line,
+				fmt.Sprintf("Tag %v outside an entry, which must start with TY", tag)}
+		case tag == "ER":
+			b, err := risEntryBook(entry)
+			if err != nil {
+				imp.invalid(entryLine, err)
+				entry, lastTag = nil, ""
+				continue
+			}
+			if b.status, err = imp.existingStatuses("KW", b.status); err != nil {
+				return imp.report, fmt.Errorf("importRis: %w", err)
+			}
+			if err := imp.add(entryLine, b); err != nil {
+				return imp.report, fmt.Errorf("importRis: %w", err)
+			}
+			entry, lastTag = nil, ""
+			continue
+		}
+
+		if !isRisRoleTag(tag) && !slices.Contains(risKnownTags, tag) {
+			imp.ignore(tag)
+		}
+		entry[tag] = append(entry[tag], value)
+		lastTag = tag
+	}
+	if err := scanner.Err(); err != nil {
+		return imp.report, fmt.Errorf("importRis, Couldn't read file: %v", err)
+	}
+	if entry != nil {
+		return imp.report, &RisSyntaxError{"importRis", entryLine, "Entry has no ER"}
+	}
+	return imp.report, nil
+}
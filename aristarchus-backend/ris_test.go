@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRisName(t *testing.T) {
+	names := map[string]string{
+		"Peter J. Gentry":        "Gentry, Peter J.",
+		"Anselm":                 "Anselm",
+		"Martin Luther King Jr.": "King, Martin Luther, Jr.",
+	}
+	for name, expected := range names {
+		if result := risName(name); result != expected {
+			t.Errorf("risName(%q): expected %q, got %q", name, expected, result)
+		}
+		if result := risNameFromString(expected); result != name {
+			t.Errorf("risNameFromString(%q): expected %q, got %q", expected, name, result)
+		}
+	}
+}
+
+func TestWriteRisEntry(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Fatalf("Problem getting book #5: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := writeRisEntry(&buf, b, "gentry2018kingdom"); err != nil {
+		t.Fatalf("writeRisEntry returned error: %v", err)
+	}
+	expected := `TY  - BOOK
+ID  - gentry2018kingdom
+AU  - Gentry, Peter J.
+AU  - Wellum, Stephen J.
+TI  - Kingdom through Covenant: A Biblical-Theological Understanding of the Covenants
+ST  - Kingdom through Covenant
+ET  - 2nd
+PB  - Crossway
+PY  - 2018
+SN  - 978-1-4335-5307-3
+KW  - Owned
+KW  - Read
+C4  - January 2022
+C5  - 4.5
+` + "ER  - \n"
+	if buf.String() != expected {
+		t.Errorf("RIS entry: expected\n%v\ngot\n%v", expected, buf.String())
+	}
+}
+
+func TestExportRisRoundTrip(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	var buf bytes.Buffer
+	count, err := exportLibraryRis(db, &buf, ExportSelection{})
+	if err != nil {
+		t.Fatalf("exportLibraryRis returned error: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("exportLibraryRis: expected 6 books, got %v", count)
+	}
+	report, err := importRis(db, &buf, true)
+	if err != nil {
+		t.Fatalf("importRis returned error: %v", err)
+	}
+
+	if len(report.Added) != 0 || len(report.Invalid) != 0 || len(report.Ignored) != 0 ||
+		len(report.Duplicates) != 6 {
+		t.Errorf("Expected exported books to be read back as duplicates, got %v", report)
+	}
+	for i, rowErr := range report.Duplicates {
+		var duplicateErr *AddingDuplicateBookError
+		if !errors.As(rowErr.Err, &duplicateErr) {
+			t.Errorf("Row %v: expected AddingDuplicateBookError, got %v", rowErr.Row,
+				rowErr.Err)
+			continue
+		}
+		expected, err := getBookById(db, i+1)
+		if err != nil {
+			t.Errorf("Problem getting book #%v: %v", i+1, err)
+		}
+		expected.id = 0
+		if !reflect.DeepEqual(*duplicateErr.book, expected) {
+			t.Errorf("Entry at line %v read differently:\nexpected %#v\ngot      %#v",
+				rowErr.Row, expected, *duplicateErr.book)
+		}
+	}
+}
+
+// testImportRis has an entry for a new book, written as other programs write
+// it, an article, an entry without a publisher and a duplicate.
+const testImportRis = "\ufeff" + `TY  - BOOK
+A1  - Jobes, Karen H.
+A1  - Silva, Moisés
+TI  - Invitation to the Septuagint
+PB  - Baker Academic
+PY  - 2015/01/01/
+ET  - 2nd edition
+SN  - 9780801036491 (pbk.)
+N1  - A note which is
+  continued on the next line
+ER  -
+
+TY  - JOUR
+AU  - Gentry, Peter J.
+TI  - The Septuagint and the Text of the Old Testament
+ER  -
+
+TY  - BOOK
+AU  - Barth, Karl
+TI  - Church Dogmatics
+ER  -
+
+TY  - BOOK
+AU  - Gentry, Peter J.
+AU  - Wellum, Stephen J.
+TI  - Kingdom through Covenant
+PB  - Crossway
+ER  -
+`
+
+func TestImportRis(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := importRis(db, strings.NewReader(testImportRis), false)
+	if err != nil {
+		t.Fatalf("importRis returned error: %v", err)
+	}
+	for _, added := range report.Added {
+		defer deleteBook(db, added.Book.id)
+	}
+
+	if len(report.Added) != 1 || report.Added[0].Row != 1 {
+		t.Fatalf("Expected one book added from line 1, got %v", report.Added)
+	}
+	b, err := getBookById(db, report.Added[0].Book.id)
+	if err != nil {
+		t.Fatalf("Problem getting imported book: %v", err)
+	}
+	if b.author != "Karen H. Jobes and Moisés Silva" || b.year != 2015 ||
+		b.edition != (Edition{number: 2}) || b.isbn != "9780801036491" {
+		t.Errorf("Book imported unexpectedly: %#v", b)
+	}
+
+	var typeErr *UnsupportedTypeError
+	var missingErr *MissingFieldError
+	if len(report.Invalid) != 2 || report.Invalid[0].Row != 13 ||
+		!errors.As(report.Invalid[0].Err, &typeErr) || report.Invalid[1].Row != 18 ||
+		!errors.As(report.Invalid[1].Err, &missingErr) {
+		t.Errorf("Expected article at line 13 and missing publisher at line 18, got %v",
+			report.Invalid)
+	}
+	var duplicateErr *AddingDuplicateBookError
+	if len(report.Duplicates) != 1 || report.Duplicates[0].Row != 23 ||
+		!errors.As(report.Duplicates[0].Err, &duplicateErr) {
+		t.Errorf("Expected duplicate at line 23, got %v", report.Duplicates)
+	}
+	if !reflect.DeepEqual(report.Ignored, []string{"N1"}) {
+		t.Errorf("Expected N1 to be ignored, got %v", report.Ignored)
+	}
+}
+
+func TestImportRisKeywords(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// keywords as a reference manager writes them, with subjects as well as
+	// statuses, and two lifecycle statuses
+	ris := `TY  - BOOK
+AU  - Dempster, Stephen G.
+TI  - Dominion and Dynasty
+PB  - Apollos
+KW  - Theology
+KW  - Owned
+KW  - Read
+KW  - Want
+KW  - Owned
+KW  - Old Testament
+ER  -
+`
+	report, err := importRis(db, strings.NewReader(ris), false)
+	if err != nil {
+		t.Fatalf("importRis returned error: %v", err)
+	}
+	for _, added := range report.Added {
+		defer deleteBook(db, added.Book.id)
+	}
+
+	if len(report.Added) != 1 {
+		t.Fatalf("Expected book to be added, got %+v", report)
+	}
+	b, err := getBookById(db, report.Added[0].Book.id)
+	if err != nil {
+		t.Fatalf("Problem getting imported book: %v", err)
+	}
+	if !reflect.DeepEqual(b.status, []string{"Owned", "Read"}) {
+		t.Errorf("Expected statuses Owned and Read, got %v", b.status)
+	}
+	expected := []string{`KW "Theology"`, `KW "Want"`, `KW "Old Testament"`}
+	if !reflect.DeepEqual(report.Ignored, expected) {
+		t.Errorf("Expected ignored %v, got %v", expected, report.Ignored)
+	}
+
+	statuses, err := listStatuses(db)
+	if err != nil {
+		t.Fatalf("Problem listing statuses: %v", err)
+	}
+	if slices.Contains(statuses, "Theology") || slices.Contains(statuses, "Old Testament") {
+		t.Errorf("Keywords added as statuses: %v", statuses)
+	}
+}
+
+func TestImportRisSyntaxError(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	files := map[string]int{
+		"TI  - No type\nER  - \n":                     1,
+		"Not RIS at all\n":                            1,
+		"TY  - BOOK\nTI  - No end\n":                  1,
+		"TY  - BOOK\nTI  - One\nTY  - BOOK\nER  - \n": 3,
+	}
+	for file, line := range files {
+		report, err := importRis(db, strings.NewReader(file), false)
+		var syntaxErr *RisSyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Line != line {
+			t.Errorf("importRis(%q): expected RisSyntaxError at line %v, got %v", file,
+				line, err)
+		}
+		if len(report.Added) != 0 {
+			t.Errorf("importRis(%q) added books: %v", file, report.Added)
+		}
+	}
+}
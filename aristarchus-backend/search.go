@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Full-text search uses the book_search FTS5 table, which holds each book's
+// title, subtitle, people, publisher, series and notes, and is kept up to date
+// by triggers in the database (see db/setup_books_db.sql). The SQLite driver
+// must be built with FTS5 support, i.e. with `go build -tags sqlite_fts5`.
+//
+// In PostgreSQL, book_search is a view giving the same fields with a weighted
+// tsvector of them all, so needs no index kept up to date. Its aristarchus
+// text search configuration ignores diacritics, as remove_diacritics does for
+// FTS5.
+
+// Markers placed around matched terms in search snippets.
+const (
+	snippetStart = "["
+	snippetEnd   = "]"
+)
+
+// SearchResult is a book matching a search, with a snippet of the best
+// matching field where the matched terms are highlighted. Results with a lower
+// rank are better matches.
+type SearchResult struct {
+	Book    Book
+	Snippet string
+	Rank    float64
+}
+
+func (sr SearchResult) String() string {
+	return fmt.Sprintf("%v\n    %v", sr.Book, sr.Snippet)
+}
+
+// ftsQuery turns a search typed by the user into an FTS5 query. Each word is
+// quoted, so that punctuation such as the apostrophe in "God's" or a hyphen is
+// handled by the tokenizer rather than read as query syntax, and matches as a
+// prefix, so that e.g. "Gent" finds "Gentry". All words must match.
+func ftsQuery(search string) string {
+	var terms []string
+	for _, word := range strings.Fields(search) {
+		if strings.IndexFunc(word, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r)
+		}) < 0 {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// tsQueryWords splits a search into words, each given as its runs of letters
+// and digits, which is how PostgreSQL's parser splits e.g. "God's" into "God"
+// and "s".
+func tsQueryWords(search string) [][]string {
+	var words [][]string
+	for _, word := range strings.Fields(search) {
+		tokens := strings.FieldsFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(tokens) > 0 {
+			words = append(words, tokens)
+		}
+	}
+	return words
+}
+
+// tsQuery turns a search typed by the user into a PostgreSQL tsquery, as
+// ftsQuery does for FTS5. The parts of each word must follow one another, and
+// the last matches as a prefix. The words are joined by op, "&" for all words
+// to match or "|" for any.
+func tsQuery(search string, op string) string {
+	var terms []string
+	for _, tokens := range tsQueryWords(search) {
+		terms = append(terms, "("+strings.Join(tokens, " <-> ")+":*)")
+	}
+	return strings.Join(terms, " "+op+" ")
+}
+
+// tsPhraseQuery gives a tsquery matching the words of a phrase in order,
+// without prefixes.
+func tsPhraseQuery(phrase string) string {
+	var tokens []string
+	for _, word := range tsQueryWords(phrase) {
+		tokens = append(tokens, word...)
+	}
+	return strings.Join(tokens, " <-> ")
+}
+
+// searchSql gives the SQL statement for searchBooks in a dialect, selecting the
+// id, snippet and score of each matching book, and its arguments. The
+// PostgreSQL snippet is of the first field matching any of the words, which
+// are all highlighted, with the fields weighted as for FTS5.
+func searchSql(d Dialect, search string) (string, []any) {
+	if d == Postgres {
+		options := fmt.Sprintf(`StartSel="%v", StopSel="%v", MaxWords=12, MinWords=4`,
+			snippetStart, snippetEnd)
+		var snippets []string
+		for _, field := range []string{"title", "subtitle", "people", "publisher",
+			"series", "notes"} {
+			snippets = append(snippets, fmt.Sprintf(`
+            WHEN to_tsvector('aristarchus', %v) @@ any_word
+              THEN ts_headline('aristarchus', %[1]v, any_word, '%v')`, field, options))
+		}
+		return `
+        SELECT book_id,
+          CASE` + strings.Join(snippets, "") + `
+            ELSE ''
+          END,
+          -ts_rank('{0.1, 0.25, 0.5, 1.0}', document, query) AS score
+        FROM book_search,
+          to_tsquery('aristarchus', ?) AS query,
+          to_tsquery('aristarchus', ?) AS any_word
+        WHERE document @@ query
+        ORDER BY score, book_id`, []any{tsQuery(search, "&"), tsQuery(search, "|")}
+	}
+
+	return `
+        SELECT rowid,
+          snippet(book_search, -1, ?, ?, '…', 12),
+          bm25(book_search, 10.0, 5.0, 8.0, 2.0, 3.0, 1.0) AS score
+        FROM book_search
+        WHERE book_search MATCH ?
+        ORDER BY score, rowid`, []any{snippetStart, snippetEnd, ftsQuery(search)}
+}
+
+// searchBooks finds books matching all the words of the search in any of their
+// title, subtitle, authors and editors, publisher, series or notes, best
+// matches first. Matches in the title and people count for more than matches
+// in the notes.
+func searchBooks(db DBInterface, search string) ([]SearchResult, error) {
+	if len(ftsQuery(search)) == 0 {
+		return nil, nil
+	}
+
+	sqlStmt, args := searchSql(dialectOf(db), search)
+	rows, err := db.Query(sqlStmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searchBooks, Couldn't search for \"%v\": %v",
+			search, err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var sr SearchResult
+		if err := rows.Scan(&sr.Book.id, &sr.Snippet, &sr.Rank); err != nil {
+			return nil, fmt.Errorf("searchBooks, Issue scanning search result: %v", err)
+		}
+		results = append(results, sr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("searchBooks, rows.Next() error: %v", err)
+	}
+	rows.Close()
+
+	ids := make([]int, len(results))
+	for i := range results {
+		ids[i] = results[i].Book.id
+	}
+	books, err := loadBooks(db, ids)
+	if err != nil {
+		return nil, fmt.Errorf("searchBooks: %w", err)
+	}
+	for i := range results {
+		results[i].Book = books[i]
+	}
+	return results, nil
+}
+
+// rebuildSearchIndex refills the search index from the books in the database,
+// for use if it has got out of step, e.g. after editing the database with the
+// triggers disabled. A PostgreSQL database has no index to rebuild.
+func rebuildSearchIndex(db DBInterface) error {
+	if dialectOf(db) == Postgres {
+		return nil
+	}
+	if _, err := db.Exec("DELETE FROM book_search"); err != nil {
+		return fmt.Errorf("rebuildSearchIndex, Couldn't clear index: %v", err)
+	}
+	sqlStmt := `
+        INSERT INTO book_search (rowid, title, subtitle, people, publisher,
+                                 series, notes)
+        SELECT * FROM book_search_source`
+	if _, err := db.Exec(sqlStmt); err != nil {
+		return fmt.Errorf("rebuildSearchIndex, Couldn't fill index: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func searchResultIds(results []SearchResult) []int {
+	var ids []int
+	for _, r := range results {
+		ids = append(ids, r.Book.id)
+	}
+	return ids
+}
+
+func TestFtsQuery(t *testing.T) {
+	searches := map[string]string{
+		"Gentry":               `"Gentry"*`,
+		"God's emotions":       `"God's"*` + ` "emotions"*`,
+		`say "hello" - & :`:    `"say"* """hello"""*`,
+		"   ":                  "",
+		"Biblical-Theological": `"Biblical-Theological"*`,
+	}
+
+	for search, expected := range searches {
+		if query := ftsQuery(search); query != expected {
+			t.Errorf("ftsQuery(%q) returned %v, expected %v", search, query, expected)
+		}
+	}
+}
+
+func TestTsQuery(t *testing.T) {
+	searches := map[string]string{
+		"Gentry":               "(Gentry:*)",
+		"God's emotions":       "(God <-> s:*) & (emotions:*)",
+		`say "hello" - & :`:    "(say:*) & (hello:*)",
+		"   ":                  "",
+		"Biblical-Theological": "(Biblical <-> Theological:*)",
+		"Moisés":               "(Moisés:*)",
+	}
+
+	for search, expected := range searches {
+		if query := tsQuery(search, "&"); query != expected {
+			t.Errorf("tsQuery(%q) returned %v, expected %v", search, query, expected)
+		}
+	}
+	if query := tsQuery("Gentry covenant", "|"); query != "(Gentry:*) | (covenant:*)" {
+		t.Errorf("tsQuery with | returned %v", query)
+	}
+	if query := tsPhraseQuery("God's emotions"); query != "God <-> s <-> emotions" {
+		t.Errorf("tsPhraseQuery returned %v", query)
+	}
+}
+
+func TestSearchBooks(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	searches := map[string][]int{
+		"Gentry":                    {4, 5},
+		"gentry covenant":           {5},
+		"wellum kingdom":            {5},
+		"God's":                     {2},
+		"gods emotions":             {},
+		"Anselm":                    {3},
+		"Spectrum multiview":        {2},
+		"progressive covenantalism": {5},
+		"Crossway prophets":         {4},
+		"Bavinck science":           {6},
+		"Moises":                    {},
+	}
+
+	for search, expected := range searches {
+		results, err := searchBooks(db, search)
+		if err != nil {
+			t.Errorf("searchBooks(%q) returned error: %v", search, err)
+			continue
+		}
+		ids := searchResultIds(results)
+		if len(ids) == 0 && len(expected) == 0 {
+			continue
+		}
+		if !slices.Equal(ids, expected) {
+			t.Errorf("searchBooks(%q) returned books %v, expected %v", search,
+				ids, expected)
+		}
+	}
+}
+
+func TestSearchBooksRanking(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// "covenant" is in the title of book 5, but only in a note of no other
+	// book, so book 5 should come first
+	results, err := searchBooks(db, "covenant")
+	if err != nil {
+		t.Fatalf("searchBooks returned error: %v", err)
+	}
+	if len(results) == 0 || results[0].Book.id != 5 {
+		t.Errorf("searchBooks ranked unexpected book first: %v", searchResultIds(results))
+	}
+	if results[0].Book.title != "Kingdom through Covenant" {
+		t.Errorf("searchBooks did not return full book: %v", results[0].Book)
+	}
+}
+
+func TestSearchBooksSnippet(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	results, err := searchBooks(db, "God's")
+	if err != nil {
+		t.Fatalf("searchBooks returned error: %v", err)
+	}
+	expected := "Four Views of [God's] Emotions and Suffering"
+	if testDialect == Postgres {
+		// ts_headline highlights each part of the word
+		expected = "Four Views of [God]'[s] Emotions and Suffering"
+	}
+	if len(results) != 1 || results[0].Snippet != expected {
+		t.Errorf("searchBooks returned unexpected snippet. Expected %v, got %v",
+			expected, results)
+	}
+}
+
+func TestSearchBooksEmpty(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	for _, search := range []string{"", "  ", "- & :", `"`} {
+		results, err := searchBooks(db, search)
+		if err != nil {
+			t.Errorf("searchBooks(%q) returned error: %v", search, err)
+		}
+		if len(results) != 0 {
+			t.Errorf("searchBooks(%q) returned results: %v", search, results)
+		}
+	}
+}
+
+func TestSearchIndexFollowsUpdates(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id, err := addBook(db, makeTestBook())
+	if err != nil {
+		t.Fatalf("Problem adding book to test search index: %v", err)
+	}
+	defer deleteBook(db, id)
+
+	check := func(search string, found bool) {
+		t.Helper()
+		results, err := searchBooks(db, search)
+		if err != nil {
+			t.Errorf("searchBooks(%q) returned error: %v", search, err)
+		}
+		if slices.Contains(searchResultIds(results), id) != found {
+			t.Errorf("searchBooks(%q) found book %v: %v, expected %v", search,
+				id, !found, found)
+		}
+	}
+
+	check("Jobes Septuagint", true)
+	check("Moises", true)
+
+	if _, err := updateBookTitle(db, id, "Invitation to the Greek Old Testament"); err != nil {
+		t.Errorf("Problem updating title: %v", err)
+	}
+	check("Septuagint", false)
+	check("Greek", true)
+
+	pid, err := personId(db, "Karen H. Jobes")
+	if err != nil {
+		t.Fatalf("Problem getting person id: %v", err)
+	}
+	if _, err := updatePersonName(db, pid, "Karen Jobes"); err != nil {
+		t.Errorf("Problem updating person name: %v", err)
+	}
+	check("Karen H.", false)
+	check("Karen Jobes", true)
+
+	if _, err := addNote(db, id, "", "Very good on the Hexapla."); err != nil {
+		t.Errorf("Problem adding note: %v", err)
+	}
+	check("hexapla", true)
+
+	if err := deleteBook(db, id); err != nil {
+		t.Errorf("Problem deleting book: %v", err)
+	}
+	check("Greek", false)
+}
+
+func TestRebuildSearchIndex(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	if err := rebuildSearchIndex(db); err != nil {
+		t.Errorf("rebuildSearchIndex returned error: %v", err)
+	}
+
+	results, err := searchBooks(db, "Harrison")
+	if err != nil {
+		t.Errorf("searchBooks returned error: %v", err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Snippet, "[Harrison]") {
+		t.Errorf("Unexpected search results after rebuilding index: %v", results)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// The queries run most often by the helper functions are registered here and
+// prepared once per database by prepareStatements, rather than being parsed
+// again on every call. Helpers run them through stmtQueryRow, stmtQuery and
+// stmtExec, which use the prepared statement when there is one for the
+// database handle they are given (bound to the transaction for a StmtTx), and
+// otherwise fall back to running the SQL directly.
+
+type stmtKey int
+
+const (
+	stmtCountBooks stmtKey = iota
+	stmtCountBooksByStatus
+	stmtBookIdValid
+	stmtBookById
+	stmtStatusListById
+	stmtContributorsListById
+	stmtContributorsLastPosition
+	stmtInsertContributor
+	stmtDeleteContributor
+	stmtSetContributorPosition
+	stmtPersonIdValid
+	stmtPersonIdByName
+	stmtInsertPerson
+	stmtPersonName
+	stmtBooksByPersonId
+	stmtPublisherIdValid
+	stmtPublisherIdByName
+	stmtInsertPublisher
+	stmtPublisherName
+	stmtSeriesIdValid
+	stmtSeriesIdByName
+	stmtInsertSeries
+	stmtSeriesName
+	stmtStatusIdByName
+	stmtInsertStatus
+)
+
+var statementSql = map[stmtKey]string{
+	stmtCountBooks: `SELECT COUNT(book_id) FROM books`,
+	stmtCountBooksByStatus: `
+        SELECT COUNT(book_status.book_id)
+        FROM book_status
+        INNER JOIN status
+          ON book_status.status_id = status.status_id
+        WHERE status.status_name = ?`,
+	stmtBookIdValid: `
+        SELECT COUNT(*)
+        FROM books
+        WHERE book_id = ?`,
+	stmtBookById: bookSelect + `
+        WHERE book_id = ?`,
+	stmtStatusListById: `
+        SELECT status.status_name
+        FROM status
+        INNER JOIN book_status
+          ON book_status.status_id = status.status_id
+        WHERE book_status.book_id = ?
+        ORDER BY status.status_name`,
+	stmtContributorsListById: `
+        SELECT people.name
+        FROM people
+        INNER JOIN book_contributor
+          ON book_contributor.person_id = people.person_id
+        WHERE book_contributor.book_id = ?
+          AND book_contributor.role = ?
+        ORDER BY book_contributor.position`,
+	stmtContributorsLastPosition: `
+        SELECT COALESCE(MAX(position), 0)
+        FROM book_contributor
+        WHERE book_id = ? AND role = ?`,
+	stmtInsertContributor: `
+        INSERT INTO book_contributor (book_id, person_id, role, position)
+        VALUES (?, ?, ?, ?)`,
+	stmtDeleteContributor: `
+        DELETE FROM book_contributor
+        WHERE book_id = ? AND person_id = ? AND role = ?`,
+	stmtSetContributorPosition: `
+        UPDATE book_contributor
+        SET position = ?
+        WHERE book_id = ? AND person_id = ? AND role = ?`,
+	stmtPersonIdValid: `
+        SELECT COUNT(*)
+        FROM people
+        WHERE person_id = ?`,
+	stmtPersonIdByName: `SELECT person_id FROM people WHERE name = ?`,
+	stmtInsertPerson:   `INSERT INTO people (name) VALUES (?) RETURNING person_id`,
+	stmtPersonName: `
+        SELECT name
+        FROM people
+        WHERE person_id = ?`,
+	stmtBooksByPersonId: `
+        SELECT DISTINCT book_id
+        FROM book_contributor
+        WHERE person_id = ?
+        ORDER BY book_id`,
+	stmtPublisherIdValid: `
+        SELECT COUNT(*)
+        FROM publishers
+        WHERE publisher_id = ?`,
+	stmtPublisherIdByName: `SELECT publisher_id FROM publishers WHERE name = ?`,
+	stmtInsertPublisher:   `INSERT INTO publishers (name) VALUES (?) RETURNING publisher_id`,
+	stmtPublisherName: `
+        SELECT name
+        FROM publishers
+        WHERE publisher_id = ?`,
+	stmtSeriesIdValid: `
+        SELECT COUNT(*)
+        FROM series
+        WHERE series_id = ?`,
+	stmtSeriesIdByName: `SELECT series_id FROM series WHERE series_name = ?`,
+	stmtInsertSeries:   `INSERT INTO series (series_name) VALUES (?) RETURNING series_id`,
+	stmtSeriesName: `
+        SELECT series_name
+        FROM series
+        WHERE series_id = ?`,
+	stmtStatusIdByName: `SELECT status_id FROM status WHERE status_name = ?`,
+	stmtInsertStatus:   `INSERT INTO status (status_name) VALUES (?) RETURNING status_id`,
+}
+
+// Statements holds the registered queries prepared against a database.
+type Statements struct {
+	db    *sql.DB
+	stmts map[stmtKey]*sql.Stmt
+}
+
+// preparedStatements records the Statements prepared for each database, so
+// that the helpers find them from the *sql.DB they are passed.
+var preparedStatements = struct {
+	sync.RWMutex
+	byDb map[*sql.DB]*Statements
+}{byDb: map[*sql.DB]*Statements{}}
+
+// prepareStatements prepares all the registered queries against db, for use
+// by the helpers until Close is called. Preparing a database a second time
+// gives the existing statements.
+func prepareStatements(db *sql.DB) (*Statements, error) {
+	preparedStatements.Lock()
+	defer preparedStatements.Unlock()
+
+	if s, ok := preparedStatements.byDb[db]; ok {
+		return s, nil
+	}
+
+	s := &Statements{db, map[stmtKey]*sql.Stmt{}}
+	for key, query := range statementSql {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			s.closeAll()
+			return nil, fmt.Errorf("prepareStatements, Couldn't prepare statement %v: %v",
+				key, err)
+		}
+		s.stmts[key] = stmt
+	}
+	preparedStatements.byDb[db] = s
+	return s, nil
+}
+
+func (s *Statements) closeAll() error {
+	var errs []error
+	for _, stmt := range s.stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the prepared statements, after which the helpers go back to
+// running their queries directly on the database.
+func (s *Statements) Close() error {
+	preparedStatements.Lock()
+	delete(preparedStatements.byDb, s.db)
+	preparedStatements.Unlock()
+
+	if err := s.closeAll(); err != nil {
+		return fmt.Errorf("Statements.Close: %v", err)
+	}
+	return nil
+}
+
+func statementsFor(db *sql.DB) *Statements {
+	preparedStatements.RLock()
+	defer preparedStatements.RUnlock()
+	return preparedStatements.byDb[db]
+}
+
+// StatementDB is a DBInterface which can also give prepared statements for
+// the registered queries, returning nil for those it hasn't prepared.
+type StatementDB interface {
+	DBInterface
+	statement(key stmtKey) *sql.Stmt
+}
+
+// StmtTx is a transaction which runs the registered queries with the
+// statements prepared for its database, and knows the database's dialect.
+type StmtTx struct {
+	*sql.Tx
+	stmts   *Statements
+	bound   map[stmtKey]*sql.Stmt
+	dialect Dialect
+}
+
+// beginTx starts a transaction on db, which uses the statements prepared for
+// db if there are any.
+func beginTx(db *sql.DB) (*StmtTx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &StmtTx{tx, statementsFor(db), map[stmtKey]*sql.Stmt{}, dialectOf(db)}, nil
+}
+
+// withTx runs fn in a transaction. Given a *sql.DB, it starts one and commits
+// it if fn succeeds; given a transaction, fn runs in it, leaving the caller to
+// commit, so that a helper can be part of a larger change.
+func withTx(db DBInterface, fn func(tx DBInterface) error) error {
+	sqlDb, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+	tx, err := beginTx(sqlDb)
+	if err != nil {
+		return fmt.Errorf("Couldn't start sql transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statement gives the prepared statement for key bound to the transaction.
+// Bound statements are closed with the transaction.
+func (tx *StmtTx) statement(key stmtKey) *sql.Stmt {
+	if tx.stmts == nil {
+		return nil
+	}
+	if stmt, ok := tx.bound[key]; ok {
+		return stmt
+	}
+	stmt := tx.Tx.Stmt(tx.stmts.stmts[key])
+	tx.bound[key] = stmt
+	return stmt
+}
+
+// preparedStatement gives the prepared statement for key to use with db, or
+// nil if there isn't one.
+func preparedStatement(db DBInterface, key stmtKey) *sql.Stmt {
+	switch d := db.(type) {
+	case StatementDB:
+		return d.statement(key)
+	case *sql.DB:
+		if s := statementsFor(d); s != nil {
+			return s.stmts[key]
+		}
+	}
+	return nil
+}
+
+func stmtQueryRow(db DBInterface, key stmtKey, args ...any) *sql.Row {
+	if stmt := preparedStatement(db, key); stmt != nil {
+		return stmt.QueryRow(args...)
+	}
+	return db.QueryRow(statementSql[key], args...)
+}
+
+func stmtQuery(db DBInterface, key stmtKey, args ...any) (*sql.Rows, error) {
+	if stmt := preparedStatement(db, key); stmt != nil {
+		return stmt.Query(args...)
+	}
+	return db.Query(statementSql[key], args...)
+}
+
+func stmtExec(db DBInterface, key stmtKey, args ...any) (sql.Result, error) {
+	if stmt := preparedStatement(db, key); stmt != nil {
+		return stmt.Exec(args...)
+	}
+	return db.Exec(statementSql[key], args...)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareStatements(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	unprepared, err := getBookById(db, 5)
+	if err != nil {
+		t.Errorf("Problem getting book #5 without prepared statements: %v", err)
+	}
+
+	stmts, err := prepareStatements(db)
+	if err != nil {
+		t.Fatalf("Problem preparing statements: %v", err)
+	}
+	if len(stmts.stmts) != len(statementSql) {
+		t.Errorf("Expected %v prepared statements, got %v", len(statementSql),
+			len(stmts.stmts))
+	}
+	if statementsFor(db) != stmts {
+		t.Errorf("Prepared statements not registered for database")
+	}
+	again, err := prepareStatements(db)
+	if err != nil {
+		t.Errorf("Problem preparing statements a second time: %v", err)
+	}
+	if again != stmts {
+		t.Errorf("Preparing statements a second time didn't give the existing statements")
+	}
+
+	prepared, err := getBookById(db, 5)
+	if err != nil {
+		t.Errorf("Problem getting book #5 with prepared statements: %v", err)
+	}
+	if !reflect.DeepEqual(prepared, unprepared) {
+		t.Errorf("Prepared statements gave a different book: expected %v, got %v",
+			unprepared, prepared)
+	}
+
+	pid, err := personId(db, "Anselm")
+	if err != nil {
+		t.Errorf("Problem getting person id with prepared statements: %v", err)
+	}
+	if pid != 2 {
+		t.Errorf("Wrong id for Anselm with prepared statements: expected 2, got %v", pid)
+	}
+
+	if err := stmts.Close(); err != nil {
+		t.Errorf("Problem closing prepared statements: %v", err)
+	}
+	if statementsFor(db) != nil {
+		t.Errorf("Prepared statements still registered after Close")
+	}
+	if _, err := getBookById(db, 5); err != nil {
+		t.Errorf("Problem getting book #5 after closing statements: %v", err)
+	}
+}
+
+func TestStmtTx(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	// without prepared statements, the transaction runs the SQL directly
+	tx, err := beginTx(db)
+	if err != nil {
+		t.Fatalf("Problem starting transaction: %v", err)
+	}
+	if tx.statement(stmtBookIdValid) != nil {
+		t.Errorf("Transaction gave a statement for an unprepared database")
+	}
+	valid, err := BookIDValid(tx, 1)
+	if err != nil || !valid {
+		t.Errorf("BookIDValid in unprepared transaction: expected true, got %v, %v",
+			valid, err)
+	}
+	tx.Rollback()
+
+	stmts, err := prepareStatements(db)
+	if err != nil {
+		t.Fatalf("Problem preparing statements: %v", err)
+	}
+	defer stmts.Close()
+
+	tx, err = beginTx(db)
+	if err != nil {
+		t.Fatalf("Problem starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.statement(stmtPersonIdByName)
+	if stmt == nil {
+		t.Fatalf("Transaction gave no statement for a prepared database")
+	}
+	if tx.statement(stmtPersonIdByName) != stmt {
+		t.Errorf("Transaction bound the same statement twice")
+	}
+
+	pid, err := personId(tx, "Benedict Ward")
+	if err != nil {
+		t.Errorf("Problem adding person in transaction: %v", err)
+	}
+	var name string
+	if err := tx.QueryRow("SELECT name FROM people WHERE person_id = ?",
+		pid).Scan(&name); err != nil || name != "Benedict Ward" {
+		t.Errorf("Person added by prepared statement not seen in transaction: %v, %v",
+			name, err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Errorf("Problem rolling back transaction: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM people WHERE name = ?",
+		"Benedict Ward").Scan(&count); err != nil {
+		t.Errorf("Problem checking for rolled back person: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Person added in rolled back transaction was kept")
+	}
+}
+
+func TestAddBookPrepared(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	stmts, err := prepareStatements(db)
+	if err != nil {
+		t.Fatalf("Problem preparing statements: %v", err)
+	}
+	defer stmts.Close()
+
+	b := makeTestBook()
+	id, err := addBook(db, b)
+	if err != nil {
+		t.Fatalf("Problem adding book with prepared statements: %v", err)
+	}
+	added, err := getBookById(db, id)
+	if err != nil {
+		t.Errorf("Problem getting added book: %v", err)
+	}
+	if added.String() != b.String() {
+		t.Errorf("Book added with prepared statements differs: expected %v, got %v",
+			b, added)
+	}
+	if err := deleteBook(db, id); err != nil {
+		t.Errorf("Problem deleting added book: %v", err)
+	}
+}
+
+// benchmarkHelpers runs a mix of the helper lookups against the benchmark
+// library, as done when listing or editing books.
+func benchmarkHelpers(b *testing.B, db *sql.DB) {
+	for i := 0; i < b.N; i++ {
+		id := i%benchmarkLibrarySize + 7
+		if _, err := getBookById(db, id); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := personId(db, fmt.Sprintf("Author %d", i%1000)); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := publisherId(db, fmt.Sprintf("Publisher %d", i%40)); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := countBooksByStatus(db, "Owned"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHelpers(b *testing.B) {
+	b.Run("unprepared", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		b.ResetTimer()
+		benchmarkHelpers(b, db)
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		stmts, err := prepareStatements(db)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer stmts.Close()
+		b.ResetTimer()
+		benchmarkHelpers(b, db)
+	})
+}
+
+func BenchmarkBookIDValid(b *testing.B) {
+	b.Run("unprepared", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := BookIDValid(db, i%benchmarkLibrarySize+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("prepared", func(b *testing.B) {
+		db := openBenchmarkLibrary(b)
+		stmts, err := prepareStatements(db)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer stmts.Close()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := BookIDValid(db, i%benchmarkLibrarySize+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
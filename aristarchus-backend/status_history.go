@@ -0,0 +1,408 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Statuses which appear in the status_transition table make up the book's
+// lifecycle (e.g. Want -> Ordered -> Owned -> Lent -> Given away). A book can
+// have at most one lifecycle status at a time, and can only move from one to
+// another along an allowed transition. Once in the lifecycle a book can't
+// leave it, as it could then re-enter at any status. Other statuses, such as
+// "Read", are free labels which can be added and removed at any time.
+
+type StatusTransitionError struct {
+	CallFunc string
+	BookId   int
+	From     string
+	To       string
+}
+
+func (e *StatusTransitionError) Error() string {
+	if len(e.From) == 0 {
+		return fmt.Sprintf("%v: Book #%v cannot be given status %v",
+			e.CallFunc, e.BookId, e.To)
+	}
+	if len(e.To) == 0 {
+		return fmt.Sprintf("%v: Book #%v cannot leave lifecycle status %v",
+			e.CallFunc, e.BookId, e.From)
+	}
+	return fmt.Sprintf("%v: Book #%v cannot move from status %v to %v",
+		e.CallFunc, e.BookId, e.From, e.To)
+}
+
+// StatusChange is a single entry in a book's status history. From is empty
+// when a status was added to the book, and To is empty when a status was
+// removed.
+type StatusChange struct {
+	BookId    int
+	From      string
+	To        string
+	ChangedAt time.Time
+}
+
+func (sc StatusChange) String() string {
+	switch {
+	case len(sc.From) == 0:
+		return fmt.Sprintf("%v: book #%v %v", sc.ChangedAt.Format(timestampFormat),
+			sc.BookId, sc.To)
+	case len(sc.To) == 0:
+		return fmt.Sprintf("%v: book #%v no longer %v",
+			sc.ChangedAt.Format(timestampFormat), sc.BookId, sc.From)
+	default:
+		return fmt.Sprintf("%v: book #%v %v -> %v",
+			sc.ChangedAt.Format(timestampFormat), sc.BookId, sc.From, sc.To)
+	}
+}
+
+func addStatusTransition(db DBInterface, from string, to string) error {
+	if from == to {
+		return fmt.Errorf("addStatusTransition: Cannot add transition from %v to itself", from)
+	}
+	fromId, err := statusId(db, from)
+	if err != nil {
+		return fmt.Errorf("addStatusTransition: %v", err)
+	}
+	toId, err := statusId(db, to)
+	if err != nil {
+		return fmt.Errorf("addStatusTransition: %v", err)
+	}
+
+	sqlStmt := `
+        INSERT INTO status_transition (from_status_id, to_status_id)
+        VALUES (?, ?)
+        ON CONFLICT DO NOTHING
+    `
+	_, err = db.Exec(sqlStmt, fromId, toId)
+	if err != nil {
+		return fmt.Errorf("addStatusTransition, Couldn't add transition %v -> %v: %v",
+			from, to, err)
+	}
+	return nil
+}
+
+func removeStatusTransition(db DBInterface, from string, to string) error {
+	sqlStmt := `
+        DELETE FROM status_transition
+        WHERE from_status_id = (SELECT status_id FROM status WHERE status_name = ?)
+          AND to_status_id = (SELECT status_id FROM status WHERE status_name = ?)
+    `
+	result, err := db.Exec(sqlStmt, from, to)
+	if err != nil {
+		return fmt.Errorf("removeStatusTransition, Couldn't remove transition %v -> %v: %v",
+			from, to, err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("removeStatusTransition: %v", err)
+	}
+	if removed == 0 {
+		return fmt.Errorf("removeStatusTransition: No transition from %v to %v", from, to)
+	}
+	return nil
+}
+
+// listStatusTransitions returns the allowed transitions, mapping each
+// lifecycle status to the statuses a book can move to from it.
+func listStatusTransitions(db DBInterface) (map[string][]string, error) {
+	sqlStmt := `
+        SELECT from_status.status_name, to_status.status_name
+        FROM status_transition
+        INNER JOIN status AS from_status
+          ON status_transition.from_status_id = from_status.status_id
+        INNER JOIN status AS to_status
+          ON status_transition.to_status_id = to_status.status_id
+        ORDER BY from_status.status_name, to_status.status_name`
+	rows, err := db.Query(sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("listStatusTransitions, %v", err)
+	}
+	defer rows.Close()
+
+	transitions := make(map[string][]string)
+	for rows.Next() {
+		var from, to string
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, fmt.Errorf("listStatusTransitions, %v", err)
+		}
+		transitions[from] = append(transitions[from], to)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listStatusTransitions, rows.Next() error: %v", err)
+	}
+	return transitions, nil
+}
+
+func allowedNextStatuses(db DBInterface, from string) ([]string, error) {
+	transitions, err := listStatusTransitions(db)
+	if err != nil {
+		return nil, fmt.Errorf("allowedNextStatuses: %w", err)
+	}
+	return transitions[from], nil
+}
+
+// lifecycleStatuses returns every status which takes part in a transition.
+func lifecycleStatuses(db DBInterface) ([]string, error) {
+	sqlStmt := `
+        SELECT status_name
+        FROM status
+        WHERE status_id IN (SELECT from_status_id FROM status_transition)
+          OR status_id IN (SELECT to_status_id FROM status_transition)
+        ORDER BY status_name`
+	rows, err := db.Query(sqlStmt)
+	if err != nil {
+		return nil, fmt.Errorf("lifecycleStatuses, %v", err)
+	}
+	defer rows.Close()
+
+	var statuses []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("lifecycleStatuses, %v", err)
+		}
+		statuses = append(statuses, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("lifecycleStatuses, rows.Next() error: %v", err)
+	}
+	return statuses, nil
+}
+
+// bookLifecycleStatus picks out the lifecycle status from a book's list of
+// statuses, returning an empty string if it has none.
+func bookLifecycleStatus(statusList []string, lifecycle []string) string {
+	for _, status := range statusList {
+		if slices.Contains(lifecycle, status) {
+			return status
+		}
+	}
+	return ""
+}
+
+func statusTransitionAllowed(db DBInterface, from string, to string) (bool, error) {
+	sqlStmt := `
+        SELECT COUNT(*)
+        FROM status_transition
+        WHERE from_status_id = (SELECT status_id FROM status WHERE status_name = ?)
+          AND to_status_id = (SELECT status_id FROM status WHERE status_name = ?)`
+	var count int
+	if err := db.QueryRow(sqlStmt, from, to).Scan(&count); err != nil {
+		return false, fmt.Errorf("statusTransitionAllowed, %v", err)
+	}
+	return count != 0, nil
+}
+
+// recordStatusChange adds an entry to the status history. Either of from or to
+// may be empty, for a status being added to or removed from a book.
+func recordStatusChange(db DBInterface, id int, from string, to string) error {
+	var fromId, toId sql.NullInt64
+	if len(from) != 0 {
+		stId, err := statusId(db, from)
+		if err != nil {
+			return fmt.Errorf("recordStatusChange: %v", err)
+		}
+		fromId.Valid = true
+		fromId.Int64 = int64(stId)
+	}
+	if len(to) != 0 {
+		stId, err := statusId(db, to)
+		if err != nil {
+			return fmt.Errorf("recordStatusChange: %v", err)
+		}
+		toId.Valid = true
+		toId.Int64 = int64(stId)
+	}
+
+	sqlStmt := `
+        INSERT INTO status_history (book_id, from_status_id, to_status_id, changed_at)
+        VALUES (?, ?, ?, ?)
+    `
+	changedAt := clock().UTC().Format(timestampFormat)
+	_, err := db.Exec(sqlStmt, id, fromId, toId, changedAt)
+	if err != nil {
+		return fmt.Errorf("recordStatusChange, Couldn't record status change for book #%v: %v",
+			id, err)
+	}
+	return nil
+}
+
+// moveBookStatus replaces one status on a book with another and records the
+// change as a single entry in the history. It does not check that the
+// transition is allowed.
+func moveBookStatus(db DBInterface, id int, from string, to string) error {
+	toId, err := statusId(db, to)
+	if err != nil {
+		return fmt.Errorf("moveBookStatus: %v", err)
+	}
+
+	sqlStmt := `
+        UPDATE book_status
+        SET status_id = ?
+        WHERE book_id = ?
+          AND status_id = (SELECT status_id FROM status WHERE status_name = ?)
+    `
+	_, err = db.Exec(sqlStmt, toId, id, from)
+	if err != nil {
+		return fmt.Errorf("moveBookStatus, Couldn't move book #%v from %v to %v: %v",
+			id, from, to, err)
+	}
+
+	return recordStatusChange(db, id, from, to)
+}
+
+// transitionBookStatus moves a book to a new lifecycle status, provided the
+// move is allowed from the book's current lifecycle status. A book which has
+// never had a lifecycle status can enter the lifecycle at any status.
+func transitionBookStatus(db *sql.DB, id int, to string) ([]string, error) {
+	statusList, err := getStatusListById(db, id)
+	if err != nil {
+		return []string{}, fmt.Errorf("transitionBookStatus: %w", err)
+	}
+
+	lifecycle, err := lifecycleStatuses(db)
+	if err != nil {
+		return statusList, fmt.Errorf("transitionBookStatus: %w", err)
+	}
+	if !slices.Contains(lifecycle, to) {
+		return statusList, fmt.Errorf("transitionBookStatus: %v is not a lifecycle status", to)
+	}
+
+	from := bookLifecycleStatus(statusList, lifecycle)
+	if from == to {
+		return statusList, nil
+	}
+
+	// start a transaction so the status and its history change together
+	tx, err := beginTx(db)
+	if err != nil {
+		return statusList, fmt.Errorf("transitionBookStatus, Couldn't start sql transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if len(from) == 0 {
+		if _, err := addBookStatus(tx, id, to); err != nil {
+			return statusList, fmt.Errorf("transitionBookStatus: %w", err)
+		}
+	} else {
+		allowed, err := statusTransitionAllowed(tx, from, to)
+		if err != nil {
+			return statusList, fmt.Errorf("transitionBookStatus: %w", err)
+		}
+		if !allowed {
+			return statusList, &StatusTransitionError{"transitionBookStatus", id, from, to}
+		}
+		if err := moveBookStatus(tx, id, from, to); err != nil {
+			return statusList, fmt.Errorf("transitionBookStatus: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return statusList, fmt.Errorf("transitionBookStatus, issue updating status: %v", err)
+	}
+
+	return getStatusListById(db, id)
+}
+
+const statusHistorySelect = `
+        SELECT status_history.book_id, from_status.status_name,
+          to_status.status_name, status_history.changed_at
+        FROM status_history
+        LEFT JOIN status AS from_status
+          ON status_history.from_status_id = from_status.status_id
+        LEFT JOIN status AS to_status
+          ON status_history.to_status_id = to_status.status_id`
+
+func scanStatusHistory(rows *sql.Rows) ([]StatusChange, error) {
+	var history []StatusChange
+	for rows.Next() {
+		var sc StatusChange
+		var from, to sql.NullString
+		var changedAt string
+		if err := rows.Scan(&sc.BookId, &from, &to, &changedAt); err != nil {
+			return nil, err
+		}
+		sc.From = from.String
+		sc.To = to.String
+		t, err := time.Parse(timestampFormat, changedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Problem parsing time %v: %v", changedAt, err)
+		}
+		sc.ChangedAt = t
+		history = append(history, sc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// getStatusHistoryById returns every status change for a book, oldest first.
+func getStatusHistoryById(db DBInterface, id int) ([]StatusChange, error) {
+	bookValid, err := BookIDValid(db, id)
+	if err != nil {
+		return nil, fmt.Errorf("getStatusHistoryById, could not validate book id #%v: %v",
+			id, err)
+	}
+	if !bookValid {
+		return nil, &InvalidBookIdError{"getStatusHistoryById", id}
+	}
+
+	rows, err := db.Query(statusHistorySelect+`
+        WHERE status_history.book_id = ?
+        ORDER BY status_history.changed_at, status_history.history_id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("getStatusHistoryById %d: %v", id, err)
+	}
+	defer rows.Close()
+
+	history, err := scanStatusHistory(rows)
+	if err != nil {
+		return nil, fmt.Errorf("getStatusHistoryById %d: %v", id, err)
+	}
+	return history, nil
+}
+
+// statusFirstReached returns when a book first took the given status, e.g.
+// when it was acquired if the status is "Owned". The boolean result is false
+// if the book has never had the status.
+func statusFirstReached(db DBInterface, id int, status string) (time.Time, bool, error) {
+	history, err := getStatusHistoryById(db, id)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("statusFirstReached: %w", err)
+	}
+	for _, sc := range history {
+		if sc.To == status {
+			return sc.ChangedAt, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// booksReachingStatus returns the changes which moved a book into the given
+// status at or after from and before to, oldest first. For example, books
+// given away last year.
+func booksReachingStatus(db DBInterface, status string, from time.Time, to time.Time) ([]StatusChange, error) {
+	rows, err := db.Query(statusHistorySelect+`
+        WHERE to_status.status_name = ?
+          AND status_history.changed_at >= ?
+          AND status_history.changed_at < ?
+        ORDER BY status_history.changed_at, status_history.history_id`,
+		status,
+		from.UTC().Format(timestampFormat),
+		to.UTC().Format(timestampFormat))
+	if err != nil {
+		return nil, fmt.Errorf("booksReachingStatus, %v", err)
+	}
+	defer rows.Close()
+
+	history, err := scanStatusHistory(rows)
+	if err != nil {
+		return nil, fmt.Errorf("booksReachingStatus, %v", err)
+	}
+	return history, nil
+}
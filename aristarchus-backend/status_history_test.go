@@ -0,0 +1,278 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTransitionBookStatus(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	changeTime := time.Date(2024, time.March, 2, 10, 30, 0, 0, time.UTC)
+	clock = func() time.Time { return changeTime }
+	defer func() { clock = time.Now }()
+
+	newBook := makeTestBook()
+	newBook.status = []string{"Want", "Read"}
+	id, err := addBook(db, newBook)
+	if err != nil {
+		t.Fatalf("Problem adding book to test status transitions: %v", err)
+	}
+	defer deleteBook(db, id)
+
+	for _, status := range []string{"Ordered", "Owned", "Lent"} {
+		statusList, err := transitionBookStatus(db, id, status)
+		if err != nil {
+			t.Errorf("Could not move book to status %v: %v", status, err)
+		}
+		expected := []string{status, "Read"}
+		slices.Sort(expected)
+		if !slices.Equal(statusList, expected) {
+			t.Errorf("transitionBookStatus returned unexpected value. Expected %v, got %v",
+				expected, statusList)
+		}
+	}
+
+	expectedHistory := []StatusChange{
+		{id, "", "Want", changeTime},
+		{id, "", "Read", changeTime},
+		{id, "Want", "Ordered", changeTime},
+		{id, "Ordered", "Owned", changeTime},
+		{id, "Owned", "Lent", changeTime},
+	}
+	history, err := getStatusHistoryById(db, id)
+	if err != nil {
+		t.Errorf("Could not get status history: %v", err)
+	}
+	if !slices.Equal(history, expectedHistory) {
+		t.Errorf("Unexpected status history. Expected %v, got %v",
+			expectedHistory, history)
+	}
+}
+
+func TestTransitionBookStatusNotAllowed(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []string{"Want"}
+	statusList, err := transitionBookStatus(db, 6, "Lent")
+	if err == nil {
+		t.Errorf("Disallowed transition from Want to Lent did not return error")
+	} else {
+		var transErr *StatusTransitionError
+		if !errors.As(err, &transErr) {
+			t.Errorf("Disallowed transition returned unexpected error: %v", err)
+		}
+	}
+	if !slices.Equal(statusList, expected) {
+		t.Errorf("transitionBookStatus returned unexpected value. Expected %v, got %v",
+			expected, statusList)
+	}
+
+	_, err = transitionBookStatus(db, 6, "Read")
+	if err == nil {
+		t.Errorf("Transition to non-lifecycle status did not return error")
+	}
+}
+
+// A book can't drop its lifecycle status and so skip to any other, e.g.
+// Want -> (none) -> Given away.
+func TestRemoveLifecycleStatusNotAllowed(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	origHistory, err := getStatusHistoryById(db, 6)
+	if err != nil {
+		t.Errorf("Could not get status history: %v", err)
+	}
+
+	expected := []string{"Want"}
+	statusList, err := removeBookStatus(db, 6, "Want")
+	if err == nil {
+		t.Errorf("Removing lifecycle status Want did not return error")
+	} else {
+		var transErr *StatusTransitionError
+		if !errors.As(err, &transErr) {
+			t.Errorf("Removing lifecycle status returned unexpected error: %v", err)
+		}
+	}
+	if !slices.Equal(statusList, expected) {
+		t.Errorf("removeBookStatus returned unexpected value. Expected %v, got %v",
+			expected, statusList)
+	}
+
+	_, err = updateBookStatus(db, 6, []string{"Read"})
+	if err == nil {
+		t.Errorf("Updating status to drop lifecycle status Want did not return error")
+	} else {
+		var transErr *StatusTransitionError
+		if !errors.As(err, &transErr) {
+			t.Errorf("Dropping lifecycle status returned unexpected error: %v", err)
+		}
+	}
+
+	_, err = transitionBookStatus(db, 6, "Given away")
+	if err == nil {
+		t.Errorf("Disallowed transition from Want to Given away did not return error")
+	}
+
+	statusList, err = getStatusListById(db, 6)
+	if err != nil {
+		t.Errorf("Could not get status list: %v", err)
+	}
+	if !slices.Equal(statusList, expected) {
+		t.Errorf("Book status wrongly updated in database: expected %v, got %v",
+			expected, statusList)
+	}
+	history, err := getStatusHistoryById(db, 6)
+	if err != nil {
+		t.Errorf("Could not get status history: %v", err)
+	}
+	if !slices.Equal(history, origHistory) {
+		t.Errorf("Status history wrongly updated. Expected %v, got %v", origHistory, history)
+	}
+}
+
+func TestAddBookStatusSecondLifecycleStatus(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = addBookStatus(db, 1, "Want")
+	if err == nil {
+		t.Errorf("Adding second lifecycle status did not return error")
+	} else {
+		var transErr *StatusTransitionError
+		if !errors.As(err, &transErr) {
+			t.Errorf("Adding second lifecycle status returned unexpected error: %v", err)
+		}
+	}
+}
+
+func TestStatusFirstReached(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	acquired, found, err := statusFirstReached(db, 5, "Owned")
+	if err != nil {
+		t.Errorf("statusFirstReached returned error: %v", err)
+	}
+	if !found {
+		t.Errorf("statusFirstReached did not find when book #5 was acquired")
+	}
+	if !acquired.Equal(expected) {
+		t.Errorf("statusFirstReached returned unexpected value. Expected %v, got %v",
+			expected, acquired)
+	}
+
+	_, found, err = statusFirstReached(db, 6, "Owned")
+	if err != nil {
+		t.Errorf("statusFirstReached returned error: %v", err)
+	}
+	if found {
+		t.Errorf("statusFirstReached found acquisition for book #6, which is not owned")
+	}
+}
+
+func TestBooksReachingStatus(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expected := []StatusChange{
+		{4, "", "Owned", time.Date(2021, time.July, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	changes, err := booksReachingStatus(db, "Owned", from, to)
+	if err != nil {
+		t.Errorf("booksReachingStatus returned error: %v", err)
+	}
+	if !slices.Equal(changes, expected) {
+		t.Errorf("booksReachingStatus returned unexpected value. Expected %v, got %v",
+			expected, changes)
+	}
+}
+
+func TestAddRemoveStatusTransition(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	err = addStatusTransition(db, "Lent", "Given away")
+	if err != nil {
+		t.Errorf("Could not add status transition: %v", err)
+	}
+
+	expected := []string{"Given away", "Owned"}
+	next, err := allowedNextStatuses(db, "Lent")
+	if err != nil {
+		t.Errorf("allowedNextStatuses returned error: %v", err)
+	}
+	if !slices.Equal(next, expected) {
+		t.Errorf("allowedNextStatuses returned unexpected value. Expected %v, got %v",
+			expected, next)
+	}
+
+	err = removeStatusTransition(db, "Lent", "Given away")
+	if err != nil {
+		t.Errorf("Could not remove status transition: %v", err)
+	}
+
+	expected = []string{"Owned"}
+	next, err = allowedNextStatuses(db, "Lent")
+	if err != nil {
+		t.Errorf("allowedNextStatuses returned error: %v", err)
+	}
+	if !slices.Equal(next, expected) {
+		t.Errorf("allowedNextStatuses returned unexpected value. Expected %v, got %v",
+			expected, next)
+	}
+
+	err = removeStatusTransition(db, "Lent", "Given away")
+	if err == nil {
+		t.Errorf("Removing missing transition did not return error")
+	}
+}
+
+func TestLifecycleStatuses(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	expected := []string{"Given away", "Lent", "Ordered", "Owned", "Want"}
+	lifecycle, err := lifecycleStatuses(db)
+	if err != nil {
+		t.Errorf("lifecycleStatuses returned error: %v", err)
+	}
+	if !slices.Equal(lifecycle, expected) {
+		t.Errorf("lifecycleStatuses returned unexpected value. Expected %v, got %v",
+			expected, lifecycle)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errTerminalUnsupported = errors.New("The terminal UI is only supported on Linux")
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func() error, error) {
+	return nil, errTerminalUnsupported
+}
+
+func terminalSize(fd int) (int, int, error) {
+	return 0, 0, errTerminalUnsupported
+}
+
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,815 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The terminal UI shows the list of books on the left and the details of the
+// selected book on the right. It is driven from the keyboard, with the keys
+// for the current mode shown on the bottom line. Tui holds the state and draws
+// the screen, apart from the terminal itself, so that it can be tested by
+// feeding it keys and reading back the screen.
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyEnter
+	keyEsc
+	keyBackspace
+	keyTab
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyCtrlC
+	keyCtrlU
+	keyUnknown
+)
+
+// Key is a key press read from the terminal. For keyRune, r is the character
+// typed.
+type Key struct {
+	code keyCode
+	r    rune
+}
+
+func (k Key) is(r rune) bool {
+	return k.code == keyRune && k.r == r
+}
+
+// parseKeys reads the keys from a chunk of terminal input, including the
+// escape sequences sent for the arrows and other special keys.
+func parseKeys(input []byte) []Key {
+	var keys []Key
+	for len(input) > 0 {
+		n := 1
+		switch b := input[0]; {
+		case b == 0x1b:
+			var key Key
+			key, n = parseEscape(input)
+			keys = append(keys, key)
+		case b == '\r' || b == '\n':
+			keys = append(keys, Key{code: keyEnter})
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, Key{code: keyBackspace})
+		case b == '\t':
+			keys = append(keys, Key{code: keyTab})
+		case b == 0x03:
+			keys = append(keys, Key{code: keyCtrlC})
+		case b == 0x15:
+			keys = append(keys, Key{code: keyCtrlU})
+		case b < 0x20:
+			keys = append(keys, Key{code: keyUnknown})
+		default:
+			var r rune
+			r, n = utf8.DecodeRune(input)
+			keys = append(keys, Key{keyRune, r})
+		}
+		input = input[n:]
+	}
+	return keys
+}
+
+// escapeKeys are the keys sent as CSI or SS3 sequences, by the part of the
+// sequence after "ESC [" or "ESC O".
+var escapeKeys = map[string]keyCode{
+	"A":  keyUp,
+	"B":  keyDown,
+	"C":  keyRight,
+	"D":  keyLeft,
+	"H":  keyHome,
+	"F":  keyEnd,
+	"1~": keyHome,
+	"7~": keyHome,
+	"4~": keyEnd,
+	"8~": keyEnd,
+	"5~": keyPageUp,
+	"6~": keyPageDown,
+}
+
+// parseEscape reads a key starting with ESC, giving the key and the number of
+// bytes read. ESC which doesn't start a CSI or SS3 sequence is the escape key.
+func parseEscape(input []byte) (Key, int) {
+	if len(input) < 2 || (input[1] != '[' && input[1] != 'O') {
+		return Key{code: keyEsc}, 1
+	}
+	// sequences end with a byte from @ to ~
+	for i := 2; i < len(input); i++ {
+		if input[i] >= 0x40 && input[i] <= 0x7e {
+			if code, ok := escapeKeys[string(input[2:i+1])]; ok {
+				return Key{code: code}, i + 1
+			}
+			return Key{code: keyUnknown}, i + 1
+		}
+	}
+	return Key{code: keyUnknown}, len(input)
+}
+
+type tuiMode int
+
+const (
+	modeList tuiMode = iota
+	modeSearch
+	modeDetail
+	modeEdit
+	modeConfirmDelete
+)
+
+var tuiHelp = map[tuiMode]string{
+	modeList:          "↑↓ move  / search  enter details  d delete  r reload  q quit",
+	modeSearch:        "type to search  enter done  esc clear",
+	modeDetail:        "↑↓ field  enter edit  esc back  d delete  q quit",
+	modeEdit:          "enter save  esc cancel  ctrl-u clear",
+	modeConfirmDelete: "y delete  n keep",
+}
+
+// tuiField is a field of a book shown in the detail pane. Fields are edited
+// as text, which patch turns into a change saved with applyBookPatch.
+type tuiField struct {
+	label string
+	value func(b Book) string
+	patch func(value string) (BookPatch, error)
+}
+
+func tuiStringField(label string, value func(b Book) string,
+	set func(p *BookPatch, v *string)) tuiField {
+	return tuiField{label, value, func(v string) (BookPatch, error) {
+		var p BookPatch
+		set(&p, &v)
+		return p, nil
+	}}
+}
+
+func tuiIntField(label string, value func(b Book) int,
+	set func(p *BookPatch, v *int)) tuiField {
+	return tuiField{
+		label,
+		func(b Book) string {
+			if n := value(b); n != 0 {
+				return strconv.Itoa(n)
+			}
+			return ""
+		},
+		func(v string) (BookPatch, error) {
+			var p BookPatch
+			n := 0
+			if len(v) > 0 {
+				var err error
+				if n, err = strconv.Atoi(v); err != nil {
+					return p, &BadRequestError{"tuiField",
+						fmt.Sprintf("%v must be a whole number", label)}
+				}
+			}
+			set(&p, &n)
+			return p, nil
+		},
+	}
+}
+
+func tuiFields() []tuiField {
+	fields := []tuiField{
+		tuiStringField("Title", func(b Book) string { return b.title },
+			func(p *BookPatch, v *string) { p.Title = v }),
+		tuiStringField("Subtitle", func(b Book) string { return b.subtitle },
+			func(p *BookPatch, v *string) { p.Subtitle = v }),
+	}
+	for _, role := range contributorRoles {
+		role := role
+		fields = append(fields, tuiStringField(role.label(),
+			func(b Book) string { return *b.contributors(role) },
+			func(p *BookPatch, v *string) { *p.contributors(role) = v }))
+	}
+	return append(fields,
+		tuiIntField("Year", func(b Book) int { return b.year },
+			func(p *BookPatch, v *int) { p.Year = v }),
+		tuiIntField("Edition", func(b Book) int { return b.edition.number },
+			func(p *BookPatch, v *int) { p.Edition = v }),
+		tuiStringField("Edition description",
+			func(b Book) string { return b.edition.description },
+			func(p *BookPatch, v *string) { p.EditionDescription = v }),
+		tuiStringField("Publisher", func(b Book) string { return b.publisher },
+			func(p *BookPatch, v *string) { p.Publisher = v }),
+		tuiStringField("ISBN", func(b Book) string { return b.isbn.String() },
+			func(p *BookPatch, v *string) { p.Isbn = v }),
+		tuiStringField("Series", func(b Book) string { return b.series },
+			func(p *BookPatch, v *string) { p.Series = v }),
+		tuiField{
+			"Status",
+			func(b Book) string { return strings.Join(b.status, ", ") },
+			func(v string) (BookPatch, error) {
+				statuses := splitStatuses(v)
+				return BookPatch{Status: &statuses}, nil
+			},
+		},
+		tuiStringField("Purchased", func(b Book) string { return b.purchased.String() },
+			func(p *BookPatch, v *string) { p.Purchased = v }),
+		tuiField{
+			"Rating",
+			func(b Book) string {
+				if !b.rating.rated {
+					return ""
+				}
+				return strconv.FormatFloat(b.rating.stars(), 'f', -1, 64)
+			},
+			func(v string) (BookPatch, error) {
+				if len(v) == 0 {
+					return BookPatch{ClearRating: true}, nil
+				}
+				stars, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return BookPatch{}, &BadRequestError{"tuiField",
+						"Rating must be a number of stars, e.g. 3.5"}
+				}
+				return BookPatch{Rating: &stars}, nil
+			},
+		},
+	)
+}
+
+// Tui is the state of the terminal UI.
+type Tui struct {
+	db         *sql.DB
+	fields     []tuiField
+	books      []Book
+	notes      map[int][]Note
+	search     string
+	cursor     int // index of the selected book
+	offset     int // index of the first book shown in the list
+	field      int // index of the selected field in the detail pane
+	mode       tuiMode
+	returnMode tuiMode // mode to go back to when a deletion isn't confirmed
+	input      []rune  // text typed in search and edit modes
+	orphans    DeletionOrphans
+	message    string
+	width      int
+	height     int
+	quit       bool
+}
+
+func newTui(db *sql.DB) (*Tui, error) {
+	t := &Tui{db: db, fields: tuiFields(), width: 80, height: 24}
+	if err := t.reload(); err != nil {
+		return nil, fmt.Errorf("newTui: %w", err)
+	}
+	return t, nil
+}
+
+// reload reads the books again, keeping the search and, if it is still there,
+// the selected book.
+func (t *Tui) reload() error {
+	selected := 0
+	if b, ok := t.selected(); ok {
+		selected = b.id
+	}
+
+	var books []Book
+	var err error
+	if len(strings.TrimSpace(t.search)) == 0 {
+		books, err = loadAllBooks(t.db)
+	} else {
+		var results []SearchResult
+		results, err = searchBooks(t.db, t.search)
+		for _, sr := range results {
+			books = append(books, sr.Book)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Tui.reload: %w", err)
+	}
+
+	t.books = books
+	t.notes = map[int][]Note{}
+	t.cursor = 0
+	for i, b := range books {
+		if b.id == selected {
+			t.cursor = i
+		}
+	}
+	t.clampCursor()
+	return nil
+}
+
+func (t *Tui) reloadOrReport() {
+	if err := t.reload(); err != nil {
+		t.message = fmt.Sprintf("Couldn't load books: %v", err)
+	}
+}
+
+func (t *Tui) selected() (Book, bool) {
+	if t.cursor < 0 || t.cursor >= len(t.books) {
+		return Book{}, false
+	}
+	return t.books[t.cursor], true
+}
+
+// paneHeight is the number of rows between the header and the bottom line.
+func (t *Tui) paneHeight() int {
+	return max(t.height-2, 1)
+}
+
+func (t *Tui) moveCursor(delta int) {
+	t.cursor += delta
+	t.clampCursor()
+}
+
+// clampCursor keeps the selection within the list, and scrolls the list so
+// that the selection can be seen.
+func (t *Tui) clampCursor() {
+	t.cursor = max(min(t.cursor, len(t.books)-1), 0)
+	h := t.paneHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+h {
+		t.offset = t.cursor - h + 1
+	}
+	t.offset = max(min(t.offset, len(t.books)-h), 0)
+}
+
+func (t *Tui) resize(width, height int) {
+	t.width, t.height = width, height
+	t.clampCursor()
+}
+
+func (t *Tui) handleKey(k Key) {
+	if k.code == keyCtrlC {
+		t.quit = true
+		return
+	}
+	t.message = ""
+	switch t.mode {
+	case modeList:
+		t.listKey(k)
+	case modeSearch:
+		t.searchKey(k)
+	case modeDetail:
+		t.detailKey(k)
+	case modeEdit:
+		t.editKey(k)
+	case modeConfirmDelete:
+		t.confirmKey(k)
+	}
+}
+
+// moveKey handles the keys for moving through the list, which work in both
+// list and search modes, reporting whether k was one of them.
+func (t *Tui) moveKey(k Key) bool {
+	switch k.code {
+	case keyUp:
+		t.moveCursor(-1)
+	case keyDown:
+		t.moveCursor(1)
+	case keyPageUp:
+		t.moveCursor(-t.paneHeight())
+	case keyPageDown:
+		t.moveCursor(t.paneHeight())
+	case keyHome:
+		t.moveCursor(-len(t.books))
+	case keyEnd:
+		t.moveCursor(len(t.books))
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *Tui) listKey(k Key) {
+	if t.moveKey(k) {
+		return
+	}
+	switch {
+	case k.is('k'):
+		t.moveCursor(-1)
+	case k.is('j'):
+		t.moveCursor(1)
+	case k.is('g'):
+		t.moveCursor(-len(t.books))
+	case k.is('G'):
+		t.moveCursor(len(t.books))
+	case k.code == keyEnter, k.code == keyTab, k.code == keyRight, k.is('l'):
+		if _, ok := t.selected(); ok {
+			t.mode = modeDetail
+		}
+	case k.is('/'):
+		t.mode = modeSearch
+		t.input = []rune(t.search)
+	case k.is('d'):
+		t.confirmDelete()
+	case k.is('r'):
+		t.reloadOrReport()
+	case k.is('q'):
+		t.quit = true
+	}
+}
+
+// setSearch shows the books matching a search, best matches first, selecting
+// the best.
+func (t *Tui) setSearch(search string) {
+	t.search = search
+	t.cursor, t.offset = 0, 0
+	if err := t.reload(); err != nil {
+		t.message = fmt.Sprintf("Couldn't search: %v", err)
+		return
+	}
+	t.cursor, t.offset = 0, 0
+}
+
+func (t *Tui) searchKey(k Key) {
+	if t.moveKey(k) {
+		return
+	}
+	switch k.code {
+	case keyRune:
+		t.input = append(t.input, k.r)
+	case keyBackspace:
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case keyCtrlU:
+		t.input = nil
+	case keyEnter:
+		t.mode = modeList
+		return
+	case keyEsc:
+		t.input = nil
+		t.mode = modeList
+	default:
+		return
+	}
+	t.setSearch(string(t.input))
+}
+
+func (t *Tui) detailKey(k Key) {
+	switch {
+	case k.code == keyUp, k.is('k'):
+		t.field = max(t.field-1, 0)
+	case k.code == keyDown, k.is('j'):
+		t.field = min(t.field+1, len(t.fields)-1)
+	case k.code == keyEnter, k.is('e'):
+		if b, ok := t.selected(); ok {
+			t.input = []rune(t.fields[t.field].value(b))
+			t.mode = modeEdit
+		}
+	case k.code == keyEsc, k.code == keyTab, k.code == keyLeft, k.is('h'):
+		t.mode = modeList
+	case k.is('d'):
+		t.confirmDelete()
+	case k.is('r'):
+		t.reloadOrReport()
+	case k.is('q'):
+		t.quit = true
+	}
+}
+
+func (t *Tui) editKey(k Key) {
+	switch k.code {
+	case keyRune:
+		t.input = append(t.input, k.r)
+	case keyBackspace:
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case keyCtrlU:
+		t.input = nil
+	case keyEnter:
+		t.saveField()
+	case keyEsc:
+		t.mode = modeDetail
+		t.message = "Edit cancelled"
+	}
+}
+
+// saveField saves the text typed for the selected field. If it can't be saved
+// the editor stays open, so that the text can be corrected.
+func (t *Tui) saveField() {
+	b, ok := t.selected()
+	if !ok {
+		t.mode = modeList
+		return
+	}
+	f := t.fields[t.field]
+	p, err := f.patch(strings.TrimSpace(string(t.input)))
+	if err == nil {
+		err = applyBookPatch(t.db, b.id, b, p)
+	}
+	if err != nil {
+		t.message = fmt.Sprintf("Couldn't update %v: %v", f.label, err)
+		return
+	}
+
+	t.mode = modeDetail
+	updated, err := getBookById(t.db, b.id)
+	if err != nil {
+		t.message = fmt.Sprintf("Couldn't reload book #%d: %v", b.id, err)
+		return
+	}
+	t.books[t.cursor] = updated
+	t.message = fmt.Sprintf("Updated %v of book #%d", f.label, b.id)
+}
+
+// confirmDelete asks for the selected book's deletion to be confirmed, showing
+// what else will be removed with it.
+func (t *Tui) confirmDelete() {
+	b, ok := t.selected()
+	if !ok {
+		return
+	}
+	orphans, err := deletionOrphans(t.db, b.id)
+	if err != nil {
+		t.message = fmt.Sprintf("Couldn't check book #%d: %v", b.id, err)
+		return
+	}
+	t.orphans = orphans
+	t.returnMode = t.mode
+	t.mode = modeConfirmDelete
+}
+
+func (t *Tui) confirmKey(k Key) {
+	b, _ := t.selected()
+	switch {
+	case k.is('y'), k.is('Y'):
+		t.mode = modeList
+		if err := deleteBook(t.db, b.id); err != nil {
+			t.message = fmt.Sprintf("Couldn't delete book #%d: %v", b.id, err)
+			return
+		}
+		t.reloadOrReport()
+		if len(t.message) == 0 {
+			t.message = fmt.Sprintf("Deleted book #%d", b.id)
+		}
+	case k.is('n'), k.is('N'), k.is('q'), k.code == keyEsc:
+		t.mode = t.returnMode
+		t.message = fmt.Sprintf("Kept book #%d", b.id)
+	}
+}
+
+func (t *Tui) notesFor(id int) []Note {
+	if notes, ok := t.notes[id]; ok {
+		return notes
+	}
+	notes, err := getNotesByBookId(t.db, id)
+	if err != nil {
+		t.message = fmt.Sprintf("Couldn't load notes: %v", err)
+	}
+	t.notes[id] = notes
+	return notes
+}
+
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+)
+
+// fit truncates or pads s to width columns, taking each rune as a column.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+func (t *Tui) listWidth() int {
+	return min(max(t.width*2/5, 24), t.width)
+}
+
+func (t *Tui) listRows() []string {
+	w := t.listWidth()
+	rows := make([]string, t.paneHeight())
+	for i := range rows {
+		idx := t.offset + i
+		if idx >= len(t.books) {
+			rows[i] = fit("", w)
+			continue
+		}
+		b := t.books[idx]
+		text := fmt.Sprintf("%4d  %v", b.id, b.title)
+		if names := b.authorEditor(); len(names) > 0 {
+			text += " — " + names
+		}
+		row := fit(text, w)
+		if idx == t.cursor {
+			style := styleReverse
+			if t.mode == modeDetail || t.mode == modeEdit {
+				style = styleBold
+			}
+			row = style + row + styleReset
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func (t *Tui) detailRows() []string {
+	w := t.width - t.listWidth() - 1
+	rows := make([]string, t.paneHeight())
+	for i := range rows {
+		rows[i] = fit("", w)
+	}
+	b, ok := t.selected()
+	if !ok || w <= 0 {
+		return rows
+	}
+
+	labelWidth := 0
+	for _, f := range t.fields {
+		labelWidth = max(labelWidth, utf8.RuneCountInString(f.label)+2)
+	}
+
+	lines := []string{styleBold + fit(fmt.Sprintf(" Book #%d", b.id), w) + styleReset}
+	for i, f := range t.fields {
+		label := " " + fit(f.label+":", labelWidth)
+		selected := i == t.field && (t.mode == modeDetail || t.mode == modeEdit)
+		switch {
+		case selected && t.mode == modeEdit:
+			// show the end of the text being typed, followed by a cursor
+			room := max(w-utf8.RuneCountInString(label)-1, 0)
+			input := t.input
+			if len(input) > room {
+				input = input[len(input)-room:]
+			}
+			text := label + string(input)
+			lines = append(lines, text+styleReverse+" "+styleReset+
+				strings.Repeat(" ", max(w-utf8.RuneCountInString(text)-1, 0)))
+		case selected:
+			lines = append(lines, styleReverse+fit(label+f.value(b), w)+styleReset)
+		default:
+			lines = append(lines, fit(label+f.value(b), w))
+		}
+	}
+
+	if notes := t.notesFor(b.id); len(notes) > 0 {
+		lines = append(lines, fit("", w), fit(" Notes:", w))
+		for _, n := range notes {
+			lines = append(lines, fit("   "+n.String(), w))
+		}
+	}
+
+	for i := range rows {
+		if i < len(lines) {
+			rows[i] = lines[i]
+		}
+	}
+	return rows
+}
+
+// dialogRows gives the rows of the box confirming a deletion.
+func (t *Tui) dialogRows() []string {
+	b, _ := t.selected()
+	text := []string{fmt.Sprintf("Delete book #%d?", b.id), b.title, ""}
+	if t.orphans.isEmpty() {
+		text = append(text, "Nothing else will be removed.")
+	} else {
+		text = append(text, "This will also remove:")
+		for _, p := range t.orphans.people {
+			text = append(text, "  Person: "+p)
+		}
+		if len(t.orphans.publisher) > 0 {
+			text = append(text, "  Publisher: "+t.orphans.publisher)
+		}
+		if len(t.orphans.series) > 0 {
+			text = append(text, "  Series: "+t.orphans.series)
+		}
+	}
+	text = append(text, "", "y: delete   n: keep")
+
+	inner := 0
+	for _, line := range text {
+		inner = max(inner, utf8.RuneCountInString(line))
+	}
+	inner = max(min(inner, t.width-4), 0)
+
+	rows := []string{"┌" + strings.Repeat("─", inner+2) + "┐"}
+	for _, line := range text {
+		rows = append(rows, "│ "+fit(line, inner)+" │")
+	}
+	return append(rows, "└"+strings.Repeat("─", inner+2)+"┘")
+}
+
+// lines gives the rows of the screen, each of them width columns wide.
+func (t *Tui) lines() []string {
+	header := fmt.Sprintf(" Aristarchus  %d books", len(t.books))
+	switch {
+	case t.mode == modeSearch:
+		header += "  search: " + string(t.input) + "▏"
+	case len(t.search) > 0:
+		header += "  search: " + t.search
+	}
+	lines := []string{styleReverse + fit(header, t.width) + styleReset}
+
+	listRows := t.listRows()
+	detailRows := t.detailRows()
+	for i := range listRows {
+		lines = append(lines, listRows[i]+styleDim+"│"+styleReset+detailRows[i])
+	}
+
+	if t.mode == modeConfirmDelete {
+		dialog := t.dialogRows()
+		top := max((t.height-len(dialog))/2, 1)
+		for i, row := range dialog {
+			if top+i >= len(lines) {
+				break
+			}
+			left := max((t.width-utf8.RuneCountInString(row))/2, 0)
+			lines[top+i] = fit(strings.Repeat(" ", left)+row, t.width)
+		}
+	}
+
+	if len(t.message) > 0 {
+		lines = append(lines, styleBold+fit(" "+t.message, t.width)+styleReset)
+	} else {
+		lines = append(lines, styleDim+fit(" "+tuiHelp[t.mode], t.width)+styleReset)
+	}
+	return lines[:min(len(lines), t.height)]
+}
+
+// draw writes the screen to the terminal.
+func (t *Tui) draw(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("\x1b[H")
+	for i, line := range t.lines() {
+		if i > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(line)
+		sb.WriteString("\x1b[K")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// runTui runs the terminal UI on the terminal given by in and out, until it is
+// quit.
+func runTui(db *sql.DB, in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !isTerminal(fd) {
+		return fmt.Errorf("runTui: Input is not a terminal")
+	}
+	t, err := newTui(db)
+	if err != nil {
+		return fmt.Errorf("runTui: %w", err)
+	}
+
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("runTui, Couldn't set up terminal: %v", err)
+	}
+	defer restore()
+	// use the alternate screen with the cursor hidden, restoring the normal
+	// screen on the way out
+	io.WriteString(out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+	defer io.WriteString(out, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan []Key)
+	readErr := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			keys <- parseKeys(buf[:n])
+		}
+	}()
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+	for !t.quit {
+		if width, height, err := terminalSize(fd); err == nil && width > 0 && height > 0 {
+			t.resize(width, height)
+		}
+		if err := t.draw(out); err != nil {
+			return fmt.Errorf("runTui, Couldn't draw screen: %v", err)
+		}
+		select {
+		case ks := <-keys:
+			for _, k := range ks {
+				t.handleKey(k)
+			}
+		case <-resized:
+		case err := <-readErr:
+			return fmt.Errorf("runTui, Couldn't read input: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// pressKeys feeds a Tui the keys sent by a terminal for the given input.
+func pressKeys(tui *Tui, input string) {
+	for _, k := range parseKeys([]byte(input)) {
+		tui.handleKey(k)
+	}
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;?]*[A-Za-z]")
+
+// screenText gives the rows of a Tui's screen without styling.
+func screenText(tui *Tui) []string {
+	var rows []string
+	for _, line := range tui.lines() {
+		rows = append(rows, ansiEscape.ReplaceAllString(line, ""))
+	}
+	return rows
+}
+
+func bookIds(books []Book) []int {
+	var ids []int
+	for _, b := range books {
+		ids = append(ids, b.id)
+	}
+	return ids
+}
+
+func TestParseKeys(t *testing.T) {
+	input := "a\x1b[A\x1b[B\x1bOC\x1b[D\x1b[5~\x1b[6~\x1b[H\x1b[4~\r\x7f\t\x03\x15é\x1b\x1b[1;5A\x1b"
+	expected := []Key{
+		{keyRune, 'a'}, {code: keyUp}, {code: keyDown}, {code: keyRight},
+		{code: keyLeft}, {code: keyPageUp}, {code: keyPageDown}, {code: keyHome},
+		{code: keyEnd}, {code: keyEnter}, {code: keyBackspace}, {code: keyTab},
+		{code: keyCtrlC}, {code: keyCtrlU}, {keyRune, 'é'}, {code: keyEsc},
+		{code: keyUnknown}, {code: keyEsc},
+	}
+	keys := parseKeys([]byte(input))
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("parseKeys(%q): expected %v, got %v", input, expected, keys)
+	}
+}
+
+func TestTuiNavigateAndSearch(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tui, err := newTui(db)
+	if err != nil {
+		t.Fatalf("Problem starting TUI: %v", err)
+	}
+	tui.resize(100, 5)
+	if !reflect.DeepEqual(bookIds(tui.books), []int{1, 2, 3, 4, 5, 6}) {
+		t.Fatalf("Expected all books listed, got %v", bookIds(tui.books))
+	}
+
+	pressKeys(tui, "jjj\x1b[B")
+	if b, _ := tui.selected(); b.id != 5 || tui.offset != 2 {
+		t.Errorf("Expected book #5 selected and list scrolled to 2, got #%v at %v",
+			b.id, tui.offset)
+	}
+	pressKeys(tui, "G")
+	if b, _ := tui.selected(); b.id != 6 {
+		t.Errorf("Expected last book selected, got #%v", b.id)
+	}
+	pressKeys(tui, "\x1b[H")
+	if b, _ := tui.selected(); b.id != 1 || tui.offset != 0 {
+		t.Errorf("Expected first book selected, got #%v at %v", b.id, tui.offset)
+	}
+
+	pressKeys(tui, "/covenant")
+	if tui.mode != modeSearch || !reflect.DeepEqual(bookIds(tui.books), []int{5}) {
+		t.Errorf("Search for covenant: expected book [5], got %v", bookIds(tui.books))
+	}
+	pressKeys(tui, "\x15Gentry")
+	if !reflect.DeepEqual(bookIds(tui.books), []int{4, 5}) &&
+		!reflect.DeepEqual(bookIds(tui.books), []int{5, 4}) {
+		t.Errorf("Search for Gentry: expected books 4 and 5, got %v", bookIds(tui.books))
+	}
+	pressKeys(tui, "\r")
+	if tui.mode != modeList || tui.search != "Gentry" {
+		t.Errorf("Enter should keep search and return to list, got mode %v search %q",
+			tui.mode, tui.search)
+	}
+	if !strings.Contains(screenText(tui)[0], "search: Gentry") {
+		t.Errorf("Header doesn't show search: %q", screenText(tui)[0])
+	}
+
+	pressKeys(tui, "/\x1b")
+	if tui.search != "" || len(tui.books) != 6 {
+		t.Errorf("Esc should clear search, got %q with %v books", tui.search,
+			len(tui.books))
+	}
+
+	pressKeys(tui, "q")
+	if !tui.quit {
+		t.Errorf("q didn't quit")
+	}
+}
+
+func TestTuiEditField(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tui, err := newTui(db)
+	if err != nil {
+		t.Fatalf("Problem starting TUI: %v", err)
+	}
+	tui.resize(120, 30)
+
+	ratingField := len(tui.fields) - 1
+	// select book #5, open its details, and go to the rating
+	pressKeys(tui, "jjjj\r")
+	for i := 0; i < ratingField; i++ {
+		pressKeys(tui, "j")
+	}
+	if tui.mode != modeDetail || tui.fields[tui.field].label != "Rating" {
+		t.Fatalf("Expected rating selected in details, got mode %v field %v",
+			tui.mode, tui.field)
+	}
+
+	pressKeys(tui, "\r")
+	if tui.mode != modeEdit || string(tui.input) != "4.5" {
+		t.Fatalf("Expected to edit rating 4.5, got mode %v input %q", tui.mode,
+			string(tui.input))
+	}
+	pressKeys(tui, "\x7f\x7f\x7f4.2\r")
+	if tui.mode != modeEdit || !strings.Contains(tui.message, "Couldn't update Rating") {
+		t.Errorf("Invalid rating should keep editor open with error, got mode %v, %q",
+			tui.mode, tui.message)
+	}
+	pressKeys(tui, "\x153\r")
+	if tui.mode != modeDetail {
+		t.Errorf("Expected details after saving, got mode %v: %v", tui.mode, tui.message)
+	}
+	b, err := getBookById(db, 5)
+	if err != nil {
+		t.Fatalf("Problem getting book #5: %v", err)
+	}
+	if b.rating.stars() != 3 {
+		t.Errorf("Rating not saved: expected 3, got %v", b.rating)
+	}
+	if sel, _ := tui.selected(); sel.rating.stars() != 3 {
+		t.Errorf("Listed book not updated: rating %v", sel.rating)
+	}
+	screen := strings.Join(screenText(tui), "\n")
+	if !strings.Contains(screen, "Rating:              3") {
+		t.Errorf("Details don't show new rating:\n%v", screen)
+	}
+
+	// title is the first field
+	pressKeys(tui, "\x1b[H")
+	for i := 0; i < ratingField; i++ {
+		pressKeys(tui, "k")
+	}
+	pressKeys(tui, "e\x1bx")
+	if tui.mode != modeDetail || tui.message != "" {
+		t.Errorf("Esc should cancel edit, got mode %v %q", tui.mode, tui.message)
+	}
+
+	// author is the third field
+	pressKeys(tui, "\x1b[B\x1b[B\r\x15Stephen J. Wellum and Peter J. Gentry\r")
+	b, _ = getBookById(db, 5)
+	if b.author != "Stephen J. Wellum and Peter J. Gentry" {
+		t.Errorf("Author edit not saved, got %q", b.author)
+	}
+
+	// revert changes
+	pressKeys(tui, "\r\x15Peter J. Gentry and Stephen J. Wellum\r")
+	for i := 2; i < ratingField; i++ {
+		pressKeys(tui, "j")
+	}
+	pressKeys(tui, "\r\x154.5\r")
+	b, _ = getBookById(db, 5)
+	if b.author != "Peter J. Gentry and Stephen J. Wellum" || b.rating.stars() != 4.5 {
+		t.Errorf("Couldn't revert book #5, got %v, rating %v", b.author, b.rating)
+	}
+}
+
+func TestTuiDelete(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	id, err := addBook(db, makeTestBook())
+	if err != nil {
+		t.Fatalf("Problem adding test book: %v", err)
+	}
+	defer deleteBook(db, id)
+
+	tui, err := newTui(db)
+	if err != nil {
+		t.Fatalf("Problem starting TUI: %v", err)
+	}
+	tui.resize(100, 24)
+
+	pressKeys(tui, "Gd")
+	if tui.mode != modeConfirmDelete {
+		t.Fatalf("Expected deletion to be confirmed, got mode %v", tui.mode)
+	}
+	screen := strings.Join(screenText(tui), "\n")
+	for _, text := range []string{"Delete book #", "Person: Karen H. Jobes",
+		"Person: Moisés Silva", "Publisher: Baker Academic", "y: delete"} {
+		if !strings.Contains(screen, text) {
+			t.Errorf("Confirmation missing %q:\n%v", text, screen)
+		}
+	}
+
+	pressKeys(tui, "n")
+	if tui.mode != modeList {
+		t.Errorf("Expected list after keeping book, got mode %v", tui.mode)
+	}
+	if valid, _ := BookIDValid(db, id); !valid {
+		t.Fatalf("Book deleted without confirmation")
+	}
+
+	pressKeys(tui, "\rd\x1b")
+	if tui.mode != modeDetail {
+		t.Errorf("Expected details after keeping book, got mode %v", tui.mode)
+	}
+
+	pressKeys(tui, "dy")
+	if valid, _ := BookIDValid(db, id); valid {
+		t.Errorf("Book not deleted after confirmation")
+	}
+	if tui.mode != modeList || len(tui.books) != 6 || tui.message != "Deleted book #"+
+		strconv.Itoa(id) {
+		t.Errorf("Unexpected state after deletion: mode %v, %v books, %q", tui.mode,
+			len(tui.books), tui.message)
+	}
+}
+
+func TestTuiScreen(t *testing.T) {
+	db, err := sql.Open(testDriver, testDatabase)
+	if err != nil {
+		t.Errorf("Problem opening database: %v", err)
+	}
+	defer db.Close()
+
+	tui, err := newTui(db)
+	if err != nil {
+		t.Fatalf("Problem starting TUI: %v", err)
+	}
+	tui.resize(100, 30)
+	pressKeys(tui, "jjjj")
+
+	screen := screenText(tui)
+	if len(screen) != 30 {
+		t.Fatalf("Expected 30 rows, got %v", len(screen))
+	}
+	for i, row := range screen {
+		if n := utf8.RuneCountInString(row); n != 100 {
+			t.Errorf("Row %v is %v columns wide, expected 100: %q", i, n, row)
+		}
+	}
+	if !strings.HasPrefix(screen[0], " Aristarchus  6 books") {
+		t.Errorf("Unexpected header: %q", screen[0])
+	}
+	if !strings.HasPrefix(screen[5], "   5  Kingdom through Covenant — Peter") {
+		t.Errorf("Unexpected list row for book #5: %q", screen[5])
+	}
+	text := strings.Join(screen, "\n")
+	for _, detail := range []string{"Book #5", "Subtitle:            A Biblical-Theological",
+		"Status:              Owned, Read", "Notes:", "(xiv) Argues"} {
+		if !strings.Contains(text, detail) {
+			t.Errorf("Details missing %q:\n%v", detail, text)
+		}
+	}
+	if !strings.Contains(screen[29], "/ search") {
+		t.Errorf("Bottom line doesn't show keys: %q", screen[29])
+	}
+}
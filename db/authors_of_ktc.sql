@@ -1,7 +1,9 @@
 SELECT people.name
 FROM people
-INNER JOIN book_author
-  ON book_author.author_id = people.person_id
+INNER JOIN book_contributor
+  ON book_contributor.person_id = people.person_id
 INNER JOIN books
-  ON book_author.book_id = books.book_id
-WHERE books.title = "Kingdom through Covenant";
+  ON book_contributor.book_id = books.book_id
+WHERE books.title = "Kingdom through Covenant"
+  AND book_contributor.role = 'author'
+ORDER BY book_contributor.position;
@@ -1,7 +1,7 @@
-SELECT author_id, name, title
+SELECT book_contributor.person_id, name, role, title
 FROM books
-INNER JOIN book_author
-  ON books.book_id = book_author.book_id
+INNER JOIN book_contributor
+  ON books.book_id = book_contributor.book_id
 INNER JOIN people
-  ON book_author.author_id = people.person_id
-WHERE author_id = 3;
+  ON book_contributor.person_id = people.person_id
+WHERE book_contributor.person_id = 3;
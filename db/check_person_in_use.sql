@@ -1,10 +1,3 @@
-SELECT COUNT(*)
-FROM (
-SELECT book_id
-FROM book_author
-WHERE author_id = 1
-UNION
-SELECT book_id
-FROM book_editor
-WHERE editor_id = 1
-);
+SELECT COUNT(DISTINCT book_id)
+FROM book_contributor
+WHERE person_id = 1;
@@ -7,7 +7,8 @@ CREATE TABLE people (
 DROP TABLE IF EXISTS publishers;
 CREATE TABLE publishers (
        publisher_id INTEGER PRIMARY KEY,
-       name TEXT
+       name TEXT,
+       location TEXT
 );
 
 DROP TABLE IF EXISTS series;
@@ -23,11 +24,13 @@ CREATE TABLE books (
        subtitle TEXT,
        year INTEGER,
        edition INTEGER,
+       edition_description TEXT,
        publisher_id INTEGER,
        isbn TEXT,
        series_id INTEGER,
-       status TEXT NOT NULL,
        purchased_date TEXT,
+       rating REAL CHECK (rating >= 0 AND rating <= 5),
+       citation_key TEXT,
        FOREIGN KEY (publisher_id)
          REFERENCES publishers (publisher_id)
            ON DELETE RESTRICT
@@ -38,36 +41,256 @@ CREATE TABLE books (
            ON UPDATE CASCADE
 );
 
-DROP TABLE IF EXISTS book_author;
-CREATE TABLE book_author (
+CREATE UNIQUE INDEX books_citation_key ON books (citation_key);
+
+DROP TABLE IF EXISTS book_contributor;
+CREATE TABLE book_contributor (
        book_id INTEGER,
-       author_id INTEGER,
-       PRIMARY KEY (book_id, author_id),
+       person_id INTEGER,
+       role TEXT NOT NULL
+         CHECK (role IN ('author', 'editor', 'translator', 'illustrator',
+                         'foreword', 'compiler')),
+       position INTEGER NOT NULL,
+       PRIMARY KEY (book_id, person_id, role),
        FOREIGN KEY (book_id)
          REFERENCES books (book_id)
            ON DELETE CASCADE
            ON UPDATE CASCADE,
-       FOREIGN KEY (author_id)
+       FOREIGN KEY (person_id)
          REFERENCES people (person_id)
            ON DELETE RESTRICT
            ON UPDATE CASCADE
 );
 
-DROP TABLE IF EXISTS book_editor;
-CREATE TABLE book_editor (
+DROP TABLE IF EXISTS status;
+CREATE TABLE status (
+       status_id INTEGER PRIMARY KEY,
+       status_name TEXT NOT NULL UNIQUE
+);
+
+DROP TABLE IF EXISTS book_status;
+CREATE TABLE book_status (
        book_id INTEGER,
-       editor_id INTEGER,
-       PRIMARY KEY (book_id, editor_id),
+       status_id INTEGER,
+       PRIMARY KEY (book_id, status_id),
        FOREIGN KEY (book_id)
          REFERENCES books (book_id)
            ON DELETE CASCADE
            ON UPDATE CASCADE,
-       FOREIGN KEY (editor_id)
-         REFERENCES people (person_id)
+       FOREIGN KEY (status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS status_transition;
+CREATE TABLE status_transition (
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       PRIMARY KEY (from_status_id, to_status_id),
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS status_history;
+CREATE TABLE status_history (
+       history_id INTEGER PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       changed_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
            ON DELETE RESTRICT
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS book_note;
+CREATE TABLE book_note (
+       note_id INTEGER PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       page TEXT,
+       note TEXT NOT NULL,
+       created_at TEXT NOT NULL,
+       updated_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS category;
+CREATE TABLE category (
+       category_id INTEGER PRIMARY KEY,
+       name TEXT NOT NULL,
+       parent_id INTEGER,
+       FOREIGN KEY (parent_id)
+         REFERENCES category (category_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS book_category;
+CREATE TABLE book_category (
+       book_id INTEGER,
+       category_id INTEGER,
+       PRIMARY KEY (book_id, category_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (category_id)
+         REFERENCES category (category_id)
+           ON DELETE CASCADE
            ON UPDATE CASCADE
 );
 
+-- Full-text search index, one row per book with rowid = book_id. It is kept in
+-- sync with the tables it draws on by the triggers below.
+DROP VIEW IF EXISTS book_search_source;
+CREATE VIEW book_search_source AS
+SELECT books.book_id,
+       books.title,
+       COALESCE(books.subtitle, '') AS subtitle,
+       COALESCE((SELECT group_concat(name, ' ')
+                 FROM (SELECT people.name
+                       FROM book_contributor
+                       INNER JOIN people
+                         ON book_contributor.person_id = people.person_id
+                       WHERE book_contributor.book_id = books.book_id
+                       ORDER BY CASE book_contributor.role
+                                  WHEN 'author' THEN 1
+                                  WHEN 'editor' THEN 2
+                                  WHEN 'translator' THEN 3
+                                  WHEN 'illustrator' THEN 4
+                                  WHEN 'foreword' THEN 5
+                                  ELSE 6
+                                END,
+                                book_contributor.position)), '') AS people,
+       COALESCE(publishers.name, '') AS publisher,
+       COALESCE(series.series_name, '') AS series,
+       COALESCE((SELECT group_concat(COALESCE(book_note.page, '') || ' ' || book_note.note, ' ')
+                 FROM book_note
+                 WHERE book_note.book_id = books.book_id), '') AS notes
+FROM books
+LEFT JOIN publishers
+  ON books.publisher_id = publishers.publisher_id
+LEFT JOIN series
+  ON books.series_id = series.series_id;
+
+DROP TABLE IF EXISTS book_search;
+CREATE VIRTUAL TABLE book_search USING fts5(
+       title,
+       subtitle,
+       people,
+       publisher,
+       series,
+       notes,
+       tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER book_search_books_insert AFTER INSERT ON books BEGIN
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_books_update AFTER UPDATE ON books BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_books_delete AFTER DELETE ON books BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_insert AFTER INSERT ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_delete AFTER DELETE ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_update AFTER UPDATE ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid IN (OLD.book_id, NEW.book_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (OLD.book_id, NEW.book_id);
+END;
+
+CREATE TRIGGER book_search_note_insert AFTER INSERT ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_note_update AFTER UPDATE ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid IN (OLD.book_id, NEW.book_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (OLD.book_id, NEW.book_id);
+END;
+
+CREATE TRIGGER book_search_note_delete AFTER DELETE ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_people_update AFTER UPDATE OF name ON people BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM book_contributor WHERE person_id = NEW.person_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM book_contributor WHERE person_id = NEW.person_id);
+END;
+
+CREATE TRIGGER book_search_publishers_update AFTER UPDATE OF name ON publishers BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM books WHERE publisher_id = NEW.publisher_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM books WHERE publisher_id = NEW.publisher_id);
+END;
+
+CREATE TRIGGER book_search_series_update AFTER UPDATE OF series_name ON series BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM books WHERE series_id = NEW.series_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM books WHERE series_id = NEW.series_id);
+END;
+
+-- The schema above is that given by the numbered migrations in
+-- aristarchus-backend/migrations, up to the version recorded here.
+DROP TABLE IF EXISTS schema_version;
+CREATE TABLE schema_version (
+       version INTEGER PRIMARY KEY,
+       name TEXT NOT NULL,
+       applied_at TEXT NOT NULL
+);
+
+INSERT INTO schema_version (version, name, applied_at)
+VALUES
+  (11, "citation_keys", datetime('now'));
+
 INSERT INTO people (name)
 VALUES
   ("R. K. Harrison"),
@@ -82,42 +305,108 @@ VALUES
   ("James Eglinton"),
   ("Cory C. Brock");
 
-INSERT INTO publishers (name)
+INSERT INTO publishers (name, location)
 VALUES
-  ("IVP"),
-  ("Hackett"),
-  ("Crossway");
+  ("IVP", "Downers Grove, IL"),
+  ("Hackett", "Indianapolis"),
+  ("Crossway", "Wheaton, IL");
 
 INSERT INTO series (series_name)
 VALUES
   ("Spectrum Multiview Books");
 
+-- The catalogue (populate_books.sql) records book 4's ISBN as printed in the
+-- library's records, 978-1-4335-5403-8, which has the wrong check digit, and
+-- is reported by aristarchus until it is checked against the book and
+-- corrected. Here it is given with the check digit recomputed, 9781433554032,
+-- so that the test books can be exported and imported again, and
+-- checkStoredIsbns is tested against the uncorrected value separately.
 INSERT INTO books (title, subtitle, year, edition, publisher_id, isbn,
-series_id, status, purchased_date)
+series_id, purchased_date, rating)
+VALUES
+  ("Introduction to the Old Testament", NULL, 1969,  NULL, 1, "9780851117232", NULL, "May 2023", NULL),
+  ("Divine Impassibility", "Four Views of God's Emotions and Suffering", 2019,  NULL, 1, "9780830852536", 1, "October 2019", NULL),
+  ("Basic Writings", NULL, 2007, NULL, 2, "9780872208957", NULL, "October 2015", 4),
+  ("How to Read and Understand the Biblical Prophets", NULL, 2017, NULL, 3, "9781433554032", NULL, "July 2021", NULL),
+  ("Kingdom through Covenant", "A Biblical-Theological Understanding of the Covenants", 2018, 2, 3, "9781433553073", NULL, "January 2022", 4.5),
+  ("Christianity and Science", NULL, 2023, NULL, 3, "9781433579202", NULL, NULL, NULL);
+
+INSERT INTO book_contributor (book_id, person_id, role, position)
+VALUES
+  (1, 1, 'author', 1),
+  (2, 6, 'editor', 1),
+  (2, 7, 'editor', 2),
+  (3, 2, 'author', 1),
+  (3, 8, 'translator', 1),
+  (4, 3, 'author', 1),
+  (5, 3, 'author', 1),
+  (5, 4, 'author', 2),
+  (6, 5, 'author', 1),
+  (6, 9, 'editor', 1),
+  (6, 10, 'editor', 2),
+  (6, 11, 'editor', 3);
+
+INSERT INTO status (status_name)
+VALUES
+  ("Owned"),
+  ("Want"),
+  ("Read"),
+  ("Ordered"),
+  ("Lent"),
+  ("Given away");
+
+INSERT INTO status_transition (from_status_id, to_status_id)
 VALUES
-  ("Introduction to the Old Testament", NULL, 1969,  NULL, 1, "0-85111-723-6", NULL, "Owned", "May 2023"),
-  ("Divine Impassibility", "Four Views of God's Emotions and Suffering", 2019,  NULL, 1, "978-0-8308-5253-6", 1, "Owned", "October 2019"),
-  ("Basic Writings", NULL, 2007, NULL, 2, "978-0-87220-895-7", NULL, "Owned", "October 2015"),
-  ("How to Read and Understand the Biblical Prophets", NULL, 2017, NULL, 3, "978-1-4335-5403-8", NULL, "Owned", "July 2021"),
-  ("Kingdom through Covenant", "A Biblical-Theological Understanding of the Covenants", 2018, 2, 3, "978-1-4335-5307-3", NULL, "Owned", "January 2022"),
-  ("Christianity and Science", NULL, 2023, NULL, 3, "978-1-4335-7920-2", NULL, "Want", NULL);
-
-INSERT INTO book_author (book_id, author_id)
+  (2, 4),
+  (2, 1),
+  (4, 1),
+  (4, 2),
+  (1, 5),
+  (5, 1),
+  (1, 6);
+
+INSERT INTO book_status (book_id, status_id)
 VALUES
   (1, 1),
-  (3, 2),
-  (4, 3),
+  (2, 1),
+  (3, 1),
+  (4, 1),
+  (5, 1),
   (5, 3),
-  (5, 4),
-  (6, 5);
+  (6, 2);
 
-INSERT INTO book_editor (book_id, editor_id)
+INSERT INTO status_history (book_id, from_status_id, to_status_id, changed_at)
 VALUES
-  (2, 6),
-  (2, 7),
-  (3, 8),
-  (6, 9),
-  (6, 10),
-  (6, 11);
+  (1, NULL, 1, "2023-05-01 00:00:00"),
+  (2, NULL, 1, "2019-10-01 00:00:00"),
+  (3, NULL, 1, "2015-10-01 00:00:00"),
+  (4, NULL, 1, "2021-07-01 00:00:00"),
+  (5, NULL, 1, "2022-01-01 00:00:00"),
+  (5, NULL, 3, "2022-06-01 00:00:00"),
+  (6, NULL, 2, "2023-09-01 00:00:00");
+
+INSERT INTO book_note (book_id, page, note, created_at, updated_at)
+VALUES
+  (5, "xiv", "Argues for progressive covenantalism as a via media between dispensationalism and covenant theology.", "2022-02-03 19:30:00", "2022-02-03 19:30:00"),
+  (5, NULL, "Worth re-reading the chapter on the new covenant alongside Hebrews.", "2022-06-01 21:15:00", "2022-06-01 21:15:00");
+
+INSERT INTO category (name, parent_id)
+VALUES
+  ("Theology", NULL),
+  ("Systematic Theology", 1),
+  ("Doctrine of God", 2),
+  ("Biblical Theology", 1),
+  ("Old Testament", 4),
+  ("Philosophy", NULL);
+
+INSERT INTO book_category (book_id, category_id)
+VALUES
+  (1, 5),
+  (2, 3),
+  (3, 3),
+  (3, 6),
+  (4, 5),
+  (5, 4),
+  (6, 2);
 
 .quit
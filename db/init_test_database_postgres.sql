@@ -0,0 +1,123 @@
+-- Test data for running the tests against PostgreSQL, as in
+-- init_test_database.sql. The schema is created by the migrations before this
+-- is run, so the default statuses they add are cleared first, for the test
+-- statuses to have the same ids as in SQLite.
+TRUNCATE status, status_transition RESTART IDENTITY CASCADE;
+
+INSERT INTO people (name)
+VALUES
+  ('R. K. Harrison'),
+  ('Anselm'),
+  ('Peter J. Gentry'),
+  ('Stephen J. Wellum'),
+  ('Herman Bavinck'),
+  ('Robert J. Matz'),
+  ('A. Chadwick Thornhill'),
+  ('Thomas Williams'),
+  ('N. Gray Sutanto'),
+  ('James Eglinton'),
+  ('Cory C. Brock');
+
+INSERT INTO publishers (name, location)
+VALUES
+  ('IVP', 'Downers Grove, IL'),
+  ('Hackett', 'Indianapolis'),
+  ('Crossway', 'Wheaton, IL');
+
+INSERT INTO series (series_name)
+VALUES
+  ('Spectrum Multiview Books');
+
+-- The catalogue (populate_books.sql) records book 4's ISBN as printed in the
+-- library's records, 978-1-4335-5403-8, which has the wrong check digit, and
+-- is reported by aristarchus until it is checked against the book and
+-- corrected. Here it is given with the check digit recomputed, 9781433554032,
+-- so that the test books can be exported and imported again, and
+-- checkStoredIsbns is tested against the uncorrected value separately.
+INSERT INTO books (title, subtitle, year, edition, publisher_id, isbn,
+series_id, purchased_date, rating)
+VALUES
+  ('Introduction to the Old Testament', NULL, 1969,  NULL, 1, '9780851117232', NULL, 'May 2023', NULL),
+  ('Divine Impassibility', 'Four Views of God''s Emotions and Suffering', 2019,  NULL, 1, '9780830852536', 1, 'October 2019', NULL),
+  ('Basic Writings', NULL, 2007, NULL, 2, '9780872208957', NULL, 'October 2015', 4),
+  ('How to Read and Understand the Biblical Prophets', NULL, 2017, NULL, 3, '9781433554032', NULL, 'July 2021', NULL),
+  ('Kingdom through Covenant', 'A Biblical-Theological Understanding of the Covenants', 2018, 2, 3, '9781433553073', NULL, 'January 2022', 4.5),
+  ('Christianity and Science', NULL, 2023, NULL, 3, '9781433579202', NULL, NULL, NULL);
+
+INSERT INTO book_contributor (book_id, person_id, role, position)
+VALUES
+  (1, 1, 'author', 1),
+  (2, 6, 'editor', 1),
+  (2, 7, 'editor', 2),
+  (3, 2, 'author', 1),
+  (3, 8, 'translator', 1),
+  (4, 3, 'author', 1),
+  (5, 3, 'author', 1),
+  (5, 4, 'author', 2),
+  (6, 5, 'author', 1),
+  (6, 9, 'editor', 1),
+  (6, 10, 'editor', 2),
+  (6, 11, 'editor', 3);
+
+INSERT INTO status (status_name)
+VALUES
+  ('Owned'),
+  ('Want'),
+  ('Read'),
+  ('Ordered'),
+  ('Lent'),
+  ('Given away');
+
+INSERT INTO status_transition (from_status_id, to_status_id)
+VALUES
+  (2, 4),
+  (2, 1),
+  (4, 1),
+  (4, 2),
+  (1, 5),
+  (5, 1),
+  (1, 6);
+
+INSERT INTO book_status (book_id, status_id)
+VALUES
+  (1, 1),
+  (2, 1),
+  (3, 1),
+  (4, 1),
+  (5, 1),
+  (5, 3),
+  (6, 2);
+
+INSERT INTO status_history (book_id, from_status_id, to_status_id, changed_at)
+VALUES
+  (1, NULL, 1, '2023-05-01 00:00:00'),
+  (2, NULL, 1, '2019-10-01 00:00:00'),
+  (3, NULL, 1, '2015-10-01 00:00:00'),
+  (4, NULL, 1, '2021-07-01 00:00:00'),
+  (5, NULL, 1, '2022-01-01 00:00:00'),
+  (5, NULL, 3, '2022-06-01 00:00:00'),
+  (6, NULL, 2, '2023-09-01 00:00:00');
+
+INSERT INTO book_note (book_id, page, note, created_at, updated_at)
+VALUES
+  (5, 'xiv', 'Argues for progressive covenantalism as a via media between dispensationalism and covenant theology.', '2022-02-03 19:30:00', '2022-02-03 19:30:00'),
+  (5, NULL, 'Worth re-reading the chapter on the new covenant alongside Hebrews.', '2022-06-01 21:15:00', '2022-06-01 21:15:00');
+
+INSERT INTO category (name, parent_id)
+VALUES
+  ('Theology', NULL),
+  ('Systematic Theology', 1),
+  ('Doctrine of God', 2),
+  ('Biblical Theology', 1),
+  ('Old Testament', 4),
+  ('Philosophy', NULL);
+
+INSERT INTO book_category (book_id, category_id)
+VALUES
+  (1, 5),
+  (2, 3),
+  (3, 3),
+  (3, 6),
+  (4, 5),
+  (5, 4),
+  (6, 2);
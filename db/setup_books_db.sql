@@ -7,7 +7,8 @@ CREATE TABLE people (
 DROP TABLE IF EXISTS publishers;
 CREATE TABLE publishers (
        publisher_id INTEGER PRIMARY KEY,
-       name TEXT
+       name TEXT,
+       location TEXT
 );
 
 DROP TABLE IF EXISTS series;
@@ -23,11 +24,13 @@ CREATE TABLE books (
        subtitle TEXT,
        year INTEGER,
        edition INTEGER,
+       edition_description TEXT,
        publisher_id INTEGER,
        isbn TEXT,
        series_id INTEGER,
-       status TEXT NOT NULL,
        purchased_date TEXT,
+       rating REAL CHECK (rating >= 0 AND rating <= 5),
+       citation_key TEXT,
        FOREIGN KEY (publisher_id)
          REFERENCES publishers (publisher_id)
            ON DELETE RESTRICT
@@ -38,32 +41,252 @@ CREATE TABLE books (
            ON UPDATE CASCADE
 );
 
-DROP TABLE IF EXISTS book_author;
-CREATE TABLE book_author (
+CREATE UNIQUE INDEX books_citation_key ON books (citation_key);
+
+DROP TABLE IF EXISTS book_contributor;
+CREATE TABLE book_contributor (
        book_id INTEGER,
-       author_id INTEGER,
-       PRIMARY KEY (book_id, author_id),
+       person_id INTEGER,
+       role TEXT NOT NULL
+         CHECK (role IN ('author', 'editor', 'translator', 'illustrator',
+                         'foreword', 'compiler')),
+       position INTEGER NOT NULL,
+       PRIMARY KEY (book_id, person_id, role),
        FOREIGN KEY (book_id)
          REFERENCES books (book_id)
            ON DELETE CASCADE
            ON UPDATE CASCADE,
-       FOREIGN KEY (author_id)
+       FOREIGN KEY (person_id)
          REFERENCES people (person_id)
            ON DELETE RESTRICT
            ON UPDATE CASCADE
 );
 
-DROP TABLE IF EXISTS book_editor;
-CREATE TABLE book_editor (
+DROP TABLE IF EXISTS status;
+CREATE TABLE status (
+       status_id INTEGER PRIMARY KEY,
+       status_name TEXT NOT NULL UNIQUE
+);
+
+DROP TABLE IF EXISTS book_status;
+CREATE TABLE book_status (
        book_id INTEGER,
-       editor_id INTEGER,
-       PRIMARY KEY (book_id, editor_id),
+       status_id INTEGER,
+       PRIMARY KEY (book_id, status_id),
        FOREIGN KEY (book_id)
          REFERENCES books (book_id)
            ON DELETE CASCADE
            ON UPDATE CASCADE,
-       FOREIGN KEY (editor_id)
-         REFERENCES people (person_id)
+       FOREIGN KEY (status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS status_transition;
+CREATE TABLE status_transition (
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       PRIMARY KEY (from_status_id, to_status_id),
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS status_history;
+CREATE TABLE status_history (
+       history_id INTEGER PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       from_status_id INTEGER,
+       to_status_id INTEGER,
+       changed_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (from_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE,
+       FOREIGN KEY (to_status_id)
+         REFERENCES status (status_id)
+           ON DELETE RESTRICT
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS book_note;
+CREATE TABLE book_note (
+       note_id INTEGER PRIMARY KEY,
+       book_id INTEGER NOT NULL,
+       page TEXT,
+       note TEXT NOT NULL,
+       created_at TEXT NOT NULL,
+       updated_at TEXT NOT NULL,
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+DROP TABLE IF EXISTS category;
+CREATE TABLE category (
+       category_id INTEGER PRIMARY KEY,
+       name TEXT NOT NULL,
+       parent_id INTEGER,
+       FOREIGN KEY (parent_id)
+         REFERENCES category (category_id)
            ON DELETE RESTRICT
            ON UPDATE CASCADE
 );
+
+DROP TABLE IF EXISTS book_category;
+CREATE TABLE book_category (
+       book_id INTEGER,
+       category_id INTEGER,
+       PRIMARY KEY (book_id, category_id),
+       FOREIGN KEY (book_id)
+         REFERENCES books (book_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE,
+       FOREIGN KEY (category_id)
+         REFERENCES category (category_id)
+           ON DELETE CASCADE
+           ON UPDATE CASCADE
+);
+
+-- Full-text search index, one row per book with rowid = book_id. It is kept in
+-- sync with the tables it draws on by the triggers below.
+DROP VIEW IF EXISTS book_search_source;
+CREATE VIEW book_search_source AS
+SELECT books.book_id,
+       books.title,
+       COALESCE(books.subtitle, '') AS subtitle,
+       COALESCE((SELECT group_concat(name, ' ')
+                 FROM (SELECT people.name
+                       FROM book_contributor
+                       INNER JOIN people
+                         ON book_contributor.person_id = people.person_id
+                       WHERE book_contributor.book_id = books.book_id
+                       ORDER BY CASE book_contributor.role
+                                  WHEN 'author' THEN 1
+                                  WHEN 'editor' THEN 2
+                                  WHEN 'translator' THEN 3
+                                  WHEN 'illustrator' THEN 4
+                                  WHEN 'foreword' THEN 5
+                                  ELSE 6
+                                END,
+                                book_contributor.position)), '') AS people,
+       COALESCE(publishers.name, '') AS publisher,
+       COALESCE(series.series_name, '') AS series,
+       COALESCE((SELECT group_concat(COALESCE(book_note.page, '') || ' ' || book_note.note, ' ')
+                 FROM book_note
+                 WHERE book_note.book_id = books.book_id), '') AS notes
+FROM books
+LEFT JOIN publishers
+  ON books.publisher_id = publishers.publisher_id
+LEFT JOIN series
+  ON books.series_id = series.series_id;
+
+DROP TABLE IF EXISTS book_search;
+CREATE VIRTUAL TABLE book_search USING fts5(
+       title,
+       subtitle,
+       people,
+       publisher,
+       series,
+       notes,
+       tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER book_search_books_insert AFTER INSERT ON books BEGIN
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_books_update AFTER UPDATE ON books BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_books_delete AFTER DELETE ON books BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_insert AFTER INSERT ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_delete AFTER DELETE ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_contributor_update AFTER UPDATE ON book_contributor BEGIN
+  DELETE FROM book_search WHERE rowid IN (OLD.book_id, NEW.book_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (OLD.book_id, NEW.book_id);
+END;
+
+CREATE TRIGGER book_search_note_insert AFTER INSERT ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid = NEW.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = NEW.book_id;
+END;
+
+CREATE TRIGGER book_search_note_update AFTER UPDATE ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid IN (OLD.book_id, NEW.book_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (OLD.book_id, NEW.book_id);
+END;
+
+CREATE TRIGGER book_search_note_delete AFTER DELETE ON book_note BEGIN
+  DELETE FROM book_search WHERE rowid = OLD.book_id;
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id = OLD.book_id;
+END;
+
+CREATE TRIGGER book_search_people_update AFTER UPDATE OF name ON people BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM book_contributor WHERE person_id = NEW.person_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM book_contributor WHERE person_id = NEW.person_id);
+END;
+
+CREATE TRIGGER book_search_publishers_update AFTER UPDATE OF name ON publishers BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM books WHERE publisher_id = NEW.publisher_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM books WHERE publisher_id = NEW.publisher_id);
+END;
+
+CREATE TRIGGER book_search_series_update AFTER UPDATE OF series_name ON series BEGIN
+  DELETE FROM book_search WHERE rowid IN (
+    SELECT book_id FROM books WHERE series_id = NEW.series_id);
+  INSERT INTO book_search (rowid, title, subtitle, people, publisher, series, notes)
+    SELECT * FROM book_search_source WHERE book_id IN (
+      SELECT book_id FROM books WHERE series_id = NEW.series_id);
+END;
+
+-- The schema above is that given by the numbered migrations in
+-- aristarchus-backend/migrations, up to the version recorded here.
+DROP TABLE IF EXISTS schema_version;
+CREATE TABLE schema_version (
+       version INTEGER PRIMARY KEY,
+       name TEXT NOT NULL,
+       applied_at TEXT NOT NULL
+);
+
+INSERT INTO schema_version (version, name, applied_at)
+VALUES
+  (11, "citation_keys", datetime('now'));
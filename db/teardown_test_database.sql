@@ -1,12 +1,27 @@
-DELETE FROM book_author;
-DELETE FROM book_editor;
+DELETE FROM book_category;
+DELETE FROM category;
+DELETE FROM book_note;
+DELETE FROM status_history;
+DELETE FROM status_transition;
+DELETE FROM book_status;
+DELETE FROM status;
+DELETE FROM book_contributor;
 DELETE FROM series;
 DELETE FROM books;
 DELETE FROM pubishers;
 DELETE FROM people;
 
-DROP TABLE IF EXISTS book_author;
-DROP TABLE IF EXISTS book_editor;
+DROP TABLE IF EXISTS schema_version;
+DROP TABLE IF EXISTS book_category;
+DROP TABLE IF EXISTS category;
+DROP TABLE IF EXISTS book_search;
+DROP VIEW IF EXISTS book_search_source;
+DROP TABLE IF EXISTS book_note;
+DROP TABLE IF EXISTS status_history;
+DROP TABLE IF EXISTS status_transition;
+DROP TABLE IF EXISTS book_status;
+DROP TABLE IF EXISTS status;
+DROP TABLE IF EXISTS book_contributor;
 DROP TABLE IF EXISTS series;
 DROP TABLE IF EXISTS books;
 DROP TABLE IF EXISTS publishers;